@@ -0,0 +1,196 @@
+package api
+
+// auth.go - API keys on the way in: who is calling, whether they are over
+// their rate or quota, and an audit line per request saying which key
+// touched which project. All of it is off until SetKeyFile or SetAuditLog.
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/survey-validator/auth"
+	"github.com/survey-validator/wire"
+)
+
+// APIKeyHeader - where a key can go instead of "Authorization: Bearer"
+const APIKeyHeader = "X-API-Key"
+
+// SetKeyFile - require an API key on every API request, from the key file
+// at path. A missing or empty file gets one admin key, whose secret is
+// returned so it can be shown once; "" otherwise.
+func (s *Server) SetKeyFile(path string) (string, error) {
+	keys, err := auth.LoadKeys(path)
+	if err != nil {
+		return "", err
+	}
+	s.keys, s.limiter = keys, auth.NewLimiter()
+	if keys.Len() > 0 {
+		return "", nil
+	}
+	_, secret, err := keys.Create(auth.Key{Name: "admin", Admin: true})
+	return secret, err
+}
+
+// SetAuditLog - append a line per API request to the file at path
+func (s *Server) SetAuditLog(path string) error {
+	a, err := auth.OpenAuditLog(path)
+	if err != nil {
+		return err
+	}
+	s.audit = a
+	return nil
+}
+
+// requestInfo - what the audit line needs that only handlers know, filled
+// in as the request goes
+type requestInfo struct {
+	key     *auth.Key
+	project string
+}
+
+func infoFrom(ctx context.Context) *requestInfo {
+	info, _ := ctx.Value(requestInfoKey).(*requestInfo)
+	return info
+}
+
+// APIKey - the key a request was made with, nil when auth is off
+func APIKey(ctx context.Context) *auth.Key {
+	if info := infoFrom(ctx); info != nil {
+		return info.key
+	}
+	return nil
+}
+
+// noteProject - say which project the request is about, for the audit log
+func noteProject(r *http.Request, project string) {
+	if info := infoFrom(r.Context()); info != nil && project != "" {
+		info.project = project
+	}
+}
+
+// authenticate - no API request without a good key when there are keys,
+// no more of them than the key's rate and quota allow, and an audit line
+// for each one when there is an audit log. /health and the OpenAPI
+// document stay open.
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if (s.keys == nil && s.audit == nil) || !strings.HasPrefix(r.URL.Path, "/api/") || r.URL.Path == "/api/v1/openapi.json" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		info := &requestInfo{}
+		r = r.WithContext(context.WithValue(r.Context(), requestInfoKey, info))
+		sw := &statusWriter{ResponseWriter: w}
+		defer s.record(r, sw, info)
+
+		if s.keys == nil {
+			next.ServeHTTP(sw, r)
+			return
+		}
+		key, ok := s.keys.Authenticate(auth.FromRequest(r.Header.Get("Authorization"), r.Header.Get(APIKeyHeader)))
+		if !ok {
+			sw.Header().Set("WWW-Authenticate", `Bearer realm="survey-validator"`)
+			s.respondError(sw, http.StatusUnauthorized, "A valid API key is needed: send Authorization: Bearer <key> or "+APIKeyHeader)
+			return
+		}
+		info.key = key
+
+		d := s.limiter.Allow(key)
+		if d.Limit > 0 {
+			sw.Header().Set("X-RateLimit-Limit", strconv.Itoa(d.Limit))
+			sw.Header().Set("X-RateLimit-Remaining", strconv.Itoa(d.Remaining))
+		}
+		if d.QuotaLeft >= 0 {
+			sw.Header().Set("X-Quota-Remaining", strconv.Itoa(d.QuotaLeft))
+		}
+		if !d.Allowed {
+			retry := int(math.Ceil(d.RetryAfter.Seconds()))
+			sw.Header().Set("Retry-After", strconv.Itoa(retry))
+			msg := fmt.Sprintf("Key %s is over its limit of %d requests a minute, try again in %ds", key.ID, key.RateLimit, retry)
+			if d.Reason == auth.ReasonQuota {
+				msg = fmt.Sprintf("Key %s has used its %d requests for today", key.ID, key.DailyQuota)
+			}
+			s.respondAPIError(sw, &APIError{Status: http.StatusTooManyRequests, Code: d.Reason, Message: msg})
+			return
+		}
+		next.ServeHTTP(sw, r)
+	})
+}
+
+// record - the request's audit line, if there is an audit log
+func (s *Server) record(r *http.Request, sw *statusWriter, info *requestInfo) {
+	if s.audit == nil || r.Method == http.MethodOptions {
+		return
+	}
+	e := auth.AuditEntry{
+		Time:      time.Now().UTC(),
+		RequestID: RequestID(r.Context()),
+		Method:    r.Method,
+		Path:      r.URL.Path,
+		ProjectID: info.project,
+		Status:    sw.status,
+		Remote:    r.RemoteAddr,
+	}
+	if info.key != nil {
+		e.KeyID, e.KeyName = info.key.ID, info.key.Name
+	}
+	if err := s.audit.Record(e); err != nil {
+		requestLogger(r).Error("Audit log", "error", err)
+	}
+}
+
+// handleKeys - list, create and revoke API keys, for admin keys only.
+// The secret of a new key is in the answer to its POST and nowhere else.
+func (s *Server) handleKeys(w http.ResponseWriter, r *http.Request) {
+	if s.keys == nil {
+		s.respondError(w, http.StatusServiceUnavailable, "API keys are off, start the server with -keys")
+		return
+	}
+	if key := APIKey(r.Context()); key == nil || !key.Admin {
+		s.respondError(w, http.StatusForbidden, "Managing keys needs an admin key")
+		return
+	}
+
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/keys"), "/")
+	switch {
+	case id == "" && r.Method == http.MethodGet:
+		s.respondJSON(w, http.StatusOK, wire.KeysResponse{Keys: wireKeys(s.keys.List())})
+	case id == "" && r.Method == http.MethodPost:
+		defer r.Body.Close()
+		var req wire.KeyRequest
+		if !s.decodeJSON(w, r, &req) {
+			return
+		}
+		if req.RateLimit < 0 || req.DailyQuota < 0 {
+			s.respondError(w, http.StatusBadRequest, "rate_limit and daily_quota can't be negative")
+			return
+		}
+		key, secret, err := s.keys.Create(auth.Key{Name: req.Name, Admin: req.Admin, RateLimit: req.RateLimit, DailyQuota: req.DailyQuota})
+		if err != nil {
+			s.respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		requestLogger(r).Info("Key created", "key_id", key.ID, "name", key.Name, "by", APIKey(r.Context()).ID)
+		s.respondJSON(w, http.StatusCreated, wire.CreatedKeyResponse{Key: wire.Key(*key), Secret: secret})
+	case id != "" && !strings.Contains(id, "/") && r.Method == http.MethodDelete:
+		if err := s.keys.Delete(id); err == auth.ErrNotFound {
+			s.respondError(w, http.StatusNotFound, "No key "+id)
+			return
+		} else if err != nil {
+			s.respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		s.limiter.Forget(id)
+		requestLogger(r).Info("Key revoked", "key_id", id, "by", APIKey(r.Context()).ID)
+		w.WriteHeader(http.StatusNoContent)
+	case id == "":
+		s.respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	default:
+		s.respondError(w, http.StatusMethodNotAllowed, "Method not allowed. Use DELETE.")
+	}
+}
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/survey-validator/wire"
+)
+
+// callAs - call with key as a bearer token, none when ""
+func callAs(t *testing.T, h http.Handler, key, method, target string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	r := newRequest(t, method, target, body)
+	if key != "" {
+		r.Header.Set("Authorization", "Bearer "+key)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	return rec
+}
+
+func TestKeys(t *testing.T) {
+	dir := t.TempDir()
+	s := NewServer("")
+	admin, err := s.SetKeyFile(filepath.Join(dir, "keys.json"))
+	if err != nil || admin == "" {
+		t.Fatalf("Expected an admin key to be made, got %q %v", admin, err)
+	}
+	if err := s.SetAuditLog(filepath.Join(dir, "audit.log")); err != nil {
+		t.Fatal(err)
+	}
+	h := s.Handler()
+
+	rec := callAs(t, h, "", http.MethodGet, "/api/v1/checks", nil)
+	if rec.Code != http.StatusUnauthorized || errorCode(t, rec) != CodeUnauthorized || rec.Header().Get("WWW-Authenticate") == "" {
+		t.Fatalf("Expected a 401 without a key, got %d %s", rec.Code, rec.Body)
+	}
+	if rec := callAs(t, h, "wrong", http.MethodGet, "/api/v1/checks", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected a 401 for an unknown key, got %d", rec.Code)
+	}
+	for _, path := range []string{"/health", "/api/v1/openapi.json", "/"} {
+		if rec := callAs(t, h, "", http.MethodGet, path, nil); rec.Code != http.StatusOK {
+			t.Errorf("Expected %s to stay open, got %d", path, rec.Code)
+		}
+	}
+	// the key can go in X-API-Key instead
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/checks", nil)
+	r.Header.Set(APIKeyHeader, admin)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected the key from %s to be taken, got %d", APIKeyHeader, rec.Code)
+	}
+
+	rec = callAs(t, h, admin, http.MethodPost, "/api/v1/keys", wire.KeyRequest{Name: "field crew", RateLimit: 2})
+	var created wire.CreatedKeyResponse
+	decode(t, rec, &created)
+	if rec.Code != http.StatusCreated || created.Secret == "" || created.ID == "" {
+		t.Fatalf("Expected a new key with its secret, got %d %s", rec.Code, rec.Body)
+	}
+	if rec := callAs(t, h, admin, http.MethodPost, "/api/v1/keys", wire.KeyRequest{Name: "x", DailyQuota: -1}); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a negative quota, got %d", rec.Code)
+	}
+
+	crew := created.Secret
+	rec = callAs(t, h, crew, http.MethodPost, "/api/v1/validate", wire.ValidateBody{SurveyData: testData()})
+	if rec.Code != http.StatusOK || rec.Header().Get("X-RateLimit-Limit") != "2" || rec.Header().Get("X-RateLimit-Remaining") != "1" {
+		t.Fatalf("Expected the first of 2 requests, got %d %s", rec.Code, rec.Header())
+	}
+	if rec := callAs(t, h, crew, http.MethodGet, "/api/v1/keys", nil); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected a 403 listing keys without admin, got %d", rec.Code)
+	}
+	rec = callAs(t, h, crew, http.MethodGet, "/api/v1/checks", nil)
+	if rec.Code != http.StatusTooManyRequests || errorCode(t, rec) != CodeRateLimited || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected the third request a minute to be rate limited, got %d %s", rec.Code, rec.Header())
+	}
+
+	var keys wire.KeysResponse
+	decode(t, callAs(t, h, admin, http.MethodGet, "/api/v1/keys", nil), &keys)
+	if len(keys.Keys) != 2 {
+		t.Fatalf("Expected 2 keys, got %+v", keys.Keys)
+	}
+	if strings.Contains(fmt.Sprint(keys), crew) {
+		t.Error("Expected no secrets in the key list")
+	}
+	if rec := callAs(t, h, admin, http.MethodDelete, "/api/v1/keys/"+created.ID, nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected 204 revoking, got %d %s", rec.Code, rec.Body)
+	}
+	if rec := callAs(t, h, admin, http.MethodDelete, "/api/v1/keys/"+created.ID, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 revoking again, got %d", rec.Code)
+	}
+	if rec := callAs(t, h, crew, http.MethodGet, "/api/v1/checks", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected a revoked key to get 401, got %d", rec.Code)
+	}
+
+	audit, err := os.ReadFile(filepath.Join(dir, "audit.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := fmt.Sprintf(`"key_id":%q,"key_name":"field crew","method":"POST","path":"/api/v1/validate","project_id":"API-1","status":200`, created.ID)
+	if !strings.Contains(string(audit), want) {
+		t.Errorf("Expected %s in the audit log:\n%s", want, audit)
+	}
+}
+
+func TestKeys_Quota(t *testing.T) {
+	s := NewServer("")
+	admin, err := s.SetKeyFile(filepath.Join(t.TempDir(), "keys.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := s.Handler()
+	var created wire.CreatedKeyResponse
+	decode(t, callAs(t, h, admin, http.MethodPost, "/api/v1/keys", wire.KeyRequest{Name: "trial", DailyQuota: 2}), &created)
+
+	for i, left := range []string{"1", "0"} {
+		rec := callAs(t, h, created.Secret, http.MethodGet, "/api/v1/checks", nil)
+		if rec.Code != http.StatusOK || rec.Header().Get("X-Quota-Remaining") != left {
+			t.Errorf("Request %d: expected %s left today, got %d %q", i+1, left, rec.Code, rec.Header().Get("X-Quota-Remaining"))
+		}
+	}
+	rec := callAs(t, h, created.Secret, http.MethodGet, "/api/v1/checks", nil)
+	if rec.Code != http.StatusTooManyRequests || errorCode(t, rec) != CodeQuotaExceeded {
+		t.Errorf("Expected the quota to be used up, got %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestKeys_Off(t *testing.T) {
+	h := NewServer("").Handler()
+	if rec := call(t, h, http.MethodGet, "/api/v1/checks", nil); rec.Code != http.StatusOK {
+		t.Errorf("Expected no key needed without a key file, got %d", rec.Code)
+	}
+	if rec := call(t, h, http.MethodGet, "/api/v1/keys", nil); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 managing keys without a key file, got %d", rec.Code)
+	}
+}
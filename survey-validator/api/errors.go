@@ -0,0 +1,312 @@
+package api
+
+// errors.go - structured error bodies: a code for the kind of failure and,
+// for bad input, one detail per problem saying where it is (JSON pointer,
+// point row, CSV line, byte offset) and what was there, so a UI can
+// highlight the field and a script can branch on the code
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/survey-validator/formats"
+	"github.com/survey-validator/ingest"
+	"github.com/survey-validator/openapi"
+	"github.com/survey-validator/wire"
+)
+
+// wire.ErrorResponse.Code, one per kind of failure
+const (
+	CodeBadRequest       = wire.CodeBadRequest
+	CodeMalformedJSON    = wire.CodeMalformedJSON
+	CodeInvalidRequest   = wire.CodeInvalidRequest
+	CodeInvalidUpload    = wire.CodeInvalidUpload
+	CodeInvalidOptions   = wire.CodeInvalidOptions
+	CodeUnauthorized     = wire.CodeUnauthorized
+	CodeForbidden        = wire.CodeForbidden
+	CodeNotFound         = wire.CodeNotFound
+	CodeMethodNotAllowed = wire.CodeMethodNotAllowed
+	CodeNotAcceptable    = wire.CodeNotAcceptable
+	CodeConflict         = wire.CodeConflict
+	CodePayloadTooLarge  = wire.CodePayloadTooLarge
+	CodeRateLimited      = wire.CodeRateLimited
+	CodeQuotaExceeded    = wire.CodeQuotaExceeded
+	CodeUnprocessable    = wire.CodeUnprocessable
+	CodeInternal         = wire.CodeInternal
+	CodeUnavailable      = wire.CodeUnavailable
+)
+
+// wire.ErrorDetail.Code, past the schema's own (openapi.CodeRequired and co)
+const (
+	CodeSyntax    = wire.CodeSyntax
+	CodeNotFinite = wire.CodeNotFinite
+	CodeBadNumber = wire.CodeBadNumber
+)
+
+// APIError - an error with everything an error body needs
+type APIError struct {
+	Status  int
+	Code    string
+	Message string
+	Details []wire.ErrorDetail
+}
+
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+// respondAPIError - the error's body, with the request ID
+func (s *Server) respondAPIError(w http.ResponseWriter, e *APIError) {
+	s.respondJSON(w, e.Status, wire.ErrorResponse{
+		Error:     e.Message,
+		Code:      e.Code,
+		Details:   e.Details,
+		RequestID: w.Header().Get(wire.RequestIDHeader),
+	})
+}
+
+// statusCodes - the code respondError gives each status
+var statusCodes = map[int]string{
+	http.StatusBadRequest:            CodeBadRequest,
+	http.StatusUnauthorized:          CodeUnauthorized,
+	http.StatusForbidden:             CodeForbidden,
+	http.StatusNotFound:              CodeNotFound,
+	http.StatusMethodNotAllowed:      CodeMethodNotAllowed,
+	http.StatusNotAcceptable:         CodeNotAcceptable,
+	http.StatusConflict:              CodeConflict,
+	http.StatusRequestEntityTooLarge: CodePayloadTooLarge,
+	http.StatusUnprocessableEntity:   CodeUnprocessable,
+	http.StatusTooManyRequests:       CodeRateLimited,
+	http.StatusServiceUnavailable:    CodeUnavailable,
+}
+
+func codeFor(status int) string {
+	if code, ok := statusCodes[status]; ok {
+		return code
+	}
+	if status >= 500 {
+		return CodeInternal
+	}
+	return CodeBadRequest
+}
+
+// optionsError - a 400 for engine.ErrInvalidOptions
+func optionsError(err error) *APIError {
+	return &APIError{Status: http.StatusBadRequest, Code: CodeInvalidOptions, Message: err.Error()}
+}
+
+// parseJSON - raw into v, checked against v's schema in the OpenAPI
+// document first so every bad field is reported at once
+func parseJSON(raw []byte, v interface{}) *APIError {
+	var body interface{}
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return jsonError(raw, err)
+	}
+	if err := spec.Validate(v, body); err != nil {
+		var fields openapi.Errors
+		errors.As(err, &fields)
+		e := &APIError{Status: http.StatusBadRequest, Code: CodeInvalidRequest, Message: "Invalid request: " + err.Error()}
+		for _, f := range fields {
+			e.Details = append(e.Details, wire.ErrorDetail{Code: f.Code, Message: f.Message, Pointer: f.Pointer, Row: pointRow(f.Pointer), Value: f.Value})
+		}
+		return e
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return jsonError(raw, err)
+	}
+	return nil
+}
+
+// jsonError - what encoding/json said about raw, placed in it
+func jsonError(raw []byte, err error) *APIError {
+	e := &APIError{Status: http.StatusBadRequest, Code: CodeMalformedJSON, Message: "Invalid JSON: " + err.Error()}
+	var syntax *json.SyntaxError
+	var typ *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntax):
+		at := syntax.Offset - 1 // the offset is past the byte it stopped at
+		d := detailAt(raw, at, CodeSyntax, err.Error())
+		if lit := ingest.LiteralAt(raw, at); lit != "" {
+			// NaN and Infinity aren't JSON, but are what some encoders write
+			d.Code, d.Value, d.Message = CodeNotFinite, lit, lit+" isn't a number JSON can carry"
+		}
+		e.Details = []wire.ErrorDetail{d}
+	case errors.As(err, &typ):
+		// the offset is somewhere past the value; a number can be found
+		// by its text, anything else is the last value read
+		at := typ.Offset
+		if at > int64(len(raw)) {
+			at = int64(len(raw))
+		}
+		n, isNumber := strings.CutPrefix(typ.Value, "number ")
+		if i := bytes.LastIndex(raw[:at], []byte(n)); isNumber && i >= 0 {
+			at = int64(i)
+		}
+		d := detailAt(raw, at, openapi.CodeWrongType, err.Error())
+		if !isNumber {
+			_, d.Pointer = pointerAt(raw, typ.Offset)
+			d.Row = pointRow(d.Pointer)
+		} else if typ.Type.Kind() == reflect.Float64 || typ.Type.Kind() == reflect.Float32 {
+			d.Code, d.Value, d.Message = CodeNotFinite, n, n+" is too big to be a coordinate"
+		}
+		e.Code = CodeInvalidRequest
+		e.Details = []wire.ErrorDetail{d}
+	}
+	return e
+}
+
+// detailAt - a detail for the byte of raw at offset, with its line, column
+// and the pointer of the value being read there
+func detailAt(raw []byte, offset int64, code, message string) wire.ErrorDetail {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > int64(len(raw)) {
+		offset = int64(len(raw))
+	}
+	line, column := position(raw, offset)
+	pointer, _ := pointerAt(raw, offset)
+	return wire.ErrorDetail{Code: code, Message: message, Pointer: pointer, Row: pointRow(pointer), Line: line, Column: column, Offset: offset}
+}
+
+// position - line and column, from 1, of the byte at offset
+func position(raw []byte, offset int64) (line, column int) {
+	before := raw[:offset]
+	line = bytes.Count(before, []byte("\n")) + 1
+	column = int(offset) - bytes.LastIndexByte(before, '\n')
+	return line, column
+}
+
+// pointerAt - where in the JSON document offset falls: the pointer of the
+// value being read there, and of the last complete value before it. The
+// prefix is tokenised until it runs out or stops parsing.
+func pointerAt(raw []byte, offset int64) (current, last string) {
+	type frame struct {
+		array   bool
+		index   int
+		key     string
+		haveKey bool
+	}
+	var stack []*frame
+	pointer := func() string {
+		var b strings.Builder
+		for _, f := range stack {
+			switch {
+			case f.array:
+				b.WriteString("/" + strconv.Itoa(f.index))
+			case f.haveKey:
+				b.WriteString("/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(f.key))
+			}
+		}
+		return b.String()
+	}
+	done := func() {
+		if len(stack) == 0 {
+			return
+		}
+		if top := stack[len(stack)-1]; top.array {
+			top.index++
+		} else {
+			top.haveKey = false
+		}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw[:offset]))
+	dec.UseNumber()
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return pointer(), last
+		}
+		if n := len(stack); n > 0 && !stack[n-1].array && !stack[n-1].haveKey {
+			if key, ok := tok.(string); ok {
+				stack[n-1].key, stack[n-1].haveKey = key, true
+				continue
+			}
+		}
+		switch tok {
+		case json.Delim('{'):
+			stack = append(stack, &frame{})
+		case json.Delim('['):
+			stack = append(stack, &frame{array: true})
+		case json.Delim('}'), json.Delim(']'):
+			stack = stack[:len(stack)-1]
+			last = pointer()
+			done()
+		default:
+			last = pointer()
+			done()
+		}
+	}
+}
+
+var pointsPointer = regexp.MustCompile(`^/points/(\d+)(/|$)`)
+
+// pointRow - the point's row, from 1, for pointers into the points array
+func pointRow(pointer string) int {
+	m := pointsPointer.FindStringSubmatch(pointer)
+	if m == nil {
+		return 0
+	}
+	i, _ := strconv.Atoi(m[1])
+	return i + 1
+}
+
+// bodyError - a body that couldn't be read or parsed: 413 when it went
+// over its limit, 400 with prefix and the error otherwise, with what is
+// known of where the problem is
+func (s *Server) bodyError(prefix string, err error) *APIError {
+	var tooBig *http.MaxBytesError
+	if errors.As(err, &tooBig) {
+		msg := fmt.Sprintf("Request body is over the %s limit", megabytes(tooBig.Limit))
+		if tooBig.Limit < s.streamLimit() {
+			msg += "; send big datasets to /api/v1/validate/stream"
+		}
+		return &APIError{Status: http.StatusRequestEntityTooLarge, Code: CodePayloadTooLarge, Message: msg, Details: []wire.ErrorDetail{{
+			Code: CodePayloadTooLarge, Message: fmt.Sprintf("the limit is %d bytes", tooBig.Limit), Value: tooBig.Limit,
+		}}}
+	}
+
+	e := &APIError{Status: http.StatusBadRequest, Code: CodeInvalidUpload, Message: prefix + err.Error()}
+	var input *ingest.InputError
+	var row *formats.RowError
+	var parse *csv.ParseError
+	switch {
+	case errors.As(err, &input):
+		d := wire.ErrorDetail{Code: CodeSyntax, Message: input.Err.Error(), Row: input.Point, Offset: input.Offset}
+		var typ *json.UnmarshalTypeError
+		if errors.As(input.Err, &typ) {
+			d.Code, d.Field = openapi.CodeWrongType, typ.Field
+			if n, ok := strings.CutPrefix(typ.Value, "number "); ok && typ.Type.Kind() == reflect.Float64 {
+				d.Code, d.Value = CodeNotFinite, n
+			}
+		} else if input.Literal != "" {
+			d.Code, d.Value, d.Message = CodeNotFinite, input.Literal, input.Literal+" isn't a number JSON can carry"
+		}
+		e.Code = CodeMalformedJSON
+		if d.Code != CodeSyntax {
+			e.Code = CodeInvalidRequest
+		}
+		e.Details = []wire.ErrorDetail{d}
+	case errors.As(err, &row):
+		d := wire.ErrorDetail{Code: CodeBadNumber, Message: row.Error(), Line: row.Line, Field: row.Column, Value: row.Value}
+		if row.NotFinite {
+			d.Code = CodeNotFinite
+		}
+		e.Details = []wire.ErrorDetail{d}
+	case errors.As(err, &parse):
+		e.Details = []wire.ErrorDetail{{Code: CodeSyntax, Message: parse.Err.Error(), Line: parse.Line, Column: parse.Column}}
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		e.Details = []wire.ErrorDetail{{Code: CodeSyntax, Message: "the body ends part way through"}}
+	}
+	return e
+}
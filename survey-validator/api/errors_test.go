@@ -0,0 +1,104 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/survey-validator/wire"
+)
+
+// errorBody - the error answer to a POST of raw to target
+func errorBody(t *testing.T, h http.Handler, target, raw string) (int, wire.ErrorResponse) {
+	t.Helper()
+	rec := call(t, h, http.MethodPost, target, raw)
+	var e wire.ErrorResponse
+	decode(t, rec, &e)
+	return rec.Code, e
+}
+
+func TestErrors_Schema(t *testing.T) {
+	h := NewServer("").Handler()
+
+	// every bad field at once
+	status, e := errorBody(t, h, "/api/v1/validate", `{"points": [{"point_id": "A", "easting": "1"}], "suppressions": [{"fingerprint": "abc"}]}`)
+	if status != http.StatusBadRequest || e.Code != CodeInvalidRequest || len(e.Details) != 3 {
+		t.Fatalf("Expected invalid_request with 3 details, got %d %s %+v", status, e.Code, e.Details)
+	}
+	for _, want := range []string{"/points/0/easting: expected a number", "/points/0/northing: is required", "/suppressions/0/justification: is required"} {
+		if !strings.Contains(e.Error, want) {
+			t.Errorf("Expected %q in %q", want, e.Error)
+		}
+	}
+	for _, d := range e.Details {
+		if d.Pointer == "/points/0/easting" && (d.Code != "wrong_type" || d.Row != 1 || d.Value != "1") {
+			t.Errorf("Unexpected easting detail %+v", d)
+		}
+	}
+
+	// malformed JSON, NaN and an unknown survey type, each placed in the body
+	for raw, want := range map[string]wire.ErrorDetail{
+		"{\"points\": [\n  {\"point_id\": \"A\", \"easting\": 1,}\n]}":                            {Code: CodeSyntax, Pointer: "/points/0", Row: 1, Line: 2},
+		`{"points": [{"point_id": "A", "easting": 1, "northing": NaN}]}`:                          {Code: CodeNotFinite, Pointer: "/points/0/northing", Row: 1, Line: 1, Value: "NaN"},
+		`{"points": [{"point_id": "A", "easting": 1, "northing": -1e999}]}`:                       {Code: CodeNotFinite, Pointer: "/points/0/northing", Row: 1, Line: 1, Value: "-1e999"},
+		`{"points": [{}, {"point_id": "B", "easting": 1, "northing": 1, "survey_type": "trav"}]}`: {Code: "invalid_value", Pointer: "/points/1/survey_type", Row: 2, Value: "trav"},
+	} {
+		_, e := errorBody(t, h, "/api/v1/validate", raw)
+		var found bool
+		for _, d := range e.Details {
+			found = found || d.Code == want.Code && d.Pointer == want.Pointer && d.Row == want.Row && d.Line == want.Line && d.Value == want.Value
+		}
+		if !found {
+			t.Errorf("%s: expected %+v among %+v", raw, want, e.Details)
+		}
+	}
+}
+
+func TestErrors_Stream(t *testing.T) {
+	h := NewServer("").Handler()
+
+	// a bad CSV cell names its line and column
+	_, e := errorBody(t, h, "/api/v1/validate/stream?format=csv", "point_id,easting,northing\nA,1,1\nB,inf,2\n")
+	if len(e.Details) != 1 {
+		t.Fatalf("Expected one detail, got %+v", e)
+	}
+	if d := e.Details[0]; d.Code != CodeNotFinite || d.Line != 3 || d.Field != "easting" || d.Value != "inf" {
+		t.Errorf("Unexpected CSV detail %+v", d)
+	}
+
+	// the streamed body is gone by the time it fails, the literal is noted
+	// as it is read; a word that only starts like one is a syntax error
+	for raw, want := range map[string]wire.ErrorDetail{
+		`{"points": [{"point_id": "A", "easting": 1, "northing": 1}, {"point_id": "B", "easting": NaN}]}`: {Code: CodeNotFinite, Row: 2, Value: "NaN"},
+		`[{"point_id": "A", "easting": -Infinity}]`:                                                       {Code: CodeNotFinite, Row: 1, Value: "-Infinity"},
+		`[{"point_id": "A", "easting": Null}]`:                                                            {Code: CodeSyntax, Row: 1},
+		`[{"point_id": "A", "easting": Inf}]`:                                                             {Code: CodeSyntax, Row: 1},
+	} {
+		status, e := errorBody(t, h, "/api/v1/validate/stream", raw)
+		if status != http.StatusBadRequest || len(e.Details) != 1 {
+			t.Errorf("%s: expected a 400 with one detail, got %d %+v", raw, status, e)
+			continue
+		}
+		if d := e.Details[0]; d.Code != want.Code || d.Row != want.Row || d.Value != want.Value {
+			t.Errorf("%s: expected %+v, got %+v", raw, want, d)
+		}
+	}
+}
+
+func TestErrors_Codes(t *testing.T) {
+	h := NewServer("").Handler()
+
+	for name, tt := range map[string]struct {
+		method, target string
+		code           string
+	}{
+		"unavailable": {http.MethodGet, "/api/v1/projects", CodeUnavailable}, // no data directory
+		"method":      {http.MethodGet, "/api/v1/validate", CodeMethodNotAllowed},
+		"bad options": {http.MethodPost, "/api/v1/validate/stream?disable=nope", CodeInvalidOptions},
+	} {
+		rec := call(t, h, tt.method, tt.target, "[]")
+		if code := errorCode(t, rec); code != tt.code {
+			t.Errorf("%s: expected %s, got %s", name, tt.code, code)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package api
+
+// handler.go - every endpoint behind the shared middleware, for the local
+// server and the serverless function both
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/survey-validator/web"
+)
+
+// Handler - the whole API. Endpoints that need a data directory or the
+// job pool answer 503 on a server without them.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/", s.webApp())
+	mux.HandleFunc("/health", s.handleHealth)
+	mux.HandleFunc("/health/live", s.handleHealth)
+	mux.HandleFunc("/health/ready", s.handleReady)
+	mux.HandleFunc("/health/selftest", s.handleSelfTest)
+	mux.HandleFunc("/metrics", s.handleMetrics)
+	mux.HandleFunc("/api/v1/openapi.json", s.handleOpenAPI)
+	mux.HandleFunc("/api/v1/validate", s.handleValidate)
+	mux.HandleFunc("/api/v1/validate/stream", s.handleValidateStream)
+	mux.HandleFunc("/api/v1/checks", s.handleChecks)
+	mux.HandleFunc("/api/v1/import", s.handleImport)
+	mux.HandleFunc("/api/v1/export", s.handleExport)
+	mux.HandleFunc("/api/v1/certificate", s.handleCertificate)
+	mux.HandleFunc("/api/v1/compare", s.handleCompare)
+	mux.HandleFunc("/api/v1/jobs", s.handleJobs)
+	mux.HandleFunc("/api/v1/jobs/", s.handleJobs)
+	mux.HandleFunc("/api/v1/projects", s.handleProjects)
+	mux.HandleFunc("/api/v1/projects/", s.handleProjects)
+	mux.HandleFunc("/api/v1/monitor", s.handleMonitor)
+	mux.HandleFunc("/api/v1/monitor/", s.handleMonitor)
+	mux.HandleFunc("/api/v1/keys", s.handleKeys)
+	mux.HandleFunc("/api/v1/keys/", s.handleKeys)
+
+	// outermost first: the ID is there for everything after it
+	var h http.Handler = mux
+	h = s.negotiate(h)
+	h = s.limitBody(h)
+	h = s.authenticate(h)
+	h = s.cors(h)
+	h = s.recoverPanics(h)
+	h = s.observe(mux, h)
+	return withRequestID(h)
+}
+
+// webApp - the browser app, embedded unless SetStaticDir says otherwise
+func (s *Server) webApp() http.Handler {
+	if s.staticDir != "" {
+		return web.DirHandler(s.staticDir)
+	}
+	return web.Handler()
+}
+
+var (
+	serverlessOnce    sync.Once
+	serverlessHandler http.Handler
+)
+
+// Serverless - Handler for a serverless function: no data directory and
+// no jobs, and one engine per instance rather than per request. It serves
+// the web app too.
+func Serverless() http.Handler {
+	serverlessOnce.Do(func() {
+		serverlessHandler = NewServer("").Handler()
+	})
+	return serverlessHandler
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/survey-validator/wire"
+)
+
+func TestWebApp(t *testing.T) {
+	s := NewServer("")
+	if _, err := s.SetKeyFile(filepath.Join(t.TempDir(), "keys.json")); err != nil {
+		t.Fatal(err)
+	}
+	h := s.Handler()
+
+	// the embedded app, open without a key, through the same middleware
+	rec := call(t, h, http.MethodGet, "/", nil)
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || etag == "" || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("Expected the embedded app, got %d %s", rec.Code, rec.Header())
+	}
+	if rec.Header().Get(wire.RequestIDHeader) == "" {
+		t.Errorf("Expected the app behind the middleware, got %s", rec.Header())
+	}
+	r := newRequest(t, http.MethodGet, "/", nil)
+	r.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("Expected a 304, got %d", rec.Code)
+	}
+
+	// SetStaticDir swaps it for files on disk
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "index.html"), []byte("<html>working copy</html>"), 0o644)
+	s.SetStaticDir(dir)
+	rec = call(t, s.Handler(), http.MethodGet, "/", nil)
+	if rec.Code != http.StatusOK || rec.Body.String() != "<html>working copy</html>" {
+		t.Errorf("Expected the app from %s, got %d %s", dir, rec.Code, rec.Body)
+	}
+}
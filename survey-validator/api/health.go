@@ -0,0 +1,158 @@
+package api
+
+// health.go - is the server up, and is it ready for work: liveness for a
+// process supervisor, readiness for a load balancer, and a self-test that
+// checks the engine still gives the known answers on sample data
+
+import (
+	"context"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/survey-validator/engine"
+	"github.com/survey-validator/selftest"
+	"github.com/survey-validator/wire"
+)
+
+// Version - the build, set with -ldflags "-X
+// github.com/survey-validator/api.Version=v1.2.3". Unset, it comes from
+// the module version or VCS revision Go stamped into the binary.
+var Version string
+
+// buildVersion - Version, or the best the build info can do
+func buildVersion() string {
+	if Version != "" {
+		return Version
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "dev"
+	}
+	if v := info.Main.Version; v != "" && v != "(devel)" {
+		return v
+	}
+	var rev, dirty string
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			rev = s.Value
+		case "vcs.modified":
+			if s.Value == "true" {
+				dirty = "-dirty"
+			}
+		}
+	}
+	if len(rev) > 12 {
+		rev = rev[:12]
+	}
+	if rev == "" {
+		return "dev"
+	}
+	return rev + dirty
+}
+
+func (s *Server) health(status string) wire.HealthResponse {
+	return wire.HealthResponse{
+		Status:        status,
+		Service:       "survey-validator",
+		Version:       buildVersion(),
+		UptimeSeconds: time.Since(s.started).Round(time.Second).Seconds(),
+	}
+}
+
+// handleHealth - /health and /health/live: the process is up and
+// answering, nothing more
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	s.respondJSON(w, http.StatusOK, s.health("healthy"))
+}
+
+// handleReady - 200 when the server can take work: it isn't shutting
+// down, the startup self-test passed, the stores can be written and the
+// job queue has room. 503 with the reasons otherwise.
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	resp := wire.ReadinessResponse{HealthResponse: s.health("ready"), Checks: s.engine.CheckOrder()}
+
+	if s.draining.Load() {
+		resp.Problems = append(resp.Problems, "shutting down")
+	}
+	rep := s.startupSelfTest()
+	resp.SelfTest = wireSelfTest(rep)
+	if !rep.Passed {
+		resp.Problems = append(resp.Problems, "self-test failed")
+	}
+
+	if s.store != nil {
+		storageHealth(&resp, "projects", s.store.Check())
+	}
+	if s.monitor != nil {
+		storageHealth(&resp, "monitor", s.monitor.Check())
+	}
+
+	if s.jobs != nil {
+		st := s.jobs.Stats()
+		resp.Jobs = &wire.JobsHealth{PoolStats: wire.PoolStats(st), Saturation: float64(st.Running) / float64(st.Workers)}
+		if st.Queued >= st.QueueSize {
+			resp.Problems = append(resp.Problems, "job queue is full")
+		}
+	}
+
+	status := http.StatusOK
+	resp.Ready = len(resp.Problems) == 0
+	if !resp.Ready {
+		resp.Status = "not_ready"
+		status = http.StatusServiceUnavailable
+		requestLogger(r).Warn("Not ready", "problems", resp.Problems)
+	}
+	s.respondJSON(w, status, resp)
+}
+
+// storageHealth - note how a store's check went
+func storageHealth(resp *wire.ReadinessResponse, name string, err error) {
+	if resp.Storage == nil {
+		resp.Storage = make(map[string]wire.StorageHealth)
+	}
+	if err != nil {
+		resp.Storage[name] = wire.StorageHealth{Error: err.Error()}
+		resp.Problems = append(resp.Problems, name+" storage isn't writable")
+		return
+	}
+	resp.Storage[name] = wire.StorageHealth{Available: true}
+}
+
+// handleSelfTest - run the self-test now, 503 if it fails
+func (s *Server) handleSelfTest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		s.respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	rep := runSelfTest(r.Context())
+	status := http.StatusOK
+	if !rep.Passed {
+		status = http.StatusServiceUnavailable
+	}
+	s.respondJSON(w, status, wireSelfTest(rep))
+}
+
+// startupSelfTest - the self-test, run the first time it's asked for (by
+// Serve, or the first readiness probe on a serverless instance)
+func (s *Server) startupSelfTest() selftest.Report {
+	s.selfTest.once.Do(func() {
+		s.selfTest.report = runSelfTest(context.Background())
+	})
+	return s.selfTest.report
+}
+
+// runSelfTest - on an engine of its own, so rules the server loaded can't
+// change the answers and the samples don't show up in the metrics
+func runSelfTest(ctx context.Context) selftest.Report {
+	return selftest.Run(ctx, engine.NewEngine())
+}
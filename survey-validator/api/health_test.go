@@ -0,0 +1,90 @@
+package api
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/survey-validator/engine"
+	"github.com/survey-validator/wire"
+)
+
+func TestHealth(t *testing.T) {
+	h := NewServer("").Handler()
+	for _, path := range []string{"/health", "/health/live"} {
+		var health wire.HealthResponse
+		rec := call(t, h, http.MethodGet, path, nil)
+		decode(t, rec, &health)
+		if rec.Code != http.StatusOK || health.Status != "healthy" || health.Version == "" {
+			t.Errorf("%s: expected healthy with a version, got %d %+v", path, rec.Code, health)
+		}
+	}
+	if rec := call(t, h, http.MethodPost, "/health", nil); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for POST, got %d", rec.Code)
+	}
+}
+
+func TestReady(t *testing.T) {
+	dir := t.TempDir()
+	s := NewServer("")
+	if err := s.SetDataDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	h := s.Handler()
+
+	var ready wire.ReadinessResponse
+	rec := call(t, h, http.MethodGet, "/health/ready", nil)
+	decode(t, rec, &ready)
+	if rec.Code != http.StatusOK || !ready.Ready || ready.Status != "ready" || !ready.SelfTest.Passed {
+		t.Errorf("Expected ready, got %d %+v", rec.Code, ready)
+	}
+	if !ready.Storage["projects"].Available || !ready.Storage["monitor"].Available {
+		t.Errorf("Expected both stores available, got %+v", ready.Storage)
+	}
+	if len(ready.Checks) == 0 || ready.Checks[0] != "input_validation" || ready.Jobs != nil {
+		t.Errorf("Expected the checks and no job pool, got %v %+v", ready.Checks, ready.Jobs)
+	}
+
+	s.jobs = engine.NewPool(s.engine, 2, 10)
+	defer s.jobs.Close()
+	decode(t, call(t, h, http.MethodGet, "/health/ready", nil), &ready)
+	if ready.Jobs == nil || ready.Jobs.Workers != 2 || ready.Jobs.QueueSize != 10 {
+		t.Errorf("Expected the pool's stats, got %+v", ready.Jobs)
+	}
+
+	// a store that can't be written takes it out of rotation
+	os.RemoveAll(filepath.Join(dir, "projects"))
+	rec = call(t, h, http.MethodGet, "/health/ready", nil)
+	ready = wire.ReadinessResponse{}
+	decode(t, rec, &ready)
+	if rec.Code != http.StatusServiceUnavailable || ready.Ready || ready.Status != "not_ready" || len(ready.Problems) != 1 {
+		t.Errorf("Expected not ready without the projects directory, got %d %+v", rec.Code, ready)
+	}
+	if ready.Storage["projects"].Available || ready.Storage["projects"].Error == "" || !ready.Storage["monitor"].Available {
+		t.Errorf("Expected only the projects store to be down, got %+v", ready.Storage)
+	}
+
+	// and so does shutting down
+	s2 := NewServer("")
+	s2.draining.Store(true)
+	decode(t, call(t, s2.Handler(), http.MethodGet, "/health/ready", nil), &ready)
+	if ready.Ready || len(ready.Problems) != 1 || ready.Problems[0] != "shutting down" {
+		t.Errorf("Expected not ready while shutting down, got %+v", ready)
+	}
+}
+
+func TestSelfTest(t *testing.T) {
+	h := NewServer("").Handler()
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		var rep wire.SelfTestReport
+		rec := call(t, h, method, "/health/selftest", nil)
+		decode(t, rec, &rep)
+		if rec.Code != http.StatusOK || !rep.Passed || len(rep.Results) < 2 || rep.RanAt.IsZero() {
+			t.Errorf("%s: expected the self-test to pass, got %d %+v", method, rec.Code, rep)
+		}
+	}
+	if rec := call(t, h, http.MethodDelete, "/health/selftest", nil); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for DELETE, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,159 @@
+package api
+
+// jobs.go - background validation:
+//
+//	POST   /api/v1/jobs               same body as validate, answers 202 with the job
+//	GET    /api/v1/jobs/{id}          status and progress
+//	GET    /api/v1/jobs/{id}/events   the same as server-sent events until it finishes
+//	GET    /api/v1/jobs/{id}/report   the report once it's done
+//	DELETE /api/v1/jobs/{id}          cancel
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/survey-validator/engine"
+	"github.com/survey-validator/models"
+	"github.com/survey-validator/wire"
+)
+
+func (s *Server) handleJobs(w http.ResponseWriter, r *http.Request) {
+	if s.jobs == nil {
+		s.respondError(w, http.StatusServiceUnavailable, "Background jobs need the local server")
+		return
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/jobs"), "/"), "/")
+	if parts[0] == "" {
+		parts = nil
+	}
+
+	switch {
+	case len(parts) == 0 && r.Method == http.MethodPost:
+		s.handleSubmitJob(w, r)
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		job, err := s.jobs.Get(parts[0])
+		if s.jobError(w, err) {
+			return
+		}
+		s.respondJSON(w, http.StatusOK, wireJob(job))
+	case len(parts) == 1 && r.Method == http.MethodDelete:
+		job, err := s.jobs.Cancel(parts[0])
+		if s.jobError(w, err) {
+			return
+		}
+		s.respondJSON(w, http.StatusOK, wireJob(job))
+	case len(parts) == 2 && parts[1] == "report" && r.Method == http.MethodGet:
+		job, err := s.jobs.Get(parts[0])
+		if s.jobError(w, err) {
+			return
+		}
+		if job.Status != engine.JobDone {
+			msg := "Job is " + string(job.Status)
+			if job.Error != "" {
+				msg += ": " + job.Error
+			}
+			s.respondError(w, http.StatusConflict, msg)
+			return
+		}
+		s.respondJSON(w, http.StatusOK, job.Report)
+	case len(parts) == 2 && parts[1] == "events" && r.Method == http.MethodGet:
+		s.handleJobEvents(w, r, parts[0])
+	case len(parts) <= 1 || (len(parts) == 2 && (parts[1] == "report" || parts[1] == "events")):
+		s.respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	default:
+		s.respondError(w, http.StatusNotFound, "Not found")
+	}
+}
+
+func (s *Server) handleSubmitJob(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	var body wire.ValidateBody
+	if !s.decodeJSON(w, r, &body) {
+		return
+	}
+	noteProject(r, body.ProjectID)
+	var after func(*models.SurveyData, *models.ValidationReport)
+	if body.Store {
+		if !s.canStore(w, body.ProjectID) {
+			return
+		}
+		after = s.storeReport
+	}
+
+	job, err := s.jobs.SubmitWith(&body.SurveyData, s.withProfile(validateOptions(&body)), after)
+	switch {
+	case errors.Is(err, engine.ErrInvalidOptions):
+		s.respondAPIError(w, optionsError(err))
+		return
+	case errors.Is(err, engine.ErrQueueFull), errors.Is(err, engine.ErrPoolClosed):
+		w.Header().Set("Retry-After", "30")
+		s.respondError(w, http.StatusServiceUnavailable, "Too many jobs waiting, try again shortly")
+		return
+	case err != nil:
+		s.respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Location", "/api/v1/jobs/"+job.ID)
+	s.respondJSON(w, http.StatusAccepted, wireJob(job))
+}
+
+// handleJobEvents - one event per change, named after the job's status,
+// with the job as data. The stream ends when the job finishes.
+func (s *Server) handleJobEvents(w http.ResponseWriter, r *http.Request, id string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		s.respondError(w, http.StatusInternalServerError, "Streaming not supported")
+		return
+	}
+	updates, stop, err := s.jobs.Watch(id)
+	if s.jobError(w, err) {
+		return
+	}
+	defer stop()
+
+	noDeadlines(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	for {
+		select {
+		case job, ok := <-updates:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(wireJob(job))
+			if err != nil {
+				requestLogger(r).Warn("Encoding job event", "job_id", id, "error", err)
+				return
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", job.Status, data); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		case <-s.shutdown: // the client can reconnect to the next server
+			return
+		}
+	}
+}
+
+// jobError - answer for a lookup that failed, true if it did
+func (s *Server) jobError(w http.ResponseWriter, err error) bool {
+	if errors.Is(err, engine.ErrNoJob) {
+		s.respondError(w, http.StatusNotFound, "No such job, finished jobs are kept for an hour")
+		return true
+	}
+	if err != nil {
+		s.respondError(w, http.StatusInternalServerError, err.Error())
+		return true
+	}
+	return false
+}
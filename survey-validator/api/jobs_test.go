@@ -0,0 +1,127 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/survey-validator/engine"
+	"github.com/survey-validator/models"
+	"github.com/survey-validator/wire"
+)
+
+// jobServer - a test server with the pool Serve would start
+func jobServer(t *testing.T) *Server {
+	t.Helper()
+	s := testServer(t)
+	s.jobs = engine.NewPool(s.engine, 1, 10)
+	t.Cleanup(s.jobs.Close)
+	return s
+}
+
+// events - the job's event stream, all of it. Fails if it doesn't end.
+func events(t *testing.T, h http.Handler, id string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/jobs/"+id+"/events", nil))
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Expected the event stream to end with the job")
+	}
+	return rec
+}
+
+func TestJobs(t *testing.T) {
+	h := jobServer(t).Handler()
+
+	rec := call(t, h, http.MethodPost, "/api/v1/jobs", wire.ValidateBody{SurveyData: testData()})
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d %s", rec.Code, rec.Body)
+	}
+	var job wire.Job
+	decode(t, rec, &job)
+	if job.ID == "" || job.Points != 4 || job.ProjectID != "API-1" {
+		t.Errorf("Expected a job for API-1's 4 points, got %+v", job)
+	}
+	if got := rec.Header().Get("Location"); got != "/api/v1/jobs/"+job.ID {
+		t.Errorf("Expected the job's location, got %q", got)
+	}
+
+	// the stream sends what's happened so far and ends when the job does
+	rec = events(t, h, job.ID)
+	if got := rec.Header().Get("Content-Type"); got != "text/event-stream" {
+		t.Errorf("Expected text/event-stream, got %s", got)
+	}
+	body := rec.Body.String()
+	if !strings.HasSuffix(body, "\n\n") || !strings.Contains(body, "event: done\ndata: {") {
+		t.Errorf("Expected the stream to end on a done event, got %s", body)
+	}
+	// and a finished job's stream is its last state, then the end
+	if rec := events(t, h, job.ID); strings.Count(rec.Body.String(), "event: ") != 1 {
+		t.Errorf("Expected one event for a finished job, got %s", rec.Body)
+	}
+
+	decode(t, call(t, h, http.MethodGet, "/api/v1/jobs/"+job.ID, nil), &job)
+	if job.Status != wire.JobStatus(engine.JobDone) || job.FinishedAt == nil {
+		t.Errorf("Expected a finished job, got %+v", job)
+	}
+	var report models.ValidationReport
+	decode(t, call(t, h, http.MethodGet, "/api/v1/jobs/"+job.ID+"/report", nil), &report)
+	if report.Summary.TotalPoints != 4 || report.ReportID != "" {
+		t.Errorf("Expected an unstored report of 4 points, got %+v", report)
+	}
+
+	for name, tt := range map[string]struct {
+		method, path string
+		status       int
+	}{
+		"unknown job":    {http.MethodGet, "/api/v1/jobs/nope", http.StatusNotFound},
+		"unknown events": {http.MethodGet, "/api/v1/jobs/nope/events", http.StatusNotFound},
+		"unknown report": {http.MethodGet, "/api/v1/jobs/nope/report", http.StatusNotFound},
+		"method":         {http.MethodPut, "/api/v1/jobs/" + job.ID, http.StatusMethodNotAllowed},
+		"path":           {http.MethodGet, "/api/v1/jobs/" + job.ID + "/nope", http.StatusNotFound},
+	} {
+		if rec := call(t, h, tt.method, tt.path, nil); rec.Code != tt.status {
+			t.Errorf("%s: expected %d, got %d %s", name, tt.status, rec.Code, rec.Body)
+		}
+	}
+}
+
+func TestJobs_Store(t *testing.T) {
+	h := jobServer(t).Handler()
+
+	rec := call(t, h, http.MethodPost, "/api/v1/jobs", wire.ValidateBody{SurveyData: testData(), Store: true})
+	var job wire.Job
+	decode(t, rec, &job)
+	events(t, h, job.ID)
+
+	var report models.ValidationReport
+	decode(t, call(t, h, http.MethodGet, "/api/v1/jobs/"+job.ID+"/report", nil), &report)
+	if report.ReportID == "" {
+		t.Fatalf("Expected the job's report to be stored, got %+v", report)
+	}
+	if rec := call(t, h, http.MethodGet, "/api/v1/projects/API-1/reports/"+report.ReportID, nil); rec.Code != http.StatusOK {
+		t.Errorf("Expected the stored report, got %d %s", rec.Code, rec.Body)
+	}
+
+	// storing is checked before the job is queued
+	data := testData()
+	data.ProjectID = ""
+	if rec := call(t, h, http.MethodPost, "/api/v1/jobs", wire.ValidateBody{SurveyData: data, Store: true}); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 storing without a project ID, got %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestJobs_NoPool(t *testing.T) {
+	rec := call(t, testServer(t).Handler(), http.MethodPost, "/api/v1/jobs", wire.ValidateBody{SurveyData: testData()})
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 without the local server's pool, got %d %s", rec.Code, rec.Body)
+	}
+}
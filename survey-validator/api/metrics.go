@@ -0,0 +1,65 @@
+package api
+
+// metrics.go - what the server has been doing, for Prometheus to scrape at
+// /metrics: requests and their latency by route, how long each check
+// takes, points validated and reports by status
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/survey-validator/metrics"
+	"github.com/survey-validator/models"
+)
+
+// serverMetrics - one per server, fed by the observe middleware and the
+// engine's hooks
+type serverMetrics struct {
+	registry *metrics.Registry
+	requests *metrics.Counter   // method, route, status
+	latency  *metrics.Histogram // method, route
+	inFlight *metrics.Gauge
+	checks   *metrics.Histogram // check
+	points   *metrics.Counter
+	reports  *metrics.Counter // status
+}
+
+func newServerMetrics() *serverMetrics {
+	r := metrics.NewRegistry()
+	return &serverMetrics{
+		registry: r,
+		requests: r.NewCounter("survey_validator_http_requests_total", "HTTP requests by method, route and status.", "method", "route", "status"),
+		latency:  r.NewHistogram("survey_validator_http_request_duration_seconds", "Time to answer HTTP requests, by method and route.", nil, "method", "route"),
+		inFlight: r.NewGauge("survey_validator_http_requests_in_flight", "HTTP requests being answered now."),
+		checks:   r.NewHistogram("survey_validator_check_duration_seconds", "Time each validation check took.", nil, "check"),
+		points:   r.NewCounter("survey_validator_points_processed_total", "Survey points validated, through any endpoint or job."),
+		reports:  r.NewCounter("survey_validator_reports_total", "Validation reports made, by status.", "status"),
+	}
+}
+
+func (m *serverMetrics) observeCheck(name string, took time.Duration) {
+	m.checks.Observe(took.Seconds(), name)
+}
+
+func (m *serverMetrics) observeReport(report *models.ValidationReport) {
+	m.points.Add(float64(report.Summary.TotalPoints))
+	m.reports.Inc(string(report.Status))
+}
+
+func (m *serverMetrics) observeRequest(method, route string, status int, took time.Duration) {
+	m.requests.Inc(method, route, strconv.Itoa(status))
+	m.latency.Observe(took.Seconds(), method, route)
+}
+
+// handleMetrics - every metric in the Prometheus text format
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	w.Header().Set("Content-Type", metrics.ContentType)
+	if err := s.metrics.registry.WriteText(w); err != nil {
+		requestLogger(r).Warn("Writing metrics", "error", err)
+	}
+}
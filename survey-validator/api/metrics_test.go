@@ -0,0 +1,71 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/survey-validator/wire"
+)
+
+func TestMetrics(t *testing.T) {
+	h := testServer(t).Handler()
+	call(t, h, http.MethodPost, "/api/v1/validate", wire.ValidateBody{SurveyData: testData()})
+	call(t, h, http.MethodGet, "/api/v1/projects/no-such-project", nil)
+
+	rec := call(t, h, http.MethodGet, "/metrics", nil)
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain") {
+		t.Fatalf("Unexpected %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	for _, want := range []string{
+		`survey_validator_http_requests_total{method="POST",route="/api/v1/validate",status="200"} 1`,
+		// by the route matched, not the path
+		`survey_validator_http_requests_total{method="GET",route="/api/v1/projects/",status="404"} 1`,
+		`survey_validator_http_request_duration_seconds_count{method="POST",route="/api/v1/validate"} 1`,
+		`survey_validator_check_duration_seconds_count{check="duplicate_detection"} 1`,
+		`survey_validator_points_processed_total 4`,
+		`survey_validator_reports_total{status="`,
+		`survey_validator_http_requests_in_flight 1`, // this one
+	} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("Expected %s in:\n%s", want, rec.Body)
+		}
+	}
+}
+
+func TestRequestLog(t *testing.T) {
+	var buf bytes.Buffer
+	old := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(old) })
+
+	h := NewServer("").Handler()
+	r := newRequest(t, http.MethodGet, "/api/v1/projects/x", nil)
+	r.Header.Set(wire.RequestIDHeader, "log-1")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	var line map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("Expected one JSON log line, got %s", buf.String())
+	}
+	for key, want := range map[string]interface{}{
+		"msg":        "Request",
+		"level":      "ERROR", // a 503 is the server's fault
+		"request_id": "log-1",
+		"method":     "GET",
+		"path":       "/api/v1/projects/x",
+		"route":      "/api/v1/projects/",
+		"status":     float64(http.StatusServiceUnavailable),
+	} {
+		if line[key] != want {
+			t.Errorf("Expected %s %v, got %v", key, want, line[key])
+		}
+	}
+	if _, ok := line["duration_ms"]; !ok {
+		t.Errorf("Expected a duration in %s", buf.String())
+	}
+}
@@ -0,0 +1,265 @@
+package api
+
+// middleware.go - what every request goes through, on the local server and
+// on Vercel alike: a request ID, logging, panic recovery, CORS, API keys
+// (see auth.go), body size limits and a check that the client will take
+// what the endpoint answers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/survey-validator/wire"
+)
+
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+type ctxKey int
+
+const (
+	requestIDKey ctxKey = iota
+	requestInfoKey
+)
+
+// RequestID - the ID of the request ctx belongs to, "" outside one
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// withRequestID - give the request an ID, in its context and the response
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(wire.RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(wire.RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey, id)))
+	})
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// requestLogger - the default logger with the request's ID on every line
+func requestLogger(r *http.Request) *slog.Logger {
+	return slog.Default().With("request_id", RequestID(r.Context()))
+}
+
+// statusWriter - remembers the status and size for the log. Flush and
+// Unwrap keep server-sent events working through it.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// observe - one structured log line per request, and its count and
+// latency in the metrics. Metrics are labelled by the mux pattern the
+// request matched rather than its path, so IDs in paths don't make a
+// series each.
+func (s *Server) observe(mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		_, route := mux.Handler(r)
+		if route == "" {
+			route = "unmatched"
+		}
+		logger := requestLogger(r)
+		logger.Debug("Request started", "method", r.Method, "path", r.URL.Path)
+
+		s.metrics.inFlight.Add(1)
+		sw := &statusWriter{ResponseWriter: w}
+		defer func() {
+			s.metrics.inFlight.Add(-1)
+			took := time.Since(start)
+			if sw.status == 0 {
+				sw.status = http.StatusOK // nothing written
+			}
+			s.metrics.observeRequest(r.Method, route, sw.status, took)
+			level := slog.LevelInfo
+			if sw.status >= 500 {
+				level = slog.LevelError
+			}
+			logger.LogAttrs(r.Context(), level, "Request",
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.String("route", route),
+				slog.Int("status", sw.status),
+				slog.Int64("bytes", sw.bytes),
+				slog.Float64("duration_ms", float64(took.Microseconds())/1000),
+				slog.String("remote", r.RemoteAddr),
+			)
+		}()
+		next.ServeHTTP(sw, r)
+	})
+}
+
+// recoverPanics - a handler that panics answers 500 rather than dropping
+// the connection
+func (s *Server) recoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sw := &statusWriter{ResponseWriter: w}
+		defer func() {
+			if v := recover(); v != nil {
+				if v == http.ErrAbortHandler {
+					panic(v)
+				}
+				requestLogger(r).Error("Panic", "method", r.Method, "path", r.URL.Path, "panic", v)
+				if sw.status == 0 {
+					s.respondError(sw, http.StatusInternalServerError, "Internal server error")
+				}
+			}
+		}()
+		next.ServeHTTP(sw, r)
+	})
+}
+
+// cors - let browsers on other origins call the API. With no origins set
+// any origin may; otherwise only the listed ones get the headers.
+// Preflight requests are answered here.
+func (s *Server) cors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		if origin := s.allowOrigin(r.Header.Get("Origin")); origin != "" {
+			h.Set("Access-Control-Allow-Origin", origin)
+			h.Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+			h.Set("Access-Control-Allow-Headers", "Content-Type, Accept, Authorization, "+APIKeyHeader+", "+wire.RequestIDHeader)
+			h.Set("Access-Control-Expose-Headers", wire.RequestIDHeader+", Location, Retry-After, Content-Disposition, X-RateLimit-Limit, X-RateLimit-Remaining, X-Quota-Remaining")
+			h.Set("Access-Control-Max-Age", "600")
+		}
+		if len(s.corsOrigins) > 0 {
+			h.Add("Vary", "Origin")
+		}
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) allowOrigin(origin string) string {
+	if len(s.corsOrigins) == 0 {
+		return "*"
+	}
+	for _, o := range s.corsOrigins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return origin
+		}
+	}
+	return ""
+}
+
+// limitBody - no request body past its limit: maxStream for the streaming
+// endpoint, maxBody for everything else. Handlers see the overrun as a
+// *http.MaxBytesError, see respondBodyError.
+func (s *Server) limitBody(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Body != nil && r.Body != http.NoBody {
+			limit := s.bodyLimit()
+			if r.URL.Path == "/api/v1/validate/stream" {
+				limit = s.streamLimit()
+			}
+			if r.ContentLength > limit {
+				s.respondBodyError(w, "", &http.MaxBytesError{Limit: limit})
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// negotiate - 406 for API requests that won't take JSON, unless the
+// endpoint answers in other formats (exports, certificates, job events)
+func (s *Server) negotiate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/") && !otherFormats(r.URL.Path) && !accepts(r, "application/json") {
+			s.respondError(w, http.StatusNotAcceptable, "This endpoint answers with application/json")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func otherFormats(path string) bool {
+	return path == "/api/v1/export" || path == "/api/v1/certificate" ||
+		(strings.HasPrefix(path, "/api/v1/jobs/") && strings.HasSuffix(path, "/events"))
+}
+
+// accepts - does the request's Accept header take mediaType. No header
+// takes anything; q=0 is read as a refusal.
+func accepts(r *http.Request, mediaType string) bool {
+	header := r.Header.Values("Accept")
+	if len(header) == 0 {
+		return true
+	}
+	major, _, _ := strings.Cut(mediaType, "/")
+	for _, value := range header {
+		for _, part := range strings.Split(value, ",") {
+			t, params, _ := strings.Cut(part, ";")
+			t = strings.ToLower(strings.TrimSpace(t))
+			if strings.ReplaceAll(strings.TrimSpace(params), " ", "") == "q=0" {
+				continue
+			}
+			if t == mediaType || t == "*/*" || t == major+"/*" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// formatFromAccept - the format whose media type the Accept header names
+// first, for when the query doesn't say. "" when it names none of them.
+func formatFromAccept(r *http.Request, types map[string]string) string {
+	for _, value := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(value, ",") {
+			t, _, _ := strings.Cut(part, ";")
+			if format, ok := types[strings.ToLower(strings.TrimSpace(t))]; ok {
+				return format
+			}
+		}
+	}
+	return ""
+}
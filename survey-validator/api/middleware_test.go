@@ -0,0 +1,118 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/survey-validator/wire"
+)
+
+func TestRequestID(t *testing.T) {
+	h := NewServer("").Handler()
+
+	r := httptest.NewRequest(http.MethodGet, "/health", nil)
+	r.Header.Set(wire.RequestIDHeader, "crew-7.upload_1")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	if got := rec.Header().Get(wire.RequestIDHeader); got != "crew-7.upload_1" {
+		t.Errorf("Expected the client's request ID back, got %q", got)
+	}
+
+	// one that could mess up a log line is replaced
+	r.Header.Set(wire.RequestIDHeader, "bad id\nforged=1")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	if got := rec.Header().Get(wire.RequestIDHeader); !validRequestID.MatchString(got) || strings.Contains(got, "forged") {
+		t.Errorf("Expected a fresh request ID, got %q", got)
+	}
+
+	// error bodies carry it too
+	rec = call(t, h, http.MethodGet, "/api/v1/validate", nil)
+	var e wire.ErrorResponse
+	decode(t, rec, &e)
+	if e.RequestID == "" || e.RequestID != rec.Header().Get(wire.RequestIDHeader) {
+		t.Errorf("Expected the error to carry request ID %q, got %q", rec.Header().Get(wire.RequestIDHeader), e.RequestID)
+	}
+}
+
+func TestCORS(t *testing.T) {
+	preflight := func(h http.Handler, origin string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodOptions, "/api/v1/validate", nil)
+		r.Header.Set("Origin", origin)
+		r.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		return rec
+	}
+
+	rec := preflight(NewServer("").Handler(), "https://survey.example")
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Errorf("Expected any origin by default, got %d %q", rec.Code, rec.Header().Get("Access-Control-Allow-Origin"))
+	}
+	if !strings.Contains(rec.Header().Get("Access-Control-Allow-Headers"), APIKeyHeader) {
+		t.Errorf("Expected the API key header to be allowed, got %q", rec.Header().Get("Access-Control-Allow-Headers"))
+	}
+
+	s := NewServer("")
+	s.SetCORSOrigins([]string{"https://survey.example"})
+	h := s.Handler()
+	rec = preflight(h, "https://SURVEY.example")
+	if rec.Header().Get("Access-Control-Allow-Origin") != "https://SURVEY.example" || rec.Header().Get("Vary") != "Origin" {
+		t.Errorf("Expected a listed origin echoed back, got %q vary %q", rec.Header().Get("Access-Control-Allow-Origin"), rec.Header().Get("Vary"))
+	}
+	rec = preflight(h, "https://elsewhere.example")
+	if rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("Expected no CORS headers for an unlisted origin, got %q", rec.Header().Get("Access-Control-Allow-Origin"))
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	h := NewServer("").Handler()
+
+	for accept, want := range map[string]int{
+		"":                             http.StatusOK,
+		"application/json":             http.StatusOK,
+		"text/html, */*;q=0.8":         http.StatusOK,
+		"application/*":                http.StatusOK,
+		"text/html":                    http.StatusNotAcceptable,
+		"application/json;q=0, text/*": http.StatusNotAcceptable,
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/checks", nil)
+		if accept != "" {
+			r.Header.Set("Accept", accept)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		if rec.Code != want {
+			t.Errorf("Accept %q: expected %d, got %d", accept, want, rec.Code)
+		}
+	}
+
+	// exports answer in their own formats, and the web app isn't the API
+	for _, path := range []string{"/api/v1/export", "/"} {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.Header.Set("Accept", "text/html")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		if rec.Code == http.StatusNotAcceptable {
+			t.Errorf("%s: expected no 406 for text/html", path)
+		}
+	}
+}
+
+func TestRecoverPanics(t *testing.T) {
+	s := NewServer("")
+	h := s.recoverPanics(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("checks went wrong")
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/checks", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("Expected 500 from a panic, got %d", rec.Code)
+	}
+	if code := errorCode(t, rec); code != wire.CodeInternal {
+		t.Errorf("Expected %s, got %s", wire.CodeInternal, code)
+	}
+}
@@ -0,0 +1,162 @@
+package api
+
+// monitor.go - deformation monitoring endpoints:
+//
+//	GET    /api/v1/monitor                          projects
+//	GET    /api/v1/monitor/{project}                analysis of all epochs
+//	POST   /api/v1/monitor/{project}/epochs         add an epoch
+//	DELETE /api/v1/monitor/{project}/epochs/{id}    remove one
+//	GET    /api/v1/monitor/{project}/config         baseline, precisions, limits
+//	PUT    /api/v1/monitor/{project}/config
+//
+// Project and epoch IDs are path-escaped, the same as for stored reports.
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/survey-validator/monitor"
+	"github.com/survey-validator/wire"
+)
+
+func (s *Server) handleMonitor(w http.ResponseWriter, r *http.Request) {
+	if s.monitor == nil {
+		s.respondError(w, http.StatusServiceUnavailable, "Monitoring needs a data directory, start the server with -data")
+		return
+	}
+
+	parts, ok := pathParts(r.URL.EscapedPath(), "/api/v1/monitor")
+	if !ok {
+		s.respondError(w, http.StatusBadRequest, "Malformed path")
+		return
+	}
+	if len(parts) > 0 {
+		noteProject(r, parts[0])
+	}
+
+	switch {
+	case len(parts) == 0 && r.Method == http.MethodGet:
+		projects, err := s.monitor.Projects()
+		if err != nil {
+			s.respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		s.respondJSON(w, http.StatusOK, wire.MonitorProjectsResponse{Projects: projects})
+	case len(parts) == 0:
+		s.respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		s.respondMonitorReport(w, parts[0], http.StatusOK)
+	case len(parts) == 2 && parts[1] == "epochs" && r.Method == http.MethodPost:
+		s.handleAddEpoch(w, r, parts[0])
+	case len(parts) == 3 && parts[1] == "epochs" && r.Method == http.MethodDelete:
+		err := s.monitor.DeleteEpoch(parts[0], parts[2])
+		if errors.Is(err, monitor.ErrNotFound) {
+			s.respondError(w, http.StatusNotFound, "No epoch "+parts[2]+" in project "+parts[0])
+			return
+		}
+		if err != nil {
+			s.respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case len(parts) == 2 && parts[1] == "config" && r.Method == http.MethodGet:
+		cfg, err := s.monitor.Config(parts[0])
+		if err != nil {
+			s.respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		s.respondJSON(w, http.StatusOK, wire.MonitorConfig(cfg))
+	case len(parts) == 2 && parts[1] == "config" && r.Method == http.MethodPut:
+		s.handleMonitorConfig(w, r, parts[0])
+	case len(parts) == 1 || (len(parts) == 2 && (parts[1] == "epochs" || parts[1] == "config")) ||
+		(len(parts) == 3 && parts[1] == "epochs"):
+		s.respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	default:
+		s.respondError(w, http.StatusNotFound, "Not found")
+	}
+}
+
+// handleAddEpoch - store an epoch and answer with the updated analysis
+func (s *Server) handleAddEpoch(w http.ResponseWriter, r *http.Request, project string) {
+	defer r.Body.Close()
+
+	var req wire.EpochRequest
+	if !s.decodeJSON(w, r, &req) {
+		return
+	}
+	if req.ObservedAt.IsZero() {
+		s.respondError(w, http.StatusBadRequest, "observed_at is required")
+		return
+	}
+	if len(req.Points) == 0 {
+		s.respondError(w, http.StatusBadRequest, "At least one point is required")
+		return
+	}
+	if req.ID == "" {
+		req.ID = req.ObservedAt.UTC().Format("20060102T150405Z")
+	}
+
+	epoch := &monitor.Epoch{
+		ID:         req.ID,
+		ProjectID:  project,
+		ObservedAt: req.ObservedAt,
+		ReceivedAt: time.Now().UTC(),
+		Notes:      req.Notes,
+		Points:     req.Points,
+	}
+	if err := s.monitor.SaveEpoch(epoch); err != nil {
+		s.respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.respondMonitorReport(w, project, http.StatusCreated)
+}
+
+func (s *Server) handleMonitorConfig(w http.ResponseWriter, r *http.Request, project string) {
+	defer r.Body.Close()
+
+	// fields left out keep their defaults
+	body := wire.MonitorConfig(monitor.DefaultConfig())
+	if !s.decodeJSON(w, r, &body) {
+		return
+	}
+	cfg := monitor.Config(body)
+	if err := cfg.Validate(); err != nil {
+		s.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := s.monitor.SaveConfig(project, cfg); err != nil {
+		s.respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.respondJSON(w, http.StatusOK, body)
+}
+
+func (s *Server) respondMonitorReport(w http.ResponseWriter, project string, status int) {
+	epochs, err := s.monitor.Epochs(project)
+	if errors.Is(err, monitor.ErrNotFound) {
+		s.respondError(w, http.StatusNotFound, "No epochs for project "+project)
+		return
+	}
+	if err != nil {
+		s.respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	cfg, err := s.monitor.Config(project)
+	if err != nil {
+		s.respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	rep, err := monitor.Analyse(project, cfg, epochs)
+	if err != nil {
+		// e.g. the baseline epoch was deleted
+		s.respondError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	if len(rep.Alerts) > 0 {
+		slog.Info("Monitoring alerts", "project_id", project, "alerts", len(rep.Alerts), "epoch", rep.Latest)
+	}
+	s.respondJSON(w, status, wireMonitor(rep))
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/survey-validator/wire"
+)
+
+func TestMonitor(t *testing.T) {
+	h := testServer(t).Handler()
+	const path = "/api/v1/monitor/Dam%20West"
+
+	start := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	for i, dE := range []float64{0, 0.03} {
+		data := testData()
+		data.Points[0].Easting += dE
+		rec := call(t, h, http.MethodPost, path+"/epochs", wire.EpochRequest{ObservedAt: start.AddDate(0, 0, 7*i), Points: data.Points})
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d %s", rec.Code, rec.Body)
+		}
+		var rep wire.MonitorReport
+		decode(t, rec, &rep)
+		if len(rep.Epochs) != i+1 || rep.ProjectID != "Dam West" {
+			t.Errorf("Expected %d epochs of Dam West, got %d of %q", i+1, len(rep.Epochs), rep.ProjectID)
+		}
+	}
+
+	var rep wire.MonitorReport
+	decode(t, call(t, h, http.MethodGet, path, nil), &rep)
+	if rep.Baseline != "20260301T090000Z" || rep.Latest != "20260308T090000Z" {
+		t.Errorf("Expected epochs named after their times, got %s and %s", rep.Baseline, rep.Latest)
+	}
+	if len(rep.Alerts) == 0 || rep.Alerts[0].PointID != "T1" {
+		t.Errorf("Expected a 30mm move of T1 to raise an alert, got %+v", rep.Alerts)
+	}
+
+	var projects wire.MonitorProjectsResponse
+	decode(t, call(t, h, http.MethodGet, "/api/v1/monitor", nil), &projects)
+	if len(projects.Projects) != 1 || projects.Projects[0] != "Dam West" {
+		t.Errorf("Expected [Dam West], got %q", projects.Projects)
+	}
+
+	// config: what's left out keeps its default, bad values are refused
+	var cfg wire.MonitorConfig
+	decode(t, call(t, h, http.MethodGet, path+"/config", nil), &cfg)
+	if cfg.Confidence != 0.95 || cfg.MaxDisplacement != 0.02 {
+		t.Errorf("Expected the default config, got %+v", cfg)
+	}
+	rec := call(t, h, http.MethodPut, path+"/config", `{"max_displacement": 0.05, "max_velocity": 0}`)
+	decode(t, rec, &cfg)
+	if rec.Code != http.StatusOK || cfg.MaxDisplacement != 0.05 || cfg.Confidence != 0.95 {
+		t.Errorf("Expected the new limit over the defaults, got %d %+v", rec.Code, cfg)
+	}
+	decode(t, call(t, h, http.MethodGet, path, nil), &rep)
+	if len(rep.Alerts) != 0 {
+		t.Errorf("Expected no alerts with a 50mm limit and no velocity limit, got %+v", rep.Alerts)
+	}
+	if rec := call(t, h, http.MethodPut, path+"/config", `{"confidence": 2}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a confidence of 2, got %d", rec.Code)
+	}
+
+	if rec := call(t, h, http.MethodDelete, path+"/epochs/20260308T090000Z", nil); rec.Code != http.StatusNoContent {
+		t.Errorf("Expected 204 deleting an epoch, got %d %s", rec.Code, rec.Body)
+	}
+	if rec := call(t, h, http.MethodDelete, path+"/epochs/20260308T090000Z", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 deleting it again, got %d", rec.Code)
+	}
+	decode(t, call(t, h, http.MethodGet, path, nil), &rep)
+	if len(rep.Epochs) != 1 {
+		t.Errorf("Expected 1 epoch left, got %d", len(rep.Epochs))
+	}
+}
+
+func TestMonitor_Errors(t *testing.T) {
+	h := testServer(t).Handler()
+	points := testData().Points
+
+	for name, tt := range map[string]struct {
+		method, path string
+		body         interface{}
+		status       int
+	}{
+		"unknown project": {http.MethodGet, "/api/v1/monitor/nope", nil, http.StatusNotFound},
+		"no observed_at":  {http.MethodPost, "/api/v1/monitor/A/epochs", wire.EpochRequest{Points: points}, http.StatusBadRequest},
+		"no points":       {http.MethodPost, "/api/v1/monitor/A/epochs", `{"observed_at": "2026-03-01T09:00:00Z", "points": []}`, http.StatusBadRequest},
+		"method":          {http.MethodPost, "/api/v1/monitor/A", nil, http.StatusMethodNotAllowed},
+		"path":            {http.MethodGet, "/api/v1/monitor/A/nope", nil, http.StatusNotFound},
+	} {
+		if rec := call(t, h, tt.method, tt.path, tt.body); rec.Code != tt.status {
+			t.Errorf("%s: expected %d, got %d %s", name, tt.status, rec.Code, rec.Body)
+		}
+	}
+
+	// an escaped slash is part of the project ID, not the path
+	rec := call(t, h, http.MethodPost, "/api/v1/monitor/SITE%204%2FB/epochs", wire.EpochRequest{ObservedAt: time.Now(), Points: points})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d %s", rec.Code, rec.Body)
+	}
+	var rep wire.MonitorReport
+	decode(t, rec, &rep)
+	if rep.ProjectID != "SITE 4/B" {
+		t.Errorf("Expected project SITE 4/B, got %q", rep.ProjectID)
+	}
+
+	// no data directory, no monitoring
+	if rec := call(t, NewServer("").Handler(), http.MethodGet, "/api/v1/monitor", nil); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 without a data directory, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,314 @@
+package api
+
+// openapi.go - the OpenAPI document for every endpoint, served at
+// /api/v1/openapi.json. Schemas come from the same structs the handlers
+// decode and encode, and request bodies are checked against them.
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/survey-validator/models"
+	"github.com/survey-validator/openapi"
+	"github.com/survey-validator/wire"
+)
+
+// APIVersion - the version in the OpenAPI document
+const APIVersion = "1.0.0"
+
+// spec - built once, it only depends on the types
+var spec = buildSpec()
+
+// Spec - the OpenAPI document the server describes itself with
+func Spec() *openapi.Document {
+	return spec
+}
+
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	s.respondJSON(w, http.StatusOK, spec)
+}
+
+func buildSpec() *openapi.Document {
+	g := openapi.NewGenerator()
+
+	// what requests must carry; everything else is optional
+	g.Require(models.SurveyData{}, "points")
+	g.Require(models.SurveyPoint{}, "point_id", "easting", "northing")
+	g.Require(models.Suppression{}, "fingerprint", "justification")
+	g.Require(wire.CompareRequest{}, "before", "after")
+	g.Require(wire.CertificateRequest{}, "certificate")
+	g.Require(wire.EpochRequest{}, "observed_at", "points")
+	g.Require(wire.KeyRequest{}, "name")
+
+	g.Name(wire.Project{}, "StoredProject")
+	g.Name(wire.Record{}, "StoredReport")
+
+	g.Enum(models.ValidationStatus(""), "PASS", "WARNING", "FAIL")
+	g.Enum(models.IssueSeverity(""), "error", "warning", "info")
+	g.Enum(wire.JobStatus(""), "queued", "running", "done", "failed", "cancelled")
+	g.Enum(wire.ParamType(""), "number", "integer", "boolean", "string")
+	g.Enum(wire.ImportFormat(""), "gsi", "rw5", "sdr33", "landxml", "csv")
+	// a JSON request with any other survey type is refused; imports and
+	// streamed uploads aren't checked against the schema, and the
+	// input_validation check warns about them there
+	g.Enum(models.SurveyType(""), "traverse", "control", "detail", "")
+	g.Describe(models.SurveyType(""), "traverse, control or detail")
+
+	// the tolerance checks fall back to a default for other values, so
+	// this isn't an enum
+	g.Describe(models.ToleranceClass(""), "first_order, second_order, third_order, engineering or construction")
+	g.Field(models.SurveyPoint{}, "height", func(s *openapi.Schema) { s.Description = "Omitted for 2D points" })
+	g.Field(models.SurveyPoint{}, "code", func(s *openapi.Schema) {
+		s.Description = "Field code, e.g. TREE SPREAD=4 or EP1 START; checked against the code library when one is loaded"
+	})
+	g.Field(models.SurveyPoint{}, "attributes", func(s *openapi.Schema) { s.Description = "Feature attributes by name" })
+	g.Field(models.SurveyPoint{}, "sigma_e", func(s *openapi.Schema) { s.Description = "One-sigma precision in metres" })
+
+	d := openapi.NewDocument(openapi.Info{
+		Title:       "Survey Data Validator",
+		Version:     APIVersion,
+		Description: "Validates survey point data: duplicates, outliers, traverse closure, rules, and more.",
+	}, g)
+
+	fails := func(codes ...string) map[string]*openapi.Response {
+		out := make(map[string]*openapi.Response)
+		for _, code := range codes {
+			out[code] = d.Reply(http.StatusText(statusCode(code)), wire.ErrorResponse{})
+		}
+		return out
+	}
+	with := func(base map[string]*openapi.Response, code string, resp *openapi.Response) map[string]*openapi.Response {
+		base[code] = resp
+		return base
+	}
+	query := func(name, description string) openapi.Parameter {
+		return openapi.Parameter{Name: name, In: "query", Description: description, Schema: &openapi.Schema{Type: "string"}}
+	}
+	binary := func(types ...string) map[string]openapi.MediaType {
+		out := make(map[string]openapi.MediaType)
+		for _, t := range types {
+			out[t] = openapi.MediaType{Schema: &openapi.Schema{Type: "string", Format: "binary"}}
+		}
+		return out
+	}
+	report := d.Reply("The validation report", models.ValidationReport{})
+
+	// keys are only asked for when the server runs with -keys
+	d.Components.SecuritySchemes = map[string]*openapi.SecurityScheme{
+		"bearer": {Type: "http", Scheme: "bearer", Description: "An API key as a bearer token"},
+		"apiKey": {Type: "apiKey", In: "header", Name: APIKeyHeader},
+	}
+	d.Security = []openapi.SecurityRequirement{{"bearer": {}}, {"apiKey": {}}}
+	open := []openapi.SecurityRequirement{{}}
+
+	d.Add("GET", "/health", &openapi.Operation{
+		OperationID: "health", Summary: "Health check", Tags: []string{"meta"}, Security: open,
+		Responses: map[string]*openapi.Response{"200": d.Reply("The service is up", wire.HealthResponse{})},
+	})
+	d.Add("GET", "/health/live", &openapi.Operation{
+		OperationID: "liveness", Summary: "Liveness: the process is up", Tags: []string{"meta"}, Security: open,
+		Responses: map[string]*openapi.Response{"200": d.Reply("The service is up", wire.HealthResponse{})},
+	})
+	d.Add("GET", "/health/ready", &openapi.Operation{
+		OperationID: "readiness", Summary: "Readiness: self-test, storage and job queue", Tags: []string{"meta"}, Security: open,
+		Responses: map[string]*openapi.Response{
+			"200": d.Reply("Ready for work", wire.ReadinessResponse{}),
+			"503": d.Reply("Not ready, see problems", wire.ReadinessResponse{}),
+		},
+	})
+	d.Add("GET", "/health/selftest", &openapi.Operation{
+		OperationID: "selfTest", Summary: "Validate the built-in samples and compare with the known answers", Tags: []string{"meta"}, Security: open,
+		Responses: map[string]*openapi.Response{
+			"200": d.Reply("Every case passed", wire.SelfTestReport{}),
+			"503": d.Reply("A case failed", wire.SelfTestReport{}),
+		},
+	})
+	d.Add("GET", "/api/v1/openapi.json", &openapi.Operation{
+		OperationID: "openapi", Summary: "This document", Tags: []string{"meta"}, Security: open,
+		Responses: map[string]*openapi.Response{"200": {Description: "OpenAPI 3 document", Content: map[string]openapi.MediaType{"application/json": {Schema: &openapi.Schema{Type: "object"}}}}},
+	})
+	d.Add("GET", "/metrics", &openapi.Operation{
+		OperationID: "metrics", Summary: "Prometheus metrics", Tags: []string{"meta"}, Security: open,
+		Description: "Requests and their latency by route, check durations, points processed and reports by status, in the Prometheus text format.",
+		Responses:   map[string]*openapi.Response{"200": {Description: "Prometheus text format 0.0.4", Content: map[string]openapi.MediaType{"text/plain": {Schema: &openapi.Schema{Type: "string"}}}}},
+	})
+	d.Add("GET", "/api/v1/checks", &openapi.Operation{
+		OperationID: "listChecks", Summary: "The checks the engine runs and their settings", Tags: []string{"validate"},
+		Responses: map[string]*openapi.Response{"200": d.Reply("Checks in run order", wire.ChecksResponse{})},
+	})
+	d.Add("POST", "/api/v1/validate", &openapi.Operation{
+		OperationID: "validate", Summary: "Validate survey data", Tags: []string{"validate"},
+		RequestBody: d.Body(wire.ValidateBody{}),
+		Responses:   with(fails("400", "413", "503"), "200", report),
+	})
+	d.Add("POST", "/api/v1/validate/stream", &openapi.Operation{
+		OperationID: "validateStream", Summary: "Validate a large JSON or CSV upload without holding it", Tags: []string{"validate"},
+		Description: "The body is a validate body (checks and suppressions are ignored), a bare array of points, or CSV.",
+		Parameters: []openapi.Parameter{
+			query("format", "csv to read the body as CSV whatever its Content-Type"),
+			query("project_id", ""), query("coordinate_system", ""),
+			query("enable", "Comma-separated checks to run, default all"),
+			query("disable", "Comma-separated checks to skip"),
+			query("store", "true to keep the report under project_id"),
+			query("linear_unit", "CSV only: m, mm, cm, km, ft, us_ft, in or mi"),
+			query("codes", "CSV only: code map, e.g. CP:control,TR:traverse"),
+		},
+		RequestBody: &openapi.RequestBody{Required: true, Content: map[string]openapi.MediaType{
+			"application/json": {Schema: d.JSON(wire.ValidateBody{})["application/json"].Schema},
+			"text/csv":         {Schema: &openapi.Schema{Type: "string"}},
+		}},
+		Responses: with(fails("400", "413", "503"), "200", report),
+	})
+	d.Add("POST", "/api/v1/import", &openapi.Operation{
+		OperationID: "importRaw", Summary: "Import a raw field book", Tags: []string{"formats"},
+		Parameters: []openapi.Parameter{
+			query("format", "gsi, rw5, sdr33, landxml or csv"),
+			query("filename", "Detect the format from the extension instead"),
+			query("project_id", ""), query("coordinate_system", ""),
+			query("linear_unit", "m, mm, cm, km, ft, us_ft, in or mi, when the file doesn't say"),
+			query("angle_unit", "deg, dms, gon, mil or rad, when the file doesn't say"),
+			query("codes", "Code map, e.g. CP:control,TR:traverse"),
+			query("validate", "true to validate the result too"),
+			query("store", "true to keep the dataset and report under project_id, with validate"),
+		},
+		RequestBody: &openapi.RequestBody{Required: true, Content: map[string]openapi.MediaType{
+			"text/plain": {Schema: &openapi.Schema{Type: "string"}},
+		}},
+		Responses: with(fails("400", "413", "503"), "200", d.Reply("The reduced job", wire.ImportResponse{})),
+	})
+	d.Add("POST", "/api/v1/export", &openapi.Operation{
+		OperationID: "export", Summary: "Validate and export", Tags: []string{"formats"},
+		Description: "The format comes from format=, or from Accept when that is left out.",
+		Parameters:  []openapi.Parameter{query("format", "landxml (default), geojson, kml or dxf")},
+		RequestBody: d.Body(wire.ExportRequest{}),
+		Responses: with(fails("400", "413", "422", "503"), "200", &openapi.Response{
+			Description: "The export, as an attachment",
+			Content:     binary("application/xml", "application/geo+json", "application/vnd.google-earth.kml+xml", "application/dxf"),
+		}),
+	})
+	d.Add("POST", "/api/v1/certificate", &openapi.Operation{
+		OperationID: "certificate", Summary: "QC certificate", Tags: []string{"formats"},
+		Parameters:  []openapi.Parameter{query("format", "html (default) or pdf")},
+		RequestBody: d.Body(wire.CertificateRequest{}),
+		Responses: with(fails("400", "413", "503"), "200", &openapi.Response{
+			Description: "The certificate", Content: binary("text/html", "application/pdf"),
+		}),
+	})
+	d.Add("POST", "/api/v1/compare", &openapi.Operation{
+		OperationID: "compare", Summary: "What moved between two versions of a dataset", Tags: []string{"compare"},
+		RequestBody: d.Body(wire.CompareRequest{}),
+		Responses:   with(fails("400", "413"), "200", d.Reply("Displacements and the best-fit transform", wire.CompareResult{})),
+	})
+
+	job := d.Reply("The job", wire.Job{})
+	d.Add("POST", "/api/v1/jobs", &openapi.Operation{
+		OperationID: "submitJob", Summary: "Validate in the background", Tags: []string{"jobs"},
+		RequestBody: d.Body(wire.ValidateBody{}),
+		Responses:   with(fails("400", "413", "503"), "202", d.Reply("Queued; Location has the job's URL", wire.Job{})),
+	})
+	d.Add("GET", "/api/v1/jobs/{id}", &openapi.Operation{
+		OperationID: "getJob", Summary: "A job's status and progress", Tags: []string{"jobs"},
+		Responses: with(fails("404", "503"), "200", job),
+	})
+	d.Add("DELETE", "/api/v1/jobs/{id}", &openapi.Operation{
+		OperationID: "cancelJob", Summary: "Cancel a job", Tags: []string{"jobs"},
+		Responses: with(fails("404", "503"), "200", job),
+	})
+	d.Add("GET", "/api/v1/jobs/{id}/report", &openapi.Operation{
+		OperationID: "getJobReport", Summary: "A finished job's report", Tags: []string{"jobs"},
+		Responses: with(fails("404", "409", "503"), "200", report),
+	})
+	d.Add("GET", "/api/v1/jobs/{id}/events", &openapi.Operation{
+		OperationID: "watchJob", Summary: "Progress as server-sent events", Tags: []string{"jobs"},
+		Description: "One event per change, named after the job's status, with the job as JSON data.",
+		Responses: with(fails("404", "503"), "200", &openapi.Response{
+			Description: "Event stream", Content: map[string]openapi.MediaType{"text/event-stream": {Schema: &openapi.Schema{Type: "string"}}},
+		}),
+	})
+
+	deleted := &openapi.Response{Description: "Deleted"}
+	d.Add("GET", "/api/v1/projects", &openapi.Operation{
+		OperationID: "listProjects", Summary: "Projects with stored reports", Tags: []string{"projects"},
+		Responses: with(fails("503"), "200", d.Reply("Projects by ID", wire.ProjectsResponse{})),
+	})
+	d.Add("GET", "/api/v1/projects/{project}", &openapi.Operation{
+		OperationID: "getProject", Summary: "A project's reports, oldest first", Tags: []string{"projects"},
+		Responses: with(fails("404", "503"), "200", d.Reply("The project", wire.Project{})),
+	})
+	d.Add("DELETE", "/api/v1/projects/{project}", &openapi.Operation{
+		OperationID: "deleteProject", Summary: "Delete a project and its reports", Tags: []string{"projects"},
+		Responses: with(fails("404", "503"), "204", deleted),
+	})
+	d.Add("GET", "/api/v1/projects/{project}/reports/{id}", &openapi.Operation{
+		OperationID: "getReport", Summary: "A stored dataset and its report", Tags: []string{"projects"},
+		Responses: with(fails("404", "503"), "200", d.Reply("The record", wire.Record{})),
+	})
+	d.Add("DELETE", "/api/v1/projects/{project}/reports/{id}", &openapi.Operation{
+		OperationID: "deleteReport", Summary: "Delete a stored report", Tags: []string{"projects"},
+		Responses: with(fails("404", "503"), "204", deleted),
+	})
+
+	analysis := d.Reply("The monitoring analysis", wire.MonitorReport{})
+	d.Add("GET", "/api/v1/monitor", &openapi.Operation{
+		OperationID: "listMonitorProjects", Summary: "Projects with monitoring epochs", Tags: []string{"monitor"},
+		Responses: with(fails("503"), "200", d.Reply("Project IDs", wire.MonitorProjectsResponse{})),
+	})
+	d.Add("GET", "/api/v1/monitor/{project}", &openapi.Operation{
+		OperationID: "getMonitor", Summary: "Displacements, significance and alerts", Tags: []string{"monitor"},
+		Responses: with(fails("400", "404", "422", "503"), "200", analysis),
+	})
+	d.Add("POST", "/api/v1/monitor/{project}/epochs", &openapi.Operation{
+		OperationID: "addEpoch", Summary: "Add an epoch", Tags: []string{"monitor"},
+		RequestBody: d.Body(wire.EpochRequest{}),
+		Responses:   with(fails("400", "413", "422", "503"), "201", analysis),
+	})
+	d.Add("DELETE", "/api/v1/monitor/{project}/epochs/{id}", &openapi.Operation{
+		OperationID: "deleteEpoch", Summary: "Delete an epoch", Tags: []string{"monitor"},
+		Responses: with(fails("400", "404", "503"), "204", deleted),
+	})
+	d.Add("GET", "/api/v1/monitor/{project}/config", &openapi.Operation{
+		OperationID: "getMonitorConfig", Summary: "A project's baseline, precisions and limits", Tags: []string{"monitor"},
+		Responses: with(fails("400", "503"), "200", d.Reply("The config", wire.MonitorConfig{})),
+	})
+	d.Add("PUT", "/api/v1/monitor/{project}/config", &openapi.Operation{
+		OperationID: "setMonitorConfig", Summary: "Replace a project's config", Tags: []string{"monitor"},
+		Description: "Fields left out keep their defaults.",
+		RequestBody: d.Body(wire.MonitorConfig{}),
+		Responses:   with(fails("400", "413", "503"), "200", d.Reply("The saved config", wire.MonitorConfig{})),
+	})
+
+	d.Add("GET", "/api/v1/keys", &openapi.Operation{
+		OperationID: "listKeys", Summary: "API keys, for admin keys", Tags: []string{"keys"},
+		Responses: with(fails("403", "503"), "200", d.Reply("Keys, oldest first", wire.KeysResponse{})),
+	})
+	d.Add("POST", "/api/v1/keys", &openapi.Operation{
+		OperationID: "createKey", Summary: "Make an API key, for admin keys", Tags: []string{"keys"},
+		Description: "The secret is in this answer and can't be fetched again.",
+		RequestBody: d.Body(wire.KeyRequest{}),
+		Responses:   with(fails("400", "403", "503"), "201", d.Reply("The key and its secret", wire.CreatedKeyResponse{})),
+	})
+	d.Add("DELETE", "/api/v1/keys/{id}", &openapi.Operation{
+		OperationID: "deleteKey", Summary: "Revoke an API key, for admin keys", Tags: []string{"keys"},
+		Responses: with(fails("403", "404", "503"), "204", deleted),
+	})
+
+	// with keys on, any API call can be refused for the key
+	for _, item := range d.Paths {
+		for _, op := range *item {
+			if op.Security == nil {
+				op.Responses["401"] = d.Reply("No API key, or not a known one", wire.ErrorResponse{})
+				op.Responses["429"] = d.Reply("The key is over its rate limit or daily quota", wire.ErrorResponse{})
+			}
+		}
+	}
+	return d
+}
+
+func statusCode(code string) int {
+	n, _ := strconv.Atoi(code)
+	return n
+}
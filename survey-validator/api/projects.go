@@ -0,0 +1,91 @@
+package api
+
+// projects.go - stored validations:
+//
+//	GET    /api/v1/projects                          projects, with their latest report
+//	GET    /api/v1/projects/{project}                the project's reports
+//	DELETE /api/v1/projects/{project}
+//	GET    /api/v1/projects/{project}/reports/{id}   dataset and report as validated
+//	DELETE /api/v1/projects/{project}/reports/{id}
+//
+// Project IDs are path-escaped, so "SITE 4/B" is SITE%204%2FB.
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/survey-validator/store"
+	"github.com/survey-validator/wire"
+)
+
+func (s *Server) handleProjects(w http.ResponseWriter, r *http.Request) {
+	if s.store == nil {
+		s.respondError(w, http.StatusServiceUnavailable, "Report storage needs a data directory, start the server with -data")
+		return
+	}
+
+	parts, ok := pathParts(r.URL.EscapedPath(), "/api/v1/projects")
+	if !ok {
+		s.respondError(w, http.StatusBadRequest, "Malformed path")
+		return
+	}
+	if len(parts) > 0 {
+		noteProject(r, parts[0])
+	}
+
+	var err error
+	switch {
+	case len(parts) == 0 && r.Method == http.MethodGet:
+		var projects []store.ProjectSummary
+		if projects, err = s.store.Projects(); err == nil {
+			s.respondJSON(w, http.StatusOK, wire.ProjectsResponse{Projects: wireProjects(projects)})
+		}
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		var p *store.Project
+		if p, err = s.store.Project(parts[0]); err == nil {
+			s.respondJSON(w, http.StatusOK, wireProject(p))
+		}
+	case len(parts) == 1 && r.Method == http.MethodDelete:
+		if err = s.store.DeleteProject(parts[0]); err == nil {
+			w.WriteHeader(http.StatusNoContent)
+		}
+	case len(parts) == 3 && parts[1] == "reports" && r.Method == http.MethodGet:
+		var rec *store.Record
+		if rec, err = s.store.Report(parts[0], parts[2]); err == nil {
+			s.respondJSON(w, http.StatusOK, wire.Record(*rec))
+		}
+	case len(parts) == 3 && parts[1] == "reports" && r.Method == http.MethodDelete:
+		if err = s.store.DeleteReport(parts[0], parts[2]); err == nil {
+			w.WriteHeader(http.StatusNoContent)
+		}
+	case len(parts) <= 1 || (len(parts) == 3 && parts[1] == "reports"):
+		s.respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	default:
+		s.respondError(w, http.StatusNotFound, "Not found")
+	}
+
+	if errors.Is(err, store.ErrNotFound) {
+		s.respondError(w, http.StatusNotFound, "Not found")
+	} else if err != nil {
+		s.respondError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// pathParts - the unescaped segments of an escaped path after prefix
+func pathParts(escaped, prefix string) ([]string, bool) {
+	rest := strings.Trim(strings.TrimPrefix(escaped, prefix), "/")
+	if rest == "" {
+		return nil, true
+	}
+	parts := strings.Split(rest, "/")
+	for i, p := range parts {
+		v, err := url.PathUnescape(p)
+		if err != nil || v == "" {
+			return nil, false
+		}
+		parts[i] = v
+	}
+	return parts, true
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/survey-validator/models"
+	"github.com/survey-validator/wire"
+)
+
+func TestProjects(t *testing.T) {
+	h := testServer(t).Handler()
+	data := testData()
+	data.ProjectID = "SITE 4/B"
+	const path = "/api/v1/projects/SITE%204%2FB"
+
+	// nothing is kept unless asked for
+	var report models.ValidationReport
+	decode(t, call(t, h, http.MethodPost, "/api/v1/validate", wire.ValidateBody{SurveyData: data}), &report)
+	if report.ReportID != "" {
+		t.Errorf("Expected no report ID without store, got %s", report.ReportID)
+	}
+	var list wire.ProjectsResponse
+	decode(t, call(t, h, http.MethodGet, "/api/v1/projects", nil), &list)
+	if len(list.Projects) != 0 {
+		t.Fatalf("Expected no projects, got %+v", list.Projects)
+	}
+
+	rec := call(t, h, http.MethodPost, "/api/v1/validate", wire.ValidateBody{SurveyData: data, Store: true})
+	decode(t, rec, &report)
+	if rec.Code != http.StatusOK || report.ReportID == "" {
+		t.Fatalf("Expected a stored report, got %d %s", rec.Code, rec.Body)
+	}
+	first := report.ReportID
+	rec = call(t, h, http.MethodPost, "/api/v1/export?format=geojson", wire.ExportRequest{SurveyData: data, Store: true})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected the export, got %d %s", rec.Code, rec.Body)
+	}
+
+	decode(t, call(t, h, http.MethodGet, "/api/v1/projects", nil), &list)
+	if len(list.Projects) != 1 || list.Projects[0].ID != "SITE 4/B" || list.Projects[0].Reports != 2 || list.Projects[0].Latest == nil {
+		t.Fatalf("Expected SITE 4/B with 2 reports, got %+v", list.Projects)
+	}
+	var project wire.Project
+	decode(t, call(t, h, http.MethodGet, path, nil), &project)
+	if len(project.Reports) != 2 || project.Reports[0].ID != first || project.Reports[0].Points != 4 {
+		t.Errorf("Expected the validation first, got %+v", project.Reports)
+	}
+	var record wire.Record
+	decode(t, call(t, h, http.MethodGet, path+"/reports/"+first, nil), &record)
+	if record.ProjectID != "SITE 4/B" || len(record.Data.Points) != 4 || record.Report.ReportID != first {
+		t.Errorf("Expected the dataset and report as validated, got %+v", record)
+	}
+
+	if rec := call(t, h, http.MethodDelete, path+"/reports/"+first, nil); rec.Code != http.StatusNoContent {
+		t.Errorf("Expected 204, got %d %s", rec.Code, rec.Body)
+	}
+	if rec := call(t, h, http.MethodGet, path+"/reports/"+first, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a deleted report, got %d", rec.Code)
+	}
+	if rec := call(t, h, http.MethodDelete, path, nil); rec.Code != http.StatusNoContent {
+		t.Errorf("Expected 204, got %d %s", rec.Code, rec.Body)
+	}
+	if rec := call(t, h, http.MethodGet, path, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a deleted project, got %d", rec.Code)
+	}
+}
+
+func TestProjects_StoreOnRequest(t *testing.T) {
+	h := testServer(t).Handler()
+	csv := "point_id,easting,northing\nA,100,100\nB,200,200\n"
+
+	// imports and streamed uploads ask in the query
+	rec := call(t, h, http.MethodPost, "/api/v1/import?format=csv&project_id=IMP&validate=true&store=true", csv)
+	var imported wire.ImportResponse
+	decode(t, rec, &imported)
+	if rec.Code != http.StatusOK || imported.Report == nil || imported.Report.ReportID == "" {
+		t.Errorf("Expected a stored import report, got %d %s", rec.Code, rec.Body)
+	}
+	rec = call(t, h, http.MethodPost, "/api/v1/validate/stream?format=csv&project_id=STREAM&store=true", csv)
+	var streamed models.ValidationReport
+	decode(t, rec, &streamed)
+	if rec.Code != http.StatusOK || streamed.ReportID == "" {
+		t.Errorf("Expected a stored streamed report, got %d %s", rec.Code, rec.Body)
+	}
+	var record wire.Record
+	decode(t, call(t, h, http.MethodGet, "/api/v1/projects/STREAM/reports/"+streamed.ReportID, nil), &record)
+	if record.Data != nil || record.Report == nil {
+		t.Errorf("Expected a streamed record without its points, got %+v", record)
+	}
+
+	for name, tt := range map[string]struct {
+		target string
+		body   interface{}
+		status int
+	}{
+		"no project ID":       {"/api/v1/validate", wire.ValidateBody{SurveyData: models.SurveyData{Points: testData().Points}, Store: true}, http.StatusBadRequest},
+		"stream, no project":  {"/api/v1/validate/stream?format=csv&store=true", csv, http.StatusBadRequest},
+		"import, no validate": {"/api/v1/import?format=csv&project_id=IMP&store=true", csv, http.StatusBadRequest},
+	} {
+		if rec := call(t, h, http.MethodPost, tt.target, tt.body); rec.Code != tt.status {
+			t.Errorf("%s: expected %d, got %d %s", name, tt.status, rec.Code, rec.Body)
+		}
+	}
+
+	var list wire.ProjectsResponse
+	decode(t, call(t, h, http.MethodGet, "/api/v1/projects", nil), &list)
+	if len(list.Projects) != 2 {
+		t.Errorf("Expected IMP and STREAM only, got %+v", list.Projects)
+	}
+
+	// without a data directory asking to store fails rather than quietly
+	// not storing
+	plain := NewServer("").Handler()
+	rec = call(t, plain, http.MethodPost, "/api/v1/validate", wire.ValidateBody{SurveyData: testData(), Store: true})
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), "data directory") {
+		t.Errorf("Expected 503 storing without a data directory, got %d %s", rec.Code, rec.Body)
+	}
+	if rec := call(t, plain, http.MethodGet, "/api/v1/projects", nil); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 listing without a data directory, got %d", rec.Code)
+	}
+}
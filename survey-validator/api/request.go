@@ -1,11 +1,13 @@
 package api
 
 import (
-	"encoding/json"
-	"fmt"
+	"io"
 	"net/http"
 
+	"github.com/survey-validator/engine"
 	"github.com/survey-validator/models"
+	"github.com/survey-validator/openapi"
+	"github.com/survey-validator/wire"
 )
 
 // ValidationRequest represents the request body for validation
@@ -20,28 +22,55 @@ type ValidationResponse struct {
 	*models.ValidationReport
 }
 
-// ValidateRequest validates the incoming request
+// validateOptions - a validate body's engine options
+func validateOptions(b *wire.ValidateBody) engine.Options {
+	opts := selectionOptions(b.Checks)
+	opts.Suppressions = b.Suppressions
+	return opts
+}
+
+// selectionOptions - a check selection as engine options
+func selectionOptions(c *wire.CheckSelection) engine.Options {
+	if c == nil {
+		return engine.Options{}
+	}
+	return engine.Options{Enable: c.Enable, Disable: c.Disable, Config: checkConfigs(c.Config), Severity: c.Severity}
+}
+
+// exportOptions - an export or certificate request's engine options
+func exportOptions(req *wire.ExportRequest) engine.Options {
+	opts := selectionOptions(req.Checks)
+	opts.Traverse = req.Traverse
+	opts.Suppressions = req.Suppressions
+	return opts
+}
+
+// ValidateRequest validates the incoming request. Errors are *APIError,
+// with the same codes and details the server answers with.
 func ValidateRequest(r *http.Request) (*models.SurveyData, error) {
 	if r.Method != http.MethodPost {
-		return nil, fmt.Errorf("method not allowed: %s", r.Method)
+		return nil, &APIError{Status: http.StatusMethodNotAllowed, Code: CodeMethodNotAllowed, Message: "method not allowed: " + r.Method}
 	}
 
 	if r.Body == nil {
-		return nil, fmt.Errorf("request body is required")
+		return nil, &APIError{Status: http.StatusBadRequest, Code: CodeBadRequest, Message: "request body is required"}
 	}
 
-	var req ValidationRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		return nil, fmt.Errorf("invalid JSON: %w", err)
+	raw, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, &APIError{Status: http.StatusBadRequest, Code: CodeBadRequest, Message: "reading body: " + err.Error()}
+	}
+	// the same fields as ValidationRequest, and SurveyData has a schema
+	var data models.SurveyData
+	if e := parseJSON(raw, &data); e != nil {
+		return nil, e
 	}
 
-	if len(req.Points) == 0 {
-		return nil, fmt.Errorf("at least one point is required")
+	if len(data.Points) == 0 {
+		return nil, &APIError{Status: http.StatusBadRequest, Code: CodeInvalidRequest, Message: "at least one point is required", Details: []wire.ErrorDetail{
+			{Code: openapi.CodeTooFewItems, Message: "needs at least 1 item(s)", Pointer: "/points"},
+		}}
 	}
 
-	return &models.SurveyData{
-		ProjectID:        req.ProjectID,
-		CoordinateSystem: req.CoordinateSystem,
-		Points:           req.Points,
-	}, nil
+	return &data, nil
 }
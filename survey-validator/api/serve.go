@@ -0,0 +1,156 @@
+package api
+
+// serve.go - running the server: timeouts, TLS, and a graceful shutdown
+// that lets requests and background jobs finish before the process exits
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/survey-validator/engine"
+)
+
+// Timeouts - how long the server waits on clients, and on itself when it
+// shuts down. Zero means no limit, except Shutdown where it keeps the
+// default.
+type Timeouts struct {
+	ReadHeader time.Duration // for a request's headers
+	Read       time.Duration // for a whole request, body included
+	Write      time.Duration // from the end of the headers to the end of the answer
+	Idle       time.Duration // a keep-alive connection between requests
+	Shutdown   time.Duration // for requests and jobs to finish on shutdown
+}
+
+// DefaultTimeouts - long enough for a big validation, short enough that a
+// stalled client doesn't hold a connection for ever. Streamed uploads and
+// job event streams aren't held to Read and Write.
+var DefaultTimeouts = Timeouts{
+	ReadHeader: 10 * time.Second,
+	Read:       2 * time.Minute,
+	Write:      5 * time.Minute,
+	Idle:       2 * time.Minute,
+	Shutdown:   30 * time.Second,
+}
+
+// SetTimeouts - replace DefaultTimeouts
+func (s *Server) SetTimeouts(t Timeouts) {
+	if t.Shutdown <= 0 {
+		t.Shutdown = DefaultTimeouts.Shutdown
+	}
+	s.timeouts = t
+}
+
+// SetTLS - serve HTTPS with the PEM certificate and key in these files
+func (s *Server) SetTLS(certFile, keyFile string) {
+	s.tlsCert, s.tlsKey = certFile, keyFile
+}
+
+// SetStaticDir - serve the web app from dir rather than the copy built
+// into the binary, e.g. to work on it without rebuilding
+func (s *Server) SetStaticDir(dir string) {
+	s.staticDir = dir
+}
+
+// SetProfile - the validation profile for requests, which don't pick one
+// themselves
+func (s *Server) SetProfile(p engine.Profile) {
+	s.profile = &p
+}
+
+// withProfile - opts with the server's profile
+func (s *Server) withProfile(opts engine.Options) engine.Options {
+	if opts.Profile == nil {
+		opts.Profile = s.profile
+	}
+	return opts
+}
+
+// Start - Run until the process is killed
+func (s *Server) Start() error {
+	return s.Run(context.Background())
+}
+
+// Run - listen on the server's address and Serve
+func (s *Server) Run(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, ln)
+}
+
+// Serve - answer requests on ln until ctx ends, then shut down: stop
+// accepting connections, end job event streams, and wait up to the
+// Shutdown timeout for requests in flight and background jobs (queued
+// ones included) to finish. Jobs still running then are cancelled. Returns
+// nil after a clean shutdown. A server serves once.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	workers := s.workers
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+	s.jobs = engine.NewPool(s.engine, workers, engine.DefaultQueueSize)
+	defer s.jobs.Close()
+
+	if rep := s.startupSelfTest(); !rep.Passed {
+		slog.Error("Self-test failed, /health/ready will say not ready", "results", rep.Results)
+	}
+
+	t := s.timeouts
+	srv := &http.Server{
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: t.ReadHeader,
+		ReadTimeout:       t.Read,
+		WriteTimeout:      t.Write,
+		IdleTimeout:       t.Idle,
+		ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+	}
+	errs := make(chan error, 1)
+	go func() {
+		if s.tlsCert != "" {
+			slog.Info("Starting server", "addr", ln.Addr().String(), "tls", true)
+			errs <- srv.ServeTLS(ln, s.tlsCert, s.tlsKey)
+		} else {
+			slog.Info("Starting server", "addr", ln.Addr().String())
+			errs <- srv.Serve(ln)
+		}
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("Shutting down, waiting for requests and jobs", "timeout", t.Shutdown.String())
+	s.draining.Store(true)
+	close(s.shutdown)
+	sctx, cancel := context.WithTimeout(context.Background(), t.Shutdown)
+	defer cancel()
+	if err := srv.Shutdown(sctx); err != nil {
+		slog.Warn("Requests still running at the shutdown timeout", "error", err)
+		srv.Close()
+	}
+	if err := s.jobs.Drain(sctx); err != nil {
+		slog.Warn("Jobs still running at the shutdown timeout were cancelled", "error", err)
+	}
+	if err := <-errs; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	slog.Info("Shut down")
+	return nil
+}
+
+// noDeadlines - lift the server's read and write timeouts for a request
+// that is meant to run long, like an event stream or a multi-gigabyte
+// upload. Its size limit still applies.
+func noDeadlines(w http.ResponseWriter) {
+	rc := http.NewResponseController(w)
+	rc.SetReadDeadline(time.Time{})
+	rc.SetWriteDeadline(time.Time{})
+}
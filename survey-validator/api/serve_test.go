@@ -0,0 +1,201 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/survey-validator/models"
+)
+
+// serve - s on a port of its own, shut down by the returned cancel; done
+// gets what Serve returned
+func serve(t *testing.T, s *Server) (addr string, cancel func(), done <-chan error) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(ctx, ln) }()
+	t.Cleanup(cancel)
+	return ln.Addr().String(), cancel, served
+}
+
+// inFlight - wait for the server at addr to have n requests in flight,
+// counting the one asking
+func inFlight(t *testing.T, addr string, n int) {
+	t.Helper()
+	want := fmt.Sprintf("survey_validator_http_requests_in_flight %d", n)
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(5 * time.Millisecond) {
+		resp, err := http.Get("http://" + addr + "/metrics")
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if strings.Contains(string(body), want) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d requests in flight", n)
+		}
+	}
+}
+
+// upload - stream what's written to the returned pipe to the server at
+// addr, the answer's status and point count to result when it's done
+func upload(addr string) (*io.PipeWriter, <-chan error) {
+	pr, pw := io.Pipe()
+	result := make(chan error, 1)
+	go func() {
+		resp, err := http.Post("http://"+addr+"/api/v1/validate/stream", "application/json", pr)
+		if err != nil {
+			result <- err
+			return
+		}
+		defer resp.Body.Close()
+		var report models.ValidationReport
+		if err := json.NewDecoder(resp.Body).Decode(&report); err != nil || resp.StatusCode != http.StatusOK || report.Summary.TotalPoints != 2 {
+			result <- fmt.Errorf("expected 2 points, got %d %+v %v", resp.StatusCode, report.Summary, err)
+			return
+		}
+		result <- nil
+	}()
+	return pw, result
+}
+
+func TestServe_GracefulShutdown(t *testing.T) {
+	addr, shutdown, served := serve(t, NewServer(""))
+
+	// an upload that is still arriving when the shutdown starts
+	pw, result := upload(addr)
+	fmt.Fprint(pw, `{"project_id": "DRAIN", "points": [{"point_id": "A", "easting": 1000, "northing": 1000},`)
+	inFlight(t, addr, 2)
+
+	shutdown()
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case err := <-served:
+		t.Fatalf("Expected Serve to wait for the upload, it returned %v", err)
+	default:
+	}
+	if _, err := http.Get("http://" + addr + "/health"); err == nil {
+		t.Error("Expected new connections to be refused while shutting down")
+	}
+
+	fmt.Fprint(pw, `{"point_id": "B", "easting": 1100, "northing": 1000}]}`)
+	pw.Close()
+	if err := <-result; err != nil {
+		t.Errorf("Expected the upload to finish, got %v", err)
+	}
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("Expected a clean shutdown, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve didn't return after the last request")
+	}
+}
+
+func TestServe_Timeouts(t *testing.T) {
+	s := NewServer("")
+	s.SetTimeouts(Timeouts{ReadHeader: 100 * time.Millisecond, Read: 200 * time.Millisecond})
+	if s.timeouts.Shutdown != DefaultTimeouts.Shutdown {
+		t.Errorf("Expected the default shutdown timeout, got %s", s.timeouts.Shutdown)
+	}
+	addr, _, _ := serve(t, s)
+
+	// a client that stalls in its headers is dropped
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	fmt.Fprint(conn, "GET /health HTTP/1.1\r\nHost: x\r\n")
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := bufio.NewReader(conn).ReadString('\n'); err == nil || strings.Contains(err.Error(), "timeout") {
+		t.Errorf("Expected the server to hang up, got %v", err)
+	}
+
+	// a streamed upload isn't held to the read timeout
+	pw, result := upload(addr)
+	fmt.Fprint(pw, `[{"point_id": "A", "easting": 1000, "northing": 1000},`)
+	time.Sleep(400 * time.Millisecond)
+	fmt.Fprint(pw, `{"point_id": "B", "easting": 1100, "northing": 1000}]`)
+	pw.Close()
+	if err := <-result; err != nil {
+		t.Errorf("Expected the slow upload to finish, got %v", err)
+	}
+}
+
+func TestServe_TLS(t *testing.T) {
+	certFile, keyFile, pool := selfSigned(t, t.TempDir())
+	s := NewServer("")
+	s.SetTLS(certFile, keyFile)
+	addr, _, _ := serve(t, s)
+
+	c := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	resp, err := c.Get("https://" + addr + "/health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected health over HTTPS, got %d", resp.StatusCode)
+	}
+	if resp, err := http.Get("http://" + addr + "/health"); err == nil && resp.StatusCode == http.StatusOK {
+		t.Error("Expected plain HTTP to fail on the TLS port")
+	}
+}
+
+// selfSigned - a certificate for 127.0.0.1 in dir, and a pool trusting it
+func selfSigned(t *testing.T, dir string) (certFile, keyFile string, pool *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600)
+
+	cert, _ := x509.ParseCertificate(der)
+	pool = x509.NewCertPool()
+	pool.AddCert(cert)
+	return certFile, keyFile, pool
+}
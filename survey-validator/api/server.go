@@ -1,91 +1,465 @@
 package api
 
 import (
+	"bytes"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/survey-validator/auth"
+	"github.com/survey-validator/certificate"
+	"github.com/survey-validator/codes"
+	"github.com/survey-validator/compare"
+	"github.com/survey-validator/domain"
 	"github.com/survey-validator/engine"
+	"github.com/survey-validator/formats"
 	"github.com/survey-validator/models"
+	"github.com/survey-validator/monitor"
+	"github.com/survey-validator/rules"
+	"github.com/survey-validator/selftest"
+	"github.com/survey-validator/store"
+	"github.com/survey-validator/wire"
 )
 
 type Server struct {
-	engine *engine.Engine
-	addr   string
+	engine  *engine.Engine
+	addr    string
+	monitor *monitor.FileStore // nil until SetDataDir
+	store   *store.FileStore
+	jobs    *engine.Pool // started by Serve
+	workers int
+	profile *engine.Profile // nil for the default
+
+	staticDir string // "" for the embedded web app
+	timeouts  Timeouts
+	tlsCert   string // HTTPS when set, see SetTLS
+	tlsKey    string
+	draining  atomic.Bool   // Serve is shutting down
+	shutdown  chan struct{} // closed when it starts to
+
+	maxBody     int64 // request body limits, see SetLimits
+	maxStream   int64
+	corsOrigins []string // any origin when empty
+
+	keys    *auth.Keyring // nil for no auth, see SetKeyFile
+	limiter *auth.Limiter
+	audit   *auth.AuditLog // nil for no audit log
+
+	metrics *serverMetrics
+
+	started  time.Time
+	selfTest struct { // see startupSelfTest
+		once   sync.Once
+		report selftest.Report
+	}
 }
 
 func NewServer(addr string) *Server {
-	return &Server{
-		engine: engine.NewEngine(),
-		addr:   addr,
+	s := &Server{
+		engine:  engine.NewEngine(),
+		addr:    addr,
+		metrics: newServerMetrics(),
+		started: time.Now(),
+
+		timeouts: DefaultTimeouts,
+		shutdown: make(chan struct{}),
+	}
+	s.engine.OnCheck = s.metrics.observeCheck
+	s.engine.OnReport = s.metrics.observeReport
+	return s
+}
+
+// LoadRules - add the rules in a JSON rules file as extra checks
+func (s *Server) LoadRules(path string) error {
+	list, err := rules.LoadFile(path)
+	if err != nil {
+		return err
 	}
+	return rules.Register(s.engine, list)
 }
 
-func (s *Server) Start() error {
-	mux := http.NewServeMux()
-	mux.Handle("/", http.FileServer(http.Dir("static")))
-	mux.HandleFunc("/health", s.handleHealth)
-	mux.HandleFunc("/api/v1/validate", s.handleValidate)
+// LoadCodes - check field codes against the code library in a JSON file
+func (s *Server) LoadCodes(path string) error {
+	lib, err := codes.LoadFile(path)
+	if err != nil {
+		return err
+	}
+	return codes.Register(s.engine, lib)
+}
 
-	handler := s.loggingMiddleware(mux)
+// SetDataDir - keep validated datasets, their reports and monitoring
+// epochs under dir
+func (s *Server) SetDataDir(dir string) error {
+	reports, err := store.NewFileStore(filepath.Join(dir, "projects"))
+	if err != nil {
+		return err
+	}
+	epochs, err := monitor.NewFileStore(filepath.Join(dir, "monitor"))
+	if err != nil {
+		return err
+	}
+	s.store, s.monitor = reports, epochs
+	return nil
+}
 
-	log.Printf("Starting server on %s", s.addr)
-	return http.ListenAndServe(s.addr, handler)
+// SetCORSOrigins - the browser origins allowed to call the API, any when
+// none are given
+func (s *Server) SetCORSOrigins(origins []string) {
+	s.corsOrigins = origins
 }
 
-func (s *Server) loggingMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
-		log.Printf("Started %s %s", r.Method, r.URL.Path)
-		next.ServeHTTP(w, r)
-		log.Printf("Completed %s %s in %v", r.Method, r.URL.Path, time.Since(start))
-	})
+// SetWorkers - how many background jobs run at once, one per CPU if unset
+func (s *Server) SetWorkers(n int) {
+	s.workers = n
 }
 
-func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+func (s *Server) handleValidate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.respondError(w, http.StatusMethodNotAllowed, "Method not allowed. Use POST.")
+		return
+	}
+
+	defer r.Body.Close()
+
+	var body wire.ValidateBody
+	if !s.decodeJSON(w, r, &body) {
+		return
+	}
+
+	report, ok := s.validate(w, r, &body.SurveyData, validateOptions(&body), body.Store)
+	if !ok {
+		return
+	}
+	s.respondJSON(w, http.StatusOK, report)
+}
+
+// handleChecks - what the engine will run, with each check's settings
+func (s *Server) handleChecks(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		s.respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
 		return
 	}
+	s.respondJSON(w, http.StatusOK, wire.ChecksResponse{Checks: wireChecks(s.engine.Checks())})
+}
 
-	s.respondJSON(w, http.StatusOK, map[string]string{
-		"status":  "healthy",
-		"service": "survey-validator",
-	})
+// validate - run the engine under the request's context, and store the
+// result if keep. A validation that runs out of time is answered with 503
+// and false, as is one asking to be kept that can't be, see canStore.
+func (s *Server) validate(w http.ResponseWriter, r *http.Request, data *models.SurveyData, opts engine.Options, keep bool) (*models.ValidationReport, bool) {
+	noteProject(r, data.ProjectID)
+	if keep && !s.canStore(w, data.ProjectID) {
+		return nil, false
+	}
+	report, err := s.engine.ValidateContext(r.Context(), data, s.withProfile(opts))
+	if errors.Is(err, engine.ErrInvalidOptions) {
+		s.respondAPIError(w, optionsError(err))
+		return nil, false
+	}
+	if err != nil {
+		requestLogger(r).Warn("Validation aborted", "project_id", data.ProjectID, "error", err)
+		s.respondError(w, http.StatusServiceUnavailable, "Validation timed out, try a smaller dataset")
+		return nil, false
+	}
+	if keep {
+		s.storeReport(data, report)
+	}
+	return report, true
 }
 
-func (s *Server) handleValidate(w http.ResponseWriter, r *http.Request) {
+// canStore - whether a report can be kept: not without a data directory
+// (503) or a project ID to keep it under (400)
+func (s *Server) canStore(w http.ResponseWriter, projectID string) bool {
+	if s.store == nil {
+		s.respondError(w, http.StatusServiceUnavailable, "Storing reports needs a data directory, start the server with -data")
+		return false
+	}
+	if projectID == "" {
+		s.respondError(w, http.StatusBadRequest, "Storing a report needs a project_id")
+		return false
+	}
+	return true
+}
+
+// storeReport - keep a report, once canStore said it can be. One that
+// couldn't be kept is still worth answering with, so errors are only
+// logged and the report goes back without a report_id.
+func (s *Server) storeReport(data *models.SurveyData, report *models.ValidationReport) {
+	if _, err := s.store.Save(data, report); err != nil {
+		slog.Error("Storing report", "project_id", report.ProjectID, "error", err)
+	}
+}
+
+// handleCompare - what moved between two versions of a dataset
+func (s *Server) handleCompare(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		s.respondError(w, http.StatusMethodNotAllowed, "Method not allowed. Use POST.")
 		return
 	}
+	defer r.Body.Close()
+
+	var req wire.CompareRequest
+	if !s.decodeJSON(w, r, &req) {
+		return
+	}
+	noteProject(r, req.After.ProjectID)
+	if len(req.Before.Points) == 0 || len(req.After.Points) == 0 {
+		s.respondError(w, http.StatusBadRequest, "Both before and after need points")
+		return
+	}
+	if req.Threshold < 0 || req.RenameTolerance < 0 {
+		s.respondError(w, http.StatusBadRequest, "threshold and rename_tolerance can't be negative")
+		return
+	}
 
-	var surveyData models.SurveyData
-	if err := json.NewDecoder(r.Body).Decode(&surveyData); err != nil {
-		s.respondError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+	s.respondJSON(w, http.StatusOK, wireCompare(compare.Compare(&req.Before, &req.After, compare.Options(req.CompareOptions))))
+}
+
+// handleImport - POST a raw field book (GSI, RW5, SDR33, LandXML) as the request body.
+// query: format (or filename to detect it), project_id, coordinate_system,
+// linear_unit, angle_unit, codes=CP:control,TR:traverse, validate=true,
+// and store=true with it to keep the report
+func (s *Server) handleImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.respondError(w, http.StatusMethodNotAllowed, "Method not allowed. Use POST.")
 		return
 	}
 	defer r.Body.Close()
 
-	report := s.engine.Validate(&surveyData)
-	s.respondJSON(w, http.StatusOK, report)
+	q := r.URL.Query()
+	format := formats.Format(strings.ToLower(q.Get("format")))
+	if format == "" {
+		detected, ok := formats.DetectFormat(q.Get("filename"))
+		if !ok {
+			s.respondError(w, http.StatusBadRequest, "Unknown raw format: pass format=gsi|rw5|sdr33|landxml or a filename")
+			return
+		}
+		format = detected
+	}
+
+	keep := q.Get("store") == "true"
+	if keep && q.Get("validate") != "true" {
+		s.respondError(w, http.StatusBadRequest, "store=true needs validate=true, there is no report to keep otherwise")
+		return
+	}
+
+	opts := formats.ImportOptions{
+		ProjectID:        q.Get("project_id"),
+		CoordinateSystem: q.Get("coordinate_system"),
+		LinearUnit:       formats.LinearUnit(q.Get("linear_unit")),
+		AngleUnit:        formats.AngleUnit(q.Get("angle_unit")),
+		CodeMap:          parseCodeMap(q.Get("codes")),
+	}
+
+	result, err := formats.Import(format, r.Body, opts)
+	if err != nil {
+		s.respondBodyError(w, "Import failed: ", err)
+		return
+	}
+
+	resp := wire.ImportResponse{ImportResult: wireImport(result)}
+	if q.Get("validate") == "true" {
+		report, ok := s.validate(w, r, result.Data, engine.Options{}, keep)
+		if !ok {
+			return
+		}
+		resp.Report = report
+	}
+	s.respondJSON(w, http.StatusOK, resp)
+}
+
+// handleExport - validate and write the result in an exchange format.
+// query: format=landxml|geojson|kml|dxf (KML needs a UTM coordinate system)
+func (s *Server) handleExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.respondError(w, http.StatusMethodNotAllowed, "Method not allowed. Use POST.")
+		return
+	}
+	defer r.Body.Close()
+
+	var req wire.ExportRequest
+	if !s.decodeJSON(w, r, &req) {
+		return
+	}
+
+	report, ok := s.validate(w, r, &req.SurveyData, exportOptions(&req), req.Store)
+	if !ok {
+		return
+	}
+
+	leveling := computeLeveling(req.Control)
+
+	format := formats.Format(strings.ToLower(r.URL.Query().Get("format")))
+	if format == "" {
+		format = formats.Format(formatFromAccept(r, exportTypes))
+	}
+	in := formats.ExportInput{Data: &req.SurveyData, Report: report}
+
+	var buf bytes.Buffer
+	var err error
+	var contentType, ext string
+
+	switch format {
+	case "", formats.FormatLandXML:
+		contentType, ext = "application/xml", "xml"
+		err = formats.WriteLandXML(&buf, formats.LandXMLExport{
+			Data:     &req.SurveyData,
+			Traverse: report.TraverseResult,
+			Leveling: leveling,
+		})
+	case formats.FormatGeoJSON:
+		contentType, ext = "application/geo+json", "geojson"
+		err = formats.WriteGeoJSON(&buf, in)
+	case formats.FormatKML:
+		contentType, ext = "application/vnd.google-earth.kml+xml", "kml"
+		err = formats.WriteKML(&buf, in)
+	case formats.FormatDXF:
+		contentType, ext = "application/dxf", "dxf"
+		err = formats.WriteDXF(&buf, in)
+	default:
+		s.respondError(w, http.StatusBadRequest, "Unknown export format: "+string(format))
+		return
+	}
+
+	if err != nil {
+		s.respondError(w, http.StatusUnprocessableEntity, "Export failed: "+err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", exportFilename(req.ProjectID, ext))
+	w.WriteHeader(http.StatusOK)
+	if _, err := buf.WriteTo(w); err != nil {
+		requestLogger(r).Warn("Writing export", "error", err)
+	}
+}
+
+func (s *Server) handleCertificate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.respondError(w, http.StatusMethodNotAllowed, "Method not allowed. Use POST.")
+		return
+	}
+	defer r.Body.Close()
+
+	var req wire.CertificateRequest
+	if !s.decodeJSON(w, r, &req) {
+		return
+	}
+	noteProject(r, req.ProjectID)
+	if len(req.Points) == 0 {
+		s.respondError(w, http.StatusBadRequest, "At least one point is required")
+		return
+	}
+	if req.Certificate.SurveyorName == "" {
+		s.respondError(w, http.StatusBadRequest, "certificate.surveyor_name is required")
+		return
+	}
+
+	report, ok := s.validate(w, r, &req.SurveyData, exportOptions(&req.ExportRequest), req.Store)
+	if !ok {
+		return
+	}
+	cert := certificate.New(certificate.Metadata(req.Certificate), &req.SurveyData, report, computeLeveling(req.Control))
+
+	var buf bytes.Buffer
+	var err error
+	var contentType, ext string
+
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format == "" {
+		format = formatFromAccept(r, certificateTypes)
+	}
+	switch format {
+	case "", "html":
+		contentType, ext = "text/html; charset=utf-8", "html"
+		err = cert.WriteHTML(&buf)
+	case "pdf":
+		contentType, ext = "application/pdf", "pdf"
+		err = cert.WritePDF(&buf)
+	default:
+		s.respondError(w, http.StatusBadRequest, "Unknown certificate format: "+format)
+		return
+	}
+
+	if err != nil {
+		s.respondError(w, http.StatusInternalServerError, "Certificate failed: "+err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	if ext == "pdf" {
+		w.Header().Set("Content-Disposition", exportFilename(req.ProjectID, "qc.pdf"))
+	}
+	w.WriteHeader(http.StatusOK)
+	if _, err := buf.WriteTo(w); err != nil {
+		requestLogger(r).Warn("Writing certificate", "error", err)
+	}
+}
+
+// export and certificate formats by media type, for requests that ask
+// with Accept instead of format=
+var (
+	exportTypes = map[string]string{
+		"application/xml":                      string(formats.FormatLandXML),
+		"application/geo+json":                 string(formats.FormatGeoJSON),
+		"application/vnd.google-earth.kml+xml": string(formats.FormatKML),
+		"application/dxf":                      string(formats.FormatDXF),
+	}
+	certificateTypes = map[string]string{
+		"text/html":       "html",
+		"application/pdf": "pdf",
+	}
+)
+
+// computeLeveling - run the level reduction when the request carries one
+func computeLeveling(c *models.ControlExtensionInput) *models.LevelingResult {
+	if c == nil || len(c.LevelingObs) == 0 {
+		return nil
+	}
+	var endHeight float64
+	if c.EndControl != nil {
+		endHeight = c.EndControl.Height
+	}
+	return domain.ComputeLeveling(c.LevelingObs, c.StartBMHeight, endHeight, c.ToleranceClass)
+}
+
+// exportFilename - attachment header named after the project
+func exportFilename(projectID, ext string) string {
+	name := projectID
+	if name == "" {
+		name = "survey"
+	}
+	return fmt.Sprintf("attachment; filename=%q", name+"."+ext)
+}
+
+// parseCodeMap - "CP:control,TR:traverse" into a code map
+func parseCodeMap(s string) map[string]models.SurveyType {
+	codes := make(map[string]models.SurveyType)
+	for _, pair := range strings.Split(s, ",") {
+		code, t, ok := strings.Cut(pair, ":")
+		if !ok || strings.TrimSpace(code) == "" {
+			continue
+		}
+		codes[strings.TrimSpace(code)] = models.SurveyType(strings.TrimSpace(t))
+	}
+	return codes
 }
 
 func (s *Server) respondJSON(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	if err := json.NewEncoder(w).Encode(data); err != nil {
-		log.Printf("Error encoding response: %v", err)
+		slog.Warn("Encoding response", "error", err)
 	}
 }
 
+// respondError - the message, the status's code and the request ID
 func (s *Server) respondError(w http.ResponseWriter, status int, message string) {
-	s.respondJSON(w, status, map[string]string{
-		"error": message,
-	})
-}
-
-type ErrorResponse struct {
-	Error string `json:"error"`
+	s.respondAPIError(w, &APIError{Status: status, Code: codeFor(status), Message: message})
 }
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/survey-validator/models"
+	"github.com/survey-validator/wire"
+)
+
+// testServer - a server with a data directory in a temp dir
+func testServer(t *testing.T) *Server {
+	t.Helper()
+	s := NewServer("")
+	if err := s.SetDataDir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// call - one request through h, see newRequest
+func call(t *testing.T, h http.Handler, method, target string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, newRequest(t, method, target, body))
+	return rec
+}
+
+// newRequest - a request with body: a string goes as it is, anything
+// else as JSON
+func newRequest(t *testing.T, method, target string, body interface{}) *http.Request {
+	t.Helper()
+	switch b := body.(type) {
+	case nil:
+		return httptest.NewRequest(method, target, nil)
+	case string:
+		return httptest.NewRequest(method, target, strings.NewReader(b))
+	default:
+		raw, err := json.Marshal(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := httptest.NewRequest(method, target, bytes.NewReader(raw))
+		r.Header.Set("Content-Type", "application/json")
+		return r
+	}
+}
+
+// decode - the answer's JSON body into v
+func decode(t *testing.T, rec *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		t.Fatalf("Decoding %d %s: %v", rec.Code, rec.Body, err)
+	}
+}
+
+// errorCode - the code of an error answer
+func errorCode(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+	var e wire.ErrorResponse
+	decode(t, rec, &e)
+	return e.Code
+}
+
+func testData() models.SurveyData {
+	return models.SurveyData{
+		ProjectID: "API-1",
+		Points: []models.SurveyPoint{
+			{PointID: "T1", Easting: 1000, Northing: 1000, SurveyType: models.SurveyTypeTraverse},
+			{PointID: "T2", Easting: 1100, Northing: 1000, SurveyType: models.SurveyTypeTraverse},
+			{PointID: "T3", Easting: 1100, Northing: 1100, SurveyType: models.SurveyTypeTraverse},
+			{PointID: "T4", Easting: 1000.01, Northing: 1000, SurveyType: models.SurveyTypeTraverse},
+		},
+	}
+}
+
+func TestCompare(t *testing.T) {
+	h := NewServer("").Handler()
+
+	before, after := testData(), testData()
+	after.Points[2].Easting += 0.05
+	after.Points[3].PointID = "T4a" // renamed in place
+	rec := call(t, h, http.MethodPost, "/api/v1/compare", wire.CompareRequest{Before: before, After: after})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d %s", rec.Code, rec.Body)
+	}
+	var res wire.CompareResult
+	decode(t, rec, &res)
+	if res.Matched != 4 || len(res.Moved) != 1 || res.Moved[0] != "T3" {
+		t.Errorf("Expected 4 matched and T3 moved, got %d %v", res.Matched, res.Moved)
+	}
+	if len(res.Renamed) != 1 || res.Renamed[0].From != "T4" || res.Renamed[0].To != "T4a" {
+		t.Errorf("Expected T4 renamed to T4a, got %+v", res.Renamed)
+	}
+	if res.Transform == nil || len(res.Displacements) != 4 {
+		t.Errorf("Expected a transform and 4 displacements, got %+v", res)
+	}
+	// nothing added or removed is [], not null
+	if !strings.Contains(rec.Body.String(), `"added":[]`) {
+		t.Errorf("Expected an empty added list in %s", rec.Body)
+	}
+
+	for name, req := range map[string]wire.CompareRequest{
+		"no points before":   {After: after},
+		"negative threshold": {Before: before, After: after, CompareOptions: wire.CompareOptions{Threshold: -1}},
+	} {
+		if rec := call(t, h, http.MethodPost, "/api/v1/compare", req); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d %s", name, rec.Code, rec.Body)
+		}
+	}
+	if rec := call(t, h, http.MethodGet, "/api/v1/compare", nil); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for GET, got %d", rec.Code)
+	}
+}
+
+func TestExport(t *testing.T) {
+	h := NewServer("").Handler()
+	req := wire.ExportRequest{SurveyData: testData()}
+
+	for format, want := range map[string]struct{ contentType, ext, body string }{
+		"":        {"application/xml", "xml", "<LandXML"},
+		"geojson": {"application/geo+json", "geojson", "FeatureCollection"},
+		"dxf":     {"application/dxf", "dxf", "SECTION"},
+	} {
+		rec := call(t, h, http.MethodPost, "/api/v1/export?format="+format, req)
+		if rec.Code != http.StatusOK {
+			t.Errorf("%q: expected 200, got %d %s", format, rec.Code, rec.Body)
+			continue
+		}
+		if got := rec.Header().Get("Content-Type"); got != want.contentType {
+			t.Errorf("%q: expected %s, got %s", format, want.contentType, got)
+		}
+		if got := rec.Header().Get("Content-Disposition"); !strings.Contains(got, `API-1.`+want.ext) {
+			t.Errorf("%q: expected an API-1.%s attachment, got %s", format, want.ext, got)
+		}
+		if !strings.Contains(rec.Body.String(), want.body) {
+			t.Errorf("%q: expected %s in %.80s", format, want.body, rec.Body)
+		}
+	}
+
+	// the Accept header picks the format when the query doesn't
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/export", strings.NewReader(`{"project_id": "API-1", "points": [{"point_id": "A", "easting": 1, "northing": 1}]}`))
+	r.Header.Set("Accept", "application/geo+json")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/geo+json" {
+		t.Errorf("Expected GeoJSON from the Accept header, got %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+
+	// KML needs a UTM coordinate system
+	if rec := call(t, h, http.MethodPost, "/api/v1/export?format=kml", req); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422 for KML without a UTM zone, got %d %s", rec.Code, rec.Body)
+	}
+	if rec := call(t, h, http.MethodPost, "/api/v1/export?format=shp", req); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown format, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,159 @@
+package api
+
+// stream.go - validation of uploads too big to decode in one go, and the
+// request size limits that send them here
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/survey-validator/engine"
+	"github.com/survey-validator/formats"
+	"github.com/survey-validator/ingest"
+)
+
+// request body limits, see SetLimits
+const (
+	DefaultMaxBody   = 32 << 20 // 32MB for the decode-it-all endpoints
+	DefaultMaxStream = 2 << 30  // 2GB for /api/v1/validate/stream
+)
+
+// SetLimits - the largest request body the JSON endpoints and the
+// streaming endpoint accept. Zero keeps the default.
+func (s *Server) SetLimits(maxBody, maxStream int64) {
+	if maxBody > 0 {
+		s.maxBody = maxBody
+	}
+	if maxStream > 0 {
+		s.maxStream = maxStream
+	}
+}
+
+// decodeJSON - read a JSON body into v, checked against v's schema in the
+// OpenAPI document first so every bad field is reported at once. Anything
+// wrong is answered (400, or 413 when it went over the limit) and gives
+// false.
+func (s *Server) decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	raw, err := io.ReadAll(r.Body)
+	if err != nil {
+		s.respondBodyError(w, "Reading body: ", err)
+		return false
+	}
+	if e := parseJSON(raw, v); e != nil {
+		s.respondAPIError(w, e)
+		return false
+	}
+	return true
+}
+
+// respondBodyError - 413 when the body went over its limit, 400 with
+// prefix and the error otherwise, see bodyError
+func (s *Server) respondBodyError(w http.ResponseWriter, prefix string, err error) {
+	s.respondAPIError(w, s.bodyError(prefix, err))
+}
+
+func (s *Server) bodyLimit() int64 {
+	if s.maxBody > 0 {
+		return s.maxBody
+	}
+	return DefaultMaxBody
+}
+
+func (s *Server) streamLimit() int64 {
+	if s.maxStream > 0 {
+		return s.maxStream
+	}
+	return DefaultMaxStream
+}
+
+func megabytes(n int64) string {
+	return fmt.Sprintf("%d MB", n>>20)
+}
+
+// handleValidateStream - validate a JSON or CSV upload without holding it.
+// Points are spooled to a temp file as they are parsed and the checks read
+// them from there, so memory stays flat however many points are sent.
+// The body is a validate body (checks and suppressions in it are ignored),
+// a bare array of points, or CSV with Content-Type text/csv or format=csv.
+// query: project_id, coordinate_system, enable, disable (comma separated
+// check names), store=true to keep the report, and for CSV the import
+// options: linear_unit, codes
+func (s *Server) handleValidateStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.respondError(w, http.StatusMethodNotAllowed, "Method not allowed. Use POST.")
+		return
+	}
+	defer r.Body.Close()
+	noDeadlines(w) // a big upload takes as long as it takes; streamLimit caps it
+
+	sp, err := ingest.NewSpool("")
+	if err != nil {
+		requestLogger(r).Error("Spooling upload", "error", err)
+		s.respondError(w, http.StatusInternalServerError, "Couldn't spool the upload")
+		return
+	}
+	defer sp.Close()
+
+	q := r.URL.Query()
+	sp.SetMeta(q.Get("project_id"), q.Get("coordinate_system"))
+	if isCSV(r) {
+		err = ingest.ReadCSV(r.Body, formats.ImportOptions{
+			ProjectID:        q.Get("project_id"),
+			CoordinateSystem: q.Get("coordinate_system"),
+			LinearUnit:       formats.LinearUnit(q.Get("linear_unit")),
+			CodeMap:          parseCodeMap(q.Get("codes")),
+		}, sp)
+	} else {
+		err = ingest.ReadJSON(r.Body, sp)
+	}
+	if err == nil {
+		err = sp.Finish()
+	}
+	noteProject(r, sp.Meta().ProjectID)
+	if err != nil {
+		s.respondBodyError(w, "Invalid upload: ", err)
+		return
+	}
+	keep := q.Get("store") == "true"
+	if keep && !s.canStore(w, sp.Meta().ProjectID) {
+		return
+	}
+
+	opts := engine.Options{Enable: splitList(q.Get("enable")), Disable: splitList(q.Get("disable"))}
+	report, err := s.engine.ValidateSource(r.Context(), sp, s.withProfile(opts))
+	if errors.Is(err, engine.ErrInvalidOptions) {
+		s.respondAPIError(w, optionsError(err))
+		return
+	}
+	if err != nil {
+		requestLogger(r).Warn("Streamed validation aborted", "project_id", sp.Meta().ProjectID, "error", err)
+		s.respondError(w, http.StatusServiceUnavailable, "Validation timed out")
+		return
+	}
+	// the points aren't kept with a streamed report, just the report
+	if keep {
+		s.storeReport(nil, report)
+	}
+	s.respondJSON(w, http.StatusOK, report)
+}
+
+func isCSV(r *http.Request) bool {
+	if strings.EqualFold(r.URL.Query().Get("format"), "csv") {
+		return true
+	}
+	return strings.HasPrefix(strings.ToLower(r.Header.Get("Content-Type")), "text/csv")
+}
+
+// splitList - "a, b,c" into its names, nil for ""
+func splitList(s string) []string {
+	var out []string
+	for _, name := range strings.Split(s, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			out = append(out, name)
+		}
+	}
+	return out
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/survey-validator/models"
+	"github.com/survey-validator/wire"
+)
+
+func TestValidateStream(t *testing.T) {
+	h := NewServer("").Handler()
+
+	for name, tt := range map[string]struct {
+		target, contentType, body string
+	}{
+		"validate body": {"/api/v1/validate/stream", "application/json", `{"project_id": "S-1", "points": [{"point_id": "A", "easting": 1, "northing": 1}, {"point_id": "B", "easting": 2, "northing": 2}]}`},
+		"bare array":    {"/api/v1/validate/stream?project_id=S-1", "application/json", `[{"point_id": "A", "easting": 1, "northing": 1}, {"point_id": "B", "easting": 2, "northing": 2}]`},
+		"csv by type":   {"/api/v1/validate/stream?project_id=S-1", "text/csv", "point_id,easting,northing\nA,1,1\nB,2,2\n"},
+		"csv by format": {"/api/v1/validate/stream?project_id=S-1&format=csv", "", "point_id,easting,northing\nA,1,1\nB,2,2\n"},
+	} {
+		r := httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(tt.body))
+		r.Header.Set("Content-Type", tt.contentType)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: expected 200, got %d %s", name, rec.Code, rec.Body)
+			continue
+		}
+		var report models.ValidationReport
+		decode(t, rec, &report)
+		if report.ProjectID != "S-1" || report.Summary.TotalPoints != 2 {
+			t.Errorf("%s: expected 2 points of S-1, got %s with %d", name, report.ProjectID, report.Summary.TotalPoints)
+		}
+	}
+
+	for name, tt := range map[string]struct {
+		method, target, body string
+		status               int
+	}{
+		"bad JSON":      {http.MethodPost, "/api/v1/validate/stream", `[{"point_id": "A", "easting": 1,`, http.StatusBadRequest},
+		"bad CSV":       {http.MethodPost, "/api/v1/validate/stream?format=csv", "point_id,easting,northing\nA,1,1\nB,inf,2\n", http.StatusBadRequest},
+		"unknown check": {http.MethodPost, "/api/v1/validate/stream?enable=nope", `[{"point_id": "A", "easting": 1, "northing": 1}]`, http.StatusBadRequest},
+		"method":        {http.MethodGet, "/api/v1/validate/stream", "", http.StatusMethodNotAllowed},
+	} {
+		if rec := call(t, h, tt.method, tt.target, tt.body); rec.Code != tt.status {
+			t.Errorf("%s: expected %d, got %d %s", name, tt.status, rec.Code, rec.Body)
+		}
+	}
+}
+
+func TestLimits(t *testing.T) {
+	s := NewServer("")
+	s.SetLimits(1<<10, 4<<10)
+	h := s.Handler()
+
+	points := "[" + strings.Repeat(`{"point_id": "A", "easting": 1, "northing": 1},`, 120) + `{"point_id": "B", "easting": 2, "northing": 2}]`
+	if len(points) < 4<<10 {
+		t.Fatalf("Expected a body over the stream limit, got %d bytes", len(points))
+	}
+	big := `{"project_id": "L-1", "points": ` + points + "}"
+
+	for name, tt := range map[string]struct {
+		target, body string
+		hideLength   bool // so only reading finds it too big
+	}{
+		"validate, by length":  {"/api/v1/validate", big, false},
+		"validate, by reading": {"/api/v1/validate", big, true},
+		"stream, by length":    {"/api/v1/validate/stream", points, false},
+		"stream, by reading":   {"/api/v1/validate/stream", points, true},
+	} {
+		r := httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(tt.body))
+		if tt.hideLength {
+			r.ContentLength = -1
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		if rec.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("%s: expected 413, got %d %s", name, rec.Code, rec.Body)
+			continue
+		}
+		if code := errorCode(t, rec); code != wire.CodePayloadTooLarge {
+			t.Errorf("%s: expected %s, got %s", name, wire.CodePayloadTooLarge, code)
+		}
+	}
+
+	// the streaming endpoint takes what's too big for the others
+	medium := points[:strings.Index(points, "},")+1]
+	medium = "[" + strings.Repeat(medium[1:]+",", 40) + medium[1:] + "]"
+	if len(medium) < 1<<10 || len(medium) > 4<<10 {
+		t.Fatalf("Expected a body between the limits, got %d bytes", len(medium))
+	}
+	if rec := call(t, h, http.MethodPost, "/api/v1/validate/stream?project_id=L-1", medium); rec.Code != http.StatusOK {
+		t.Errorf("Expected the stream to take %d bytes, got %d %s", len(medium), rec.Code, rec.Body)
+	}
+	if rec := call(t, h, http.MethodPost, "/api/v1/validate", `{"points": `+medium+"}"); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected validate to refuse %d bytes, got %d", len(medium), rec.Code)
+	}
+}
@@ -0,0 +1,15 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/survey-validator/api"
+)
+
+// Handler is the Vercel serverless function for every path, the web app
+// included. It serves the same handler as the local server, so endpoints
+// behave the same in both places (bar the ones that need a data directory
+// or jobs).
+func Handler(w http.ResponseWriter, r *http.Request) {
+	api.Serverless().ServeHTTP(w, r)
+}
@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/survey-validator/wire"
+)
+
+func serve(method, path, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	Handler(rec, r)
+	return rec
+}
+
+func TestHandler(t *testing.T) {
+	// the same endpoints as the local server, behind the same middleware...
+	rec := serve(http.MethodPost, "/api/v1/validate", `{"project_id": "V-1", "points": [{"point_id": "A", "easting": 1, "northing": 1}]}`)
+	if rec.Code != http.StatusOK || rec.Header().Get(wire.RequestIDHeader) == "" {
+		t.Errorf("Expected validation with a request ID, got %d %s", rec.Code, rec.Body)
+	}
+	if rec := serve(http.MethodGet, "/", ""); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "<html") {
+		t.Errorf("Expected the web app, got %d", rec.Code)
+	}
+
+	// ...bar those that need a data directory or jobs
+	for _, path := range []string{"/api/v1/projects", "/api/v1/monitor", "/api/v1/jobs/x"} {
+		if rec := serve(http.MethodGet, path, ""); rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("%s: expected 503, got %d", path, rec.Code)
+		}
+	}
+}
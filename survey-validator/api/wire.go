@@ -0,0 +1,202 @@
+package api
+
+// wire.go - the domain packages' types to and from the wire package's, so
+// the engine, stores and the rest don't depend on how the API spells its
+// JSON. Structs with the same fields convert directly; the ones holding
+// other named types are copied field by field.
+
+import (
+	"github.com/survey-validator/auth"
+	"github.com/survey-validator/compare"
+	"github.com/survey-validator/engine"
+	"github.com/survey-validator/formats"
+	"github.com/survey-validator/monitor"
+	"github.com/survey-validator/selftest"
+	"github.com/survey-validator/store"
+	"github.com/survey-validator/wire"
+)
+
+// checkConfigs - a request's per-check settings as the engine takes them
+func checkConfigs(in map[string]wire.CheckConfig) map[string]engine.Config {
+	if in == nil {
+		return nil
+	}
+	out := make(map[string]engine.Config, len(in))
+	for name, c := range in {
+		out[name] = engine.Config(c)
+	}
+	return out
+}
+
+func wireChecks(in []engine.CheckInfo) []wire.CheckInfo {
+	if in == nil {
+		return nil
+	}
+	out := make([]wire.CheckInfo, len(in))
+	for i, c := range in {
+		out[i] = wire.CheckInfo{
+			Name:        c.Name,
+			Description: c.Description,
+			Version:     c.Version,
+			Category:    c.Category,
+			AppliesTo:   c.AppliesTo,
+			DependsOn:   c.DependsOn,
+			Priority:    c.Priority,
+		}
+		if c.Config != nil {
+			out[i].Config = make(map[string]wire.ParamSpec, len(c.Config))
+			for key, p := range c.Config {
+				out[i].Config[key] = wire.ParamSpec{Type: wire.ParamType(p.Type), Description: p.Description, Default: p.Default}
+			}
+		}
+	}
+	return out
+}
+
+func wireJob(j engine.Job) wire.Job {
+	return wire.Job{
+		ID:          j.ID,
+		ProjectID:   j.ProjectID,
+		Points:      j.Points,
+		Status:      wire.JobStatus(j.Status),
+		Progress:    wire.Progress(j.Progress),
+		SubmittedAt: j.SubmittedAt,
+		StartedAt:   j.StartedAt,
+		FinishedAt:  j.FinishedAt,
+		Error:       j.Error,
+		Report:      j.Report,
+	}
+}
+
+func wireSelfTest(rep selftest.Report) *wire.SelfTestReport {
+	out := &wire.SelfTestReport{Passed: rep.Passed, RanAt: rep.RanAt}
+	if rep.Results != nil {
+		out.Results = make([]wire.SelfTestResult, len(rep.Results))
+		for i, r := range rep.Results {
+			out.Results[i] = wire.SelfTestResult(r)
+		}
+	}
+	return out
+}
+
+func wireImport(r *formats.ImportResult) *wire.ImportResult {
+	return &wire.ImportResult{
+		Format:       wire.ImportFormat(r.Format),
+		Data:         r.Data,
+		Observations: r.Observations,
+		Warnings:     r.Warnings,
+	}
+}
+
+func wireKeys(in []auth.Key) []wire.Key {
+	if in == nil {
+		return nil
+	}
+	out := make([]wire.Key, len(in))
+	for i, k := range in {
+		out[i] = wire.Key(k)
+	}
+	return out
+}
+
+func wireProjects(in []store.ProjectSummary) []wire.ProjectSummary {
+	if in == nil {
+		return nil
+	}
+	out := make([]wire.ProjectSummary, len(in))
+	for i, p := range in {
+		out[i] = wire.ProjectSummary{ID: p.ID, Reports: p.Reports, FirstAt: p.FirstAt}
+		if p.Latest != nil {
+			latest := wire.ReportInfo(*p.Latest)
+			out[i].Latest = &latest
+		}
+	}
+	return out
+}
+
+func wireProject(p *store.Project) *wire.Project {
+	out := &wire.Project{ID: p.ID}
+	if p.Reports != nil {
+		out.Reports = make([]wire.ReportInfo, len(p.Reports))
+		for i, r := range p.Reports {
+			out.Reports[i] = wire.ReportInfo(r)
+		}
+	}
+	return out
+}
+
+func wireTransform(t *compare.Transform) *wire.Transform {
+	if t == nil {
+		return nil
+	}
+	out := wire.Transform(*t)
+	return &out
+}
+
+func wireCompare(r *compare.Result) *wire.CompareResult {
+	out := &wire.CompareResult{
+		Before:    r.Before,
+		After:     r.After,
+		Threshold: r.Threshold,
+		Matched:   r.Matched,
+		Added:     r.Added,
+		Removed:   r.Removed,
+		Moved:     r.Moved,
+		Summary:   wire.CompareSummary(r.Summary),
+		Transform: wireTransform(r.Transform),
+	}
+	if r.Renamed != nil {
+		out.Renamed = make([]wire.Rename, len(r.Renamed))
+		for i, rn := range r.Renamed {
+			out.Renamed[i] = wire.Rename(rn)
+		}
+	}
+	if r.Displacements != nil {
+		out.Displacements = make([]wire.Displacement, len(r.Displacements))
+		for i, d := range r.Displacements {
+			out.Displacements[i] = wire.Displacement(d)
+		}
+	}
+	return out
+}
+
+func wireMonitor(r *monitor.Report) *wire.MonitorReport {
+	out := &wire.MonitorReport{
+		ProjectID:     r.ProjectID,
+		Baseline:      r.Baseline,
+		Latest:        r.Latest,
+		Config:        wire.MonitorConfig(r.Config),
+		NotInBaseline: r.NotInBaseline,
+		Systematic:    wireTransform(r.Systematic),
+	}
+	if r.Epochs != nil {
+		out.Epochs = make([]wire.EpochInfo, len(r.Epochs))
+		for i, e := range r.Epochs {
+			out.Epochs[i] = wire.EpochInfo(e)
+		}
+	}
+	if r.Points != nil {
+		out.Points = make([]wire.Series, len(r.Points))
+		for i, s := range r.Points {
+			out.Points[i] = wire.Series{
+				PointID:          s.PointID,
+				Velocity:         s.Velocity,
+				VerticalVelocity: s.VerticalVelocity,
+				Status:           s.Status,
+			}
+			if s.Observations != nil {
+				out.Points[i].Observations = make([]wire.MonitorObservation, len(s.Observations))
+				for j, o := range s.Observations {
+					out.Points[i].Observations[j] = wire.MonitorObservation(o)
+				}
+			}
+		}
+	}
+	if r.Alerts != nil {
+		out.Alerts = make([]wire.Alert, len(r.Alerts))
+		for i, a := range r.Alerts {
+			out.Alerts[i] = wire.Alert(a)
+		}
+	}
+	return out
+}
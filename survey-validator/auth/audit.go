@@ -0,0 +1,60 @@
+package auth
+
+// audit.go - who did what: one JSON line per API request, saying which key
+// sent it and which project it was about
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// AuditEntry - one request in the audit log
+type AuditEntry struct {
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"request_id,omitempty"`
+	KeyID     string    `json:"key_id,omitempty"` // empty when auth is off
+	KeyName   string    `json:"key_name,omitempty"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	ProjectID string    `json:"project_id,omitempty"`
+	Status    int       `json:"status"`
+	Remote    string    `json:"remote,omitempty"`
+}
+
+// AuditLog - entries appended to a file, one JSON object per line
+type AuditLog struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// OpenAuditLog - append to the file at path, created if missing
+func OpenAuditLog(path string) (*AuditLog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &AuditLog{f: f}, nil
+}
+
+// Record - add an entry. The line goes out in one write so entries from
+// concurrent requests don't interleave.
+func (a *AuditLog) Record(e AuditEntry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	_, err = a.f.Write(append(line, '\n'))
+	return err
+}
+
+func (a *AuditLog) Close() error {
+	return a.f.Close()
+}
@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKeyring(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	k, err := LoadKeys(path)
+	if err != nil || k.Len() != 0 {
+		t.Fatalf("Expected an empty keyring, got %v %v", k, err)
+	}
+
+	key, secret, err := k.Create(Key{Name: "ci", RateLimit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(secret, SecretPrefix) || key.RateLimit != 10 || key.ID == "" {
+		t.Errorf("Unexpected key %+v with secret %q", key, secret)
+	}
+	if got, ok := k.Authenticate(secret); !ok || got.ID != key.ID {
+		t.Errorf("Expected the secret to find %s, got %v", key.ID, got)
+	}
+	if _, ok := k.Authenticate(secret + "x"); ok {
+		t.Error("Expected a wrong secret to fail")
+	}
+	raw, _ := os.ReadFile(path)
+	if strings.Contains(string(raw), secret) {
+		t.Error("Expected the file to hold only the hash")
+	}
+
+	// the file survives a reload, and a hand-written secret is hashed
+	var f keyFile
+	json.Unmarshal(raw, &f)
+	f.Keys = append(f.Keys, &storedKey{Key: Key{ID: "ops", Name: "ops", Admin: true}, Secret: "let-me-in"})
+	raw, _ = json.Marshal(f)
+	os.WriteFile(path, raw, 0o600)
+	k, err = LoadKeys(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := k.Authenticate(secret); !ok {
+		t.Error("Expected the created key after a reload")
+	}
+	if got, ok := k.Authenticate("let-me-in"); !ok || !got.Admin {
+		t.Errorf("Expected the hand-written admin key, got %v", got)
+	}
+
+	if err := k.Delete(key.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := k.Authenticate(secret); ok {
+		t.Error("Expected a deleted key to fail")
+	}
+	if err := k.Delete(key.ID); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	if list := k.List(); len(list) != 1 || list[0].ID != "ops" {
+		t.Errorf("Expected just ops, got %+v", list)
+	}
+
+	os.WriteFile(path, []byte(`{"keys": [{"id": "a"}]}`), 0o600)
+	if _, err := LoadKeys(path); err == nil {
+		t.Error("Expected a key without a hash or secret to be refused")
+	}
+}
+
+func TestFromRequest(t *testing.T) {
+	for _, c := range []struct{ authorization, apiKey, want string }{
+		{"Bearer sv_1", "", "sv_1"},
+		{"bearer  sv_2 ", "", "sv_2"},
+		{"Basic abc", "sv_3", "sv_3"},
+		{"", "", ""},
+	} {
+		if got := FromRequest(c.authorization, c.apiKey); got != c.want {
+			t.Errorf("FromRequest(%q, %q) = %q, expected %q", c.authorization, c.apiKey, got, c.want)
+		}
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	now := time.Date(2026, 3, 1, 23, 59, 0, 0, time.UTC)
+	l := NewLimiter()
+	l.now = func() time.Time { return now }
+
+	rate := &Key{ID: "rate", RateLimit: 2}
+	for i := 0; i < 2; i++ {
+		if d := l.Allow(rate); !d.Allowed || d.QuotaLeft != -1 {
+			t.Fatalf("Request %d: expected to be allowed, got %+v", i+1, d)
+		}
+	}
+	d := l.Allow(rate)
+	if d.Allowed || d.Reason != ReasonRate || d.RetryAfter != 30*time.Second {
+		t.Errorf("Expected the third to wait 30s, got %+v", d)
+	}
+	now = now.Add(30 * time.Second)
+	if d := l.Allow(rate); !d.Allowed {
+		t.Errorf("Expected a token back after 30s, got %+v", d)
+	}
+
+	quota := &Key{ID: "quota", DailyQuota: 1}
+	if d := l.Allow(quota); !d.Allowed || d.QuotaLeft != 0 {
+		t.Errorf("Expected the first to be allowed with none left, got %+v", d)
+	}
+	d = l.Allow(quota)
+	if d.Allowed || d.Reason != ReasonQuota || d.RetryAfter != 30*time.Second {
+		t.Errorf("Expected the quota to be used up until midnight, got %+v", d)
+	}
+	now = now.Add(time.Minute)
+	if d := l.Allow(quota); !d.Allowed {
+		t.Errorf("Expected a new day's quota, got %+v", d)
+	}
+
+	if d := l.Allow(&Key{ID: "open"}); !d.Allowed || d.Limit != 0 {
+		t.Errorf("Expected no limits, got %+v", d)
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "audit.log")
+	a, err := OpenAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Record(AuditEntry{KeyID: "k1", Method: "POST", Path: "/api/v1/validate", ProjectID: "P1", Status: 200})
+	a.Record(AuditEntry{Method: "GET", Path: "/api/v1/checks", Status: 401})
+	a.Close()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var entries []AuditEntry
+	for sc := bufio.NewScanner(f); sc.Scan(); {
+		var e AuditEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, e)
+	}
+	if len(entries) != 2 || entries[0].KeyID != "k1" || entries[0].ProjectID != "P1" || entries[1].Status != 401 {
+		t.Errorf("Unexpected entries %+v", entries)
+	}
+}
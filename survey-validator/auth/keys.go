@@ -0,0 +1,196 @@
+package auth
+
+// keys.go - API keys for the server, kept in one JSON file. Only a hash of
+// each secret is stored; the secret itself is shown once, when it is made.
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/survey-validator/store"
+)
+
+// ErrNotFound - no key with that ID
+var ErrNotFound = errors.New("no such key")
+
+// SecretPrefix - starts every secret Create makes, so a leaked one is easy
+// to spot in a repo or a log
+const SecretPrefix = "sv_"
+
+// Key - an API key. ID is public and is what logs and the audit trail
+// show; the secret isn't part of it.
+type Key struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Admin     bool      `json:"admin,omitempty"` // may manage keys
+	CreatedAt time.Time `json:"created_at"`
+
+	// RateLimit - requests per minute, 0 for no limit. Short bursts of up
+	// to a minute's worth are allowed.
+	RateLimit int `json:"rate_limit,omitempty"`
+	// DailyQuota - requests per UTC day, 0 for no quota
+	DailyQuota int `json:"daily_quota,omitempty"`
+}
+
+// storedKey - a key as the file has it, with the hex SHA-256 of its
+// secret. A hand-written file can give the secret itself instead; it is
+// hashed on load.
+type storedKey struct {
+	Key
+	Hash   string `json:"hash,omitempty"`
+	Secret string `json:"secret,omitempty"`
+}
+
+// keyFile - the file's layout
+type keyFile struct {
+	Keys []*storedKey `json:"keys"`
+}
+
+// Keyring - the keys in a key file. Changes are written back straight
+// away; the file is only read on load.
+type Keyring struct {
+	path   string
+	mu     sync.RWMutex
+	keys   map[string]*storedKey // by ID
+	byHash map[string]*storedKey
+	now    func() time.Time
+}
+
+// LoadKeys - the keys in the file at path. A missing file is an empty
+// keyring, written out on the first Create.
+func LoadKeys(path string) (*Keyring, error) {
+	k := &Keyring{path: path, keys: make(map[string]*storedKey), byHash: make(map[string]*storedKey), now: time.Now}
+	var f keyFile
+	if err := store.ReadJSON(path, &f); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	for i, key := range f.Keys {
+		if key.Secret != "" && key.Hash == "" {
+			key.Hash = hash(key.Secret)
+		}
+		key.Secret = ""
+		if key.ID == "" || key.Hash == "" {
+			return nil, fmt.Errorf("%s: key %d needs an id and a hash or secret", path, i+1)
+		}
+		if _, dup := k.keys[key.ID]; dup {
+			return nil, fmt.Errorf("%s: key id %s is used twice", path, key.ID)
+		}
+		k.keys[key.ID] = key
+		k.byHash[key.Hash] = key
+	}
+	return k, nil
+}
+
+// Authenticate - the key whose secret this is
+func (k *Keyring) Authenticate(secret string) (*Key, bool) {
+	if secret == "" {
+		return nil, false
+	}
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	key, ok := k.byHash[hash(secret)]
+	if !ok {
+		return nil, false
+	}
+	return &key.Key, true
+}
+
+// Create - a new key, saved. The secret is in the returned string and
+// nowhere else.
+func (k *Keyring) Create(spec Key) (*Key, string, error) {
+	secret := SecretPrefix + randomHex(24)
+	key := &storedKey{Key: spec, Hash: hash(secret)}
+	key.ID, key.CreatedAt = "key_"+randomHex(6), k.now().UTC()
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys[key.ID] = key
+	k.byHash[key.Hash] = key
+	if err := k.save(); err != nil {
+		delete(k.keys, key.ID)
+		delete(k.byHash, key.Hash)
+		return nil, "", err
+	}
+	return &key.Key, secret, nil
+}
+
+// Delete - revoke a key, saved
+func (k *Keyring) Delete(id string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	key, ok := k.keys[id]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(k.keys, id)
+	delete(k.byHash, key.Hash)
+	if err := k.save(); err != nil {
+		k.keys[id], k.byHash[key.Hash] = key, key
+		return err
+	}
+	return nil
+}
+
+// List - the keys, oldest first
+func (k *Keyring) List() []Key {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	out := make([]Key, 0, len(k.keys))
+	for _, key := range k.keys {
+		out = append(out, key.Key)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].CreatedAt.Before(out[j].CreatedAt)
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
+// Len - how many keys there are
+func (k *Keyring) Len() int {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return len(k.keys)
+}
+
+// save - write the file, the caller holds mu
+func (k *Keyring) save() error {
+	f := keyFile{Keys: make([]*storedKey, 0, len(k.keys))}
+	for _, key := range k.keys {
+		f.Keys = append(f.Keys, key)
+	}
+	sort.Slice(f.Keys, func(i, j int) bool { return f.Keys[i].ID < f.Keys[j].ID })
+	return store.WriteJSON(k.path, f)
+}
+
+// FromRequest - the secret a request carries, as "Authorization: Bearer
+// <secret>" or "X-API-Key: <secret>"
+func FromRequest(authorization, apiKey string) string {
+	if scheme, token, ok := strings.Cut(strings.TrimSpace(authorization), " "); ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+	return strings.TrimSpace(apiKey)
+}
+
+func hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic("auth: no randomness: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
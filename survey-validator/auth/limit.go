@@ -0,0 +1,97 @@
+package auth
+
+// limit.go - per-key rate limits and daily quotas, counted in memory. A
+// restart starts every key afresh, which is fine for keeping one client
+// from swamping the others.
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// what stopped a request, Decision.Reason
+const (
+	ReasonRate  = "rate_limited"
+	ReasonQuota = "quota_exceeded"
+)
+
+// Decision - whether a request may go ahead, and what is left
+type Decision struct {
+	Allowed    bool
+	Reason     string        // ReasonRate or ReasonQuota when not allowed
+	Limit      int           // requests per minute, 0 for none
+	Remaining  int           // whole requests left in the bucket
+	RetryAfter time.Duration // when not allowed
+	QuotaLeft  int           // requests left today, -1 for no quota
+}
+
+// Limiter - a token bucket and a day's count per key
+type Limiter struct {
+	mu    sync.Mutex
+	state map[string]*usage
+	now   func() time.Time
+}
+
+type usage struct {
+	tokens float64
+	last   time.Time
+	day    string // UTC date the count is for
+	count  int
+}
+
+func NewLimiter() *Limiter {
+	return &Limiter{state: make(map[string]*usage), now: time.Now}
+}
+
+// Allow - take one request from key's allowance
+func (l *Limiter) Allow(key *Key) Decision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	u := l.state[key.ID]
+	if u == nil {
+		u = &usage{tokens: float64(key.RateLimit), last: now}
+		l.state[key.ID] = u
+	}
+	d := Decision{Allowed: true, Limit: key.RateLimit, QuotaLeft: -1}
+
+	day := now.UTC().Format("2006-01-02")
+	if u.day != day {
+		u.day, u.count = day, 0
+	}
+	if key.DailyQuota > 0 && u.count >= key.DailyQuota {
+		midnight := now.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+		return Decision{Reason: ReasonQuota, Limit: key.RateLimit, RetryAfter: midnight.Sub(now), QuotaLeft: 0}
+	}
+
+	if key.RateLimit > 0 {
+		perSecond := float64(key.RateLimit) / 60
+		u.tokens = math.Min(float64(key.RateLimit), u.tokens+now.Sub(u.last).Seconds()*perSecond)
+		u.last = now
+		if u.tokens < 1 {
+			wait := time.Duration((1 - u.tokens) / perSecond * float64(time.Second))
+			return Decision{Reason: ReasonRate, Limit: key.RateLimit, RetryAfter: wait, QuotaLeft: quotaLeft(key, u)}
+		}
+		u.tokens--
+		d.Remaining = int(u.tokens)
+	}
+
+	u.count++
+	d.QuotaLeft = quotaLeft(key, u)
+	return d
+}
+
+// Forget - drop a revoked key's counts
+func (l *Limiter) Forget(id string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.state, id)
+}
+
+func quotaLeft(key *Key, u *usage) int {
+	if key.DailyQuota <= 0 {
+		return -1
+	}
+	return key.DailyQuota - u.count
+}
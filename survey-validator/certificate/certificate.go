@@ -0,0 +1,341 @@
+package certificate
+
+// certificate.go - QC certificate content shared by the HTML and PDF renderers
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/survey-validator/models"
+)
+
+// Metadata - who the certificate is for and who signs it
+type Metadata struct {
+	ProjectName     string `json:"project_name,omitempty"`
+	Client          string `json:"client,omitempty"`
+	Company         string `json:"company,omitempty"`
+	SurveyorName    string `json:"surveyor_name"`
+	SurveyorLicence string `json:"surveyor_licence,omitempty"`
+	SurveyDate      string `json:"survey_date,omitempty"` // as the surveyor wrote it
+	Notes           string `json:"notes,omitempty"`
+}
+
+// Certificate - everything that goes on the QC sheet
+type Certificate struct {
+	Meta     Metadata
+	Data     *models.SurveyData
+	Report   *models.ValidationReport
+	Leveling *models.LevelingResult
+	Issued   time.Time
+}
+
+// New - certificate for a validated dataset, leveling is optional
+func New(meta Metadata, data *models.SurveyData, report *models.ValidationReport, leveling *models.LevelingResult) *Certificate {
+	if meta.ProjectName == "" {
+		meta.ProjectName = report.ProjectID
+	}
+	return &Certificate{
+		Meta:     meta,
+		Data:     data,
+		Report:   report,
+		Leveling: leveling,
+		Issued:   time.Now(),
+	}
+}
+
+// table - a titled table both renderers can draw
+type table struct {
+	Title   string
+	Headers []string
+	Rows    [][]string
+	Widths  []float64 // PDF column widths in points
+	Empty   string    // shown instead of an empty table
+
+	SeverityColumn bool // first column is an issue severity, colour it
+}
+
+// field - label/value pair for the summary blocks
+type field struct {
+	Label string
+	Value string
+}
+
+func (c *Certificate) projectFields() []field {
+	fields := []field{
+		{"Project", c.Meta.ProjectName},
+		{"Project ID", c.Report.ProjectID},
+	}
+	if c.Meta.Client != "" {
+		fields = append(fields, field{"Client", c.Meta.Client})
+	}
+	if c.Meta.Company != "" {
+		fields = append(fields, field{"Company", c.Meta.Company})
+	}
+	if c.Data != nil && c.Data.CoordinateSystem != "" {
+		fields = append(fields, field{"Coordinate system", c.Data.CoordinateSystem})
+	}
+	if c.Meta.SurveyDate != "" {
+		fields = append(fields, field{"Survey date", c.Meta.SurveyDate})
+	}
+	fields = append(fields,
+		field{"Validated", c.Report.Timestamp.Format("2006-01-02 15:04 MST")},
+		field{"Certificate issued", c.Issued.Format("2006-01-02")},
+	)
+	return fields
+}
+
+func (c *Certificate) summaryFields() []field {
+	s := c.Report.Summary
+	return []field{
+		{"Status", string(c.Report.Status)},
+		{"Confidence score", fmt.Sprintf("%.0f / 100", c.Report.ConfidenceScore)},
+		{"Total points", fmt.Sprint(s.TotalPoints)},
+		{"Control / traverse / detail", fmt.Sprintf("%d / %d / %d", s.ControlPoints, s.TraversePoints, s.DetailPoints)},
+		{"Points with height", fmt.Sprint(s.PointsWithHeight)},
+		{"Extent (E x N)", fmt.Sprintf("%.1fm x %.1fm",
+			s.BoundingBox.MaxEasting-s.BoundingBox.MinEasting,
+			s.BoundingBox.MaxNorthing-s.BoundingBox.MinNorthing)},
+		{"Checks performed", strings.Join(c.Report.ChecksPerformed, ", ")},
+	}
+}
+
+// closureStatement - the sentence clients actually read
+func (c *Certificate) closureStatement() string {
+	tr := c.Report.TraverseResult
+	if tr == nil {
+		return "No traverse was submitted, so no closure was computed."
+	}
+	if tr.Status == "ERROR" {
+		return tr.Message
+	}
+
+	verdict := "meets"
+	if tr.Status != "PASS" {
+		verdict = "does not meet"
+	}
+	return fmt.Sprintf("The %s traverse of %.3fm closes with a linear misclosure of %.4fm "+
+		"(dE %.4fm, dN %.4fm), a relative precision of %s, which %s the required 1:%.0f.",
+		tr.TraverseType, tr.TotalDistance, tr.LinearMisclosure, tr.SumDeltaE, tr.SumDeltaN,
+		tr.ClosureRatio, verdict, tr.RequiredPrecision)
+}
+
+func (c *Certificate) levelingStatement() string {
+	lv := c.Leveling
+	if lv == nil {
+		return ""
+	}
+	return fmt.Sprintf("Level run %s to %s over %.3fkm: misclosure %.4fm against %.4fm allowable (%s).",
+		lv.StartBM, lv.EndBM, lv.TotalDistance, lv.HeightMisclosure, lv.AllowableMisc, lv.Status)
+}
+
+func (c *Certificate) tables() []table {
+	issues := table{
+		Title:   "Issues",
+		Headers: []string{"Severity", "Check", "Points", "Description"},
+		Widths:  []float64{55, 110, 80, 250},
+		Empty:   "No issues found.",
+
+		SeverityColumn: true,
+	}
+	for _, issue := range c.Report.Issues {
+		issues.Rows = append(issues.Rows, []string{
+			string(issue.Severity),
+			issue.CheckName,
+			strings.Join(issue.PointIDs, ", "),
+			issue.Description,
+		})
+	}
+	tables := []table{issues}
+
+	if b := c.Report.ScoreBreakdown; b != nil && len(b.Deductions) > 0 {
+		score := table{
+			Title:   "Score deductions",
+			Headers: []string{"Check", "Category", "Points", "Reason"},
+			Widths:  []float64{110, 65, 45, 275},
+		}
+		for _, d := range b.Deductions {
+			name := d.CheckName
+			if name == "" {
+				name = "-"
+			}
+			score.Rows = append(score.Rows, []string{name, d.Category, fmt.Sprintf("-%.1f", d.Points), d.Reason})
+		}
+		tables = append(tables, score)
+	}
+
+	if len(c.Report.Suppressed) > 0 {
+		accepted := table{
+			Title:   "Accepted issues",
+			Headers: []string{"Check", "Points", "Description", "Justification"},
+			Widths:  []float64{100, 70, 170, 155},
+		}
+		for _, issue := range c.Report.Suppressed {
+			why := issue.Suppression.Justification
+			if by := issue.Suppression.AcknowledgedBy; by != "" {
+				why += " (" + by + ")"
+			}
+			accepted.Rows = append(accepted.Rows, []string{
+				issue.CheckName,
+				strings.Join(issue.PointIDs, ", "),
+				issue.Description,
+				why,
+			})
+		}
+		tables = append(tables, accepted)
+	}
+
+	if tr := c.Report.TraverseResult; tr != nil && len(tr.Legs) > 0 {
+		legs := table{
+			Title:   "Traverse legs",
+			Headers: []string{"From", "To", "Distance", "Bearing", "Corr E", "Corr N", "Adj dE", "Adj dN"},
+			Widths:  []float64{45, 45, 65, 75, 55, 55, 75, 75},
+		}
+		for _, leg := range tr.Legs {
+			legs.Rows = append(legs.Rows, []string{
+				leg.FromPoint,
+				leg.ToPoint,
+				fmt.Sprintf("%.3f", leg.Distance),
+				FormatBearing(leg.Bearing),
+				fmt.Sprintf("%+.4f", leg.CorrectionE),
+				fmt.Sprintf("%+.4f", leg.CorrectionN),
+				fmt.Sprintf("%.3f", leg.AdjustedDE),
+				fmt.Sprintf("%.3f", leg.AdjustedDN),
+			})
+		}
+		tables = append(tables, legs)
+
+		adjusted := table{
+			Title:   "Adjusted coordinates",
+			Headers: []string{"Point", "Raw E", "Raw N", "Adj E", "Adj N", "Residual"},
+			Widths:  []float64{60, 85, 90, 85, 90, 70},
+		}
+		for _, ap := range tr.AdjustedPoints {
+			adjusted.Rows = append(adjusted.Rows, []string{
+				ap.PointID,
+				fmt.Sprintf("%.3f", ap.RawEasting),
+				fmt.Sprintf("%.3f", ap.RawNorthing),
+				fmt.Sprintf("%.3f", ap.AdjEasting),
+				fmt.Sprintf("%.3f", ap.AdjNorthing),
+				fmt.Sprintf("%.4f", ap.ResidualDist),
+			})
+		}
+		tables = append(tables, adjusted)
+	}
+
+	if lv := c.Leveling; lv != nil && len(lv.Points) > 0 {
+		levels := table{
+			Title:   "Leveling",
+			Headers: []string{"Point", "Rise", "Fall", "Raw RL", "Correction", "Adjusted RL"},
+			Widths:  []float64{70, 70, 70, 90, 90, 90},
+		}
+		for _, lp := range lv.Points {
+			levels.Rows = append(levels.Rows, []string{
+				lp.PointID,
+				blankZero("%.4f", lp.Rise),
+				blankZero("%.4f", lp.Fall),
+				fmt.Sprintf("%.4f", lp.RawRL),
+				fmt.Sprintf("%+.4f", lp.Correction),
+				fmt.Sprintf("%.4f", lp.AdjustedRL),
+			})
+		}
+		tables = append(tables, levels)
+	}
+
+	return tables
+}
+
+// FormatBearing - decimal degrees as ddd°mm'ss"
+func FormatBearing(deg float64) string {
+	totalSec := math.Round(deg * 3600)
+	d := int(totalSec) / 3600
+	m := (int(totalSec) % 3600) / 60
+	s := int(totalSec) % 60
+	return fmt.Sprintf("%03d°%02d'%02d\"", d%360, m, s)
+}
+
+func blankZero(format string, v float64) string {
+	if v == 0 {
+		return ""
+	}
+	return fmt.Sprintf(format, v)
+}
+
+// sketchPoint - a point placed in sketch space (origin top left)
+type sketchPoint struct {
+	ID       string
+	X, Y     float64
+	Type     models.SurveyType
+	Severity models.IssueSeverity
+}
+
+// sketch - points and traverse line scaled into a w x h box, north up,
+// same scale on both axes
+type sketch struct {
+	Points   []sketchPoint
+	Traverse [][2]float64
+	Width    float64
+	Height   float64
+	ScaleBar float64 // length of a round-number scale bar in box units
+	BarLabel string
+}
+
+func (c *Certificate) sketch(w, h float64) *sketch {
+	sk := &sketch{Width: w, Height: h}
+	if c.Data == nil || len(c.Data.Points) == 0 {
+		return sk
+	}
+
+	minE, maxE := c.Data.Points[0].Easting, c.Data.Points[0].Easting
+	minN, maxN := c.Data.Points[0].Northing, c.Data.Points[0].Northing
+	for _, p := range c.Data.Points {
+		minE, maxE = math.Min(minE, p.Easting), math.Max(maxE, p.Easting)
+		minN, maxN = math.Min(minN, p.Northing), math.Max(maxN, p.Northing)
+	}
+
+	const pad = 20.0
+	spanE := math.Max(maxE-minE, 1)
+	spanN := math.Max(maxN-minN, 1)
+	scale := math.Min((w-2*pad)/spanE, (h-2*pad)/spanN)
+	offX := (w - spanE*scale) / 2
+	offY := (h - spanN*scale) / 2
+
+	place := func(e, n float64) (float64, float64) {
+		return offX + (e-minE)*scale, h - (offY + (n-minN)*scale)
+	}
+
+	worst := make(map[string]models.IssueSeverity)
+	for _, issue := range c.Report.Issues {
+		for _, id := range issue.PointIDs {
+			if issue.Severity == models.SeverityError || worst[id] == "" {
+				worst[id] = issue.Severity
+			}
+		}
+	}
+
+	for _, p := range c.Data.Points {
+		x, y := place(p.Easting, p.Northing)
+		sev := worst[p.PointID]
+		if sev == models.SeverityInfo {
+			sev = ""
+		}
+		sk.Points = append(sk.Points, sketchPoint{ID: p.PointID, X: x, Y: y, Type: p.SurveyType, Severity: sev})
+		if p.SurveyType == models.SurveyTypeTraverse {
+			sk.Traverse = append(sk.Traverse, [2]float64{x, y})
+		}
+	}
+
+	// scale bar about a fifth of the box wide, rounded to 1/2/5 x 10^n meters
+	target := (w / 5) / scale
+	mag := math.Pow(10, math.Floor(math.Log10(target)))
+	bar := mag
+	for _, m := range []float64{2, 5, 10} {
+		if m*mag <= target {
+			bar = m * mag
+		}
+	}
+	sk.ScaleBar = bar * scale
+	sk.BarLabel = fmt.Sprintf("%gm", bar)
+	return sk
+}
@@ -0,0 +1,141 @@
+package certificate
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/survey-validator/engine"
+	"github.com/survey-validator/models"
+)
+
+func sampleCertificate() *Certificate {
+	data := &models.SurveyData{
+		ProjectID:        "QC-001",
+		CoordinateSystem: "UTM Zone 36N",
+		Points: []models.SurveyPoint{
+			{PointID: "CP1", Easting: 500000, Northing: 100000, SurveyType: models.SurveyTypeControl},
+			{PointID: "T1", Easting: 500000, Northing: 100000, SurveyType: models.SurveyTypeTraverse},
+			{PointID: "T2", Easting: 500100.01, Northing: 100000, SurveyType: models.SurveyTypeTraverse},
+			{PointID: "T3", Easting: 500100, Northing: 100100.02, SurveyType: models.SurveyTypeTraverse},
+			{PointID: "T4", Easting: 500000, Northing: 100100, SurveyType: models.SurveyTypeTraverse},
+			{PointID: "T5", Easting: 500000.01, Northing: 100000.01, SurveyType: models.SurveyTypeTraverse},
+			{PointID: "D1", Easting: 500050, Northing: 100050, SurveyType: models.SurveyTypeDetail},
+		},
+	}
+	report := engine.NewEngine().Validate(data)
+	meta := Metadata{
+		ProjectName:  "Riverside (Phase 2)",
+		Client:       "Acme Homes",
+		SurveyorName: "J. Banda",
+		SurveyDate:   "2026-03-14",
+	}
+	return New(meta, data, report, nil)
+}
+
+func TestFormatBearing(t *testing.T) {
+	tests := []struct {
+		in       float64
+		expected string
+	}{
+		{0, "000°00'00\""},
+		{45.5, "045°30'00\""},
+		{359.99999, "000°00'00\""},
+		{123.25625, "123°15'23\""},
+	}
+
+	for _, tt := range tests {
+		if got := FormatBearing(tt.in); got != tt.expected {
+			t.Errorf("FormatBearing(%v) = %q; expected %q", tt.in, got, tt.expected)
+		}
+	}
+}
+
+func TestClosureStatement(t *testing.T) {
+	c := sampleCertificate()
+	if c.Report.TraverseResult == nil {
+		t.Fatal("Expected a traverse result")
+	}
+
+	s := c.closureStatement()
+	if !strings.Contains(s, "closed traverse") || !strings.Contains(s, c.Report.TraverseResult.ClosureRatio) {
+		t.Errorf("Unexpected closure statement: %s", s)
+	}
+
+	c.Report.TraverseResult = nil
+	if s := c.closureStatement(); !strings.Contains(s, "No traverse") {
+		t.Errorf("Expected no-traverse statement, got %s", s)
+	}
+}
+
+func TestWriteHTML(t *testing.T) {
+	var buf bytes.Buffer
+	if err := sampleCertificate().WriteHTML(&buf); err != nil {
+		t.Fatalf("WriteHTML failed: %v", err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{
+		"Survey QC Certificate",
+		"Riverside (Phase 2)",
+		"J. Banda",
+		"Traverse legs",
+		"Adjusted coordinates",
+		"<svg",
+		"<circle",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected HTML to contain %q", want)
+		}
+	}
+}
+
+func TestWritePDF(t *testing.T) {
+	var buf bytes.Buffer
+	if err := sampleCertificate().WritePDF(&buf); err != nil {
+		t.Fatalf("WritePDF failed: %v", err)
+	}
+
+	out := buf.String()
+	if !strings.HasPrefix(out, "%PDF-1.4") {
+		t.Error("Expected PDF header")
+	}
+	if !strings.HasSuffix(out, "%%EOF\n") {
+		t.Error("Expected PDF trailer")
+	}
+	// parentheses in text must be escaped inside string literals
+	if !strings.Contains(out, `Riverside \(Phase 2\)`) {
+		t.Error("Expected escaped project name")
+	}
+}
+
+func TestPDFEscape(t *testing.T) {
+	tests := map[string]string{
+		"N 45°30'":     `N 45\26030'`,
+		"José Müller":  `Jos\351 M\374ller`,
+		"“Lot 4” – €5": `\223Lot 4\224 \226 \2005`,
+		"Ω(2)":         `?\(2\)`,
+		"∞ and \t tab": `inf and ? tab`,
+	}
+	for in, want := range tests {
+		if got := pdfEscape(in); got != want {
+			t.Errorf("pdfEscape(%q) = %q, expected %q", in, got, want)
+		}
+	}
+}
+
+func TestWrapText(t *testing.T) {
+	lines := wrapText("the quick brown fox jumps over the lazy dog", 60, 10)
+	if len(lines) < 2 {
+		t.Fatalf("Expected text to wrap, got %v", lines)
+	}
+	for _, l := range lines {
+		if len(l) > 11 {
+			t.Errorf("Line %q is wider than the box", l)
+		}
+	}
+
+	if lines := wrapText("", 100, 10); len(lines) != 1 {
+		t.Errorf("Expected one empty line, got %v", lines)
+	}
+}
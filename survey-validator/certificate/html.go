@@ -0,0 +1,151 @@
+package certificate
+
+// html.go - self-contained HTML certificate (inline CSS and SVG sketch)
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+
+	"github.com/survey-validator/models"
+)
+
+var htmlTemplate = template.Must(template.New("certificate").Funcs(template.FuncMap{
+	"lower": strings.ToLower,
+	"markerColor": func(p sketchPoint) string {
+		switch p.Severity {
+		case models.SeverityError:
+			return "#dc2626"
+		case models.SeverityWarning:
+			return "#f59e0b"
+		}
+		switch p.Type {
+		case models.SurveyTypeControl:
+			return "#16a34a"
+		case models.SurveyTypeTraverse:
+			return "#2563eb"
+		}
+		return "#64748b"
+	},
+	"polyline": func(pts [][2]float64) string {
+		var b strings.Builder
+		for i, p := range pts {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteString(formatXY(p[0], p[1]))
+		}
+		return b.String()
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>QC Certificate - {{.Meta.ProjectName}}</title>
+<style>
+  body { font-family: Helvetica, Arial, sans-serif; color: #1e293b; margin: 2em auto; max-width: 60em; font-size: 10pt; }
+  h1 { font-size: 18pt; margin-bottom: 0.2em; }
+  h2 { font-size: 12pt; border-bottom: 1px solid #cbd5e1; padding-bottom: 0.2em; margin-top: 1.6em; }
+  table { border-collapse: collapse; width: 100%; }
+  th, td { text-align: left; padding: 0.25em 0.5em; border-bottom: 1px solid #e2e8f0; vertical-align: top; }
+  th { background: #f1f5f9; }
+  dl { display: grid; grid-template-columns: 12em auto; gap: 0.2em 1em; margin: 0; }
+  dt { font-weight: bold; }
+  dd { margin: 0; }
+  .status { display: inline-block; padding: 0.2em 0.8em; border-radius: 0.3em; color: #fff; font-weight: bold; }
+  .status-pass { background: #16a34a; }
+  .status-warning { background: #f59e0b; }
+  .status-fail { background: #dc2626; }
+  .sev-error { color: #dc2626; font-weight: bold; }
+  .sev-warning { color: #b45309; font-weight: bold; }
+  .statement { background: #f8fafc; border-left: 3px solid #2563eb; padding: 0.5em 1em; }
+  .sketch { border: 1px solid #cbd5e1; }
+  .signature { margin-top: 3em; display: grid; grid-template-columns: 1fr 1fr; gap: 3em; }
+  .signature div { border-top: 1px solid #1e293b; padding-top: 0.3em; }
+  @media print { body { margin: 0; } h2 { break-after: avoid; } table { break-inside: auto; } }
+</style>
+</head>
+<body>
+<h1>Survey QC Certificate</h1>
+<p><span class="status status-{{lower (printf "%s" .Report.Status)}}">{{.Report.Status}}</span></p>
+
+<h2>Project</h2>
+<dl>{{range .Project}}<dt>{{.Label}}</dt><dd>{{.Value}}</dd>{{end}}</dl>
+
+<h2>Summary</h2>
+<dl>{{range .Summary}}<dt>{{.Label}}</dt><dd>{{.Value}}</dd>{{end}}</dl>
+
+<h2>Closure</h2>
+<p class="statement">{{.Closure}}</p>
+{{if .Leveling}}<p class="statement">{{.Leveling}}</p>{{end}}
+
+<h2>Sketch</h2>
+<svg class="sketch" xmlns="http://www.w3.org/2000/svg" width="{{.Sketch.Width}}" height="{{.Sketch.Height}}" viewBox="0 0 {{.Sketch.Width}} {{.Sketch.Height}}">
+  {{if .Sketch.Traverse}}<polyline points="{{polyline .Sketch.Traverse}}" fill="none" stroke="#2563eb" stroke-width="1.5"/>{{end}}
+  {{range .Sketch.Points}}<circle cx="{{.X}}" cy="{{.Y}}" r="3" fill="{{markerColor .}}"/>
+  <text x="{{.X}}" y="{{.Y}}" dx="5" dy="-5" font-size="9">{{.ID}}</text>
+  {{end}}
+  <line x1="10" y1="{{.SketchBarY}}" x2="{{.SketchBarEnd}}" y2="{{.SketchBarY}}" stroke="#1e293b" stroke-width="2"/>
+  <text x="10" y="{{.SketchBarY}}" dy="-4" font-size="9">{{.Sketch.BarLabel}}</text>
+  <text x="{{.SketchNorthX}}" y="18" font-size="12" font-weight="bold" text-anchor="middle">N &#8593;</text>
+</svg>
+
+{{range .Tables}}
+<h2>{{.Title}}</h2>
+{{if .Rows}}{{$sev := .SeverityColumn}}<table>
+  <tr>{{range .Headers}}<th>{{.}}</th>{{end}}</tr>
+  {{range .Rows}}<tr>{{range $i, $cell := .}}<td{{if and $sev (eq $i 0)}} class="sev-{{$cell}}"{{end}}>{{$cell}}</td>{{end}}</tr>
+  {{end}}
+</table>{{else}}<p>{{.Empty}}</p>{{end}}
+{{end}}
+
+{{if .Meta.Notes}}<h2>Notes</h2><p>{{.Meta.Notes}}</p>{{end}}
+
+<div class="signature">
+  <div>{{.Meta.SurveyorName}}{{if .Meta.SurveyorLicence}} ({{.Meta.SurveyorLicence}}){{end}}<br>Surveyor</div>
+  <div>{{.Issued}}<br>Date</div>
+</div>
+</body>
+</html>
+`))
+
+func formatXY(x, y float64) string {
+	return fmt.Sprintf("%.1f,%.1f", x, y)
+}
+
+// htmlView - flattened view for the template
+type htmlView struct {
+	Meta         Metadata
+	Report       *models.ValidationReport
+	Project      []field
+	Summary      []field
+	Closure      string
+	Leveling     string
+	Sketch       *sketch
+	SketchBarY   float64
+	SketchBarEnd float64
+	SketchNorthX float64
+	Tables       []table
+	Issued       string
+}
+
+// WriteHTML - render the certificate as a single HTML page, print-ready
+func (c *Certificate) WriteHTML(w io.Writer) error {
+	sk := c.sketch(640, 400)
+	view := htmlView{
+		Meta:         c.Meta,
+		Report:       c.Report,
+		Project:      c.projectFields(),
+		Summary:      c.summaryFields(),
+		Closure:      c.closureStatement(),
+		Leveling:     c.levelingStatement(),
+		Sketch:       sk,
+		SketchBarY:   sk.Height - 10,
+		SketchBarEnd: 10 + sk.ScaleBar,
+		SketchNorthX: sk.Width - 20,
+		Tables:       c.tables(),
+		Issued:       c.Issued.Format("2006-01-02"),
+	}
+	return htmlTemplate.Execute(w, view)
+}
@@ -0,0 +1,448 @@
+package certificate
+
+// pdf.go - a small PDF 1.4 writer, just enough for the certificate:
+// Helvetica text, lines, rectangles and circles on A4 pages
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/survey-validator/models"
+)
+
+// A4 in points, with margins
+const (
+	pageWidth    = 595.0
+	pageHeight   = 842.0
+	marginLeft   = 50.0
+	marginRight  = 50.0
+	marginTop    = 50.0
+	marginBottom = 50.0
+	contentWidth = pageWidth - marginLeft - marginRight
+)
+
+type rgb [3]float64
+
+var (
+	colorText    = rgb{0.12, 0.16, 0.23}
+	colorMuted   = rgb{0.39, 0.45, 0.55}
+	colorRule    = rgb{0.80, 0.84, 0.88}
+	colorHeader  = rgb{0.95, 0.96, 0.98}
+	colorPass    = rgb{0.09, 0.64, 0.29}
+	colorWarning = rgb{0.96, 0.62, 0.04}
+	colorFail    = rgb{0.86, 0.15, 0.15}
+	colorControl = rgb{0.09, 0.64, 0.29}
+	colorStation = rgb{0.15, 0.39, 0.92}
+	colorDetail  = rgb{0.39, 0.45, 0.55}
+)
+
+// pdfWriter - pages of content stream operators plus a cursor for flowing layout
+type pdfWriter struct {
+	pages []*bytes.Buffer
+	page  *bytes.Buffer
+	y     float64 // next baseline, measured from the bottom like PDF does
+	title string
+}
+
+func newPDFWriter(title string) *pdfWriter {
+	p := &pdfWriter{title: title}
+	p.newPage()
+	return p
+}
+
+func (p *pdfWriter) newPage() {
+	p.page = &bytes.Buffer{}
+	p.pages = append(p.pages, p.page)
+	p.y = pageHeight - marginTop
+}
+
+// ensure - break to a new page unless h points are left
+func (p *pdfWriter) ensure(h float64) {
+	if p.y-h < marginBottom {
+		p.newPage()
+	}
+}
+
+func (p *pdfWriter) text(x, y, size float64, bold bool, c rgb, s string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(p.page, "BT %.3f %.3f %.3f rg /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n",
+		c[0], c[1], c[2], font, size, x, y, pdfEscape(s))
+}
+
+func (p *pdfWriter) line(x1, y1, x2, y2, width float64, c rgb) {
+	fmt.Fprintf(p.page, "%.3f %.3f %.3f RG %.2f w %.2f %.2f m %.2f %.2f l S\n",
+		c[0], c[1], c[2], width, x1, y1, x2, y2)
+}
+
+func (p *pdfWriter) rect(x, y, w, h float64, fill rgb) {
+	fmt.Fprintf(p.page, "%.3f %.3f %.3f rg %.2f %.2f %.2f %.2f re f\n",
+		fill[0], fill[1], fill[2], x, y, w, h)
+}
+
+func (p *pdfWriter) strokeRect(x, y, w, h float64, c rgb) {
+	fmt.Fprintf(p.page, "%.3f %.3f %.3f RG 0.5 w %.2f %.2f %.2f %.2f re S\n",
+		c[0], c[1], c[2], x, y, w, h)
+}
+
+// circle - four bezier quarter arcs, filled
+func (p *pdfWriter) circle(x, y, r float64, fill rgb) {
+	const k = 0.5523
+	fmt.Fprintf(p.page, "%.3f %.3f %.3f rg %.2f %.2f m ", fill[0], fill[1], fill[2], x+r, y)
+	fmt.Fprintf(p.page, "%.2f %.2f %.2f %.2f %.2f %.2f c ", x+r, y+k*r, x+k*r, y+r, x, y+r)
+	fmt.Fprintf(p.page, "%.2f %.2f %.2f %.2f %.2f %.2f c ", x-k*r, y+r, x-r, y+k*r, x-r, y)
+	fmt.Fprintf(p.page, "%.2f %.2f %.2f %.2f %.2f %.2f c ", x-r, y-k*r, x-k*r, y-r, x, y-r)
+	fmt.Fprintf(p.page, "%.2f %.2f %.2f %.2f %.2f %.2f c f\n", x+k*r, y-r, x+r, y-k*r, x+r, y)
+}
+
+func (p *pdfWriter) polyline(pts [][2]float64, width float64, c rgb) {
+	if len(pts) < 2 {
+		return
+	}
+	fmt.Fprintf(p.page, "%.3f %.3f %.3f RG %.2f w %.2f %.2f m", c[0], c[1], c[2], width, pts[0][0], pts[0][1])
+	for _, pt := range pts[1:] {
+		fmt.Fprintf(p.page, " %.2f %.2f l", pt[0], pt[1])
+	}
+	p.page.WriteString(" S\n")
+}
+
+// heading - section title with a rule under it
+func (p *pdfWriter) heading(s string) {
+	p.ensure(40)
+	p.y -= 18
+	p.text(marginLeft, p.y, 12, true, colorText, s)
+	p.y -= 5
+	p.line(marginLeft, p.y, pageWidth-marginRight, p.y, 0.5, colorRule)
+	p.y -= 14
+}
+
+// paragraph - wrapped body text
+func (p *pdfWriter) paragraph(s string, size float64) {
+	for _, line := range wrapText(s, contentWidth, size) {
+		p.ensure(size + 4)
+		p.text(marginLeft, p.y, size, false, colorText, line)
+		p.y -= size + 4
+	}
+}
+
+// fields - two column label/value block
+func (p *pdfWriter) fields(fields []field) {
+	for _, f := range fields {
+		lines := wrapText(f.Value, contentWidth-140, 9)
+		p.ensure(float64(len(lines)) * 13)
+		p.text(marginLeft, p.y, 9, true, colorText, f.Label)
+		for _, line := range lines {
+			p.text(marginLeft+140, p.y, 9, false, colorText, line)
+			p.y -= 13
+		}
+	}
+}
+
+// table - header row, zebra-free rows, repeats the header after a page break
+func (p *pdfWriter) table(t table) {
+	p.heading(t.Title)
+	if len(t.Rows) == 0 {
+		p.paragraph(t.Empty, 9)
+		return
+	}
+
+	const size, rowH = 8.0, 12.0
+	header := func() {
+		p.rect(marginLeft, p.y-3, contentWidth, rowH, colorHeader)
+		x := marginLeft + 2
+		for i, h := range t.Headers {
+			p.text(x, p.y, size, true, colorText, h)
+			x += t.Widths[i]
+		}
+		p.y -= rowH
+	}
+
+	p.ensure(rowH * 2)
+	header()
+	for _, row := range t.Rows {
+		// wrap the widest cells, everything else is short
+		cells := make([][]string, len(row))
+		lines := 1
+		for i, cell := range row {
+			cells[i] = wrapText(cell, t.Widths[i]-4, size)
+			if len(cells[i]) > lines {
+				lines = len(cells[i])
+			}
+		}
+
+		if p.y-float64(lines)*rowH < marginBottom {
+			p.newPage()
+			header()
+		}
+
+		x := marginLeft + 2
+		for i, cell := range cells {
+			c := colorText
+			if t.SeverityColumn && i == 0 {
+				c = severityColor(models.IssueSeverity(row[0]))
+			}
+			for j, line := range cell {
+				p.text(x, p.y-float64(j)*rowH, size, t.SeverityColumn && i == 0, c, line)
+			}
+			x += t.Widths[i]
+		}
+		p.y -= float64(lines) * rowH
+		p.line(marginLeft, p.y+rowH-3, pageWidth-marginRight, p.y+rowH-3, 0.25, colorRule)
+	}
+}
+
+// sketch - the plotted points in a framed box
+func (p *pdfWriter) sketch(sk *sketch) {
+	p.heading("Sketch")
+	p.ensure(sk.Height + 10)
+
+	top := p.y + 8
+	left := marginLeft
+	bottom := top - sk.Height
+	p.strokeRect(left, bottom, sk.Width, sk.Height, colorRule)
+
+	// sketch space is top-left origin, PDF is bottom-left
+	place := func(x, y float64) (float64, float64) { return left + x, top - y }
+
+	if len(sk.Traverse) > 1 {
+		pts := make([][2]float64, len(sk.Traverse))
+		for i, t := range sk.Traverse {
+			pts[i][0], pts[i][1] = place(t[0], t[1])
+		}
+		p.polyline(pts, 1, colorStation)
+	}
+	for _, pt := range sk.Points {
+		x, y := place(pt.X, pt.Y)
+		p.circle(x, y, 2.5, pointColor(pt))
+		p.text(x+4, y+4, 7, false, colorMuted, pt.ID)
+	}
+
+	// scale bar and north arrow
+	p.line(left+10, bottom+10, left+10+sk.ScaleBar, bottom+10, 1.5, colorText)
+	p.text(left+10, bottom+14, 7, false, colorText, sk.BarLabel)
+	nx := left + sk.Width - 20
+	p.line(nx, top-30, nx, top-12, 1, colorText)
+	p.text(nx-3, top-40, 9, true, colorText, "N")
+
+	p.y = bottom - 10
+}
+
+// WritePDF - render the certificate as a PDF
+func (c *Certificate) WritePDF(w io.Writer) error {
+	p := newPDFWriter("QC Certificate - " + c.Meta.ProjectName)
+
+	p.text(marginLeft, p.y, 18, true, colorText, "Survey QC Certificate")
+	status := string(c.Report.Status)
+	p.rect(pageWidth-marginRight-90, p.y-6, 90, 24, statusColor(c.Report.Status))
+	p.text(pageWidth-marginRight-90+(90-textWidth(status, 12, true))/2, p.y+1, 12, true, rgb{1, 1, 1}, status)
+	p.y -= 14
+
+	p.heading("Project")
+	p.fields(c.projectFields())
+	p.heading("Summary")
+	p.fields(c.summaryFields())
+
+	p.heading("Closure")
+	p.paragraph(c.closureStatement(), 9)
+	if s := c.levelingStatement(); s != "" {
+		p.y -= 4
+		p.paragraph(s, 9)
+	}
+
+	p.sketch(c.sketch(contentWidth, 280))
+
+	for _, t := range c.tables() {
+		p.table(t)
+	}
+
+	if c.Meta.Notes != "" {
+		p.heading("Notes")
+		p.paragraph(c.Meta.Notes, 9)
+	}
+
+	// signature block
+	p.ensure(70)
+	p.y -= 50
+	half := contentWidth/2 - 20
+	p.line(marginLeft, p.y, marginLeft+half, p.y, 0.75, colorText)
+	p.line(marginLeft+half+40, p.y, pageWidth-marginRight, p.y, 0.75, colorText)
+	signer := c.Meta.SurveyorName
+	if c.Meta.SurveyorLicence != "" {
+		signer += " (" + c.Meta.SurveyorLicence + ")"
+	}
+	p.text(marginLeft, p.y-12, 9, false, colorText, signer)
+	p.text(marginLeft, p.y-23, 8, false, colorMuted, "Surveyor")
+	p.text(marginLeft+half+40, p.y-12, 9, false, colorText, c.Issued.Format("2006-01-02"))
+	p.text(marginLeft+half+40, p.y-23, 8, false, colorMuted, "Date")
+
+	// page numbers
+	for i, page := range p.pages {
+		fmt.Fprintf(page, "BT %.3f %.3f %.3f rg /F1 7 Tf %.2f %.2f Td (%s) Tj ET\n",
+			colorMuted[0], colorMuted[1], colorMuted[2], marginLeft, marginBottom-20.0,
+			pdfEscape(fmt.Sprintf("%s - page %d of %d", c.Report.ProjectID, i+1, len(p.pages))))
+	}
+
+	return p.writeTo(w)
+}
+
+// writeTo - assemble the objects and cross-reference table
+func (p *pdfWriter) writeTo(w io.Writer) error {
+	var buf bytes.Buffer
+	var offsets []int
+
+	obj := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// 1 catalog, 2 pages, 3-4 fonts, 5 info, then page/content pairs
+	const firstPage = 6
+	kids := make([]string, len(p.pages))
+	for i := range p.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPage+i*2)
+	}
+
+	obj("<< /Type /Catalog /Pages 2 0 R >>")
+	obj(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(p.pages)))
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	obj(fmt.Sprintf("<< /Title (%s) /Producer (survey-validator) >>", pdfEscape(p.title)))
+
+	for i, page := range p.pages {
+		obj(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] "+
+			"/Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pageWidth, pageHeight, firstPage+i*2+1))
+		obj(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.Len(), page.String()))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R /Info 5 0 R >>\nstartxref\n%d\n%%%%EOF\n",
+		len(offsets)+1, xref)
+
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// winAnsi - the characters WinAnsiEncoding puts in 0x80-0x9F, where
+// Latin-1 has control codes. 0xA0-0xFF are Latin-1 as is.
+var winAnsi = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87,
+	'ˆ': 0x88, '‰': 0x89, 'Š': 0x8A, '‹': 0x8B, 'Œ': 0x8C, 'Ž': 0x8E,
+	'‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97,
+	'˜': 0x98, '™': 0x99, 'š': 0x9A, '›': 0x9B, 'œ': 0x9C, 'ž': 0x9E, 'Ÿ': 0x9F,
+}
+
+// pdfEscape - string literal escaping, with anything past ASCII written as
+// its WinAnsi byte in octal (surveyor names, degree signs, curly quotes).
+// Characters the fonts' encoding doesn't have come out as ?.
+func pdfEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '∞':
+			b.WriteString("inf")
+		case r >= 32 && r <= 126:
+			b.WriteRune(r)
+		case r >= 0xA0 && r <= 0xFF:
+			fmt.Fprintf(&b, "\\%03o", r)
+		case winAnsi[r] != 0:
+			fmt.Fprintf(&b, "\\%03o", winAnsi[r])
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// textWidth - rough Helvetica width, good enough for wrapping and centring
+func textWidth(s string, size float64, bold bool) float64 {
+	avg := 0.52
+	if bold {
+		avg = 0.56
+	}
+	return float64(len([]rune(s))) * size * avg
+}
+
+// wrapText - break on spaces to fit width, hard-break words that never fit
+func wrapText(s string, width, size float64) []string {
+	maxChars := int(width / (size * 0.52))
+	if maxChars < 1 {
+		maxChars = 1
+	}
+
+	var lines []string
+	var cur string
+	for _, word := range strings.Fields(s) {
+		for len([]rune(word)) > maxChars {
+			if cur != "" {
+				lines = append(lines, cur)
+				cur = ""
+			}
+			r := []rune(word)
+			lines = append(lines, string(r[:maxChars]))
+			word = string(r[maxChars:])
+		}
+		switch {
+		case cur == "":
+			cur = word
+		case len([]rune(cur))+1+len([]rune(word)) <= maxChars:
+			cur += " " + word
+		default:
+			lines = append(lines, cur)
+			cur = word
+		}
+	}
+	if cur != "" || len(lines) == 0 {
+		lines = append(lines, cur)
+	}
+	return lines
+}
+
+func statusColor(s models.ValidationStatus) rgb {
+	switch s {
+	case models.StatusFail:
+		return colorFail
+	case models.StatusWarning:
+		return colorWarning
+	}
+	return colorPass
+}
+
+func severityColor(s models.IssueSeverity) rgb {
+	switch s {
+	case models.SeverityError:
+		return colorFail
+	case models.SeverityWarning:
+		return colorWarning
+	}
+	return colorText
+}
+
+func pointColor(pt sketchPoint) rgb {
+	switch pt.Severity {
+	case models.SeverityError:
+		return colorFail
+	case models.SeverityWarning:
+		return colorWarning
+	}
+	switch pt.Type {
+	case models.SurveyTypeControl:
+		return colorControl
+	case models.SurveyTypeTraverse:
+		return colorStation
+	}
+	return colorDetail
+}
@@ -0,0 +1,435 @@
+package client
+
+// client.go - a typed Go client for the validator's HTTP API, using the
+// same request and response structs as the server (package wire) without
+// pulling in the server itself
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/survey-validator/models"
+	"github.com/survey-validator/wire"
+)
+
+// Client - talks to one validator server. The zero HTTPClient is
+// http.DefaultClient.
+type Client struct {
+	BaseURL    string // e.g. http://localhost:8080, no trailing /api/v1
+	APIKey     string // sent as a bearer token, for servers run with -keys
+	HTTPClient *http.Client
+}
+
+// New - a client for the server at baseURL
+func New(baseURL string) *Client {
+	return &Client{BaseURL: strings.TrimRight(baseURL, "/")}
+}
+
+// Error - the server said no. Message, Code, Details and RequestID are
+// from its error body when it sent one; Code is one of the wire.Code*
+// constants, Details say which fields or rows were wrong.
+type Error struct {
+	StatusCode int
+	Message    string
+	Code       string
+	Details    []wire.ErrorDetail
+	RequestID  string
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("survey-validator: %d %s", e.StatusCode, e.Message)
+	if e.RequestID != "" {
+		msg += " (request " + e.RequestID + ")"
+	}
+	return msg
+}
+
+// Download - an export or certificate
+type Download struct {
+	ContentType string
+	Filename    string // from Content-Disposition, "" if none
+	Data        []byte
+}
+
+// StreamOptions - query settings for ValidateStream
+type StreamOptions struct {
+	CSV              bool // the body is CSV rather than JSON
+	ProjectID        string
+	CoordinateSystem string
+	Enable, Disable  []string
+	Store            bool   // keep the report under ProjectID
+	LinearUnit       string // CSV only
+	Codes            string // CSV only, e.g. CP:control,TR:traverse
+}
+
+// ImportOptions - query settings for Import
+type ImportOptions struct {
+	Format           string // gsi, rw5, sdr33, landxml or csv; or give Filename
+	Filename         string
+	ProjectID        string
+	CoordinateSystem string
+	LinearUnit       string
+	AngleUnit        string
+	Codes            string
+	Validate         bool
+	Store            bool // keep the dataset and report, with Validate
+}
+
+// Health - GET /health
+func (c *Client) Health(ctx context.Context) (*wire.HealthResponse, error) {
+	var out wire.HealthResponse
+	return &out, c.do(ctx, http.MethodGet, "/health", nil, nil, &out)
+}
+
+// Ready - GET /health/ready. A server that isn't ready answers 503 with
+// the same body, which comes back with Ready false rather than as an error.
+func (c *Client) Ready(ctx context.Context) (*wire.ReadinessResponse, error) {
+	var out wire.ReadinessResponse
+	return &out, c.probe(ctx, "/health/ready", &out)
+}
+
+// SelfTest - run the server's self-test, GET /health/selftest. A failed
+// self-test comes back with Passed false rather than as an error.
+func (c *Client) SelfTest(ctx context.Context) (*wire.SelfTestReport, error) {
+	var out wire.SelfTestReport
+	return &out, c.probe(ctx, "/health/selftest", &out)
+}
+
+// probe - a GET whose 503 has the usual body, not an error
+func (c *Client) probe(ctx context.Context, path string, out interface{}) error {
+	resp, err := c.request(ctx, http.MethodGet, path, nil, nil, "application/json")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusServiceUnavailable {
+		return errorFrom(resp)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("survey-validator: decoding GET %s: %w", path, err)
+	}
+	return nil
+}
+
+// Checks - the checks the server runs, in order
+func (c *Client) Checks(ctx context.Context) ([]wire.CheckInfo, error) {
+	var out wire.ChecksResponse
+	err := c.do(ctx, http.MethodGet, "/api/v1/checks", nil, nil, &out)
+	return out.Checks, err
+}
+
+// Validate - validate survey data
+func (c *Client) Validate(ctx context.Context, body *wire.ValidateBody) (*models.ValidationReport, error) {
+	var out models.ValidationReport
+	return &out, c.do(ctx, http.MethodPost, "/api/v1/validate", nil, jsonBody(body), &out)
+}
+
+// ValidateStream - validate a large JSON or CSV file straight from r,
+// without reading it into memory on either side
+func (c *Client) ValidateStream(ctx context.Context, r io.Reader, opts StreamOptions) (*models.ValidationReport, error) {
+	q := url.Values{}
+	set(q, "project_id", opts.ProjectID)
+	set(q, "coordinate_system", opts.CoordinateSystem)
+	set(q, "enable", strings.Join(opts.Enable, ","))
+	set(q, "disable", strings.Join(opts.Disable, ","))
+	set(q, "linear_unit", opts.LinearUnit)
+	set(q, "codes", opts.Codes)
+	if opts.Store {
+		q.Set("store", "true")
+	}
+	contentType := "application/json"
+	if opts.CSV {
+		contentType = "text/csv"
+	}
+	var out models.ValidationReport
+	return &out, c.do(ctx, http.MethodPost, "/api/v1/validate/stream", q, &body{r, contentType}, &out)
+}
+
+// Import - reduce a raw field book, and validate it if opts.Validate
+func (c *Client) Import(ctx context.Context, r io.Reader, opts ImportOptions) (*wire.ImportResponse, error) {
+	q := url.Values{}
+	set(q, "format", opts.Format)
+	set(q, "filename", opts.Filename)
+	set(q, "project_id", opts.ProjectID)
+	set(q, "coordinate_system", opts.CoordinateSystem)
+	set(q, "linear_unit", opts.LinearUnit)
+	set(q, "angle_unit", opts.AngleUnit)
+	set(q, "codes", opts.Codes)
+	if opts.Validate {
+		q.Set("validate", "true")
+	}
+	if opts.Store {
+		q.Set("store", "true")
+	}
+	var out wire.ImportResponse
+	return &out, c.do(ctx, http.MethodPost, "/api/v1/import", q, &body{r, "text/plain"}, &out)
+}
+
+// Export - validate and export as format (landxml, geojson, kml, dxf)
+func (c *Client) Export(ctx context.Context, req *wire.ExportRequest, format string) (*Download, error) {
+	return c.download(ctx, "/api/v1/export", format, req)
+}
+
+// Certificate - the QC certificate as format (html, pdf)
+func (c *Client) Certificate(ctx context.Context, req *wire.CertificateRequest, format string) (*Download, error) {
+	return c.download(ctx, "/api/v1/certificate", format, req)
+}
+
+// Compare - what moved between two versions of a dataset
+func (c *Client) Compare(ctx context.Context, req *wire.CompareRequest) (*wire.CompareResult, error) {
+	var out wire.CompareResult
+	return &out, c.do(ctx, http.MethodPost, "/api/v1/compare", nil, jsonBody(req), &out)
+}
+
+// SubmitJob - validate in the background
+func (c *Client) SubmitJob(ctx context.Context, body *wire.ValidateBody) (*wire.Job, error) {
+	var out wire.Job
+	return &out, c.do(ctx, http.MethodPost, "/api/v1/jobs", nil, jsonBody(body), &out)
+}
+
+// Job - a job's status and progress
+func (c *Client) Job(ctx context.Context, id string) (*wire.Job, error) {
+	var out wire.Job
+	return &out, c.do(ctx, http.MethodGet, "/api/v1/jobs/"+url.PathEscape(id), nil, nil, &out)
+}
+
+// CancelJob - stop a job
+func (c *Client) CancelJob(ctx context.Context, id string) (*wire.Job, error) {
+	var out wire.Job
+	return &out, c.do(ctx, http.MethodDelete, "/api/v1/jobs/"+url.PathEscape(id), nil, nil, &out)
+}
+
+// JobReport - a finished job's report
+func (c *Client) JobReport(ctx context.Context, id string) (*models.ValidationReport, error) {
+	var out models.ValidationReport
+	return &out, c.do(ctx, http.MethodGet, "/api/v1/jobs/"+url.PathEscape(id)+"/report", nil, nil, &out)
+}
+
+// WaitJob - poll every interval until the job finishes or ctx is done
+func (c *Client) WaitJob(ctx context.Context, id string, interval time.Duration) (*wire.Job, error) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		job, err := c.Job(ctx, id)
+		if err != nil || job.Status.Finished() {
+			return job, err
+		}
+		select {
+		case <-ctx.Done():
+			return job, ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// Projects - projects with stored reports
+func (c *Client) Projects(ctx context.Context) ([]wire.ProjectSummary, error) {
+	var out wire.ProjectsResponse
+	err := c.do(ctx, http.MethodGet, "/api/v1/projects", nil, nil, &out)
+	return out.Projects, err
+}
+
+// Project - a project's stored reports
+func (c *Client) Project(ctx context.Context, project string) (*wire.Project, error) {
+	var out wire.Project
+	return &out, c.do(ctx, http.MethodGet, "/api/v1/projects/"+url.PathEscape(project), nil, nil, &out)
+}
+
+// Report - a stored dataset and its report
+func (c *Client) Report(ctx context.Context, project, id string) (*wire.Record, error) {
+	var out wire.Record
+	path := "/api/v1/projects/" + url.PathEscape(project) + "/reports/" + url.PathEscape(id)
+	return &out, c.do(ctx, http.MethodGet, path, nil, nil, &out)
+}
+
+// DeleteProject - remove a project and its reports
+func (c *Client) DeleteProject(ctx context.Context, project string) error {
+	return c.do(ctx, http.MethodDelete, "/api/v1/projects/"+url.PathEscape(project), nil, nil, nil)
+}
+
+// DeleteReport - remove one stored report
+func (c *Client) DeleteReport(ctx context.Context, project, id string) error {
+	path := "/api/v1/projects/" + url.PathEscape(project) + "/reports/" + url.PathEscape(id)
+	return c.do(ctx, http.MethodDelete, path, nil, nil, nil)
+}
+
+// MonitorProjects - projects with monitoring epochs
+func (c *Client) MonitorProjects(ctx context.Context) ([]string, error) {
+	var out wire.MonitorProjectsResponse
+	err := c.do(ctx, http.MethodGet, "/api/v1/monitor", nil, nil, &out)
+	return out.Projects, err
+}
+
+// Monitor - a monitoring project's displacements and alerts
+func (c *Client) Monitor(ctx context.Context, project string) (*wire.MonitorReport, error) {
+	var out wire.MonitorReport
+	return &out, c.do(ctx, http.MethodGet, "/api/v1/monitor/"+url.PathEscape(project), nil, nil, &out)
+}
+
+// AddEpoch - add an epoch and get the updated analysis
+func (c *Client) AddEpoch(ctx context.Context, project string, epoch *wire.EpochRequest) (*wire.MonitorReport, error) {
+	var out wire.MonitorReport
+	path := "/api/v1/monitor/" + url.PathEscape(project) + "/epochs"
+	return &out, c.do(ctx, http.MethodPost, path, nil, jsonBody(epoch), &out)
+}
+
+// DeleteEpoch - remove an epoch
+func (c *Client) DeleteEpoch(ctx context.Context, project, id string) error {
+	path := "/api/v1/monitor/" + url.PathEscape(project) + "/epochs/" + url.PathEscape(id)
+	return c.do(ctx, http.MethodDelete, path, nil, nil, nil)
+}
+
+// MonitorConfig - a monitoring project's settings
+func (c *Client) MonitorConfig(ctx context.Context, project string) (*wire.MonitorConfig, error) {
+	var out wire.MonitorConfig
+	path := "/api/v1/monitor/" + url.PathEscape(project) + "/config"
+	return &out, c.do(ctx, http.MethodGet, path, nil, nil, &out)
+}
+
+// SetMonitorConfig - replace a monitoring project's settings
+func (c *Client) SetMonitorConfig(ctx context.Context, project string, cfg wire.MonitorConfig) (*wire.MonitorConfig, error) {
+	var out wire.MonitorConfig
+	path := "/api/v1/monitor/" + url.PathEscape(project) + "/config"
+	return &out, c.do(ctx, http.MethodPut, path, nil, jsonBody(cfg), &out)
+}
+
+// Keys - the server's API keys, for an admin key
+func (c *Client) Keys(ctx context.Context) ([]wire.Key, error) {
+	var out wire.KeysResponse
+	err := c.do(ctx, http.MethodGet, "/api/v1/keys", nil, nil, &out)
+	return out.Keys, err
+}
+
+// CreateKey - make an API key, for an admin key. The secret in the answer
+// can't be fetched again.
+func (c *Client) CreateKey(ctx context.Context, req *wire.KeyRequest) (*wire.CreatedKeyResponse, error) {
+	var out wire.CreatedKeyResponse
+	return &out, c.do(ctx, http.MethodPost, "/api/v1/keys", nil, jsonBody(req), &out)
+}
+
+// DeleteKey - revoke an API key, for an admin key
+func (c *Client) DeleteKey(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/api/v1/keys/"+url.PathEscape(id), nil, nil, nil)
+}
+
+// body - a request body and its content type
+type body struct {
+	r           io.Reader
+	contentType string
+}
+
+// jsonBody - v encoded as it is sent. Encoding errors surface in do.
+func jsonBody(v interface{}) *body {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(v); err != nil {
+		return &body{r: errReader{err}, contentType: "application/json"}
+	}
+	return &body{r: &buf, contentType: "application/json"}
+}
+
+type errReader struct{ err error }
+
+func (e errReader) Read([]byte) (int, error) { return 0, e.err }
+
+func set(q url.Values, key, value string) {
+	if value != "" {
+		q.Set(key, value)
+	}
+}
+
+// send - make the request, turning anything but a 2xx into an *Error
+func (c *Client) send(ctx context.Context, method, path string, q url.Values, b *body, accept string) (*http.Response, error) {
+	resp, err := c.request(ctx, method, path, q, b, accept)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		return nil, errorFrom(resp)
+	}
+	return resp, nil
+}
+
+// errorFrom - the *Error for a failed response
+func errorFrom(resp *http.Response) *Error {
+	apiErr := &Error{StatusCode: resp.StatusCode, RequestID: resp.Header.Get(wire.RequestIDHeader)}
+	var e wire.ErrorResponse
+	if json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&e) == nil && e.Error != "" {
+		apiErr.Message, apiErr.Code, apiErr.Details = e.Error, e.Code, e.Details
+	} else {
+		apiErr.Message = http.StatusText(resp.StatusCode)
+	}
+	return apiErr
+}
+
+// request - make the request, whatever the answer
+func (c *Client) request(ctx context.Context, method, path string, q url.Values, b *body, accept string) (*http.Response, error) {
+	u := c.BaseURL + path
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+	var r io.Reader
+	if b != nil {
+		r = b.r
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, r)
+	if err != nil {
+		return nil, err
+	}
+	if b != nil {
+		req.Header.Set("Content-Type", b.contentType)
+	}
+	req.Header.Set("Accept", accept)
+	if c.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	return hc.Do(req)
+}
+
+// do - a JSON request, decoding the answer into out unless it is nil
+func (c *Client) do(ctx context.Context, method, path string, q url.Values, b *body, out interface{}) error {
+	resp, err := c.send(ctx, method, path, q, b, "application/json")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("survey-validator: decoding %s %s: %w", method, path, err)
+	}
+	return nil
+}
+
+func (c *Client) download(ctx context.Context, path, format string, req interface{}) (*Download, error) {
+	q := url.Values{}
+	set(q, "format", format)
+	resp, err := c.send(ctx, http.MethodPost, path, q, jsonBody(req), "*/*")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	d := &Download{ContentType: resp.Header.Get("Content-Type"), Data: data}
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil {
+		d.Filename = params["filename"]
+	}
+	return d, nil
+}
@@ -0,0 +1,276 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/survey-validator/api"
+	"github.com/survey-validator/models"
+	"github.com/survey-validator/wire"
+)
+
+func testClient(t *testing.T) *Client {
+	t.Helper()
+	server := api.NewServer("")
+	if err := server.SetDataDir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(server.Handler())
+	t.Cleanup(ts.Close)
+	return New(ts.URL + "/")
+}
+
+func testData() models.SurveyData {
+	return models.SurveyData{
+		ProjectID: "CLIENT-1",
+		Points: []models.SurveyPoint{
+			{PointID: "T1", Easting: 1000, Northing: 1000, SurveyType: models.SurveyTypeTraverse},
+			{PointID: "T2", Easting: 1100, Northing: 1000, SurveyType: models.SurveyTypeTraverse},
+			{PointID: "T3", Easting: 1100, Northing: 1100, SurveyType: models.SurveyTypeTraverse},
+			{PointID: "T4", Easting: 1000.01, Northing: 1000, SurveyType: models.SurveyTypeTraverse},
+		},
+	}
+}
+
+func TestClient_ValidateAndStoredReports(t *testing.T) {
+	c := testClient(t)
+	ctx := context.Background()
+
+	if h, err := c.Health(ctx); err != nil || h.Status != "healthy" {
+		t.Fatalf("Health: %+v %v", h, err)
+	}
+	checks, err := c.Checks(ctx)
+	if err != nil || len(checks) == 0 {
+		t.Fatalf("Checks: %d %v", len(checks), err)
+	}
+
+	// kept only when asked
+	report, err := c.Validate(ctx, &wire.ValidateBody{SurveyData: testData()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.ReportID != "" {
+		t.Errorf("Expected a report nobody asked to store to have no ID, got %s", report.ReportID)
+	}
+	report, err = c.Validate(ctx, &wire.ValidateBody{SurveyData: testData(), Store: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Summary.TotalPoints != 4 || report.TraverseResult == nil || report.ReportID == "" {
+		t.Errorf("Expected a stored report with a traverse adjustment, got %+v", report)
+	}
+
+	stored, err := c.Report(ctx, "CLIENT-1", report.ReportID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored.Data.Points) != 4 || stored.Report.ConfidenceScore != report.ConfidenceScore {
+		t.Errorf("Expected the stored record to match, got %+v", stored)
+	}
+	projects, err := c.Projects(ctx)
+	if err != nil || len(projects) != 1 || projects[0].ID != "CLIENT-1" {
+		t.Errorf("Projects: %+v %v", projects, err)
+	}
+	if err := c.DeleteProject(ctx, "CLIENT-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	var apiErr *Error
+	_, err = c.Project(ctx, "CLIENT-1")
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound || apiErr.RequestID == "" {
+		t.Errorf("Expected a 404 *Error with a request ID, got %v", err)
+	}
+}
+
+func TestClient_StreamCompareExport(t *testing.T) {
+	c := testClient(t)
+	ctx := context.Background()
+
+	csv := "point_id,easting,northing,survey_type\nA,100,100,detail\nB,100.0005,100,detail\nC,200,200,detail\n"
+	report, err := c.ValidateStream(ctx, strings.NewReader(csv), StreamOptions{CSV: true, ProjectID: "STREAM-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Summary.TotalPoints != 3 || report.ProjectID != "STREAM-1" || report.Status != models.StatusFail {
+		t.Errorf("Expected a failed report for 3 points with a duplicate, got %+v", report)
+	}
+
+	before, after := testData(), testData()
+	after.Points[2].Easting += 0.05
+	result, err := c.Compare(ctx, &wire.CompareRequest{Before: before, After: after})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Moved) != 1 || result.Moved[0] != "T3" {
+		t.Errorf("Expected T3 to have moved, got %+v", result.Moved)
+	}
+
+	dl, err := c.Export(ctx, &wire.ExportRequest{SurveyData: testData()}, "geojson")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dl.ContentType != "application/geo+json" || dl.Filename != "CLIENT-1.geojson" || !strings.Contains(string(dl.Data), "FeatureCollection") {
+		t.Errorf("Unexpected export: %s %s %.60s", dl.ContentType, dl.Filename, dl.Data)
+	}
+}
+
+func TestClient_Monitor(t *testing.T) {
+	c := testClient(t)
+	ctx := context.Background()
+	// the same IDs as stored reports, spaces and all
+	const project = "Dam West"
+
+	start := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	for i, dE := range []float64{0, 0.03} {
+		data := testData()
+		data.Points[0].Easting += dE
+		rep, err := c.AddEpoch(ctx, project, &wire.EpochRequest{ObservedAt: start.AddDate(0, 0, 7*i), Points: data.Points})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rep.Epochs) != i+1 {
+			t.Errorf("Expected %d epochs, got %d", i+1, len(rep.Epochs))
+		}
+	}
+	rep, err := c.Monitor(ctx, project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rep.Alerts) == 0 {
+		t.Error("Expected a 30mm move to raise an alert")
+	}
+	if projects, err := c.MonitorProjects(ctx); err != nil || len(projects) != 1 || projects[0] != project {
+		t.Errorf("Expected [%s], got %q (%v)", project, projects, err)
+	}
+
+	cfg, err := c.MonitorConfig(ctx, project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.MaxDisplacement, cfg.MaxVelocity = 0.05, 0
+	if _, err := c.SetMonitorConfig(ctx, project, *cfg); err != nil {
+		t.Fatal(err)
+	}
+	if rep, _ := c.Monitor(ctx, project); len(rep.Alerts) != 0 {
+		t.Errorf("Expected no alerts with a 50mm limit and no velocity limit, got %+v", rep.Alerts)
+	}
+}
+
+func TestClient_Error(t *testing.T) {
+	c := testClient(t)
+	ctx := context.Background()
+
+	// an error body comes back as an *Error with its code and details
+	raw := `{"points": [{"point_id": "A", "easting": "1", "northing": 1}]}`
+	err := c.do(ctx, http.MethodPost, "/api/v1/validate", nil, &body{strings.NewReader(raw), "application/json"}, nil)
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected a 400, got %v", err)
+	}
+	if apiErr.Code != wire.CodeInvalidRequest || len(apiErr.Details) != 1 || apiErr.Details[0].Pointer != "/points/0/easting" || apiErr.RequestID == "" {
+		t.Errorf("Expected invalid_request about the easting, got %+v", apiErr)
+	}
+	if !strings.Contains(err.Error(), "400") || !strings.Contains(err.Error(), apiErr.Message) {
+		t.Errorf("Expected the status and message in %q", err)
+	}
+
+	// streamed uploads too
+	_, err = c.ValidateStream(ctx, strings.NewReader("point_id,easting,northing\nA,1,1\nB,inf,2\n"), StreamOptions{CSV: true})
+	if !errors.As(err, &apiErr) || len(apiErr.Details) != 1 || apiErr.Details[0].Code != wire.CodeNotFinite {
+		t.Errorf("Expected one not_finite detail, got %v", err)
+	}
+}
+
+func TestClient_Keys(t *testing.T) {
+	server := api.NewServer("")
+	admin, err := server.SetKeyFile(filepath.Join(t.TempDir(), "keys.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(server.Handler())
+	t.Cleanup(ts.Close)
+	ctx := context.Background()
+
+	var apiErr *Error
+	if _, err := New(ts.URL).Checks(ctx); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("Expected a 401 without a key, got %v", err)
+	}
+
+	// APIKey goes with every request
+	c := New(ts.URL)
+	c.APIKey = admin
+	created, err := c.CreateKey(ctx, &wire.KeyRequest{Name: "field crew"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	crew := New(ts.URL)
+	crew.APIKey = created.Secret
+	if _, err := crew.Validate(ctx, &wire.ValidateBody{SurveyData: testData()}); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := c.Keys(ctx)
+	if err != nil || len(keys) != 2 {
+		t.Fatalf("Expected 2 keys, got %+v %v", keys, err)
+	}
+	if err := c.DeleteKey(ctx, created.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := crew.Checks(ctx); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected a revoked key to get 401, got %v", err)
+	}
+}
+
+func TestClient_Health(t *testing.T) {
+	dir := t.TempDir()
+	server := api.NewServer("")
+	if err := server.SetDataDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(server.Handler())
+	t.Cleanup(ts.Close)
+	c := New(ts.URL)
+	ctx := context.Background()
+
+	if h, err := c.Health(ctx); err != nil || h.Status != "healthy" {
+		t.Fatalf("Unexpected health %+v %v", h, err)
+	}
+	if ready, err := c.Ready(ctx); err != nil || !ready.Ready {
+		t.Errorf("Expected ready, got %+v %v", ready, err)
+	}
+	if rep, err := c.SelfTest(ctx); err != nil || !rep.Passed {
+		t.Errorf("Expected the self-test to pass, got %+v %v", rep, err)
+	}
+
+	// a probe's 503 is an answer, not an error
+	os.RemoveAll(filepath.Join(dir, "projects"))
+	ready, err := c.Ready(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ready.Ready || len(ready.Problems) != 1 {
+		t.Errorf("Expected not ready with the problem, got %+v", ready)
+	}
+}
+
+func TestClient_HTTPClient(t *testing.T) {
+	ts := httptest.NewTLSServer(api.NewServer("").Handler())
+	t.Cleanup(ts.Close)
+
+	// requests go through HTTPClient, here one trusting the test server
+	c := New(ts.URL)
+	if _, err := c.Health(context.Background()); err == nil {
+		t.Error("Expected the default client not to trust the test certificate")
+	}
+	c.HTTPClient = ts.Client()
+	if h, err := c.Health(context.Background()); err != nil || h.Status != "healthy" {
+		t.Errorf("Expected health over HTTPS, got %+v %v", h, err)
+	}
+}
@@ -1,33 +1,193 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
+	"fmt"
 	"log"
+	"log/slog"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
 
 	"github.com/survey-validator/api"
+	"github.com/survey-validator/config"
+	"github.com/survey-validator/engine"
 )
 
 func main() {
-	port := flag.String("port", "8080", "Server port")
-	flag.Parse()
+	cfg, err := config.Load(os.Args[1:], os.Getenv, os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("Config: %v", err)
+	}
+	if err := setupLogging(cfg.LogFormat, cfg.LogLevel); err != nil {
+		log.Fatal(err)
+	}
 
-	if envPort := os.Getenv("PORT"); envPort != "" {
-		*port = envPort
+	server := api.NewServer(cfg.Addr)
+	if cfg.RulesFile != "" {
+		if err := server.LoadRules(cfg.RulesFile); err != nil {
+			fatal("Loading rules", err)
+		}
+		slog.Info("Loaded rules", "file", cfg.RulesFile)
+	}
+	if cfg.CodesFile != "" {
+		if err := server.LoadCodes(cfg.CodesFile); err != nil {
+			fatal("Loading code library", err)
+		}
+		slog.Info("Loaded code library", "file", cfg.CodesFile)
+	}
+	profile, err := lookupProfile(cfg.Profile, cfg.ProfilesFile)
+	if err != nil {
+		fatal("Profile", err)
+	}
+	server.SetProfile(profile)
+	server.SetWorkers(cfg.Workers)
+	server.SetLimits(cfg.MaxBodyMB<<20, cfg.MaxStreamMB<<20)
+	server.SetCORSOrigins(cfg.CORSOrigins)
+	server.SetStaticDir(cfg.StaticDir)
+	server.SetTimeouts(cfg.Timeouts.API())
+	if cfg.TLS.CertFile != "" {
+		server.SetTLS(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+	}
+	if err := server.SetDataDir(cfg.DataDir); err != nil {
+		fatal("Data directory", err)
+	}
+	if cfg.KeysFile != "" {
+		secret, err := server.SetKeyFile(cfg.KeysFile)
+		if err != nil {
+			fatal("API keys", err)
+		}
+		if secret != "" {
+			// not to stderr, that goes to the log collector with the rest
+			path, err := writeSecret(cfg.KeysFile, secret)
+			if err != nil {
+				fatal("Saving the admin key secret", err)
+			}
+			slog.Warn("No API keys, made an admin key. Its secret is in the file, move it somewhere safe and delete it", "file", path)
+		}
+		slog.Info("API keys required", "file", cfg.KeysFile)
+		if cfg.AuditLog == "" {
+			cfg.AuditLog = filepath.Join(cfg.DataDir, "audit.log")
+		}
+	}
+	if cfg.AuditLog != "" {
+		if err := server.SetAuditLog(cfg.AuditLog); err != nil {
+			fatal("Audit log", err)
+		}
+		slog.Info("Audit log", "file", cfg.AuditLog)
 	}
 
-	addr := ":" + *port
-	server := api.NewServer(addr)
+	if cfg.LogFormat == "text" {
+		printBanner(cfg)
+	}
 
-	log.Println("Survey Data Validation & Insight Engine")
-	log.Println("========================================")
-	log.Printf("Server starting on http://localhost%s", addr)
-	log.Println("Endpoints:")
-	log.Println("  GET  /health           - Health check")
-	log.Println("  POST /api/v1/validate  - Validate survey data")
-	log.Println("========================================")
+	// the first SIGINT or SIGTERM shuts down gracefully, a second one kills
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	if err := server.Run(ctx); err != nil {
+		fatal("Server failed", err)
+	}
+}
+
+// lookupProfile - a built-in profile, or one from the profiles file
+func lookupProfile(name, file string) (engine.Profile, error) {
+	if file != "" {
+		extra, err := engine.LoadProfiles(file)
+		if err != nil {
+			return engine.Profile{}, err
+		}
+		if p, ok := extra[name]; ok {
+			return p, nil
+		}
+	}
+	return engine.LookupProfile(name)
+}
+
+// writeSecret - put a new admin key's secret in a file only the server's
+// user can read, next to the keys file, and say where
+func writeSecret(keysFile, secret string) (string, error) {
+	path := keysFile + ".admin-secret"
+	// a leftover from an earlier start may have looser permissions
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return "", err
+	}
+	if _, err := fmt.Fprintln(f, secret); err != nil {
+		f.Close()
+		return "", err
+	}
+	return path, f.Close()
+}
+
+// setupLogging - the default slog logger, which the log package writes
+// through too
+func setupLogging(format, level string) error {
+	var lv slog.Level
+	if err := lv.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("-log-level: %w", err)
+	}
+	opts := &slog.HandlerOptions{Level: lv}
+	switch format {
+	case "text":
+		slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, opts)))
+	case "json":
+		slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, opts)))
+	default:
+		return fmt.Errorf("-log-format: want text or json, not %q", format)
+	}
+	return nil
+}
+
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+// printBanner - what the server offers, for people reading the terminal.
+// Plain text, outside the structured logs.
+func printBanner(cfg config.Config) {
+	scheme := "http"
+	if cfg.TLS.CertFile != "" {
+		scheme = "https"
+	}
+	fmt.Fprintln(os.Stderr, "Survey Data Validation & Insight Engine")
+	fmt.Fprintln(os.Stderr, "========================================")
+	fmt.Fprintf(os.Stderr, "Server starting on %s://%s, profile %s\n", scheme, displayAddr(cfg.Addr), cfg.Profile)
+	fmt.Fprintln(os.Stderr, "Endpoints:")
+	fmt.Fprintln(os.Stderr, "  GET  /health           - Health check")
+	fmt.Fprintln(os.Stderr, "  GET  /health/ready     - Readiness: self-test, storage, job queue")
+	fmt.Fprintln(os.Stderr, "  GET  /health/selftest  - Run the self-test now")
+	fmt.Fprintln(os.Stderr, "  POST /api/v1/validate  - Validate survey data")
+	fmt.Fprintln(os.Stderr, "  POST /api/v1/validate/stream - Validate a large JSON or CSV upload without holding it")
+	fmt.Fprintln(os.Stderr, "  GET  /api/v1/checks    - List validation checks and their settings")
+	fmt.Fprintln(os.Stderr, "  POST /api/v1/import    - Import a raw field book (GSI, RW5, SDR33, LandXML, CSV)")
+	fmt.Fprintln(os.Stderr, "  POST /api/v1/export    - Export results (LandXML, GeoJSON, KML, DXF)")
+	fmt.Fprintln(os.Stderr, "  POST /api/v1/certificate - QC certificate (HTML, PDF)")
+	fmt.Fprintln(os.Stderr, "  POST /api/v1/compare  - What moved between two datasets")
+	fmt.Fprintln(os.Stderr, "  POST /api/v1/jobs     - Validate in the background, then poll /api/v1/jobs/{id}")
+	fmt.Fprintln(os.Stderr, "  *    /api/v1/projects/{project} - Stored datasets and reports")
+	fmt.Fprintln(os.Stderr, "  *    /api/v1/monitor/{project} - Deformation monitoring epochs, config and alerts")
+	fmt.Fprintln(os.Stderr, "  *    /api/v1/keys         - API keys, with -keys and an admin key")
+	fmt.Fprintln(os.Stderr, "  GET  /metrics          - Prometheus metrics")
+	fmt.Fprintln(os.Stderr, "========================================")
+}
 
-	if err := server.Start(); err != nil {
-		log.Fatalf("Server failed to start: %v", err)
+// displayAddr - the address to put in a browser, localhost for any host
+func displayAddr(addr string) string {
+	if len(addr) > 0 && addr[0] == ':' {
+		return "localhost" + addr
 	}
+	return addr
 }
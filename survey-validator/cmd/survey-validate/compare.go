@@ -0,0 +1,103 @@
+package main
+
+// compare.go - survey-validate compare: what moved between two files
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/survey-validator/compare"
+	"github.com/survey-validator/formats"
+)
+
+func runCompare(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("survey-validate compare", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	output := fs.String("output", "text", "Output format: text or json")
+	threshold := fs.Float64("threshold", compare.DefaultThreshold, "Movement to flag, metres")
+	renameTol := fs.Float64("rename-tolerance", compare.DefaultRenameTolerance, "New ID within this of an old one is a rename, metres")
+	failOn := fs.String("fail-on", "moved", "Exit non-zero on: moved or never")
+	coordSys := fs.String("coordinate-system", "", "Coordinate system for files that don't carry one")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: survey-validate compare [flags] BEFORE AFTER")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return exitError
+	}
+	if *output != "text" && *output != "json" {
+		fmt.Fprintf(stderr, "survey-validate: -output must be text or json\n")
+		return exitError
+	}
+	if *failOn != "moved" && *failOn != "never" {
+		fmt.Fprintf(stderr, "survey-validate: -fail-on must be moved or never\n")
+		return exitError
+	}
+	if *threshold <= 0 || *renameTol < 0 {
+		fmt.Fprintf(stderr, "survey-validate: -threshold must be positive and -rename-tolerance not negative\n")
+		return exitError
+	}
+
+	opts := formats.ImportOptions{CoordinateSystem: *coordSys}
+	before, err := loadFile(fs.Arg(0), opts)
+	if err != nil {
+		fmt.Fprintf(stderr, "survey-validate: %s: %v\n", fs.Arg(0), err)
+		return exitError
+	}
+	after, err := loadFile(fs.Arg(1), opts)
+	if err != nil {
+		fmt.Fprintf(stderr, "survey-validate: %s: %v\n", fs.Arg(1), err)
+		return exitError
+	}
+
+	res := compare.Compare(before, after, compare.Options{Threshold: *threshold, RenameTolerance: *renameTol})
+
+	if *output == "json" {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(res); err != nil {
+			fmt.Fprintf(stderr, "survey-validate: %v\n", err)
+			return exitError
+		}
+	} else {
+		printCompare(stdout, fs.Arg(0), fs.Arg(1), res)
+	}
+
+	if *failOn == "moved" && len(res.Moved) > 0 {
+		return exitFailed
+	}
+	return exitOK
+}
+
+func printCompare(w io.Writer, beforeFile, afterFile string, res *compare.Result) {
+	fmt.Fprintf(w, "%s -> %s: %s\n", beforeFile, afterFile, res)
+	for _, r := range res.Renamed {
+		fmt.Fprintf(w, "  renamed  %s -> %s (%.4fm)\n", r.From, r.To, r.Distance)
+	}
+	for _, id := range res.Added {
+		fmt.Fprintf(w, "  added    %s\n", id)
+	}
+	for _, id := range res.Removed {
+		fmt.Fprintf(w, "  removed  %s\n", id)
+	}
+	for _, d := range res.Displacements {
+		if !d.Moved {
+			continue
+		}
+		dh := ""
+		if d.DH != nil {
+			dh = fmt.Sprintf(" dH %+.4f", *d.DH)
+		}
+		fmt.Fprintf(w, "  moved    %s %.4fm on %.1f° (dE %+.4f dN %+.4f%s, %.4fm relative)\n",
+			d.PointID, d.Horizontal, d.Bearing, d.DE, d.DN, dh, d.Residual)
+	}
+	if t := res.Transform; t != nil {
+		fmt.Fprintf(w, "  %s\n", t.Description)
+	}
+}
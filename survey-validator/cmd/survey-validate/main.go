@@ -0,0 +1,433 @@
+package main
+
+// survey-validate - validate survey files from the command line, for batch
+// runs and CI gates. Exits 1 when a report reaches the -fail-on level.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/survey-validator/codes"
+	"github.com/survey-validator/engine"
+	"github.com/survey-validator/formats"
+	"github.com/survey-validator/models"
+	"github.com/survey-validator/rules"
+)
+
+// exit codes
+const (
+	exitOK     = 0
+	exitFailed = 1 // at least one report hit the -fail-on level
+	exitError  = 2 // bad flags or unreadable input
+)
+
+// fileResult - one input file and what came of it
+type fileResult struct {
+	File   string                   `json:"file"`
+	Report *models.ValidationReport `json:"report,omitempty"`
+	Error  string                   `json:"error,omitempty"`
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) > 0 && args[0] == "compare" {
+		return runCompare(args[1:], stdout, stderr)
+	}
+
+	fs := flag.NewFlagSet("survey-validate", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	output := fs.String("output", "text", "Output format: text or json")
+	profileName := fs.String("profile", engine.DefaultProfile, "Validation profile")
+	profileFile := fs.String("profiles", "", "JSON file with extra profiles")
+	failOn := fs.String("fail-on", "fail", "Exit non-zero on: fail, warning or never")
+	projectID := fs.String("project", "", "Project ID for files that don't carry one")
+	coordSys := fs.String("coordinate-system", "", "Coordinate system for files that don't carry one")
+	rulesFile := fs.String("rules", "", "JSON file with extra validation rules")
+	codesFile := fs.String("codes", "", "JSON feature code library, adds the code checks")
+	enable := fs.String("enable", "", "Comma-separated checks to run (default all)")
+	disable := fs.String("disable", "", "Comma-separated checks to skip")
+	suppressFile := fs.String("suppressions", "", "JSON file of accepted issues (fingerprint + justification)")
+	severity := fs.String("severity", "", "Comma-separated check=level overrides, e.g. outlier_detection=info")
+	listProfiles := fs.Bool("list-profiles", false, "List profiles and exit")
+	listChecks := fs.Bool("list-checks", false, "List checks and exit")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: survey-validate [flags] FILE|DIR...")
+		fmt.Fprintln(stderr, "       survey-validate compare [flags] BEFORE AFTER")
+		fmt.Fprintln(stderr, "Validates .json, .csv and raw field files (.gsi, .rw5, .sdr, .xml).")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+
+	profiles := make(map[string]engine.Profile)
+	for _, p := range engine.Profiles() {
+		profiles[p.Name] = p
+	}
+	if *profileFile != "" {
+		extra, err := engine.LoadProfiles(*profileFile)
+		if err != nil {
+			fmt.Fprintf(stderr, "survey-validate: %v\n", err)
+			return exitError
+		}
+		for name, p := range extra {
+			profiles[name] = p
+		}
+	}
+
+	eng := engine.NewEngine()
+	if *rulesFile != "" {
+		list, err := rules.LoadFile(*rulesFile)
+		if err == nil {
+			err = rules.Register(eng, list)
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "survey-validate: %v\n", err)
+			return exitError
+		}
+	}
+	if *codesFile != "" {
+		lib, err := codes.LoadFile(*codesFile)
+		if err == nil {
+			err = codes.Register(eng, lib)
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "survey-validate: %v\n", err)
+			return exitError
+		}
+	}
+
+	if *listChecks {
+		for _, c := range eng.Checks() {
+			fmt.Fprintf(stdout, "%-24s %-6s %s\n", c.Name, c.Version, c.Description)
+		}
+		return exitOK
+	}
+
+	if *listProfiles {
+		names := make([]string, 0, len(profiles))
+		for name := range profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(stdout, "%-12s %s\n", name, profiles[name].Description)
+		}
+		return exitOK
+	}
+
+	profile, ok := profiles[*profileName]
+	if !ok {
+		fmt.Fprintf(stderr, "survey-validate: unknown profile %q\n", *profileName)
+		return exitError
+	}
+
+	switch *failOn {
+	case "fail", "warning", "never":
+	default:
+		fmt.Fprintf(stderr, "survey-validate: -fail-on must be fail, warning or never\n")
+		return exitError
+	}
+	if *output != "text" && *output != "json" {
+		fmt.Fprintf(stderr, "survey-validate: -output must be text or json\n")
+		return exitError
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitError
+	}
+
+	files, skipped, err := collectFiles(fs.Args(), *profileFile, *rulesFile, *codesFile, *suppressFile)
+	if err != nil {
+		fmt.Fprintf(stderr, "survey-validate: %v\n", err)
+		return exitError
+	}
+	for _, f := range skipped {
+		fmt.Fprintf(stderr, "survey-validate: skipping %s, it isn't survey data\n", f)
+	}
+	if len(files) == 0 {
+		fmt.Fprintln(stderr, "survey-validate: no survey files found")
+		return exitError
+	}
+
+	overrides, err := parseSeverity(*severity)
+	if err != nil {
+		fmt.Fprintf(stderr, "survey-validate: %v\n", err)
+		return exitError
+	}
+
+	var suppressions []models.Suppression
+	if *suppressFile != "" {
+		if suppressions, err = loadSuppressions(*suppressFile); err != nil {
+			fmt.Fprintf(stderr, "survey-validate: %v\n", err)
+			return exitError
+		}
+	}
+
+	opts := formats.ImportOptions{ProjectID: *projectID, CoordinateSystem: *coordSys}
+	validateOpts := engine.Options{
+		Profile:      &profile,
+		Enable:       splitList(*enable),
+		Disable:      splitList(*disable),
+		Severity:     overrides,
+		Suppressions: suppressions,
+	}
+	results := make([]fileResult, 0, len(files))
+	for _, f := range files {
+		data, err := loadFile(f, opts)
+		if err != nil {
+			results = append(results, fileResult{File: f, Error: err.Error()})
+			continue
+		}
+		report, err := eng.ValidateContext(context.Background(), data, validateOpts)
+		if errors.Is(err, engine.ErrInvalidOptions) {
+			fmt.Fprintf(stderr, "survey-validate: %v\n", err)
+			return exitError
+		}
+		results = append(results, fileResult{File: f, Report: report})
+	}
+
+	if *output == "json" {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(results); err != nil {
+			fmt.Fprintf(stderr, "survey-validate: %v\n", err)
+			return exitError
+		}
+	} else {
+		printText(stdout, results, profile)
+	}
+
+	return exitCode(results, *failOn)
+}
+
+// exitCode - input errors beat gate failures, gate failures beat success
+func exitCode(results []fileResult, failOn string) int {
+	code := exitOK
+	for _, r := range results {
+		if r.Error != "" {
+			return exitError
+		}
+		switch {
+		case failOn == "never":
+		case r.Report.Status == models.StatusFail:
+			code = exitFailed
+		case failOn == "warning" && r.Report.Status == models.StatusWarning:
+			code = exitFailed
+		}
+	}
+	return code
+}
+
+// splitList - comma-separated flag value, blanks dropped
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// parseSeverity - "check=level,..." into engine overrides
+func parseSeverity(s string) (map[string]models.IssueSeverity, error) {
+	out := make(map[string]models.IssueSeverity)
+	for _, item := range splitList(s) {
+		name, level, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("-severity wants check=level, got %q", item)
+		}
+		out[strings.TrimSpace(name)] = models.IssueSeverity(strings.TrimSpace(level))
+	}
+	return out, nil
+}
+
+// loadSuppressions - a JSON array of accepted issues
+func loadSuppressions(path string) ([]models.Suppression, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var list []models.Suppression
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return list, nil
+}
+
+// collectFiles - expand directories into the survey files inside them.
+// The files given to -rules, -codes and the like are left out, and JSON
+// that isn't survey data is returned as skipped rather than read.
+// Files named outright are always taken.
+func collectFiles(args []string, config ...string) (files, skipped []string, err error) {
+	exclude := make(map[string]bool)
+	for _, path := range config {
+		if path != "" {
+			exclude[absPath(path)] = true
+		}
+	}
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+		err = filepath.WalkDir(arg, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || !isSurveyFile(path) || exclude[absPath(path)] {
+				return nil
+			}
+			if strings.EqualFold(filepath.Ext(path), ".json") && !hasPoints(path) {
+				skipped = append(skipped, path)
+				return nil
+			}
+			files = append(files, path)
+			return nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return files, skipped, nil
+}
+
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
+
+func isSurveyFile(path string) bool {
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return true
+	}
+	_, ok := formats.DetectFormat(path)
+	return ok
+}
+
+// hasPoints - whether a JSON file is an object with a points key, reading
+// only as far as that key. JSON that doesn't parse counts as survey data
+// so loadFile reports what is wrong with it.
+func hasPoints(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return true
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	if tok, err := dec.Token(); err != nil {
+		return true
+	} else if tok != json.Delim('{') {
+		return false
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return true
+		}
+		if key == "points" {
+			return true
+		}
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// loadFile - JSON in the API's shape, anything else through the importers
+func loadFile(path string, opts formats.ImportOptions) (*models.SurveyData, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var data *models.SurveyData
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		data = &models.SurveyData{}
+		if err := json.NewDecoder(f).Decode(data); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+	} else {
+		format, ok := formats.DetectFormat(path)
+		if !ok {
+			return nil, fmt.Errorf("unknown file type")
+		}
+		res, err := formats.Import(format, f, opts)
+		if err != nil {
+			return nil, err
+		}
+		data = res.Data
+	}
+
+	if data.ProjectID == "" {
+		data.ProjectID = opts.ProjectID
+	}
+	if data.ProjectID == "" {
+		data.ProjectID = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if data.CoordinateSystem == "" {
+		data.CoordinateSystem = opts.CoordinateSystem
+	}
+	if len(data.Points) == 0 {
+		return nil, fmt.Errorf("no points")
+	}
+	return data, nil
+}
+
+func printText(w io.Writer, results []fileResult, profile engine.Profile) {
+	counts := map[models.ValidationStatus]int{}
+	errors := 0
+
+	for _, r := range results {
+		if r.Error != "" {
+			fmt.Fprintf(w, "ERROR  %s: %s\n", r.File, r.Error)
+			errors++
+			continue
+		}
+
+		rep := r.Report
+		counts[rep.Status]++
+		fmt.Fprintf(w, "%-7s %s (%s) - %d points, score %.0f\n",
+			rep.Status, r.File, rep.ProjectID, rep.Summary.TotalPoints, rep.ConfidenceScore)
+		if len(rep.Suppressed) > 0 {
+			fmt.Fprintf(w, "        %d accepted issue(s) suppressed\n", len(rep.Suppressed))
+		}
+		if tr := rep.TraverseResult; tr != nil && tr.ClosureRatio != "" {
+			fmt.Fprintf(w, "        traverse %s closure %s (required 1:%.0f) %s\n",
+				tr.TraverseType, tr.ClosureRatio, tr.RequiredPrecision, tr.Status)
+		}
+		for _, issue := range rep.Issues {
+			if issue.Severity == models.SeverityInfo {
+				continue
+			}
+			fmt.Fprintf(w, "        [%s] %s: %s (%s)\n", issue.Severity, issue.CheckName, issue.Description, issue.Fingerprint)
+		}
+	}
+
+	fmt.Fprintf(w, "\n%d file(s), profile %s: %d pass, %d warning, %d fail, %d error\n",
+		len(results), profile.Name, counts[models.StatusPass], counts[models.StatusWarning],
+		counts[models.StatusFail], errors)
+}
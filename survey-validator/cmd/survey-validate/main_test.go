@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun_ExitCodes(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected int
+	}{
+		{[]string{"../../testdata/sample_survey.json"}, exitOK},
+		{[]string{"-fail-on", "fail", "../../testdata/sample_with_errors.json"}, exitOK},
+		{[]string{"-fail-on", "warning", "../../testdata/sample_with_errors.json"}, exitFailed},
+		{[]string{"-fail-on", "never", "../../testdata"}, exitOK},
+		{[]string{"-profile", "nope", "../../testdata"}, exitError},
+		{[]string{"missing.json"}, exitError},
+		{[]string{}, exitError},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		if code := run(tt.args, &stdout, &stderr); code != tt.expected {
+			t.Errorf("run(%v) = %d; expected %d (stderr: %s)", tt.args, code, tt.expected, stderr.String())
+		}
+	}
+}
+
+func TestRun_JSONOutput(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-output", "json", "-fail-on", "never", "../../testdata"}, &stdout, &stderr)
+	if code != exitOK {
+		t.Fatalf("run = %d, stderr: %s", code, stderr.String())
+	}
+
+	var results []fileResult
+	if err := json.Unmarshal(stdout.Bytes(), &results); err != nil {
+		t.Fatalf("Invalid JSON output: %v", err)
+	}
+	if len(results) < 3 {
+		t.Fatalf("Expected every testdata file, got %d", len(results))
+	}
+	for _, r := range results {
+		if r.Report == nil {
+			t.Errorf("%s: no report (%s)", r.File, r.Error)
+		}
+	}
+}
+
+func TestRun_BadFileIsError(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "broken.json")
+	if err := os.WriteFile(path, []byte("{not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{path}, &stdout, &stderr); code != exitError {
+		t.Errorf("run = %d; expected %d", code, exitError)
+	}
+	if !strings.Contains(stdout.String(), "ERROR") {
+		t.Errorf("Expected the error in the summary, got %s", stdout.String())
+	}
+}
+
+func TestRun_DirWithConfigFiles(t *testing.T) {
+	dir := t.TempDir()
+	copyFile := func(from, to string) {
+		t.Helper()
+		raw, err := os.ReadFile(from)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, to), raw, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	copyFile("../../testdata/sample_survey.json", "site.json")
+	copyFile("../../rules/testdata/example.json", "rules.json")
+	copyFile("../../codes/testdata/example.json", "codes.json")
+	if err := os.WriteFile(filepath.Join(dir, "suppressions.json"), []byte("[]"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.json"), []byte(`{"crew": "B"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-output", "json", "-fail-on", "never",
+		"-rules", filepath.Join(dir, "rules.json"),
+		"-codes", filepath.Join(dir, "codes.json"),
+		"-suppressions", filepath.Join(dir, "suppressions.json"),
+		dir}, &stdout, &stderr)
+	if code != exitOK {
+		t.Fatalf("run = %d; expected %d (stderr: %s)", code, exitOK, stderr.String())
+	}
+
+	var results []fileResult
+	if err := json.Unmarshal(stdout.Bytes(), &results); err != nil {
+		t.Fatalf("Invalid JSON output: %v", err)
+	}
+	if len(results) != 1 || filepath.Base(results[0].File) != "site.json" {
+		t.Errorf("Expected only site.json to be validated, got %+v", results)
+	}
+	if !strings.Contains(stderr.String(), "skipping "+filepath.Join(dir, "notes.json")) {
+		t.Errorf("Expected a warning about notes.json, got %q", stderr.String())
+	}
+	if strings.Contains(stderr.String(), "rules.json") {
+		t.Errorf("The rules file shouldn't be mentioned, got %q", stderr.String())
+	}
+}
+
+func TestRunCompare(t *testing.T) {
+	raw, err := os.ReadFile("../../testdata/sample_survey.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		t.Fatal(err)
+	}
+	point := data["points"].([]interface{})[2].(map[string]interface{})
+	point["easting"] = point["easting"].(float64) + 0.05
+	moved, _ := json.Marshal(data)
+
+	path := filepath.Join(t.TempDir(), "resurvey.json")
+	if err := os.WriteFile(path, moved, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"compare", "../../testdata/sample_survey.json", path}, &stdout, &stderr); code != exitFailed {
+		t.Fatalf("Expected exit 1 for a moved point, got %d (%s)", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "1 moved") || !strings.Contains(stdout.String(), "moved    "+point["point_id"].(string)) {
+		t.Errorf("Unexpected output:\n%s", stdout.String())
+	}
+
+	stdout.Reset()
+	if code := run([]string{"compare", "-fail-on", "never", "../../testdata/sample_survey.json", path}, &stdout, &stderr); code != exitOK {
+		t.Errorf("Expected exit 0 with -fail-on never, got %d", code)
+	}
+	if code := run([]string{"compare", "../../testdata/sample_survey.json"}, &stdout, &stderr); code != exitError {
+		t.Errorf("Expected exit 2 with one file, got %d", code)
+	}
+}
@@ -0,0 +1,281 @@
+package codes
+
+// checks.go - the checks a code library adds: codes that aren't in the
+// library, points missing required attributes, and line strings whose
+// control codes don't add up
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/survey-validator/engine"
+	"github.com/survey-validator/models"
+)
+
+// names of the checks Register adds
+const (
+	FeatureCheck = "feature_codes"
+	LineCheck    = "line_codes"
+)
+
+// Register - add the library's checks to an engine. Like rules they can't
+// replace a check that is already registered.
+func Register(e *engine.Engine, lib *Library) error {
+	for _, check := range []engine.Check{&featureCodes{lib}, &lineCodes{lib}} {
+		name := check.Info().Name
+		if e.HasCheck(name) {
+			return fmt.Errorf("codes: %s is already a registered check", name)
+		}
+		if err := e.Add(check); err != nil {
+			return fmt.Errorf("codes: %w", err)
+		}
+	}
+	return nil
+}
+
+// featureCodes - every code is in the library and has its attributes
+type featureCodes struct {
+	lib *Library
+}
+
+// Info - engine.Check
+func (c *featureCodes) Info() engine.CheckInfo {
+	return engine.CheckInfo{
+		Name:        FeatureCheck,
+		Description: "Field codes that aren't in the code library, and points missing the attributes their code needs",
+		Version:     "1.0",
+		Category:    models.CategoryIntegrity,
+		Config: map[string]engine.ParamSpec{
+			"require_code": {Type: engine.ParamBool, Description: "Flag points with no code at all", Default: false},
+		},
+		Priority: 150,
+	}
+}
+
+// Run - engine.Check
+func (c *featureCodes) Run(ctx context.Context, data *models.SurveyData, cfg engine.Config) []models.ValidationIssue {
+	issues, _ := c.RunStream(ctx, data, cfg)
+	return issues
+}
+
+// group - points sharing a problem: an unknown code, or a code missing the
+// same attributes
+type group struct {
+	code    string
+	missing []string // empty for an unknown code
+	ids     []string
+}
+
+// RunStream - engine.StreamCheck. One issue per unknown code and per code
+// and set of missing attributes, naming every point it covers.
+func (c *featureCodes) RunStream(ctx context.Context, src models.PointSource, cfg engine.Config) ([]models.ValidationIssue, error) {
+	var (
+		groups  []*group
+		byKey   = make(map[string]*group)
+		uncoded []string
+	)
+	add := func(code string, missing []string, id string) {
+		key := code + " " + strings.Join(missing, ",")
+		g := byKey[key]
+		if g == nil {
+			g = &group{code: code, missing: missing}
+			byKey[key] = g
+			groups = append(groups, g)
+		}
+		g.ids = append(g.ids, id)
+	}
+
+	err := src.Each(func(i int, p *models.SurveyPoint) error {
+		if i%1000 == 0 && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		f := c.lib.Parse(p.Code)
+		if f.Feature == "" {
+			if !f.Controls() {
+				uncoded = append(uncoded, p.PointID)
+			}
+			return nil
+		}
+		code, _, ok := c.lib.Lookup(f.Feature)
+		if !ok {
+			add(f.Feature, nil, p.PointID)
+		} else if missing := code.Missing(p, f); len(missing) > 0 {
+			add(code.Code, missing, p.PointID)
+		}
+		return nil
+	})
+	if errors.Is(err, ctx.Err()) {
+		err = nil
+	}
+
+	issues := make([]models.ValidationIssue, 0, len(groups)+1)
+	for _, g := range groups {
+		issue := models.ValidationIssue{
+			CheckName: FeatureCheck,
+			Severity:  models.SeverityWarning,
+			PointIDs:  g.ids,
+		}
+		if len(g.missing) == 0 {
+			issue.Kind = "unknown_code"
+			issue.Description = fmt.Sprintf("Code %s isn't in the code library (%s)", g.code, points(g.ids))
+			issue.Details = map[string]interface{}{"code": g.code}
+		} else {
+			issue.Kind = "missing_attributes"
+			issue.Description = fmt.Sprintf("%s needs %s (%s)", g.code, strings.Join(g.missing, ", "), points(g.ids))
+			issue.Details = map[string]interface{}{"code": g.code, "missing": g.missing}
+		}
+		issues = append(issues, issue)
+	}
+	if len(uncoded) > 0 && cfg.Bool("require_code") {
+		issues = append(issues, models.ValidationIssue{
+			CheckName:   FeatureCheck,
+			Kind:        "missing_code",
+			Severity:    models.SeverityWarning,
+			PointIDs:    uncoded,
+			Description: fmt.Sprintf("No field code (%s)", points(uncoded)),
+		})
+	}
+	return issues, err
+}
+
+// points - "CP1" or "3 points: D1, D2, D3", kept short for long lists
+func points(ids []string) string {
+	if len(ids) == 1 {
+		return ids[0]
+	}
+	const show = 5
+	if len(ids) > show {
+		return fmt.Sprintf("%d points: %s, ...", len(ids), strings.Join(ids[:show], ", "))
+	}
+	return fmt.Sprintf("%d points: %s", len(ids), strings.Join(ids, ", "))
+}
+
+// lineCodes - line strings are started, ended and closed in sequence
+type lineCodes struct {
+	lib *Library
+}
+
+// Info - engine.Check
+func (c *lineCodes) Info() engine.CheckInfo {
+	ctl := c.lib.Control
+	return engine.CheckInfo{
+		Name: LineCheck,
+		Description: fmt.Sprintf("Line strings coded out of sequence: no %s, %s twice, left open, too short to %s, or control codes on point features",
+			ctl.Start, ctl.Start, ctl.Close),
+		Version:  "1.0",
+		Category: models.CategoryIntegrity,
+		Config: map[string]engine.ParamSpec{
+			"require_start": {Type: engine.ParamBool, Description: "A string's first point must carry " + ctl.Start, Default: true},
+			"require_end":   {Type: engine.ParamBool, Description: "Every string must be ended with " + ctl.End + " or " + ctl.Close, Default: true},
+		},
+		Priority: 160,
+	}
+}
+
+// Run - engine.Check
+func (c *lineCodes) Run(ctx context.Context, data *models.SurveyData, cfg engine.Config) []models.ValidationIssue {
+	issues, _ := c.RunStream(ctx, data, cfg)
+	return issues
+}
+
+// line - a string that has been started and not yet ended
+type line struct {
+	name        string
+	first, last string // point IDs
+	points      int
+}
+
+// RunStream - engine.StreamCheck. Strings are followed in point order,
+// one per name (EP1 and EP2 are separate strings).
+func (c *lineCodes) RunStream(ctx context.Context, src models.PointSource, cfg engine.Config) ([]models.ValidationIssue, error) {
+	ctl := c.lib.Control
+	var (
+		issues []models.ValidationIssue
+		open   = make(map[string]*line)
+		order  []*line // open strings, oldest first
+	)
+	issue := func(kind string, ids []string, format string, args ...interface{}) {
+		issues = append(issues, models.ValidationIssue{
+			CheckName:   LineCheck,
+			Kind:        kind,
+			Severity:    models.SeverityWarning,
+			PointIDs:    ids,
+			Description: fmt.Sprintf(format, args...),
+		})
+	}
+	start := func(name, id string) *line {
+		l := &line{name: name, first: id, last: id, points: 1}
+		open[name] = l
+		order = append(order, l)
+		return l
+	}
+
+	err := src.Each(func(i int, p *models.SurveyPoint) error {
+		if i%1000 == 0 && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		f := c.lib.Parse(p.Code)
+		code, name, ok := c.lib.Lookup(f.Feature)
+		if !ok {
+			return nil // feature_codes reports these
+		}
+		if !code.Line {
+			if f.Controls() {
+				issue("control_on_point_feature", []string{p.PointID},
+					"%s is a point feature, its line control code on %s is ignored", code.Code, p.PointID)
+			}
+			return nil
+		}
+
+		l := open[name]
+		switch {
+		case f.Start && l != nil:
+			issue("line_restarted", []string{l.first, p.PointID},
+				"%s started again at %s before it was ended, the string from %s is cut short", name, p.PointID, l.first)
+			l = start(name, p.PointID)
+		case f.Start || l == nil:
+			if !f.Start && cfg.Bool("require_start") {
+				issue("line_not_started", []string{p.PointID}, "%s at %s has no %s, the string starts here", name, p.PointID, ctl.Start)
+			}
+			l = start(name, p.PointID)
+		default:
+			l.last = p.PointID
+			l.points++
+		}
+
+		if !f.End && !f.Close {
+			return nil
+		}
+		delete(open, name)
+		switch {
+		case f.Close && l.points < 3:
+			ids := []string{l.first}
+			if l.first != p.PointID {
+				ids = append(ids, p.PointID)
+			}
+			issue("short_line", ids, "%s is closed at %s with %d points, a closed string needs 3", name, p.PointID, l.points)
+		case l.points < 2:
+			issue("short_line", []string{p.PointID}, "%s is ended at %s where it started", name, p.PointID)
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, ctx.Err()) {
+			err = nil
+		}
+		return issues, err
+	}
+
+	if cfg.Bool("require_end") {
+		for _, l := range order {
+			if open[l.name] != l {
+				continue
+			}
+			issue("line_not_ended", []string{l.first, l.last},
+				"%s from %s to %s (%d points) is never ended with %s or %s", l.name, l.first, l.last, l.points, ctl.End, ctl.Close)
+		}
+	}
+	return issues, nil
+}
@@ -0,0 +1,212 @@
+package codes
+
+// codes.go - the feature code library: which field codes a survey may use,
+// which attributes each one needs, and the control codes that start, end
+// and close line strings
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/survey-validator/models"
+)
+
+// Library - the codes an organisation allows, loaded from JSON:
+//
+//	{
+//	  "control": {"start": "ST", "end": "END", "close": "CL"},
+//	  "codes": [
+//	    {"code": "TREE", "description": "Tree", "attributes": ["SPREAD", "HEIGHT"]},
+//	    {"code": "EP", "description": "Edge of pavement", "line": true}
+//	  ]
+//	}
+//
+// Codes are matched without regard to case.
+type Library struct {
+	Control Control `json:"control"`
+	Codes   []Code  `json:"codes"`
+
+	index map[string]*Code
+}
+
+// Code - one feature code
+type Code struct {
+	Code        string `json:"code"`
+	Description string `json:"description,omitempty"`
+	// Line - a line string feature (kerbs, fences, walls). Several
+	// strings of the same code are told apart by a number, EP1 and EP2.
+	Line bool `json:"line,omitempty"`
+	// Attributes - names every point with this code must carry
+	Attributes []string `json:"attributes,omitempty"`
+}
+
+// Control - the control codes for line strings, START, END and CLOSE when
+// not set
+type Control struct {
+	Start string `json:"start,omitempty"`
+	End   string `json:"end,omitempty"`
+	Close string `json:"close,omitempty"`
+}
+
+// Load - read and check a code library
+func Load(r io.Reader) (*Library, error) {
+	lib := new(Library)
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(lib); err != nil {
+		return nil, fmt.Errorf("codes: %w", err)
+	}
+	if err := lib.init(); err != nil {
+		return nil, err
+	}
+	return lib, nil
+}
+
+// LoadFile - Load from a path
+func LoadFile(path string) (*Library, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Load(f)
+}
+
+// init - fill in the default control codes and index the codes, refusing
+// anything a field code couldn't be parsed back into
+func (lib *Library) init() error {
+	c := &lib.Control
+	for _, f := range []struct {
+		code *string
+		def  string
+	}{{&c.Start, "START"}, {&c.End, "END"}, {&c.Close, "CLOSE"}} {
+		*f.code = strings.ToUpper(strings.TrimSpace(*f.code))
+		if *f.code == "" {
+			*f.code = f.def
+		}
+		if !isToken(*f.code) {
+			return fmt.Errorf("codes: control code %q can't contain spaces or =", *f.code)
+		}
+	}
+	if c.Start == c.End || c.Start == c.Close || c.End == c.Close {
+		return fmt.Errorf("codes: control codes must differ, got %s, %s and %s", c.Start, c.End, c.Close)
+	}
+	if len(lib.Codes) == 0 {
+		return fmt.Errorf("codes: the library has no codes")
+	}
+
+	lib.index = make(map[string]*Code, len(lib.Codes))
+	for i := range lib.Codes {
+		code := &lib.Codes[i]
+		code.Code = strings.ToUpper(strings.TrimSpace(code.Code))
+		switch {
+		case !isToken(code.Code):
+			return fmt.Errorf("codes: code %q can't be empty or contain spaces or =", code.Code)
+		case lib.isControl(code.Code):
+			return fmt.Errorf("codes: %s is a control code", code.Code)
+		case lib.index[code.Code] != nil:
+			return fmt.Errorf("codes: %s defined twice", code.Code)
+		}
+		for j, attr := range code.Attributes {
+			code.Attributes[j] = strings.ToUpper(strings.TrimSpace(attr))
+			if !isToken(code.Attributes[j]) {
+				return fmt.Errorf("codes: %s: attribute %q can't be empty or contain spaces or =", code.Code, attr)
+			}
+		}
+		lib.index[code.Code] = code
+	}
+	return nil
+}
+
+func isToken(s string) bool {
+	return s != "" && !strings.ContainsAny(s, "= \t")
+}
+
+func (lib *Library) isControl(token string) bool {
+	return token == lib.Control.Start || token == lib.Control.End || token == lib.Control.Close
+}
+
+// Field - a point's field code taken apart. "EP1 START" is feature EP1
+// with the start control; "TREE SPREAD=4" carries an attribute inline.
+type Field struct {
+	Feature    string // the first code that isn't a control, upper case
+	Start      bool   // the control codes on the point
+	End        bool
+	Close      bool
+	Attributes map[string]string // KEY=VALUE tokens, keys upper case
+}
+
+// Controls - whether the point carries any control code
+func (f Field) Controls() bool {
+	return f.Start || f.End || f.Close
+}
+
+// Parse - split a point's code into feature, controls and attributes.
+// Words after the feature that are neither are notes and ignored.
+func (lib *Library) Parse(code string) Field {
+	var f Field
+	for _, token := range strings.Fields(code) {
+		if k, v, ok := strings.Cut(token, "="); ok {
+			if f.Attributes == nil {
+				f.Attributes = make(map[string]string)
+			}
+			f.Attributes[strings.ToUpper(k)] = v
+			continue
+		}
+		switch upper := strings.ToUpper(token); {
+		case upper == lib.Control.Start:
+			f.Start = true
+		case upper == lib.Control.End:
+			f.End = true
+		case upper == lib.Control.Close:
+			f.Close = true
+		case f.Feature == "":
+			f.Feature = upper
+		}
+	}
+	return f
+}
+
+// Lookup - the library entry for a feature code. A line code may carry a
+// string number (EP2), returned as the string's name alongside.
+func (lib *Library) Lookup(feature string) (code *Code, name string, ok bool) {
+	feature = strings.ToUpper(feature)
+	if c := lib.index[feature]; c != nil {
+		return c, feature, true
+	}
+	base := strings.TrimRightFunc(feature, unicode.IsDigit)
+	if c := lib.index[base]; c != nil && c.Line && base != feature {
+		return c, feature, true
+	}
+	return nil, "", false
+}
+
+// Missing - the attributes code needs that the point doesn't have, looking
+// at both its attributes and the ones written into the code
+func (c *Code) Missing(p *models.SurveyPoint, f Field) []string {
+	var missing []string
+	for _, attr := range c.Attributes {
+		if f.Attributes[attr] != "" || attribute(p, attr) != "" {
+			continue
+		}
+		missing = append(missing, attr)
+	}
+	return missing
+}
+
+// attribute - a point attribute by name, ignoring case
+func attribute(p *models.SurveyPoint, name string) string {
+	if v, ok := p.Attributes[name]; ok {
+		return v
+	}
+	for k, v := range p.Attributes {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,184 @@
+package codes
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/survey-validator/engine"
+	"github.com/survey-validator/models"
+)
+
+func example(t *testing.T) *Library {
+	t.Helper()
+	lib, err := LoadFile("testdata/example.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return lib
+}
+
+func point(id, code string) models.SurveyPoint {
+	return models.SurveyPoint{PointID: id, Easting: 1000, Northing: 1000, SurveyType: models.SurveyTypeDetail, Code: code}
+}
+
+// run - the issues one of the library's checks reports on points
+func run(t *testing.T, lib *Library, check string, cfg engine.Config, pts ...models.SurveyPoint) []models.ValidationIssue {
+	t.Helper()
+	e := engine.NewEngine()
+	if err := Register(e, lib); err != nil {
+		t.Fatal(err)
+	}
+	opts := engine.Options{Enable: []string{check}}
+	if cfg != nil {
+		opts.Config = map[string]engine.Config{check: cfg}
+	}
+	report, err := e.ValidateContext(context.Background(), &models.SurveyData{ProjectID: "CODES", Points: pts}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return report.Issues
+}
+
+func kinds(issues []models.ValidationIssue) []string {
+	var out []string
+	for _, issue := range issues {
+		out = append(out, issue.Kind+" "+strings.Join(issue.PointIDs, ","))
+	}
+	return out
+}
+
+func TestParse(t *testing.T) {
+	lib := example(t)
+	f := lib.Parse("ep1 st kerb spread=4")
+	if f.Feature != "EP1" || !f.Start || f.End || f.Close || f.Attributes["SPREAD"] != "4" {
+		t.Errorf("Unexpected field: %+v", f)
+	}
+	if f := lib.Parse("CL"); f.Feature != "" || !f.Close {
+		t.Errorf("Unexpected field: %+v", f)
+	}
+
+	for code, want := range map[string]string{"EP": "EP", "ep12": "EP12", "Tree": "TREE"} {
+		if _, name, ok := lib.Lookup(code); !ok || name != want {
+			t.Errorf("Lookup(%q) = %q, %v; expected %q", code, name, ok, want)
+		}
+	}
+	// only line codes take a string number
+	if _, _, ok := lib.Lookup("TREE2"); ok {
+		t.Error("Expected TREE2 to be unknown")
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	tests := map[string]string{
+		`{"codes": []}`: "no codes",
+		`{"codes": [{"code": "EP"}, {"code": "ep"}]}`:                        "twice",
+		`{"codes": [{"code": "END"}]}`:                                       "control code",
+		`{"codes": [{"code": "EP 1"}]}`:                                      "spaces",
+		`{"codes": [{"code": "EP", "line": 1}]}`:                             "codes:",
+		`{"control": {"start": "X", "end": "x"}, "codes": [{"code": "EP"}]}`: "must differ",
+		`{"codez": []}`: "unknown field",
+	}
+	for in, want := range tests {
+		_, err := Load(strings.NewReader(in))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: expected an error with %q, got %v", in, want, err)
+		}
+	}
+
+	lib, err := Load(strings.NewReader(`{"codes": [{"code": "ep", "line": true}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lib.Control != (Control{Start: "START", End: "END", Close: "CLOSE"}) {
+		t.Errorf("Expected the default control codes, got %+v", lib.Control)
+	}
+}
+
+func TestFeatureCodes(t *testing.T) {
+	lib := example(t)
+	tree := point("T2", "TREE")
+	tree.Attributes = map[string]string{"spread": "4", "height": "12"}
+
+	issues := run(t, lib, FeatureCheck, nil,
+		point("T1", "TREE SPREAD=4"),
+		tree,
+		point("X1", "WALL"),
+		point("T3", "tree spread=3"),
+		point("X2", "wall st"),
+		point("MH1", "MH"),
+		point("P1", ""),
+	)
+	got := kinds(issues)
+	want := []string{
+		"missing_attributes T1,T3",
+		"unknown_code X1,X2",
+		"missing_attributes MH1",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Issues = %q; expected %q", got, want)
+	}
+	if d := issues[0].Description; d != "TREE needs HEIGHT (2 points: T1, T3)" {
+		t.Errorf("Unexpected description %q", d)
+	}
+	if d := issues[1].Description; d != "Code WALL isn't in the code library (2 points: X1, X2)" {
+		t.Errorf("Unexpected description %q", d)
+	}
+
+	// blank codes only count when asked
+	issues = run(t, lib, FeatureCheck, engine.Config{"require_code": true}, point("P1", ""), point("CP1", "CP"))
+	if got := kinds(issues); !reflect.DeepEqual(got, []string{"missing_code P1"}) {
+		t.Errorf("Issues = %q", got)
+	}
+}
+
+func TestLineCodes(t *testing.T) {
+	lib := example(t)
+	issues := run(t, lib, LineCheck, nil,
+		point("1", "EP1 ST"),
+		point("2", "EP2 ST"),
+		point("3", "EP1"),
+		point("4", "TREE ST"),
+		point("5", "EP1 END"), // EP1 done
+		point("6", "FENCE"),   // never started
+		point("7", "FENCE"),
+		point("8", "FENCE ST"), // restarted while open
+		point("9", "FENCE END"),
+		point("10", "BLDG ST"),
+		point("11", "BLDG CL"), // too few to close
+		point("12", "BLDG1 ST"),
+		point("13", "BLDG1"),
+		point("14", "BLDG1 CL"),
+		point("15", "WALL ST"), // unknown, left to feature_codes
+		point("16", "EP2"),     // still open at the end
+	)
+	want := []string{
+		"control_on_point_feature 4",
+		"line_not_started 6",
+		"line_restarted 6,8",
+		"short_line 10,11",
+		"line_not_ended 2,16",
+	}
+	if got := kinds(issues); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Issues = %q; expected %q", got, want)
+	}
+
+	// a crew that doesn't use the start and end codes
+	issues = run(t, lib, LineCheck, engine.Config{"require_start": false, "require_end": false},
+		point("1", "EP"), point("2", "EP"), point("3", "EP"))
+	if len(issues) != 0 {
+		t.Errorf("Expected no issues, got %q", kinds(issues))
+	}
+}
+
+func TestRegister_Twice(t *testing.T) {
+	e := engine.NewEngine()
+	lib := example(t)
+	if err := Register(e, lib); err != nil {
+		t.Fatal(err)
+	}
+	if err := Register(e, lib); err == nil {
+		t.Error("Expected registering the checks twice to fail")
+	}
+}
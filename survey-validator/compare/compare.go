@@ -0,0 +1,278 @@
+package compare
+
+// compare.go - what moved between two versions of a dataset: a re-survey,
+// a revised file from the client, or two monitoring epochs
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/survey-validator/models"
+)
+
+// defaults when Options leaves them at zero
+const (
+	DefaultThreshold       = 0.02  // 20mm - movement worth flagging
+	DefaultRenameTolerance = 0.005 // 5mm - closer than this is the same mark under a new ID
+)
+
+// Options - tolerances for a comparison, in metres
+type Options struct {
+	Threshold       float64 `json:"threshold,omitempty"`
+	RenameTolerance float64 `json:"rename_tolerance,omitempty"`
+}
+
+// Result - the difference between two datasets
+type Result struct {
+	Before    string  `json:"before"` // project IDs
+	After     string  `json:"after"`
+	Threshold float64 `json:"threshold"`
+
+	Matched int      `json:"matched"` // points in both, renamed ones included
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+	Renamed []Rename `json:"renamed"`
+	Moved   []string `json:"moved"` // displaced more than Threshold
+
+	Displacements []Displacement `json:"displacements"`
+	Summary       Summary        `json:"summary"`
+
+	// Transform - the best-fit shift, rotation and scale from before to
+	// after, nil with fewer than 3 matched points
+	Transform *Transform `json:"transform,omitempty"`
+}
+
+// Rename - an added and a removed point sitting on the same spot
+type Rename struct {
+	From     string  `json:"from"`
+	To       string  `json:"to"`
+	Distance float64 `json:"distance"`
+}
+
+// Displacement - how one point moved, after minus before
+type Displacement struct {
+	PointID    string   `json:"point_id"`
+	PreviousID string   `json:"previous_id,omitempty"` // when renamed
+	DE         float64  `json:"de"`
+	DN         float64  `json:"dn"`
+	DH         *float64 `json:"dh,omitempty"` // when both have a height
+	Horizontal float64  `json:"horizontal"`
+	Bearing    float64  `json:"bearing"` // direction of movement, degrees
+	Moved      bool     `json:"moved"`
+
+	// Residual - what's left after taking out the systematic transform,
+	// i.e. how much the point moved relative to the rest
+	Residual float64 `json:"residual"`
+}
+
+// Summary - displacement stats over the matched points
+type Summary struct {
+	MaxHorizontal  float64 `json:"max_horizontal"`
+	MaxPointID     string  `json:"max_point_id,omitempty"`
+	MeanHorizontal float64 `json:"mean_horizontal"`
+	RMSHorizontal  float64 `json:"rms_horizontal"`
+	MaxVertical    float64 `json:"max_vertical,omitempty"`
+}
+
+// Transform - 2D similarity (Helmert) fit of after on before. Rotation is
+// in arc-seconds, positive clockwise like a bearing swing. Scale is in ppm.
+type Transform struct {
+	ShiftE      float64  `json:"shift_e"`
+	ShiftN      float64  `json:"shift_n"`
+	Shift       float64  `json:"shift"`
+	ShiftDir    float64  `json:"shift_bearing"` // degrees
+	Rotation    float64  `json:"rotation_seconds"`
+	Scale       float64  `json:"scale_ppm"`
+	RMS         float64  `json:"rms"`
+	PointsUsed  int      `json:"points_used"`
+	Excluded    []string `json:"excluded,omitempty"` // left out of the fit as moved
+	Significant bool     `json:"significant"`        // moves some point more than Threshold
+	Description string   `json:"description"`
+}
+
+// Compare - match points by ID, pick up renames, and measure what moved
+func Compare(before, after *models.SurveyData, opts Options) *Result {
+	if opts.Threshold <= 0 {
+		opts.Threshold = DefaultThreshold
+	}
+	if opts.RenameTolerance <= 0 {
+		opts.RenameTolerance = DefaultRenameTolerance
+	}
+
+	res := &Result{
+		Before:        before.ProjectID,
+		After:         after.ProjectID,
+		Threshold:     opts.Threshold,
+		Added:         []string{},
+		Removed:       []string{},
+		Renamed:       []Rename{},
+		Moved:         []string{},
+		Displacements: []Displacement{},
+	}
+
+	old := index(before.Points)
+	cur := index(after.Points)
+
+	// a repeated ID counts once, first occurrence wins
+	var pairs []pair
+	var removed, added []*models.SurveyPoint
+	for id, p := range old {
+		if q, ok := cur[id]; ok {
+			pairs = append(pairs, pair{p, q, ""})
+		} else if id != "" {
+			removed = append(removed, p)
+		}
+	}
+	for id, q := range cur {
+		if _, ok := old[id]; !ok && id != "" {
+			added = append(added, q)
+		}
+	}
+	// map order is random, the rename matching needs a fixed one
+	sort.Slice(removed, func(i, j int) bool { return removed[i].PointID < removed[j].PointID })
+	sort.Slice(added, func(i, j int) bool { return added[i].PointID < added[j].PointID })
+
+	renamed := matchRenames(removed, added, opts.RenameTolerance)
+	for _, r := range renamed {
+		res.Renamed = append(res.Renamed, Rename{From: r.from.PointID, To: r.to.PointID, Distance: round4(r.dist)})
+		pairs = append(pairs, pair{r.from, r.to, r.from.PointID})
+	}
+	gone := make(map[string]bool)
+	for _, r := range renamed {
+		gone[r.from.PointID], gone[r.to.PointID] = true, true
+	}
+	for _, p := range removed {
+		if !gone[p.PointID] {
+			res.Removed = append(res.Removed, p.PointID)
+		}
+	}
+	for _, p := range added {
+		if !gone[p.PointID] {
+			res.Added = append(res.Added, p.PointID)
+		}
+	}
+	sort.Strings(res.Added)
+	sort.Strings(res.Removed)
+
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].b.PointID < pairs[j].b.PointID })
+	res.Matched = len(pairs)
+	var fit *transform
+	res.Transform, fit = fitTransform(pairs, opts.Threshold)
+
+	var sum, sumSq float64
+	for _, pr := range pairs {
+		d := displacement(pr, fit)
+		d.Moved = d.Horizontal > opts.Threshold || (d.DH != nil && math.Abs(*d.DH) > opts.Threshold)
+		if d.Moved {
+			res.Moved = append(res.Moved, d.PointID)
+		}
+		if d.Horizontal > res.Summary.MaxHorizontal {
+			res.Summary.MaxHorizontal = d.Horizontal
+			res.Summary.MaxPointID = d.PointID
+		}
+		if d.DH != nil {
+			res.Summary.MaxVertical = math.Max(res.Summary.MaxVertical, math.Abs(*d.DH))
+		}
+		sum += d.Horizontal
+		sumSq += d.Horizontal * d.Horizontal
+		res.Displacements = append(res.Displacements, d)
+	}
+	if n := float64(len(pairs)); n > 0 {
+		res.Summary.MeanHorizontal = round4(sum / n)
+		res.Summary.RMSHorizontal = round4(math.Sqrt(sumSq / n))
+	}
+	return res
+}
+
+// pair - the same mark in both datasets
+type pair struct {
+	a, b       *models.SurveyPoint
+	previousID string // set when it was renamed
+}
+
+func index(points []models.SurveyPoint) map[string]*models.SurveyPoint {
+	m := make(map[string]*models.SurveyPoint, len(points))
+	for i := range points {
+		if _, dup := m[points[i].PointID]; !dup {
+			m[points[i].PointID] = &points[i]
+		}
+	}
+	return m
+}
+
+type rename struct {
+	from, to *models.SurveyPoint
+	dist     float64
+}
+
+// matchRenames - closest removed/added pairs first, each point used once.
+// Points of different survey types aren't paired.
+func matchRenames(removed, added []*models.SurveyPoint, tol float64) []rename {
+	var cand []rename
+	for _, a := range removed {
+		for _, b := range added {
+			if a.SurveyType != "" && b.SurveyType != "" && a.SurveyType != b.SurveyType {
+				continue
+			}
+			if d := math.Hypot(b.Easting-a.Easting, b.Northing-a.Northing); d <= tol {
+				cand = append(cand, rename{a, b, d})
+			}
+		}
+	}
+	sort.SliceStable(cand, func(i, j int) bool { return cand[i].dist < cand[j].dist })
+
+	used := make(map[*models.SurveyPoint]bool)
+	var out []rename
+	for _, c := range cand {
+		if used[c.from] || used[c.to] {
+			continue
+		}
+		used[c.from], used[c.to] = true, true
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].to.PointID < out[j].to.PointID })
+	return out
+}
+
+func displacement(pr pair, t *transform) Displacement {
+	a, b := pr.a, pr.b
+	d := Displacement{
+		PointID:    b.PointID,
+		PreviousID: pr.previousID,
+		DE:         round4(b.Easting - a.Easting),
+		DN:         round4(b.Northing - a.Northing),
+	}
+	d.Horizontal = round4(math.Hypot(b.Easting-a.Easting, b.Northing-a.Northing))
+	if d.Horizontal > 0 {
+		d.Bearing = round4(bearing(b.Easting-a.Easting, b.Northing-a.Northing))
+	}
+	if a.Height != nil && b.Height != nil {
+		dh := round4(*b.Height - *a.Height)
+		d.DH = &dh
+	}
+	d.Residual = d.Horizontal
+	if t != nil {
+		e, n := t.apply(a.Easting, a.Northing)
+		d.Residual = round4(math.Hypot(b.Easting-e, b.Northing-n))
+	}
+	return d
+}
+
+func bearing(dE, dN float64) float64 {
+	b := math.Atan2(dE, dN) * 180 / math.Pi
+	if b < 0 {
+		b += 360
+	}
+	return b
+}
+
+func round4(v float64) float64 {
+	return math.Round(v*10000) / 10000
+}
+
+// String - a one-line summary, for logs and the CLI
+func (r *Result) String() string {
+	return fmt.Sprintf("%d matched, %d added, %d removed, %d renamed, %d moved more than %.3fm",
+		r.Matched, len(r.Added), len(r.Removed), len(r.Renamed), len(r.Moved), r.Threshold)
+}
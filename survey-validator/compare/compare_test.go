@@ -0,0 +1,112 @@
+package compare
+
+import (
+	"math"
+	"testing"
+
+	"github.com/survey-validator/models"
+)
+
+func h(v float64) *float64 { return &v }
+
+// grid - a 4x4 grid of monitoring targets 20m apart
+func grid(id string) *models.SurveyData {
+	data := &models.SurveyData{ProjectID: id}
+	for i := 0; i < 16; i++ {
+		data.Points = append(data.Points, models.SurveyPoint{
+			PointID:    "M" + string(rune('A'+i)),
+			Easting:    500000 + float64(i%4)*20,
+			Northing:   600000 + float64(i/4)*20,
+			Height:     h(100),
+			SurveyType: models.SurveyTypeDetail,
+		})
+	}
+	return data
+}
+
+func TestCompare_AddedRemovedRenamed(t *testing.T) {
+	before, after := grid("E1"), grid("E2")
+	after.Points[0].PointID = "MA2"  // same spot, new name
+	after.Points[1].Easting += 5     // far enough to not be a rename
+	after.Points[1].PointID = "NEW1" // so MB removed, NEW1 added
+	after.Points = after.Points[:15] // MP gone
+
+	res := Compare(before, after, Options{})
+
+	if len(res.Renamed) != 1 || res.Renamed[0].From != "MA" || res.Renamed[0].To != "MA2" {
+		t.Errorf("Expected MA renamed to MA2, got %+v", res.Renamed)
+	}
+	if len(res.Added) != 1 || res.Added[0] != "NEW1" {
+		t.Errorf("Expected NEW1 added, got %v", res.Added)
+	}
+	if len(res.Removed) != 2 || res.Removed[0] != "MB" || res.Removed[1] != "MP" {
+		t.Errorf("Expected MB and MP removed, got %v", res.Removed)
+	}
+	if res.Matched != 14 || len(res.Moved) != 0 {
+		t.Errorf("Expected 14 matched and nothing moved, got %d / %v", res.Matched, res.Moved)
+	}
+}
+
+func TestCompare_MovedPoint(t *testing.T) {
+	before, after := grid("E1"), grid("E2")
+	after.Points[5].Easting += 0.030
+	after.Points[5].Northing -= 0.040
+	*after.Points[6].Height -= 0.025
+
+	res := Compare(before, after, Options{Threshold: 0.02})
+
+	if len(res.Moved) != 2 || res.Moved[0] != "MF" || res.Moved[1] != "MG" {
+		t.Fatalf("Expected MF and MG moved, got %v", res.Moved)
+	}
+	var mf Displacement
+	for _, d := range res.Displacements {
+		if d.PointID == "MF" {
+			mf = d
+		}
+	}
+	if mf.Horizontal != 0.05 || math.Abs(mf.Bearing-143.1301) > 0.001 {
+		t.Errorf("Expected 0.05m on 143.13°, got %.4f on %.4f", mf.Horizontal, mf.Bearing)
+	}
+	if res.Summary.MaxPointID != "MF" || res.Summary.MaxVertical != 0.025 {
+		t.Errorf("Unexpected summary %+v", res.Summary)
+	}
+
+	tr := res.Transform
+	if tr == nil || tr.Significant {
+		t.Fatalf("One moving target shouldn't look systematic: %+v", tr)
+	}
+	if len(tr.Excluded) != 1 || tr.Excluded[0] != "MF" || tr.RMS > 0.0001 {
+		t.Errorf("Expected MF left out of the fit, got %v (RMS %.4f)", tr.Excluded, tr.RMS)
+	}
+}
+
+func TestCompare_SystematicShiftAndRotation(t *testing.T) {
+	before, after := grid("E1"), grid("E2")
+
+	// rotate 20" clockwise about the site centre and shift 30mm east
+	theta := -20.0 / 3600 * math.Pi / 180
+	cE, cN := 500030.0, 600030.0
+	for i := range after.Points {
+		p := &after.Points[i]
+		x, y := p.Easting-cE, p.Northing-cN
+		p.Easting = cE + x*math.Cos(theta) - y*math.Sin(theta) + 0.030
+		p.Northing = cN + x*math.Sin(theta) + y*math.Cos(theta)
+	}
+
+	res := Compare(before, after, Options{})
+	tr := res.Transform
+	if tr == nil || !tr.Significant {
+		t.Fatalf("Expected a significant systematic movement, got %+v", tr)
+	}
+	if math.Abs(tr.ShiftE-0.030) > 0.0001 || math.Abs(tr.ShiftN) > 0.0001 {
+		t.Errorf("Expected 0.030m east, got %.4f, %.4f", tr.ShiftE, tr.ShiftN)
+	}
+	if math.Abs(tr.Rotation-20) > 0.1 || math.Abs(tr.Scale) > 0.1 {
+		t.Errorf("Expected +20\" and no scale, got %.1f\" %.1fppm", tr.Rotation, tr.Scale)
+	}
+	for _, d := range res.Displacements {
+		if d.Residual > 0.0001 {
+			t.Errorf("%s: expected nothing left after the transform, got %.4f", d.PointID, d.Residual)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package compare
+
+// helmert.go - best-fit 2D similarity transform between the two epochs, so a
+// whole-site shift or twist (a different control point, a bad backsight)
+// shows up as one number instead of every point "moving"
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// transform - the fitted similarity, kept out of Transform which only has
+// what the API reports
+type transform struct {
+	fromE, fromN float64 // centroid before
+	toE, toN     float64 // centroid after
+	a, b         float64 // scale*cos, scale*sin
+}
+
+// apply - where the transform puts a before coordinate
+func (t *transform) apply(e, n float64) (float64, float64) {
+	x, y := e-t.fromE, n-t.fromN
+	return t.a*x - t.b*y + t.toE, t.b*x + t.a*y + t.toN
+}
+
+func helmert(pairs []pair) transform {
+	var t transform
+	n := float64(len(pairs))
+	for _, p := range pairs {
+		t.fromE += p.a.Easting / n
+		t.fromN += p.a.Northing / n
+		t.toE += p.b.Easting / n
+		t.toN += p.b.Northing / n
+	}
+
+	var num1, num2, den float64
+	for _, p := range pairs {
+		x, y := p.a.Easting-t.fromE, p.a.Northing-t.fromN
+		X, Y := p.b.Easting-t.toE, p.b.Northing-t.toN
+		num1 += x*X + y*Y
+		num2 += x*Y - y*X
+		den += x*x + y*y
+	}
+	t.a = 1
+	if den > 0 {
+		t.a, t.b = num1/den, num2/den
+	}
+	return t
+}
+
+// fitTransform - fit on all matched points, then once more without any
+// that clearly moved on their own (over threshold and 3x the median
+// residual) so a few moving targets don't drag the datum with them. Both
+// are nil with fewer than 3 pairs.
+func fitTransform(pairs []pair, threshold float64) (*Transform, *transform) {
+	if len(pairs) < 3 {
+		return nil, nil
+	}
+
+	used := pairs
+	fit := helmert(used)
+	res := residuals(fit, used)
+
+	sorted := append([]float64(nil), res...)
+	sort.Float64s(sorted)
+	median := sorted[len(sorted)/2]
+
+	var keep []pair
+	var excluded []string
+	for i, p := range used {
+		if res[i] > threshold && res[i] > 3*median {
+			excluded = append(excluded, p.b.PointID)
+			continue
+		}
+		keep = append(keep, p)
+	}
+	if len(excluded) > 0 && len(keep) >= 3 {
+		used = keep
+		fit = helmert(used)
+		res = residuals(fit, used)
+	} else {
+		excluded = nil
+	}
+
+	var sumSq, radius float64
+	for i, p := range used {
+		sumSq += res[i] * res[i]
+		radius = math.Max(radius, math.Hypot(p.a.Easting-fit.fromE, p.a.Northing-fit.fromN))
+	}
+
+	scale := math.Hypot(fit.a, fit.b)
+	theta := math.Atan2(fit.b, fit.a) // anticlockwise
+	shiftE, shiftN := fit.toE-fit.fromE, fit.toN-fit.fromN
+
+	t := &Transform{
+		ShiftE:     round4(shiftE),
+		ShiftN:     round4(shiftN),
+		Shift:      round4(math.Hypot(shiftE, shiftN)),
+		Rotation:   math.Round(-theta*180/math.Pi*3600*10) / 10,
+		Scale:      math.Round((scale-1)*1e6*10) / 10,
+		RMS:        round4(math.Sqrt(sumSq / float64(len(used)))),
+		PointsUsed: len(used),
+		Excluded:   excluded,
+	}
+	if t.Shift > 0 {
+		t.ShiftDir = round4(bearing(shiftE, shiftN))
+	}
+	t.Rotation += 0 // no -0.0 in the output
+	t.Scale += 0
+
+	// does the systematic part move any point more than the threshold
+	twist := math.Abs(theta) * radius
+	stretch := math.Abs(scale-1) * radius
+	t.Significant = t.Shift > threshold || twist > threshold || stretch > threshold
+
+	t.Description = fmt.Sprintf("Shift %.4fm on %.1f°, rotation %+.1f\", scale %+.1fppm, fitted on %d points (RMS %.4fm)",
+		t.Shift, t.ShiftDir, t.Rotation, t.Scale, t.PointsUsed, t.RMS)
+	if t.Significant {
+		t.Description = "Systematic movement: " + t.Description
+	} else {
+		t.Description = "No systematic movement: " + t.Description
+	}
+	return t, &fit
+}
+
+func residuals(t transform, pairs []pair) []float64 {
+	out := make([]float64, len(pairs))
+	for i, p := range pairs {
+		e, n := t.apply(p.a.Easting, p.a.Northing)
+		out[i] = math.Hypot(p.b.Easting-e, p.b.Northing-n)
+	}
+	return out
+}
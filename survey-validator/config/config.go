@@ -0,0 +1,313 @@
+package config
+
+// config.go - the server's settings. Each layer overrides the one before:
+// defaults, then a JSON config file, then environment variables, then
+// command-line flags.
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/survey-validator/api"
+	"github.com/survey-validator/engine"
+)
+
+// Config - everything cmd/server can be told
+type Config struct {
+	Addr      string `json:"addr"`                 // host:port to listen on
+	StaticDir string `json:"static_dir,omitempty"` // the web app's files, "" for the embedded copy
+	DataDir   string `json:"data_dir"`             // stored reports and monitoring epochs
+
+	RulesFile    string `json:"rules_file,omitempty"`
+	CodesFile    string `json:"codes_file,omitempty"`
+	Profile      string `json:"profile"` // validation profile for every request
+	ProfilesFile string `json:"profiles_file,omitempty"`
+	Workers      int    `json:"workers,omitempty"` // 0 for one per CPU
+
+	MaxBodyMB   int64    `json:"max_body_mb"`
+	MaxStreamMB int64    `json:"max_stream_mb"`
+	CORSOrigins []string `json:"cors_origins,omitempty"` // any when empty
+
+	KeysFile string `json:"keys_file,omitempty"`
+	AuditLog string `json:"audit_log,omitempty"`
+
+	LogFormat string `json:"log_format"` // text or json
+	LogLevel  string `json:"log_level"`  // debug, info, warn or error
+
+	TLS      TLS      `json:"tls"`
+	Timeouts Timeouts `json:"timeouts"`
+}
+
+// TLS - serve HTTPS when both are set
+type TLS struct {
+	CertFile string `json:"cert_file,omitempty"`
+	KeyFile  string `json:"key_file,omitempty"`
+}
+
+// Timeouts - see api.Timeouts
+type Timeouts struct {
+	ReadHeader Duration `json:"read_header"`
+	Read       Duration `json:"read"`
+	Write      Duration `json:"write"`
+	Idle       Duration `json:"idle"`
+	Shutdown   Duration `json:"shutdown"`
+}
+
+// API - the timeouts as the server takes them
+func (t Timeouts) API() api.Timeouts {
+	return api.Timeouts{
+		ReadHeader: time.Duration(t.ReadHeader),
+		Read:       time.Duration(t.Read),
+		Write:      time.Duration(t.Write),
+		Idle:       time.Duration(t.Idle),
+		Shutdown:   time.Duration(t.Shutdown),
+	}
+}
+
+// Duration - a time.Duration written "30s" or "2m" in JSON. A bare number
+// is seconds.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var secs float64
+	if err := json.Unmarshal(b, &secs); err == nil {
+		*d = Duration(secs * float64(time.Second))
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("a duration is a string like \"30s\" or a number of seconds")
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Default - the settings with nothing overridden
+func Default() Config {
+	t := api.DefaultTimeouts
+	return Config{
+		Addr:        ":8080",
+		DataDir:     "./data",
+		Profile:     engine.DefaultProfile,
+		MaxBodyMB:   api.DefaultMaxBody >> 20,
+		MaxStreamMB: api.DefaultMaxStream >> 20,
+		LogFormat:   "text",
+		LogLevel:    "info",
+		Timeouts: Timeouts{
+			ReadHeader: Duration(t.ReadHeader),
+			Read:       Duration(t.Read),
+			Write:      Duration(t.Write),
+			Idle:       Duration(t.Idle),
+			Shutdown:   Duration(t.Shutdown),
+		},
+	}
+}
+
+// LoadFile - override c with the JSON file at path. Settings the file
+// leaves out keep their values; ones it misspells are an error.
+func (c *Config) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// envVar - a variable and the setting it overrides
+type envVar struct {
+	name string
+	set  func(c *Config, v string) error
+}
+
+// envVars - in the order they apply, so ADDR beats PORT
+var envVars = []envVar{
+	{"PORT", func(c *Config, v string) error { c.Addr = ":" + v; return nil }},
+	{"ADDR", str(func(c *Config) *string { return &c.Addr })},
+	{"STATIC_DIR", str(func(c *Config) *string { return &c.StaticDir })},
+	{"DATA_DIR", str(func(c *Config) *string { return &c.DataDir })},
+	{"RULES_FILE", str(func(c *Config) *string { return &c.RulesFile })},
+	{"CODES_FILE", str(func(c *Config) *string { return &c.CodesFile })},
+	{"PROFILE", str(func(c *Config) *string { return &c.Profile })},
+	{"PROFILES_FILE", str(func(c *Config) *string { return &c.ProfilesFile })},
+	{"WORKERS", func(c *Config, v string) error {
+		n, err := strconv.Atoi(v)
+		c.Workers = n
+		return err
+	}},
+	{"MAX_BODY_MB", megabytes(func(c *Config) *int64 { return &c.MaxBodyMB })},
+	{"MAX_STREAM_MB", megabytes(func(c *Config) *int64 { return &c.MaxStreamMB })},
+	{"CORS_ORIGINS", func(c *Config, v string) error { c.CORSOrigins = SplitList(v); return nil }},
+	{"API_KEYS_FILE", str(func(c *Config) *string { return &c.KeysFile })},
+	{"AUDIT_LOG", str(func(c *Config) *string { return &c.AuditLog })},
+	{"LOG_FORMAT", str(func(c *Config) *string { return &c.LogFormat })},
+	{"LOG_LEVEL", str(func(c *Config) *string { return &c.LogLevel })},
+	{"TLS_CERT_FILE", str(func(c *Config) *string { return &c.TLS.CertFile })},
+	{"TLS_KEY_FILE", str(func(c *Config) *string { return &c.TLS.KeyFile })},
+	{"READ_HEADER_TIMEOUT", duration(func(c *Config) *Duration { return &c.Timeouts.ReadHeader })},
+	{"READ_TIMEOUT", duration(func(c *Config) *Duration { return &c.Timeouts.Read })},
+	{"WRITE_TIMEOUT", duration(func(c *Config) *Duration { return &c.Timeouts.Write })},
+	{"IDLE_TIMEOUT", duration(func(c *Config) *Duration { return &c.Timeouts.Idle })},
+	{"SHUTDOWN_TIMEOUT", duration(func(c *Config) *Duration { return &c.Timeouts.Shutdown })},
+}
+
+func str(field func(c *Config) *string) func(c *Config, v string) error {
+	return func(c *Config, v string) error { *field(c) = v; return nil }
+}
+
+func megabytes(field func(c *Config) *int64) func(c *Config, v string) error {
+	return func(c *Config, v string) error {
+		n, err := strconv.ParseInt(v, 10, 64)
+		*field(c) = n
+		return err
+	}
+}
+
+func duration(field func(c *Config) *Duration) func(c *Config, v string) error {
+	return func(c *Config, v string) error {
+		d, err := time.ParseDuration(v)
+		*field(c) = Duration(d)
+		return err
+	}
+}
+
+// FromEnv - override c with the environment variables getenv has. Empty
+// ones are skipped.
+func (c *Config) FromEnv(getenv func(string) string) error {
+	for _, e := range envVars {
+		v := strings.TrimSpace(getenv(e.name))
+		if v == "" {
+			continue
+		}
+		if err := e.set(c, v); err != nil {
+			return fmt.Errorf("%s: %w", e.name, err)
+		}
+	}
+	return nil
+}
+
+// flags - c's settings as flags, defaulting to what c has now
+func (c *Config) flags(fs *flag.FlagSet, configFile *string) {
+	fs.StringVar(configFile, "config", *configFile, "JSON config file (env CONFIG_FILE)")
+	fs.StringVar(&c.Addr, "addr", c.Addr, "Address to listen on, host:port")
+	fs.Func("port", "Port to listen on, short for -addr :PORT", func(v string) error {
+		c.Addr = ":" + v
+		return nil
+	})
+	fs.StringVar(&c.StaticDir, "static", c.StaticDir, "Serve the web app from this directory instead of the embedded copy")
+	fs.StringVar(&c.DataDir, "data", c.DataDir, "Directory for stored reports and monitoring epochs")
+	fs.StringVar(&c.RulesFile, "rules", c.RulesFile, "JSON file with extra validation rules")
+	fs.StringVar(&c.CodesFile, "codes", c.CodesFile, "JSON feature code library, adds the code checks")
+	fs.StringVar(&c.Profile, "profile", c.Profile, "Validation profile for every request")
+	fs.StringVar(&c.ProfilesFile, "profiles", c.ProfilesFile, "JSON file with extra profiles")
+	fs.IntVar(&c.Workers, "workers", c.Workers, "Background validation jobs run at once (0 for one per CPU)")
+	fs.Int64Var(&c.MaxBodyMB, "max-body", c.MaxBodyMB, "Largest JSON request body in MB")
+	fs.Int64Var(&c.MaxStreamMB, "max-stream", c.MaxStreamMB, "Largest upload to /api/v1/validate/stream in MB")
+	fs.Func("cors-origins", "Comma-separated origins allowed to call the API (default any)", func(v string) error {
+		c.CORSOrigins = SplitList(v)
+		return nil
+	})
+	fs.StringVar(&c.KeysFile, "keys", c.KeysFile, "JSON file of API keys; when set every API request needs one")
+	fs.StringVar(&c.AuditLog, "audit", c.AuditLog, "File to append an audit line per API request to (default <data>/audit.log with -keys)")
+	fs.StringVar(&c.LogFormat, "log-format", c.LogFormat, "Log format: text or json")
+	fs.StringVar(&c.LogLevel, "log-level", c.LogLevel, "Least important logs to write: debug, info, warn or error")
+	fs.StringVar(&c.TLS.CertFile, "tls-cert", c.TLS.CertFile, "PEM certificate file, to serve HTTPS")
+	fs.StringVar(&c.TLS.KeyFile, "tls-key", c.TLS.KeyFile, "PEM key file for -tls-cert")
+	fs.DurationVar((*time.Duration)(&c.Timeouts.ReadHeader), "read-header-timeout", time.Duration(c.Timeouts.ReadHeader), "Time to read a request's headers")
+	fs.DurationVar((*time.Duration)(&c.Timeouts.Read), "read-timeout", time.Duration(c.Timeouts.Read), "Time to read a whole request (0 for none)")
+	fs.DurationVar((*time.Duration)(&c.Timeouts.Write), "write-timeout", time.Duration(c.Timeouts.Write), "Time to write an answer (0 for none)")
+	fs.DurationVar((*time.Duration)(&c.Timeouts.Idle), "idle-timeout", time.Duration(c.Timeouts.Idle), "Time to keep an idle connection open")
+	fs.DurationVar((*time.Duration)(&c.Timeouts.Shutdown), "shutdown-timeout", time.Duration(c.Timeouts.Shutdown), "Time for requests and jobs to finish on SIGTERM")
+}
+
+// Load - the settings from every layer: defaults, the config file named
+// by -config or CONFIG_FILE, the environment, then the flags in args. The
+// file is found with a first pass over args, so flags still win over it.
+func Load(args []string, getenv func(string) string, output io.Writer) (Config, error) {
+	configFile := getenv("CONFIG_FILE")
+	pre := flag.NewFlagSet("", flag.ContinueOnError)
+	pre.SetOutput(io.Discard)
+	scratch := Default()
+	scratch.flags(pre, &configFile)
+	pre.Parse(args) // errors come from the real pass
+
+	c := Default()
+	if configFile != "" {
+		if err := c.LoadFile(configFile); err != nil {
+			return c, err
+		}
+	}
+	if err := c.FromEnv(getenv); err != nil {
+		return c, err
+	}
+
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	fs.SetOutput(output)
+	c.flags(fs, &configFile)
+	if err := fs.Parse(args); err != nil {
+		return c, err
+	}
+	if fs.NArg() > 0 {
+		return c, fmt.Errorf("unexpected arguments %v", fs.Args())
+	}
+	return c, c.Validate()
+}
+
+// Validate - settings that can't work, found before the server starts
+func (c *Config) Validate() error {
+	var errs []error
+	if c.Addr == "" {
+		errs = append(errs, errors.New("addr is empty"))
+	}
+	if c.MaxBodyMB <= 0 || c.MaxStreamMB <= 0 {
+		errs = append(errs, errors.New("max_body_mb and max_stream_mb must be positive"))
+	}
+	if c.Workers < 0 {
+		errs = append(errs, errors.New("workers can't be negative"))
+	}
+	if c.LogFormat != "text" && c.LogFormat != "json" {
+		errs = append(errs, fmt.Errorf("log_format is text or json, not %q", c.LogFormat))
+	}
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		errs = append(errs, errors.New("tls needs both cert_file and key_file"))
+	}
+	t := c.Timeouts
+	for _, d := range []Duration{t.ReadHeader, t.Read, t.Write, t.Idle, t.Shutdown} {
+		if d < 0 {
+			errs = append(errs, errors.New("timeouts can't be negative"))
+			break
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// SplitList - the non-empty items of a comma-separated list
+func SplitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func env(vars map[string]string) func(string) string {
+	return func(name string) string { return vars[name] }
+}
+
+func TestLoad_Layers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.json")
+	os.WriteFile(path, []byte(`{
+		"addr": ":9000",
+		"static_dir": "/srv/www",
+		"profile": "boundary",
+		"max_body_mb": 8,
+		"timeouts": {"write": "10m", "idle": 30}
+	}`), 0o644)
+
+	// file over defaults, env over the file, flags over env
+	c, err := Load([]string{"-workers", "3", "-max-body", "16"}, env(map[string]string{
+		"CONFIG_FILE":  path,
+		"MAX_BODY_MB":  "12",
+		"PROFILE":      "topo",
+		"CORS_ORIGINS": "https://a.example, https://b.example",
+		"READ_TIMEOUT": "45s",
+	}), io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Addr != ":9000" || c.StaticDir != "/srv/www" || c.DataDir != "./data" {
+		t.Errorf("Expected the file's addr and static dir and the default data dir, got %+v", c)
+	}
+	if c.Profile != "topo" || c.MaxBodyMB != 16 || c.Workers != 3 || len(c.CORSOrigins) != 2 {
+		t.Errorf("Expected env over the file and flags over env, got %+v", c)
+	}
+	at := c.Timeouts.API()
+	if at.Write != 10*time.Minute || at.Idle != 30*time.Second || at.Read != 45*time.Second || at.Shutdown != 30*time.Second {
+		t.Errorf("Unexpected timeouts %+v", at)
+	}
+
+	// -config beats CONFIG_FILE, ADDR beats PORT, -port beats both
+	c, err = Load([]string{"-config", path}, env(map[string]string{"CONFIG_FILE": "missing.json", "PORT": "7000"}), io.Discard)
+	if err != nil || c.Addr != ":7000" {
+		t.Errorf("Expected PORT over the file, got %q %v", c.Addr, err)
+	}
+	c, _ = Load(nil, env(map[string]string{"PORT": "7000", "ADDR": "127.0.0.1:7001"}), io.Discard)
+	if c.Addr != "127.0.0.1:7001" {
+		t.Errorf("Expected ADDR over PORT, got %q", c.Addr)
+	}
+	c, _ = Load([]string{"-port", "7002"}, env(map[string]string{"ADDR": "127.0.0.1:7001"}), io.Discard)
+	if c.Addr != ":7002" {
+		t.Errorf("Expected -port over ADDR, got %q", c.Addr)
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	dir := t.TempDir()
+	typo := filepath.Join(dir, "typo.json")
+	os.WriteFile(typo, []byte(`{"adr": ":9000"}`), 0o644)
+
+	for name, c := range map[string]struct {
+		args []string
+		env  map[string]string
+		want string
+	}{
+		"unknown setting": {[]string{"-config", typo}, nil, "unknown field"},
+		"missing file":    {nil, map[string]string{"CONFIG_FILE": filepath.Join(dir, "none.json")}, "no such file"},
+		"bad env":         {nil, map[string]string{"WRITE_TIMEOUT": "soon"}, "WRITE_TIMEOUT"},
+		"bad flag":        {[]string{"-workers", "many"}, nil, "invalid value"},
+		"half of tls":     {[]string{"-tls-cert", "cert.pem"}, nil, "both cert_file and key_file"},
+		"log format":      {nil, map[string]string{"LOG_FORMAT": "xml"}, "text or json"},
+	} {
+		if _, err := Load(c.args, env(c.env), io.Discard); err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: expected an error with %q, got %v", name, c.want, err)
+		}
+	}
+}
@@ -0,0 +1,363 @@
+package domain
+
+// stream.go - the built-in checks over a PointSource, for datasets too big
+// to hold in memory. Same issues, in the same order, as the in-memory
+// versions; just read in passes, with duplicates found tile by tile.
+
+import (
+	"bufio"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/survey-validator/models"
+)
+
+// tiling for DetectDuplicatesTiled. tilePoints is a var so tests can
+// force tiling on small datasets.
+var tilePoints = 100000 // roughly how many points go in one tile
+
+const maxTiles = 256 // files open at once while tiling
+
+// each - src.Each, giving up when ctx is done
+func each(ctx context.Context, src models.PointSource, fn func(i int, p *models.SurveyPoint) error) error {
+	return src.Each(func(i int, p *models.SurveyPoint) error {
+		if i%4096 == 0 && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fn(i, p)
+	})
+}
+
+// SummarizeSource - CalculateSummaryStatistics in one pass, plus how many
+// points there are of each survey type (unknown ones included)
+func SummarizeSource(ctx context.Context, src models.PointSource) (models.SummaryStatistics, map[models.SurveyType]int, error) {
+	stats := models.SummaryStatistics{}
+	types := make(map[models.SurveyType]int)
+	var sumE, sumN float64
+
+	err := each(ctx, src, func(i int, p *models.SurveyPoint) error {
+		types[p.SurveyType]++
+		if p.HasHeight() {
+			stats.PointsWithHeight++
+		}
+		sumE += p.Easting
+		sumN += p.Northing
+		b := &stats.BoundingBox
+		if i == 0 {
+			*b = models.BBox{MinEasting: p.Easting, MaxEasting: p.Easting, MinNorthing: p.Northing, MaxNorthing: p.Northing}
+		}
+		b.MinEasting = math.Min(b.MinEasting, p.Easting)
+		b.MaxEasting = math.Max(b.MaxEasting, p.Easting)
+		b.MinNorthing = math.Min(b.MinNorthing, p.Northing)
+		b.MaxNorthing = math.Max(b.MaxNorthing, p.Northing)
+		stats.TotalPoints++
+		return nil
+	})
+	if err != nil {
+		return stats, nil, err
+	}
+
+	stats.TraversePoints = types[models.SurveyTypeTraverse]
+	stats.ControlPoints = types[models.SurveyTypeControl]
+	stats.DetailPoints = types[models.SurveyTypeDetail]
+	if n := float64(stats.TotalPoints); n > 0 {
+		stats.CentroidEasting, stats.CentroidNorthing = sumE/n, sumN/n
+	}
+	return stats, types, nil
+}
+
+// ValidateInputStream - ValidateInput over a source
+func ValidateInputStream(ctx context.Context, src models.PointSource) ([]models.ValidationIssue, error) {
+	if src.Len() == 0 {
+		return ValidateInput(&models.SurveyData{}), nil
+	}
+	var issues []models.ValidationIssue
+	err := each(ctx, src, func(i int, p *models.SurveyPoint) error {
+		issues = append(issues, pointInputIssues(p)...)
+		return nil
+	})
+	return issues, err
+}
+
+// DetectOutliersStream - DetectOutliers in three passes: centroid, spread,
+// then the points outside it
+func DetectOutliersStream(ctx context.Context, src models.PointSource) ([]models.ValidationIssue, error) {
+	n := src.Len()
+	if n < 3 {
+		return nil, nil
+	}
+
+	var sumE, sumN float64
+	err := each(ctx, src, func(i int, p *models.SurveyPoint) error {
+		sumE += p.Easting
+		sumN += p.Northing
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	cE, cN := sumE/float64(n), sumN/float64(n)
+
+	var sum float64
+	err = each(ctx, src, func(i int, p *models.SurveyPoint) error {
+		dE, dN := p.Easting-cE, p.Northing-cN
+		sum += dE*dE + dN*dN
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	stdDev := math.Sqrt(sum / float64(n))
+	if stdDev == 0 {
+		return nil, nil
+	}
+
+	threshold := OutlierThreshold * stdDev
+	centroid := &models.SurveyPoint{Easting: cE, Northing: cN}
+	var issues []models.ValidationIssue
+	err = each(ctx, src, func(i int, p *models.SurveyPoint) error {
+		if dist := Distance(centroid, p); dist > threshold {
+			issues = append(issues, outlierIssue(p.PointID, dist, threshold))
+		}
+		return nil
+	})
+	return issues, err
+}
+
+// TraverseData - just the traverse points, in order. Traverses are short
+// enough to hold even when the rest of the job isn't.
+func TraverseData(ctx context.Context, src models.PointSource) (*models.SurveyData, error) {
+	data := src.Meta()
+	err := each(ctx, src, func(i int, p *models.SurveyPoint) error {
+		if p.SurveyType == models.SurveyTypeTraverse {
+			data.Points = append(data.Points, *p)
+		}
+		return nil
+	})
+	return &data, err
+}
+
+// tilePoint - what duplicate detection needs of a point
+type tilePoint struct {
+	I    int
+	ID   string
+	E, N float64
+	Home bool // false for copies of points near the edge of a neighbour
+}
+
+// DetectDuplicatesTiled - DetectDuplicates without the O(n²) and without
+// holding every point. The extent is cut into tiles of about 100,000
+// points, spilled to temp files, and each tile is searched on a 1cm grid.
+// Points within 1cm of a tile edge are copied into the neighbour too, and
+// a pair is reported by the home tile of its first point.
+func DetectDuplicatesTiled(ctx context.Context, src models.PointSource) ([]models.ValidationIssue, error) {
+	n := src.Len()
+	if n < 2 {
+		return nil, nil
+	}
+
+	var minE, maxE, minN, maxN float64
+	err := each(ctx, src, func(i int, p *models.SurveyPoint) error {
+		if i == 0 {
+			minE, maxE, minN, maxN = p.Easting, p.Easting, p.Northing, p.Northing
+		}
+		minE, maxE = math.Min(minE, p.Easting), math.Max(maxE, p.Easting)
+		minN, maxN = math.Min(minN, p.Northing), math.Max(maxN, p.Northing)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// tile side so an even spread puts about tilePoints in each
+	tiles := int(math.Min(math.Ceil(float64(n)/float64(tilePoints)), maxTiles))
+	side := math.Max(math.Sqrt((maxE-minE)*(maxN-minN)/float64(tiles)), 1)
+	cols := int(math.Min(math.Floor((maxE-minE)/side)+1, maxTiles))
+	rows := int(math.Min(math.Floor((maxN-minN)/side)+1, float64(maxTiles/cols)))
+	if rows < 1 {
+		rows = 1
+	}
+	cell := func(v, min float64, count int) int {
+		c := int(math.Floor((v - min) / side))
+		if c < 0 {
+			return 0
+		}
+		if c >= count {
+			return count - 1
+		}
+		return c
+	}
+
+	var pairs []pairHit
+	if cols*rows == 1 {
+		var pts []tilePoint
+		err = each(ctx, src, func(i int, p *models.SurveyPoint) error {
+			pts = append(pts, tilePoint{I: i, ID: p.PointID, E: p.Easting, N: p.Northing, Home: true})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		pairs = findPairs(pts)
+	} else {
+		pairs, err = tiledPairs(ctx, src, cols, rows, func(e, n float64) (int, int) {
+			return cell(e, minE, cols), cell(n, minN, rows)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].a.I != pairs[j].a.I {
+			return pairs[i].a.I < pairs[j].a.I
+		}
+		return pairs[i].b.I < pairs[j].b.I
+	})
+	issues := make([]models.ValidationIssue, 0, len(pairs))
+	for _, pr := range pairs {
+		issue, _ := duplicateIssue(pr.a.ID, pr.b.ID, pr.dist)
+		issues = append(issues, issue)
+	}
+	return issues, nil
+}
+
+func tiledPairs(ctx context.Context, src models.PointSource, cols, rows int, tileOf func(e, n float64) (int, int)) ([]pairHit, error) {
+	dir, err := os.MkdirTemp("", "survey-tiles-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	type tileFile struct {
+		f   *os.File
+		w   *bufio.Writer
+		enc *gob.Encoder
+	}
+	files := make([]*tileFile, cols*rows)
+	defer func() {
+		for _, t := range files {
+			if t != nil {
+				t.f.Close()
+			}
+		}
+	}()
+	write := func(col, row int, tp tilePoint) error {
+		idx := row*cols + col
+		t := files[idx]
+		if t == nil {
+			f, err := os.Create(filepath.Join(dir, fmt.Sprintf("%d.tile", idx)))
+			if err != nil {
+				return err
+			}
+			w := bufio.NewWriter(f)
+			t = &tileFile{f: f, w: w, enc: gob.NewEncoder(w)}
+			files[idx] = t
+		}
+		return t.enc.Encode(tp)
+	}
+
+	err = each(ctx, src, func(i int, p *models.SurveyPoint) error {
+		tp := tilePoint{I: i, ID: p.PointID, E: p.Easting, N: p.Northing, Home: true}
+		col, row := tileOf(p.Easting, p.Northing)
+		if err := write(col, row, tp); err != nil {
+			return err
+		}
+		// copies for every other tile within 1cm
+		tp.Home = false
+		seen := map[[2]int]bool{{col, row}: true}
+		for _, dE := range []float64{-NearDuplicateThreshold, 0, NearDuplicateThreshold} {
+			for _, dN := range []float64{-NearDuplicateThreshold, 0, NearDuplicateThreshold} {
+				c, r := tileOf(p.Easting+dE, p.Northing+dN)
+				if seen[[2]int{c, r}] {
+					continue
+				}
+				seen[[2]int{c, r}] = true
+				if err := write(c, r, tp); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var pairs []pairHit
+	for _, t := range files {
+		if t == nil {
+			continue
+		}
+		if err := t.w.Flush(); err != nil {
+			return nil, err
+		}
+		if _, err := t.f.Seek(0, 0); err != nil {
+			return nil, err
+		}
+		var pts []tilePoint
+		dec := gob.NewDecoder(bufio.NewReader(t.f))
+		for {
+			var tp tilePoint
+			err := dec.Decode(&tp)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("reading tile: %w", err)
+			}
+			pts = append(pts, tp)
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, findPairs(pts)...)
+	}
+	return pairs, nil
+}
+
+// pairHit - two points closer than NearDuplicateThreshold, a first
+type pairHit struct {
+	a, b tilePoint
+	dist float64
+}
+
+// findPairs - close pairs within one tile, on a grid of 1cm cells. A pair
+// belongs to the tile that is home to its first point.
+func findPairs(pts []tilePoint) []pairHit {
+	type key struct{ e, n int64 }
+	grid := make(map[key][]int, len(pts))
+	keyOf := func(tp tilePoint) key {
+		return key{int64(math.Floor(tp.E / NearDuplicateThreshold)), int64(math.Floor(tp.N / NearDuplicateThreshold))}
+	}
+	for i, tp := range pts {
+		k := keyOf(tp)
+		grid[k] = append(grid[k], i)
+	}
+
+	var out []pairHit
+	for _, a := range pts {
+		k := keyOf(a)
+		for de := int64(-1); de <= 1; de++ {
+			for dn := int64(-1); dn <= 1; dn++ {
+				for _, j := range grid[key{k.e + de, k.n + dn}] {
+					b := pts[j]
+					if b.I <= a.I || !a.Home {
+						continue
+					}
+					dE, dN := b.E-a.E, b.N-a.N
+					if dist := math.Sqrt(dE*dE + dN*dN); dist < NearDuplicateThreshold {
+						out = append(out, pairHit{a, b, dist})
+					}
+				}
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,124 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/survey-validator/models"
+)
+
+// streamTestData - a scatter of points with duplicates and near
+// duplicates dropped in
+func streamTestData(n int) *models.SurveyData {
+	rng := rand.New(rand.NewSource(7))
+	data := &models.SurveyData{ProjectID: "BIG"}
+	for i := 0; i < n; i++ {
+		p := models.SurveyPoint{
+			PointID:    fmt.Sprintf("P%d", i),
+			Easting:    500000 + rng.Float64()*400,
+			Northing:   6000000 + rng.Float64()*400,
+			SurveyType: models.SurveyTypeDetail,
+		}
+		if i%7 == 0 {
+			p.SurveyType = models.SurveyTypeTraverse
+		}
+		data.Points = append(data.Points, p)
+		switch i % 50 {
+		case 3:
+			q := p
+			q.PointID += "a"
+			q.Easting += 0.0004
+			data.Points = append(data.Points, q)
+		case 9:
+			q := p
+			q.PointID += "b"
+			q.Northing += 0.006
+			data.Points = append(data.Points, q)
+		}
+	}
+	return data
+}
+
+// withEdgePairs - pin the extent to 400m square and put a near-duplicate
+// pair across each tile edge (and corner) the tiling will use
+func withEdgePairs(data *models.SurveyData) {
+	data.Points = append(data.Points,
+		models.SurveyPoint{PointID: "SW", Easting: 500000 - 1, Northing: 6000000 - 1},
+		models.SurveyPoint{PointID: "NE", Easting: 500400 + 1, Northing: 6000400 + 1})
+	n := len(data.Points) + 2*9
+	tiles := math.Ceil(float64(n) / float64(tilePoints))
+	side := math.Sqrt(402 * 402 / tiles)
+	for k := 1; float64(k)*side < 402; k++ {
+		e := 500000 - 1 + float64(k)*side
+		n := 6000000 - 1 + float64(k)*side
+		data.Points = append(data.Points,
+			models.SurveyPoint{PointID: fmt.Sprintf("L%d", k), Easting: e - 0.003, Northing: n - 0.002},
+			models.SurveyPoint{PointID: fmt.Sprintf("R%d", k), Easting: e + 0.003, Northing: n + 0.002},
+			models.SurveyPoint{PointID: fmt.Sprintf("S%d", k), Easting: 500100.5, Northing: n - 0.004},
+			models.SurveyPoint{PointID: fmt.Sprintf("T%d", k), Easting: 500100.5, Northing: n + 0.004})
+	}
+}
+
+func withOutlier(data *models.SurveyData) {
+	data.Points = append(data.Points, models.SurveyPoint{PointID: "FAR", Easting: 510000, Northing: 6010000})
+}
+
+func TestStreamChecks_MatchInMemory(t *testing.T) {
+	defer func(n int) { tilePoints = n }(tilePoints)
+	ctx := context.Background()
+	for _, tp := range []int{100000, 300} { // one tile, then about ten
+		tilePoints = tp
+		data := streamTestData(3000)
+		withEdgePairs(data)
+
+		got, err := DetectDuplicatesTiled(ctx, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := DetectDuplicates(data)
+		if len(want) < 100 {
+			t.Fatalf("test data should have plenty of duplicates, got %d", len(want))
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("tiles of %d: tiled duplicates differ, %d vs %d in memory", tp, len(got), len(want))
+		}
+	}
+
+	data := streamTestData(3000)
+	withOutlier(data)
+
+	if got, _ := DetectOutliersStream(ctx, data); !reflect.DeepEqual(got, DetectOutliers(data)) {
+		t.Errorf("streamed outliers differ: %v", got)
+	}
+	if got, _ := ValidateInputStream(ctx, data); !reflect.DeepEqual(got, ValidateInput(data)) {
+		t.Errorf("streamed input issues differ")
+	}
+
+	stats, types, err := SummarizeSource(ctx, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := CalculateSummaryStatistics(data); stats != want {
+		t.Errorf("summary differs:\n got %+v\nwant %+v", stats, want)
+	}
+	if types[models.SurveyTypeTraverse] != stats.TraversePoints || types[""] != 1 {
+		t.Errorf("unexpected type counts %v", types)
+	}
+
+	trav, _ := TraverseData(ctx, data)
+	if !reflect.DeepEqual(CheckTraverseClosure(trav), CheckTraverseClosure(data)) {
+		t.Error("closure on the traverse points alone should match")
+	}
+}
+
+func TestStreamChecks_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := DetectDuplicatesTiled(ctx, streamTestData(10)); err == nil {
+		t.Error("Expected a cancelled context to stop the check")
+	}
+}
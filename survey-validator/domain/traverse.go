@@ -40,10 +40,12 @@ func CheckDistanceAndBearing(data *models.SurveyData) []models.ValidationIssue {
 				p1.PointID, p2.PointID, dist)
 			issues = append(issues, models.ValidationIssue{
 				CheckName:   "distance_bearing_check",
+				Kind:        "short_leg",
 				Severity:    models.SeverityWarning,
 				PointIDs:    []string{p1.PointID, p2.PointID},
 				Description: msg,
 				Details:     map[string]interface{}{"distance": dist},
+				Magnitude:   timesOver(MinTraverseDistance, dist),
 			})
 		}
 
@@ -54,6 +56,7 @@ func CheckDistanceAndBearing(data *models.SurveyData) []models.ValidationIssue {
 				msg := fmt.Sprintf("Large bearing change at %s: %.1f°", p1.PointID, change)
 				issues = append(issues, models.ValidationIssue{
 					CheckName:   "distance_bearing_check",
+					Kind:        "bearing_change",
 					Severity:    models.SeverityWarning,
 					PointIDs:    []string{p1.PointID, p2.PointID},
 					Description: msg,
@@ -66,6 +69,7 @@ func CheckDistanceAndBearing(data *models.SurveyData) []models.ValidationIssue {
 					msg := fmt.Sprintf("Unusual distance ratio at %s: %.1f", p1.PointID, ratio)
 					issues = append(issues, models.ValidationIssue{
 						CheckName:   "distance_bearing_check",
+						Kind:        "distance_ratio",
 						Severity:    models.SeverityInfo,
 						PointIDs:    []string{p1.PointID, p2.PointID},
 						Description: msg,
@@ -123,32 +127,45 @@ func CheckTraverseClosure(data *models.SurveyData) []models.ValidationIssue {
 		precision = math.MaxFloat64
 	}
 
-	var quality string
+	var quality, kind string
 	var severity models.IssueSeverity
 
+	// kind follows the band, so accepting a poor closure doesn't also
+	// accept an unacceptable one next time
 	switch {
 	case precision >= GoodPrecision:
 		quality = "Good (better than 1:10000)"
+		kind = "closure_good"
 		severity = models.SeverityInfo
 	case precision >= AcceptablePrecision:
 		quality = "Acceptable (1:5000 to 1:10000)"
+		kind = "closure_acceptable"
 		severity = models.SeverityInfo
 	case precision >= 1000:
 		quality = "Poor (1:1000 to 1:5000)"
+		kind = "closure_poor"
 		severity = models.SeverityWarning
 	default:
 		quality = "Unacceptable (worse than 1:1000)"
+		kind = "closure_unacceptable"
 		severity = models.SeverityError
 	}
 
 	msg := fmt.Sprintf("Traverse closure: %.4fm misclosure, 1:%.0f precision (%s)",
 		linMisc, precision, quality)
 
+	var magnitude float64
+	if severity != models.SeverityInfo {
+		magnitude = timesOver(AcceptablePrecision, precision)
+	}
+
 	issues = append(issues, models.ValidationIssue{
 		CheckName:   "traverse_closure",
+		Kind:        kind,
 		Severity:    severity,
 		PointIDs:    []string{first.PointID, last.PointID},
 		Description: msg,
+		Magnitude:   magnitude,
 		Details: models.TraverseClosureDetails{
 			MisclosureEasting:  miscE,
 			MisclosureNorthing: miscN,
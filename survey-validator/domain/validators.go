@@ -20,6 +20,15 @@ const (
 	AcceptablePrecision    = 5000  // 1:5000 is ok
 )
 
+// timesOver - how many times a is of b, for issue magnitudes. Capped at
+// 10 so a zero distance doesn't blow up.
+func timesOver(a, b float64) float64 {
+	if b <= 0 || a/b > 10 {
+		return 10
+	}
+	return a / b
+}
+
 // ValidateInput - basic sanity checks before we do anything else
 func ValidateInput(data *models.SurveyData) []models.ValidationIssue {
 	var issues []models.ValidationIssue
@@ -27,46 +36,58 @@ func ValidateInput(data *models.SurveyData) []models.ValidationIssue {
 	if len(data.Points) == 0 {
 		issues = append(issues, models.ValidationIssue{
 			CheckName:   "input_validation",
+			Kind:        "no_points",
 			Severity:    models.SeverityError,
 			Description: "No survey points provided",
 		})
 		return issues
 	}
 
-	for _, p := range data.Points {
-		if p.PointID == "" {
-			issues = append(issues, models.ValidationIssue{
-				CheckName:   "input_validation",
-				Severity:    models.SeverityError,
-				Description: "Point found with empty Point ID",
-			})
-		}
+	for i := range data.Points {
+		issues = append(issues, pointInputIssues(&data.Points[i])...)
+	}
+	return issues
+}
 
-		if p.Easting == 0 && p.Northing == 0 {
-			msg := fmt.Sprintf("Point %s has zero coordinates", p.PointID)
+// pointInputIssues - what ValidateInput finds wrong with one point
+func pointInputIssues(p *models.SurveyPoint) []models.ValidationIssue {
+	var issues []models.ValidationIssue
+
+	if p.PointID == "" {
+		issues = append(issues, models.ValidationIssue{
+			CheckName:   "input_validation",
+			Kind:        "empty_id",
+			Severity:    models.SeverityError,
+			Description: "Point found with empty Point ID",
+		})
+	}
+
+	if p.Easting == 0 && p.Northing == 0 {
+		msg := fmt.Sprintf("Point %s has zero coordinates", p.PointID)
+		issues = append(issues, models.ValidationIssue{
+			CheckName:   "input_validation",
+			Kind:        "zero_coordinates",
+			Severity:    models.SeverityWarning,
+			PointIDs:    []string{p.PointID},
+			Description: msg,
+		})
+	}
+
+	// check for valid survey type (if one was given)
+	switch p.SurveyType {
+	case models.SurveyTypeTraverse, models.SurveyTypeControl, models.SurveyTypeDetail:
+		// valid type
+	default:
+		if p.SurveyType != "" {
+			msg := fmt.Sprintf("Point %s has unknown type: %s", p.PointID, p.SurveyType)
 			issues = append(issues, models.ValidationIssue{
 				CheckName:   "input_validation",
+				Kind:        "unknown_type",
 				Severity:    models.SeverityWarning,
 				PointIDs:    []string{p.PointID},
 				Description: msg,
 			})
 		}
-
-		// check for valid survey type (if one was given)
-		switch p.SurveyType {
-		case models.SurveyTypeTraverse, models.SurveyTypeControl, models.SurveyTypeDetail:
-			// valid type
-		default:
-			if p.SurveyType != "" {
-				msg := fmt.Sprintf("Point %s has unknown type: %s", p.PointID, p.SurveyType)
-				issues = append(issues, models.ValidationIssue{
-					CheckName:   "input_validation",
-					Severity:    models.SeverityWarning,
-					PointIDs:    []string{p.PointID},
-					Description: msg,
-				})
-			}
-		}
 	}
 	return issues
 }
@@ -79,33 +100,43 @@ func DetectDuplicates(data *models.SurveyData) []models.ValidationIssue {
 	for i := 0; i < len(points); i++ {
 		for j := i + 1; j < len(points); j++ {
 			dist := Distance(&points[i], &points[j])
-
-			if dist < DuplicateThreshold {
-				msg := fmt.Sprintf("Duplicate points: %s and %s (%.4fm apart)",
-					points[i].PointID, points[j].PointID, dist)
-				issues = append(issues, models.ValidationIssue{
-					CheckName:   "duplicate_detection",
-					Severity:    models.SeverityError,
-					PointIDs:    []string{points[i].PointID, points[j].PointID},
-					Description: msg,
-					Details:     map[string]interface{}{"distance": dist},
-				})
-			} else if dist < NearDuplicateThreshold {
-				msg := fmt.Sprintf("Near-duplicate points: %s and %s (%.4fm apart)",
-					points[i].PointID, points[j].PointID, dist)
-				issues = append(issues, models.ValidationIssue{
-					CheckName:   "duplicate_detection",
-					Severity:    models.SeverityWarning,
-					PointIDs:    []string{points[i].PointID, points[j].PointID},
-					Description: msg,
-					Details:     map[string]interface{}{"distance": dist},
-				})
+			if issue, ok := duplicateIssue(points[i].PointID, points[j].PointID, dist); ok {
+				issues = append(issues, issue)
 			}
 		}
 	}
 	return issues
 }
 
+// duplicateIssue - the issue for two points dist apart, if they're close
+// enough to be one
+func duplicateIssue(a, b string, dist float64) (models.ValidationIssue, bool) {
+	if dist < DuplicateThreshold {
+		msg := fmt.Sprintf("Duplicate points: %s and %s (%.4fm apart)", a, b, dist)
+		return models.ValidationIssue{
+			CheckName:   "duplicate_detection",
+			Kind:        "duplicate",
+			Severity:    models.SeverityError,
+			PointIDs:    []string{a, b},
+			Description: msg,
+			Details:     map[string]interface{}{"distance": dist},
+		}, true
+	}
+	if dist < NearDuplicateThreshold {
+		msg := fmt.Sprintf("Near-duplicate points: %s and %s (%.4fm apart)", a, b, dist)
+		return models.ValidationIssue{
+			CheckName:   "duplicate_detection",
+			Kind:        "near_duplicate",
+			Severity:    models.SeverityWarning,
+			PointIDs:    []string{a, b},
+			Description: msg,
+			Details:     map[string]interface{}{"distance": dist},
+			Magnitude:   timesOver(NearDuplicateThreshold, dist),
+		}, true
+	}
+	return models.ValidationIssue{}, false
+}
+
 // DetectOutliers - finds points that are way off from the rest
 // uses simple std deviation approach
 func DetectOutliers(data *models.SurveyData) []models.ValidationIssue {
@@ -127,19 +158,24 @@ func DetectOutliers(data *models.SurveyData) []models.ValidationIssue {
 	for _, p := range data.Points {
 		dist := Distance(centroid, &p)
 		if dist > threshold {
-			msg := fmt.Sprintf("Point %s may be an outlier (%.1fm from centroid)",
-				p.PointID, dist)
-			issues = append(issues, models.ValidationIssue{
-				CheckName:   "outlier_detection",
-				Severity:    models.SeverityWarning,
-				PointIDs:    []string{p.PointID},
-				Description: msg,
-				Details: map[string]interface{}{
-					"distance":  dist,
-					"threshold": threshold,
-				},
-			})
+			issues = append(issues, outlierIssue(p.PointID, dist, threshold))
 		}
 	}
 	return issues
 }
+
+func outlierIssue(id string, dist, threshold float64) models.ValidationIssue {
+	msg := fmt.Sprintf("Point %s may be an outlier (%.1fm from centroid)", id, dist)
+	return models.ValidationIssue{
+		CheckName:   "outlier_detection",
+		Kind:        "outlier",
+		Severity:    models.SeverityWarning,
+		PointIDs:    []string{id},
+		Description: msg,
+		Details: map[string]interface{}{
+			"distance":  dist,
+			"threshold": threshold,
+		},
+		Magnitude: timesOver(dist, threshold),
+	}
+}
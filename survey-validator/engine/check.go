@@ -0,0 +1,176 @@
+package engine
+
+// check.go - the check interface plugins implement, and per-check config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/survey-validator/models"
+)
+
+// Check - a validation check the engine can run. Built-in checks, Go
+// plugins registered with Add, and rules loaded from a file all look like this.
+type Check interface {
+	Info() CheckInfo
+	// Run gets the config already merged with the schema defaults. It
+	// should give up when ctx is done, the engine stops waiting anyway.
+	Run(ctx context.Context, data *models.SurveyData, cfg Config) []models.ValidationIssue
+}
+
+// StreamCheck - a Check that can also run over a PointSource without
+// holding the whole dataset. Checks that can't are given the dataset in
+// memory when it is small enough (see Engine.MaxInMemoryPoints).
+type StreamCheck interface {
+	Check
+	RunStream(ctx context.Context, src models.PointSource, cfg Config) ([]models.ValidationIssue, error)
+}
+
+// CheckInfo - what a check is and when it runs
+type CheckInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Version     string `json:"version"`
+
+	// Category - integrity, geometry, closure or custom (the default),
+	// weights the check's issues in the confidence score
+	Category string `json:"category,omitempty"`
+
+	// AppliesTo - the check only runs when the data has points of one of
+	// these types (a detail-only job skips traverse checks). Empty means any.
+	AppliesTo []models.SurveyType `json:"applies_to,omitempty"`
+
+	// Config - the settings a request may pass to this check
+	Config map[string]ParamSpec `json:"config,omitempty"`
+
+	DependsOn []string `json:"depends_on,omitempty"`
+	Priority  int      `json:"priority"`
+}
+
+// ParamSpec - one config setting
+type ParamSpec struct {
+	Type        ParamType   `json:"type"`
+	Description string      `json:"description,omitempty"`
+	Default     interface{} `json:"default,omitempty"`
+}
+
+// ParamType - JSON type of a config setting
+type ParamType string
+
+const (
+	ParamNumber ParamType = "number"
+	ParamString ParamType = "string"
+	ParamBool   ParamType = "bool"
+)
+
+// Config - settings for one check, keyed by name
+type Config map[string]interface{}
+
+// Number - a number setting, 0 if unset
+func (c Config) Number(key string) float64 {
+	v, _ := c[key].(float64)
+	return v
+}
+
+// String - a string setting, "" if unset
+func (c Config) String(key string) string {
+	v, _ := c[key].(string)
+	return v
+}
+
+// Bool - a bool setting, false if unset
+func (c Config) Bool(key string) bool {
+	v, _ := c[key].(bool)
+	return v
+}
+
+// ErrInvalidOptions - the request named checks or settings that don't exist
+var ErrInvalidOptions = errors.New("invalid validation options")
+
+// resolveConfig - defaults from the schema, overridden by what was passed.
+// Unknown keys and wrong types are errors so typos don't pass silently.
+func resolveConfig(info CheckInfo, given Config) (Config, error) {
+	cfg := make(Config, len(info.Config))
+	for key, spec := range info.Config {
+		if spec.Default != nil {
+			cfg[key], _ = coerce(spec.Type, spec.Default)
+		}
+	}
+
+	keys := make([]string, 0, len(given))
+	for key := range given {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		spec, ok := info.Config[key]
+		if !ok {
+			return nil, fmt.Errorf("%w: check %s has no setting %q", ErrInvalidOptions, info.Name, key)
+		}
+		v, ok := coerce(spec.Type, given[key])
+		if !ok {
+			return nil, fmt.Errorf("%w: %s.%s must be a %s", ErrInvalidOptions, info.Name, key, spec.Type)
+		}
+		cfg[key] = v
+	}
+	return cfg, nil
+}
+
+// coerce - v as the setting's type (ints become float64), false if it isn't one
+func coerce(t ParamType, v interface{}) (interface{}, bool) {
+	switch t {
+	case ParamNumber:
+		switch n := v.(type) {
+		case float64:
+			return n, true
+		case int:
+			return float64(n), true
+		}
+	case ParamString:
+		s, ok := v.(string)
+		return s, ok
+	case ParamBool:
+		b, ok := v.(bool)
+		return b, ok
+	}
+	return nil, false
+}
+
+// funcCheck - adapts a plain ValidationCheck to the Check interface
+type funcCheck struct {
+	info CheckInfo
+	fn   ValidationCheck
+}
+
+func (f funcCheck) Info() CheckInfo { return f.info }
+
+func (f funcCheck) Run(ctx context.Context, data *models.SurveyData, cfg Config) []models.ValidationIssue {
+	return f.fn(data)
+}
+
+// funcStreamCheck - a funcCheck with a streaming version
+type funcStreamCheck struct {
+	funcCheck
+	stream func(ctx context.Context, src models.PointSource) ([]models.ValidationIssue, error)
+}
+
+func (f funcStreamCheck) RunStream(ctx context.Context, src models.PointSource, cfg Config) ([]models.ValidationIssue, error) {
+	return f.stream(ctx, src)
+}
+
+// appliesTo - does the data have any point of the given types, going by
+// the count of points per type
+func appliesTo(types []models.SurveyType, counts map[models.SurveyType]int) bool {
+	if len(types) == 0 {
+		return true
+	}
+	for _, t := range types {
+		if counts[t] > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -1,8 +1,16 @@
 package engine
 
-// engine.go - runs all validation checks concurrently
+// engine.go - runs the validation checks as a dependency graph, in parallel
+// where they don't depend on each other
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -12,32 +20,393 @@ import (
 
 type ValidationCheck func(data *models.SurveyData) []models.ValidationIssue
 
+// default deadlines, generous enough for a few hundred thousand points
+const (
+	DefaultCheckTimeout = 10 * time.Second
+	DefaultTimeout      = 30 * time.Second
+)
+
+// DefaultMaxInMemoryPoints - see Engine.MaxInMemoryPoints
+const DefaultMaxInMemoryPoints = 500000
+
+// DefaultPriority - where RegisterCheck puts a check among its peers
+const DefaultPriority = 100
+
+// CheckSpec - a plain function check and where it sits in the run. For
+// checks with settings implement Check and use Add.
+type CheckSpec struct {
+	Name        string
+	Description string
+	Check       ValidationCheck
+	Category    string // see CheckInfo.Category
+
+	// Stream - the same check over a PointSource, for ValidateSource.
+	// Optional; see StreamCheck.
+	Stream func(ctx context.Context, src models.PointSource) ([]models.ValidationIssue, error)
+
+	AppliesTo []models.SurveyType
+
+	// DependsOn - checks that must finish before this one starts. They
+	// have to be registered first. A dependency that is disabled for a
+	// run is simply not waited for.
+	DependsOn []string
+
+	// Priority - orders checks that don't depend on each other in the
+	// report, lower first. Ties go by name.
+	Priority int
+}
+
+// node - a registered check. Built-in steps that need more than the data
+// (the Bowditch adjustment) set run and applies directly.
+type node struct {
+	CheckInfo
+	run     func(ctx context.Context, rs *runState, cfg Config) ([]models.ValidationIssue, error)
+	applies func(types map[models.SurveyType]int) bool
+}
+
+// runState - what a single validation shares between its checks. data is
+// nil when validating a PointSource; src is always set.
+type runState struct {
+	data     *models.SurveyData
+	src      models.PointSource
+	types    map[models.SurveyType]int // points per survey type
+	summary  models.SummaryStatistics
+	traverse *models.TraverseInput
+	adjusted chan *models.TraverseResult
+
+	loadOnce sync.Once
+	loaded   *models.SurveyData
+	loadErr  error
+}
+
 type Engine struct {
-	checks map[string]ValidationCheck
+	nodes map[string]*node
+	order []string // topological, priority then name among peers
+
+	// CheckTimeout bounds each check, Timeout the whole validation.
+	// Zero means no limit beyond the caller's context.
+	CheckTimeout time.Duration
+	Timeout      time.Duration
+
+	// MaxInMemoryPoints - with ValidateSource, checks that can't stream
+	// are given the whole dataset in memory up to this many points, and
+	// skipped above it
+	MaxInMemoryPoints int
+
+	// OnCheck is called with how long each check took, and OnReport with
+	// every report, finished or not, e.g. for metrics. Nil for none. Both
+	// are called from concurrent validations.
+	OnCheck  func(name string, took time.Duration)
+	OnReport func(report *models.ValidationReport)
 }
 
 func NewEngine() *Engine {
 	e := &Engine{
-		checks: make(map[string]ValidationCheck),
+		nodes:             make(map[string]*node),
+		CheckTimeout:      DefaultCheckTimeout,
+		Timeout:           DefaultTimeout,
+		MaxInMemoryPoints: DefaultMaxInMemoryPoints,
 	}
 
 	// add all the checks we want to run
-	e.RegisterCheck("input_validation", domain.ValidateInput)
-	e.RegisterCheck("duplicate_detection", domain.DetectDuplicates)
-	e.RegisterCheck("distance_bearing_check", domain.CheckDistanceAndBearing)
-	e.RegisterCheck("outlier_detection", domain.DetectOutliers)
-	e.RegisterCheck("traverse_closure", domain.CheckTraverseClosure)
+	e.mustRegister(CheckSpec{
+		Name:        "input_validation",
+		Description: "Missing points or IDs, zero coordinates and unknown survey types",
+		Check:       domain.ValidateInput,
+		Stream:      domain.ValidateInputStream,
+		Category:    models.CategoryIntegrity,
+		Priority:    0,
+	})
+	e.mustRegister(CheckSpec{
+		Name:        "duplicate_detection",
+		Description: "Points within 1mm (duplicate) or 1cm (near duplicate) of each other",
+		Check:       domain.DetectDuplicates,
+		Stream:      domain.DetectDuplicatesTiled,
+		Category:    models.CategoryIntegrity,
+		Priority:    10,
+	})
+	e.mustRegister(CheckSpec{
+		Name:        "distance_bearing_check",
+		Description: "Very short traverse legs, near u-turns and abrupt changes in leg length",
+		Check:       domain.CheckDistanceAndBearing,
+		Stream:      onTraverse(domain.CheckDistanceAndBearing),
+		Category:    models.CategoryGeometry,
+		AppliesTo:   []models.SurveyType{models.SurveyTypeTraverse},
+		Priority:    20,
+	})
+	e.mustRegister(CheckSpec{
+		Name:        "outlier_detection",
+		Description: "Points more than 3 standard deviations from the centroid",
+		Check:       domain.DetectOutliers,
+		Stream:      domain.DetectOutliersStream,
+		Category:    models.CategoryGeometry,
+		Priority:    30,
+	})
+	e.mustRegister(CheckSpec{
+		Name:        "traverse_closure",
+		Description: "Linear misclosure and relative precision of the traverse",
+		Check:       domain.CheckTraverseClosure,
+		Stream:      onTraverse(domain.CheckTraverseClosure),
+		Category:    models.CategoryClosure,
+		AppliesTo:   []models.SurveyType{models.SurveyTypeTraverse},
+		Priority:    40,
+	})
+
+	// the adjustment goes after the closure check, and only when there's a traverse
+	e.nodes["bowditch_adjustment"] = &node{
+		CheckInfo: CheckInfo{
+			Name:        "bowditch_adjustment",
+			Description: "Compass rule adjustment of the traverse (needs 3 or more stations)",
+			Version:     builtinVersion,
+			Category:    models.CategoryClosure,
+			AppliesTo:   []models.SurveyType{models.SurveyTypeTraverse},
+			DependsOn:   []string{"traverse_closure"},
+			Priority:    50,
+		},
+		run: func(ctx context.Context, rs *runState, cfg Config) ([]models.ValidationIssue, error) {
+			data := rs.data
+			if data == nil {
+				var err error
+				if data, err = domain.TraverseData(ctx, rs.src); err != nil {
+					return nil, err
+				}
+			}
+			rs.adjusted <- domain.ComputeTraverseAdjustment(data, rs.traverse)
+			return nil, nil
+		},
+		applies: func(types map[models.SurveyType]int) bool {
+			return types[models.SurveyTypeTraverse] >= 3
+		},
+	}
+	e.order, _ = e.sortChecks()
 
 	return e
 }
 
+// version reported for the checks that ship with the engine
+const builtinVersion = "1.0"
+
+// RegisterCheck - add or replace a check with no dependencies
 func (e *Engine) RegisterCheck(name string, check ValidationCheck) {
-	e.checks[name] = check
+	e.mustRegister(CheckSpec{Name: name, Check: check, Priority: DefaultPriority})
+}
+
+// Register - add or replace a plain function check
+func (e *Engine) Register(spec CheckSpec) error {
+	if spec.Check == nil {
+		return fmt.Errorf("check %s has no function", spec.Name)
+	}
+	fc := funcCheck{
+		fn: spec.Check,
+		info: CheckInfo{
+			Name:        spec.Name,
+			Description: spec.Description,
+			Version:     builtinVersion,
+			Category:    spec.Category,
+			AppliesTo:   spec.AppliesTo,
+			DependsOn:   spec.DependsOn,
+			Priority:    spec.Priority,
+		},
+	}
+	if spec.Stream != nil {
+		return e.Add(funcStreamCheck{funcCheck: fc, stream: spec.Stream})
+	}
+	return e.Add(fc)
+}
+
+// onTraverse - a traverse check as a stream check. Only the traverse
+// points are read into memory.
+func onTraverse(check ValidationCheck) func(context.Context, models.PointSource) ([]models.ValidationIssue, error) {
+	return func(ctx context.Context, src models.PointSource) ([]models.ValidationIssue, error) {
+		data, err := domain.TraverseData(ctx, src)
+		if err != nil {
+			return nil, err
+		}
+		return check(data), nil
+	}
+}
+
+// Add - add or replace a check. Its dependencies must already be registered.
+func (e *Engine) Add(check Check) error {
+	info := check.Info()
+	if info.Name == "" {
+		return fmt.Errorf("check needs a name")
+	}
+	for _, dep := range info.DependsOn {
+		if _, ok := e.nodes[dep]; !ok {
+			return fmt.Errorf("check %s depends on unknown check %s", info.Name, dep)
+		}
+	}
+	switch info.Category {
+	case "", models.CategoryIntegrity, models.CategoryGeometry, models.CategoryClosure, models.CategoryCustom:
+	default:
+		return fmt.Errorf("check %s: unknown category %q", info.Name, info.Category)
+	}
+	for key, spec := range info.Config {
+		switch spec.Type {
+		case ParamNumber, ParamString, ParamBool:
+		default:
+			return fmt.Errorf("check %s: setting %s has unknown type %q", info.Name, key, spec.Type)
+		}
+		if _, ok := coerce(spec.Type, spec.Default); spec.Default != nil && !ok {
+			return fmt.Errorf("check %s: default for %s is not a %s", info.Name, key, spec.Type)
+		}
+	}
+
+	prev, existed := e.nodes[info.Name]
+	types := info.AppliesTo
+	stream, _ := check.(StreamCheck)
+	e.nodes[info.Name] = &node{
+		CheckInfo: info,
+		run: func(ctx context.Context, rs *runState, cfg Config) ([]models.ValidationIssue, error) {
+			if rs.data != nil {
+				return check.Run(ctx, rs.data, cfg), nil
+			}
+			if stream != nil {
+				return stream.RunStream(ctx, rs.src, cfg)
+			}
+			data, err := rs.whole(e.MaxInMemoryPoints)
+			if err != nil {
+				return nil, err
+			}
+			return check.Run(ctx, data, cfg), nil
+		},
+		applies: func(counts map[models.SurveyType]int) bool { return appliesTo(types, counts) },
+	}
+
+	order, err := e.sortChecks()
+	if err != nil {
+		if existed {
+			e.nodes[info.Name] = prev
+		} else {
+			delete(e.nodes, info.Name)
+		}
+		return err
+	}
+	e.order = order
+	return nil
+}
+
+// HasCheck - is a check with this name registered
+func (e *Engine) HasCheck(name string) bool {
+	_, ok := e.nodes[name]
+	return ok
+}
+
+// Checks - everything registered, in CheckOrder
+func (e *Engine) Checks() []CheckInfo {
+	out := make([]CheckInfo, 0, len(e.order))
+	for _, name := range e.order {
+		out = append(out, e.nodes[name].CheckInfo)
+	}
+	return out
+}
+
+func (e *Engine) mustRegister(spec CheckSpec) {
+	if err := e.Register(spec); err != nil {
+		panic(err)
+	}
+}
+
+// CheckOrder - the order checks and their issues appear in reports
+func (e *Engine) CheckOrder() []string {
+	return append([]string(nil), e.order...)
+}
+
+// sortChecks - Kahn's algorithm, always taking the ready check with the
+// lowest priority (then name) so the order is the same on every run
+func (e *Engine) sortChecks() ([]string, error) {
+	indegree := make(map[string]int, len(e.nodes))
+	dependents := make(map[string][]string)
+	for name, n := range e.nodes {
+		indegree[name] = len(n.DependsOn)
+		for _, dep := range n.DependsOn {
+			dependents[dep] = append(dependents[dep], name)
+		}
+	}
+
+	var ready []string
+	for name, d := range indegree {
+		if d == 0 {
+			ready = append(ready, name)
+		}
+	}
+
+	order := make([]string, 0, len(e.nodes))
+	for len(ready) > 0 {
+		sort.Slice(ready, func(i, j int) bool {
+			a, b := e.nodes[ready[i]], e.nodes[ready[j]]
+			if a.Priority != b.Priority {
+				return a.Priority < b.Priority
+			}
+			return a.Name < b.Name
+		})
+		next := ready[0]
+		ready = ready[1:]
+		order = append(order, next)
+		for _, d := range dependents[next] {
+			indegree[d]--
+			if indegree[d] == 0 {
+				ready = append(ready, d)
+			}
+		}
+	}
+
+	if len(order) != len(e.nodes) {
+		return nil, fmt.Errorf("check dependencies form a cycle")
+	}
+	return order, nil
+}
+
+// Options - per-call settings for ValidateContext
+type Options struct {
+	Traverse *models.TraverseInput
+	Profile  *Profile // tolerance and check selection, nil for none
+
+	// Enable - run only these checks (empty runs everything registered).
+	// Disable - skip these, on top of the profile's disabled checks.
+	Enable  []string
+	Disable []string
+
+	// Config - per-check settings, see CheckInfo.Config
+	Config map[string]Config
+
+	// Severity - report every issue a check raises at this level instead,
+	// e.g. distance_bearing_check at info for a road job. Crashes and
+	// timeouts stay errors.
+	Severity map[string]models.IssueSeverity
+
+	// Suppressions - accepted issues. They are reported under Suppressed
+	// and left out of the status and score.
+	Suppressions []models.Suppression
+
+	// Timeout - replaces the engine's Timeout for this run, e.g. longer
+	// for background jobs. Zero keeps the engine's.
+	Timeout time.Duration
+
+	// Progress - called as each check finishes, one call at a time
+	Progress func(Progress)
+}
+
+// Progress - how far a validation has got
+type Progress struct {
+	Done  int    `json:"done"`
+	Total int    `json:"total"`           // checks that will run
+	Check string `json:"check,omitempty"` // the one that just finished
+}
+
+// runPlan - Options checked against the registered checks
+type runPlan struct {
+	disabled map[string]bool
+	configs  map[string]Config
+	severity map[string]models.IssueSeverity
+	suppress map[string]models.Suppression // by fingerprint
 }
 
 type checkResult struct {
 	checkName string
 	issues    []models.ValidationIssue
+	duration  time.Duration
 }
 
 // Validate - runs all checks in parallel, collects results
@@ -47,47 +416,451 @@ func (e *Engine) Validate(data *models.SurveyData) *models.ValidationReport {
 
 // ValidateWithOptions - validation with optional traverse adjustment settings
 func (e *Engine) ValidateWithOptions(data *models.SurveyData, traverseInput *models.TraverseInput) *models.ValidationReport {
+	report, _ := e.ValidateContext(context.Background(), data, Options{Traverse: traverseInput})
+	return report
+}
+
+// ValidateWithProfile - validation using a profile's tolerance and check
+// selection. A required precision in traverseInput beats the profile's.
+func (e *Engine) ValidateWithProfile(data *models.SurveyData, traverseInput *models.TraverseInput, profile Profile) *models.ValidationReport {
+	report, _ := e.ValidateContext(context.Background(), data, Options{Traverse: traverseInput, Profile: &profile})
+	return report
+}
+
+// ValidateContext - runs the checks under ctx and the engine's deadlines.
+// Each check starts as soon as its dependencies are done. A check that
+// panics or overruns CheckTimeout becomes an error issue naming it. If ctx
+// ends first the checks still running are reported the same way and the
+// (partial) report comes back with ctx's error.
+//
+// Checks can't be interrupted, so one that overruns keeps its goroutine
+// until it returns; we just stop waiting for it.
+//
+// ChecksPerformed follows CheckOrder. Issues are sorted by severity (error,
+// warning, info), then by check in CheckOrder, then in the order the check
+// reported them.
+func (e *Engine) ValidateContext(ctx context.Context, data *models.SurveyData, opts Options) (*models.ValidationReport, error) {
+	return e.validate(ctx, &runState{data: data, src: data}, opts)
+}
+
+// ValidateSource - ValidateContext for a dataset read in passes rather
+// than held in memory. StreamChecks (all the built-in ones) run over the
+// source; other checks get the points in memory if there are no more than
+// MaxInMemoryPoints, and are reported as not run otherwise. The report is
+// the same as ValidateContext would give for the same points.
+func (e *Engine) ValidateSource(ctx context.Context, src models.PointSource, opts Options) (*models.ValidationReport, error) {
+	return e.validate(ctx, &runState{src: src}, opts)
+}
+
+func (e *Engine) validate(ctx context.Context, rs *runState, opts Options) (*models.ValidationReport, error) {
 	startTime := time.Now()
 
-	report := models.NewValidationReport(data.ProjectID)
-	resultChan := make(chan checkResult, len(e.checks))
-	var wg sync.WaitGroup
+	timeout := e.Timeout
+	if opts.Timeout > 0 {
+		timeout = opts.Timeout
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	rs.traverse = opts.Traverse
+	rs.adjusted = make(chan *models.TraverseResult, 1)
+	plan, err := e.resolveOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	if p := opts.Profile; p != nil {
+		if p.RequiredPrecision > 0 && (rs.traverse == nil || rs.traverse.RequiredPrecision == 0) {
+			ti := models.TraverseInput{}
+			if rs.traverse != nil {
+				ti = *rs.traverse
+			}
+			ti.RequiredPrecision = p.RequiredPrecision
+			rs.traverse = &ti
+		}
+	}
 
-	for name, check := range e.checks {
+	if rs.data != nil {
+		rs.summary = domain.CalculateSummaryStatistics(rs.data)
+		rs.types = countTypes(rs.data)
+	} else if rs.summary, rs.types, err = domain.SummarizeSource(ctx, rs.src); err != nil {
+		return nil, fmt.Errorf("reading points: %w", err)
+	}
+
+	// one done channel per check, dependents wait on them
+	done := make(map[string]chan struct{}, len(e.order))
+	for _, name := range e.order {
+		done[name] = make(chan struct{})
+	}
+
+	running := make(map[string]bool, len(e.order))
+	for _, name := range e.order {
+		n := e.nodes[name]
+		if plan.disabled[name] || (n.applies != nil && !n.applies(rs.types)) {
+			close(done[name])
+			continue
+		}
+		running[name] = true
+	}
+
+	var progressMu sync.Mutex
+	finished := 0
+	progress := func(name string) {
+		if opts.Progress == nil {
+			return
+		}
+		progressMu.Lock()
+		defer progressMu.Unlock()
+		finished++
+		opts.Progress(Progress{Done: finished, Total: len(running), Check: name})
+	}
+
+	results := make([]*checkResult, len(e.order))
+	var wg sync.WaitGroup
+	for i, name := range e.order {
+		if !running[name] {
+			continue
+		}
 		wg.Add(1)
-		go func(checkName string, checkFunc ValidationCheck) {
+		go func(i int, n *node) {
 			defer wg.Done()
+			defer close(done[n.Name])
+			defer progress(n.Name)
 
-			issues := checkFunc(data)
-			resultChan <- checkResult{
-				checkName: checkName,
-				issues:    issues,
+			for _, dep := range n.DependsOn {
+				select {
+				case <-done[dep]:
+				case <-ctx.Done():
+					results[i] = cancelled(ctx, n.Name, 0)
+					return
+				}
 			}
-		}(name, check)
+			cfg := plan.configs[n.Name]
+			results[i] = e.runCheck(ctx, n.Name, func(ctx context.Context) []models.ValidationIssue {
+				issues, err := n.run(ctx, rs, cfg)
+				if err != nil && ctx.Err() == nil {
+					issues = append(issues, notRun(n.Name, err))
+				}
+				return overrideSeverity(issues, plan.severity[n.Name])
+			})
+		}(i, e.nodes[name])
 	}
+	wg.Wait()
 
-	go func() {
-		wg.Wait()
-		close(resultChan)
-	}()
-
-	for result := range resultChan {
+	report := models.NewValidationReport(rs.src.Meta().ProjectID)
+	report.Configuration = e.configuration(plan, opts.Profile, rs.traverse, opts.Suppressions)
+	report.CheckDurations = make(map[string]string)
+	rank := make(map[string]int, len(e.order))
+	used := make(map[string]bool, len(plan.suppress))
+	for i, result := range results {
+		if result == nil {
+			continue
+		}
+		rank[result.checkName] = i
 		report.ChecksPerformed = append(report.ChecksPerformed, result.checkName)
+		report.CheckDurations[result.checkName] = result.duration.String()
+		if e.OnCheck != nil {
+			e.OnCheck(result.checkName, result.duration)
+		}
 		for _, issue := range result.issues {
+			issue.Fingerprint = models.Fingerprint(issue)
+			if s, ok := plan.suppress[issue.Fingerprint]; ok && issue.Kind != models.KindCheckFailed {
+				issue.Suppression = &s
+				report.Suppressed = append(report.Suppressed, issue)
+				used[issue.Fingerprint] = true
+				continue
+			}
 			report.AddIssue(issue)
 		}
 	}
+	for _, s := range opts.Suppressions {
+		if !used[s.Fingerprint] {
+			report.UnusedSuppressions = append(report.UnusedSuppressions, s.Fingerprint)
+		}
+	}
+	sortIssues(report.Issues, rank)
+	sortIssues(report.Suppressed, rank)
 
-	report.Summary = domain.CalculateSummaryStatistics(data)
-
-	// run traverse adjustment if we have traverse points
-	if report.Summary.TraversePoints >= 3 {
-		report.TraverseResult = domain.ComputeTraverseAdjustment(data, traverseInput)
-		report.ChecksPerformed = append(report.ChecksPerformed, "bowditch_adjustment")
+	report.Summary = rs.summary
+	select {
+	case report.TraverseResult = <-rs.adjusted:
+	default: // not run, timed out or crashed - the issues say which
 	}
 
-	report.CalculateConfidenceScore()
+	report.Score(e.scoreInput(rs.types, plan.disabled))
 	report.ProcessingTime = time.Since(startTime).String()
+	if e.OnReport != nil {
+		e.OnReport(report)
+	}
 
-	return report
+	if err := ctx.Err(); err != nil {
+		return report, fmt.Errorf("validation did not finish: %w", err)
+	}
+	return report, nil
+}
+
+// resolveOptions - which checks are off for this run, the config each one
+// gets and any severity overrides. Names that aren't registered are an error.
+func (e *Engine) resolveOptions(opts Options) (*runPlan, error) {
+	known := func(name string) error {
+		if _, ok := e.nodes[name]; !ok {
+			return fmt.Errorf("%w: unknown check %q", ErrInvalidOptions, name)
+		}
+		return nil
+	}
+
+	plan := &runPlan{
+		disabled: make(map[string]bool),
+		configs:  make(map[string]Config, len(e.nodes)),
+		severity: make(map[string]models.IssueSeverity, len(opts.Severity)),
+	}
+	if len(opts.Enable) > 0 {
+		enabled := make(map[string]bool, len(opts.Enable))
+		for _, name := range opts.Enable {
+			if err := known(name); err != nil {
+				return nil, err
+			}
+			enabled[name] = true
+		}
+		for name := range e.nodes {
+			plan.disabled[name] = !enabled[name]
+		}
+	}
+	for _, name := range opts.Disable {
+		if err := known(name); err != nil {
+			return nil, err
+		}
+		plan.disabled[name] = true
+	}
+	if opts.Profile != nil {
+		// profiles are shared between engines, so unknown names are just ignored
+		for _, name := range opts.Profile.DisabledChecks {
+			plan.disabled[name] = true
+		}
+	}
+
+	for name := range opts.Config {
+		if err := known(name); err != nil {
+			return nil, err
+		}
+	}
+	for name, n := range e.nodes {
+		cfg, err := resolveConfig(n.CheckInfo, opts.Config[name])
+		if err != nil {
+			return nil, err
+		}
+		plan.configs[name] = cfg
+	}
+
+	for name, sev := range opts.Severity {
+		if err := known(name); err != nil {
+			return nil, err
+		}
+		if severityRank(sev) > severityRank(models.SeverityInfo) {
+			return nil, fmt.Errorf("%w: severity for %s must be error, warning or info, not %q", ErrInvalidOptions, name, sev)
+		}
+		plan.severity[name] = sev
+	}
+
+	plan.suppress = make(map[string]models.Suppression, len(opts.Suppressions))
+	for _, s := range opts.Suppressions {
+		if s.Fingerprint == "" {
+			return nil, fmt.Errorf("%w: suppression without a fingerprint", ErrInvalidOptions)
+		}
+		if strings.TrimSpace(s.Justification) == "" {
+			return nil, fmt.Errorf("%w: suppression of %s needs a justification", ErrInvalidOptions, s.Fingerprint)
+		}
+		plan.suppress[s.Fingerprint] = s
+	}
+	return plan, nil
+}
+
+// configuration - the settings a report was produced with, in a shape that
+// can be sent back as the request's checks block to repeat the run
+func (e *Engine) configuration(plan *runPlan, profile *Profile, traverse *models.TraverseInput, suppressions []models.Suppression) *models.RunConfiguration {
+	rc := &models.RunConfiguration{
+		Enable:   make([]string, 0, len(e.order)),
+		Config:   make(map[string]map[string]interface{}),
+		Versions: make(map[string]string, len(e.order)),
+	}
+	if profile != nil {
+		rc.Profile = profile.Name
+	}
+	if traverse != nil {
+		rc.RequiredPrecision = traverse.RequiredPrecision
+	}
+	for _, name := range e.order {
+		n := e.nodes[name]
+		rc.Versions[name] = n.Version
+		if plan.disabled[name] {
+			rc.Disable = append(rc.Disable, name)
+			continue
+		}
+		rc.Enable = append(rc.Enable, name)
+		if len(plan.configs[name]) > 0 {
+			rc.Config[name] = plan.configs[name]
+		}
+	}
+	if len(plan.severity) > 0 {
+		rc.Severity = plan.severity
+	}
+	rc.Suppressions = suppressions
+	return rc
+}
+
+// scoreInput - check categories, and which checks were meant to run on
+// this data. Checks the profile or request switched off are a choice, not
+// missing coverage.
+func (e *Engine) scoreInput(types map[models.SurveyType]int, disabled map[string]bool) models.ScoreInput {
+	in := models.ScoreInput{
+		Categories: make(map[string]string, len(e.order)),
+		Applicable: make([]string, 0, len(e.order)),
+	}
+	for _, name := range e.order {
+		n := e.nodes[name]
+		if n.Category != "" {
+			in.Categories[name] = n.Category
+		}
+		if !disabled[name] && (n.applies == nil || n.applies(types)) {
+			in.Applicable = append(in.Applicable, name)
+		}
+	}
+	return in
+}
+
+// overrideSeverity - issues at sev, remembering what the check said
+func overrideSeverity(issues []models.ValidationIssue, sev models.IssueSeverity) []models.ValidationIssue {
+	if sev == "" {
+		return issues
+	}
+	for i := range issues {
+		if issues[i].Severity != sev {
+			issues[i].OriginalSeverity = issues[i].Severity
+			issues[i].Severity = sev
+		}
+	}
+	return issues
+}
+
+// countTypes - points per survey type
+func countTypes(data *models.SurveyData) map[models.SurveyType]int {
+	n := make(map[models.SurveyType]int)
+	for _, p := range data.Points {
+		n[p.SurveyType]++
+	}
+	return n
+}
+
+// whole - the source as a SurveyData, read once and shared by every check
+// that needs it, or an error if it has more than max points
+func (rs *runState) whole(max int) (*models.SurveyData, error) {
+	if n := rs.src.Len(); n > max {
+		return nil, fmt.Errorf("%w: it needs all %d points in memory, the limit is %d", errTooBig, n, max)
+	}
+	rs.loadOnce.Do(func() {
+		data := rs.src.Meta()
+		data.Points = make([]models.SurveyPoint, 0, rs.src.Len())
+		rs.loadErr = rs.src.Each(func(i int, p *models.SurveyPoint) error {
+			data.Points = append(data.Points, *p)
+			return nil
+		})
+		rs.loaded = &data
+	})
+	return rs.loaded, rs.loadErr
+}
+
+// errTooBig - a check that can't stream skipped on a big source
+var errTooBig = errors.New("skipped")
+
+// notRun - the issue for a check that couldn't read the data. One skipped
+// for size is a warning; the score's coverage deduction covers the rest.
+func notRun(name string, err error) models.ValidationIssue {
+	sev := models.SeverityError
+	if errors.Is(err, errTooBig) {
+		sev = models.SeverityWarning
+	}
+	return models.ValidationIssue{
+		CheckName:   name,
+		Kind:        models.KindCheckFailed,
+		Severity:    sev,
+		Description: fmt.Sprintf("Check %s did not run: %v", name, err),
+	}
+}
+
+// sortIssues - by severity, then check order, keeping each check's own order
+func sortIssues(issues []models.ValidationIssue, rank map[string]int) {
+	sort.SliceStable(issues, func(i, j int) bool {
+		a, b := issues[i], issues[j]
+		if severityRank(a.Severity) != severityRank(b.Severity) {
+			return severityRank(a.Severity) < severityRank(b.Severity)
+		}
+		return rank[a.CheckName] < rank[b.CheckName]
+	})
+}
+
+func severityRank(s models.IssueSeverity) int {
+	switch s {
+	case models.SeverityError:
+		return 0
+	case models.SeverityWarning:
+		return 1
+	case models.SeverityInfo:
+		return 2
+	}
+	return 3
+}
+
+// runCheck - run fn with panic recovery and the per-check deadline. fn gets
+// the deadline too, so a check that overruns is told to stop rather than
+// left running after we stop waiting for it.
+func (e *Engine) runCheck(ctx context.Context, name string, fn func(ctx context.Context) []models.ValidationIssue) *checkResult {
+	start := time.Now()
+	if e.CheckTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.CheckTimeout)
+		defer cancel()
+	}
+
+	done := make(chan []models.ValidationIssue, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				slog.Error("Check panicked", "check", name, "panic", r, "stack", string(debug.Stack()))
+				done <- []models.ValidationIssue{{
+					CheckName:   name,
+					Kind:        models.KindCheckFailed,
+					Severity:    models.SeverityError,
+					Description: fmt.Sprintf("Check %s crashed: %v", name, r),
+				}}
+			}
+		}()
+		done <- fn(ctx)
+	}()
+
+	select {
+	case issues := <-done:
+		return &checkResult{checkName: name, issues: issues, duration: time.Since(start)}
+	case <-ctx.Done():
+		return cancelled(ctx, name, time.Since(start))
+	}
+}
+
+// cancelled - the result for a check we stopped waiting for
+func cancelled(ctx context.Context, name string, elapsed time.Duration) *checkResult {
+	reason := "was cancelled"
+	if ctx.Err() == context.DeadlineExceeded {
+		reason = fmt.Sprintf("did not finish within %s", elapsed.Round(time.Millisecond))
+	}
+	return &checkResult{
+		checkName: name,
+		duration:  elapsed,
+		issues: []models.ValidationIssue{{
+			CheckName:   name,
+			Kind:        models.KindCheckFailed,
+			Severity:    models.SeverityError,
+			Description: fmt.Sprintf("Check %s %s", name, reason),
+		}},
+	}
 }
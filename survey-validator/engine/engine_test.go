@@ -1,7 +1,14 @@
 package engine
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/survey-validator/models"
 )
@@ -73,3 +80,594 @@ func TestEngine_EmptyData(t *testing.T) {
 		t.Errorf("Status = %s, expected FAIL for empty data", report.Status)
 	}
 }
+
+func TestEngine_ValidateWithProfile(t *testing.T) {
+	engine := NewEngine()
+
+	data := &models.SurveyData{
+		ProjectID: "TEST-004",
+		Points: []models.SurveyPoint{
+			{PointID: "T1", Easting: 500000, Northing: 6000000, SurveyType: models.SurveyTypeTraverse},
+			{PointID: "T2", Easting: 500100, Northing: 6000000, SurveyType: models.SurveyTypeTraverse},
+			{PointID: "T3", Easting: 500100, Northing: 6000100, SurveyType: models.SurveyTypeTraverse},
+			{PointID: "T4", Easting: 500000.01, Northing: 6000000.01, SurveyType: models.SurveyTypeTraverse},
+		},
+	}
+
+	profile, err := LookupProfile("linear")
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := engine.ValidateWithProfile(data, nil, profile)
+
+	for _, name := range report.ChecksPerformed {
+		if name == "outlier_detection" {
+			t.Error("Expected outlier_detection to be skipped by the linear profile")
+		}
+	}
+	if report.TraverseResult == nil || report.TraverseResult.RequiredPrecision != 5000 {
+		t.Errorf("Expected profile precision 5000, got %+v", report.TraverseResult)
+	}
+
+	// explicit precision in the request wins
+	report = engine.ValidateWithProfile(data, &models.TraverseInput{RequiredPrecision: 2000}, profile)
+	if report.TraverseResult.RequiredPrecision != 2000 {
+		t.Errorf("RequiredPrecision = %v, expected 2000", report.TraverseResult.RequiredPrecision)
+	}
+
+	if _, err := LookupProfile("nope"); err == nil {
+		t.Error("Expected error for unknown profile")
+	}
+}
+
+func TestEngine_PanicRecovery(t *testing.T) {
+	engine := NewEngine()
+	engine.RegisterCheck("broken_check", func(data *models.SurveyData) []models.ValidationIssue {
+		panic("boom")
+	})
+
+	data := &models.SurveyData{
+		ProjectID: "TEST-005",
+		Points:    []models.SurveyPoint{{PointID: "P1", Easting: 100, Northing: 100}},
+	}
+	report, err := engine.ValidateContext(context.Background(), data, Options{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if report.Status != models.StatusFail {
+		t.Errorf("Status = %s, expected FAIL", report.Status)
+	}
+	found := false
+	for _, issue := range report.Issues {
+		if issue.CheckName == "broken_check" && issue.Severity == models.SeverityError && strings.Contains(issue.Description, "boom") {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("Expected an error issue naming the panicking check")
+	}
+	if _, ok := report.CheckDurations["input_validation"]; !ok {
+		t.Error("Expected per-check durations")
+	}
+}
+
+func TestEngine_CheckTimeout(t *testing.T) {
+	engine := NewEngine()
+	engine.CheckTimeout = 20 * time.Millisecond
+	release := make(chan struct{})
+	defer close(release)
+	engine.RegisterCheck("slow_check", func(data *models.SurveyData) []models.ValidationIssue {
+		<-release
+		return nil
+	})
+
+	data := &models.SurveyData{
+		ProjectID: "TEST-006",
+		Points:    []models.SurveyPoint{{PointID: "P1", Easting: 100, Northing: 100}},
+	}
+	report, err := engine.ValidateContext(context.Background(), data, Options{})
+	if err != nil {
+		t.Fatalf("A slow check shouldn't fail the whole run: %v", err)
+	}
+
+	found := false
+	for _, issue := range report.Issues {
+		if issue.CheckName == "slow_check" && strings.Contains(issue.Description, "did not finish") {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("Expected a timeout issue for slow_check")
+	}
+}
+
+// blockingCheck - waits for its ctx and says why it stopped
+type blockingCheck struct {
+	stopped chan error
+}
+
+func (blockingCheck) Info() CheckInfo { return CheckInfo{Name: "blocking_check"} }
+
+func (c blockingCheck) Run(ctx context.Context, data *models.SurveyData, cfg Config) []models.ValidationIssue {
+	<-ctx.Done()
+	c.stopped <- ctx.Err()
+	return nil
+}
+
+func TestEngine_CheckTimeoutStopsCheck(t *testing.T) {
+	engine := NewEngine()
+	engine.CheckTimeout = 20 * time.Millisecond
+	check := blockingCheck{stopped: make(chan error, 1)}
+	if err := engine.Add(check); err != nil {
+		t.Fatal(err)
+	}
+
+	data := &models.SurveyData{
+		ProjectID: "TEST-006",
+		Points:    []models.SurveyPoint{{PointID: "P1", Easting: 100, Northing: 100}},
+	}
+	if _, err := engine.ValidateContext(context.Background(), data, Options{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the run's own ctx is only cancelled when it returns; the check has
+	// to have been stopped by its deadline
+	select {
+	case err := <-check.stopped:
+		if err != context.DeadlineExceeded {
+			t.Errorf("Check stopped with %v, expected its own deadline", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Check was still running after its timeout")
+	}
+}
+
+func TestEngine_ContextCancelled(t *testing.T) {
+	engine := NewEngine()
+	release := make(chan struct{})
+	defer close(release)
+	engine.RegisterCheck("slow_check", func(data *models.SurveyData) []models.ValidationIssue {
+		<-release
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	data := &models.SurveyData{
+		ProjectID: "TEST-007",
+		Points:    []models.SurveyPoint{{PointID: "P1", Easting: 100, Northing: 100}},
+	}
+	report, err := engine.ValidateContext(ctx, data, Options{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded, got %v", err)
+	}
+	if report == nil || report.Status != models.StatusFail {
+		t.Error("Expected a partial FAIL report")
+	}
+}
+
+func TestEngine_DeterministicOrder(t *testing.T) {
+	engine := NewEngine()
+	data := &models.SurveyData{
+		ProjectID: "TEST-008",
+		Points: []models.SurveyPoint{
+			{PointID: "T1", Easting: 500000, Northing: 6000000, SurveyType: models.SurveyTypeTraverse},
+			{PointID: "T1_DUP", Easting: 500000.001, Northing: 6000000.001, SurveyType: models.SurveyTypeTraverse},
+			{PointID: "T2", Easting: 500100, Northing: 6000000, SurveyType: models.SurveyTypeTraverse},
+			{PointID: "ZERO", Easting: 0, Northing: 0, SurveyType: models.SurveyTypeDetail},
+		},
+	}
+
+	first := engine.Validate(data)
+	expected := []string{"input_validation", "duplicate_detection", "distance_bearing_check",
+		"outlier_detection", "traverse_closure", "bowditch_adjustment"}
+	if strings.Join(first.ChecksPerformed, ",") != strings.Join(expected, ",") {
+		t.Errorf("ChecksPerformed = %v, expected %v", first.ChecksPerformed, expected)
+	}
+
+	for i := 0; i < 20; i++ {
+		again := engine.Validate(data)
+		if len(again.Issues) != len(first.Issues) {
+			t.Fatalf("Run %d: %d issues, expected %d", i, len(again.Issues), len(first.Issues))
+		}
+		for j := range again.Issues {
+			if again.Issues[j].Description != first.Issues[j].Description {
+				t.Fatalf("Run %d: issue %d is %q, expected %q", i, j, again.Issues[j].Description, first.Issues[j].Description)
+			}
+		}
+	}
+
+	for i := 1; i < len(first.Issues); i++ {
+		if severityRank(first.Issues[i-1].Severity) > severityRank(first.Issues[i].Severity) {
+			t.Errorf("Issues not sorted by severity: %s before %s", first.Issues[i-1].Severity, first.Issues[i].Severity)
+		}
+	}
+}
+
+func TestEngine_Dependencies(t *testing.T) {
+	engine := NewEngine()
+
+	var mu sync.Mutex
+	var ran []string
+	record := func(name string, delay time.Duration) ValidationCheck {
+		return func(data *models.SurveyData) []models.ValidationIssue {
+			time.Sleep(delay)
+			mu.Lock()
+			ran = append(ran, name)
+			mu.Unlock()
+			return nil
+		}
+	}
+
+	if err := engine.Register(CheckSpec{Name: "slow_parent", Check: record("slow_parent", 20*time.Millisecond)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Register(CheckSpec{Name: "child", Check: record("child", 0), DependsOn: []string{"slow_parent"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	data := &models.SurveyData{ProjectID: "TEST-009", Points: []models.SurveyPoint{{PointID: "P1", Easting: 1, Northing: 1}}}
+	engine.Validate(data)
+
+	parent, child := -1, -1
+	for i, name := range ran {
+		switch name {
+		case "slow_parent":
+			parent = i
+		case "child":
+			child = i
+		}
+	}
+	if parent < 0 || child < 0 || child < parent {
+		t.Errorf("Expected child to run after slow_parent, got %v", ran)
+	}
+
+	if err := engine.Register(CheckSpec{Name: "orphan", Check: record("orphan", 0), DependsOn: []string{"missing"}}); err == nil {
+		t.Error("Expected error for unknown dependency")
+	}
+	if err := engine.Register(CheckSpec{Name: "slow_parent", Check: record("slow_parent", 0), DependsOn: []string{"child"}}); err == nil {
+		t.Error("Expected error for a dependency cycle")
+	}
+	// the failed registration must leave the old check in place
+	order := strings.Join(engine.CheckOrder(), ",")
+	if strings.Index(order, "slow_parent") > strings.Index(order, "child") {
+		t.Errorf("Unexpected order after failed registration: %s", order)
+	}
+}
+
+// thresholdCheck - a plugin-style check with a setting
+type thresholdCheck struct{}
+
+func (thresholdCheck) Info() CheckInfo {
+	return CheckInfo{
+		Name:    "max_easting",
+		Version: "2.1",
+		Config: map[string]ParamSpec{
+			"limit": {Type: ParamNumber, Default: 1000},
+		},
+	}
+}
+
+func (thresholdCheck) Run(ctx context.Context, data *models.SurveyData, cfg Config) []models.ValidationIssue {
+	var issues []models.ValidationIssue
+	for _, p := range data.Points {
+		if p.Easting > cfg.Number("limit") {
+			issues = append(issues, models.ValidationIssue{
+				CheckName: "max_easting", Severity: models.SeverityWarning, PointIDs: []string{p.PointID},
+			})
+		}
+	}
+	return issues
+}
+
+func TestEngine_CheckSelectionAndConfig(t *testing.T) {
+	engine := NewEngine()
+	if err := engine.Add(thresholdCheck{}); err != nil {
+		t.Fatal(err)
+	}
+
+	data := &models.SurveyData{
+		ProjectID: "TEST-010",
+		Points: []models.SurveyPoint{
+			{PointID: "P1", Easting: 500, Northing: 100},
+			{PointID: "P2", Easting: 1500, Northing: 100},
+		},
+	}
+
+	count := func(report *models.ValidationReport) int {
+		n := 0
+		for _, issue := range report.Issues {
+			if issue.CheckName == "max_easting" {
+				n++
+			}
+		}
+		return n
+	}
+
+	report, err := engine.ValidateContext(context.Background(), data, Options{Enable: []string{"max_easting"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.ChecksPerformed) != 1 || count(report) != 1 {
+		t.Errorf("Expected only max_easting with one issue, got %v / %d", report.ChecksPerformed, count(report))
+	}
+
+	report, _ = engine.ValidateContext(context.Background(), data, Options{
+		Enable: []string{"max_easting"},
+		Config: map[string]Config{"max_easting": {"limit": 100}},
+	})
+	if count(report) != 2 {
+		t.Errorf("Expected 2 issues with limit 100, got %d", count(report))
+	}
+
+	report, _ = engine.ValidateContext(context.Background(), data, Options{Disable: []string{"max_easting"}})
+	if count(report) != 0 {
+		t.Error("Expected max_easting to be disabled")
+	}
+
+	bad := []Options{
+		{Enable: []string{"nope"}},
+		{Disable: []string{"nope"}},
+		{Config: map[string]Config{"nope": {}}},
+		{Config: map[string]Config{"max_easting": {"limit": "high"}}},
+		{Config: map[string]Config{"max_easting": {"other": 1}}},
+		{Severity: map[string]models.IssueSeverity{"nope": models.SeverityInfo}},
+		{Severity: map[string]models.IssueSeverity{"max_easting": "fatal"}},
+	}
+	for _, opts := range bad {
+		if _, err := engine.ValidateContext(context.Background(), data, opts); !errors.Is(err, ErrInvalidOptions) {
+			t.Errorf("Options %+v: expected ErrInvalidOptions, got %v", opts, err)
+		}
+	}
+}
+
+func TestEngine_SeverityOverrideAndConfiguration(t *testing.T) {
+	engine := NewEngine()
+	if err := engine.Add(thresholdCheck{}); err != nil {
+		t.Fatal(err)
+	}
+
+	data := &models.SurveyData{
+		ProjectID: "TEST-011",
+		Points: []models.SurveyPoint{
+			{PointID: "P1", Easting: 500, Northing: 100},
+			{PointID: "P2", Easting: 1500, Northing: 100},
+		},
+	}
+
+	report, err := engine.ValidateContext(context.Background(), data, Options{
+		Profile:  &Profile{Name: "topo", RequiredPrecision: 5000},
+		Disable:  []string{"outlier_detection"},
+		Config:   map[string]Config{"max_easting": {"limit": 1200}},
+		Severity: map[string]models.IssueSeverity{"max_easting": models.SeverityInfo},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, issue := range report.Issues {
+		if issue.CheckName != "max_easting" {
+			continue
+		}
+		if issue.Severity != models.SeverityInfo || issue.OriginalSeverity != models.SeverityWarning {
+			t.Errorf("Expected info overriding warning, got %s (was %s)", issue.Severity, issue.OriginalSeverity)
+		}
+	}
+
+	rc := report.Configuration
+	if rc == nil {
+		t.Fatal("Expected the report to carry its configuration")
+	}
+	if rc.Profile != "topo" || rc.RequiredPrecision != 5000 {
+		t.Errorf("Expected profile topo at 1:5000, got %s at 1:%.0f", rc.Profile, rc.RequiredPrecision)
+	}
+	if len(rc.Disable) != 1 || rc.Disable[0] != "outlier_detection" {
+		t.Errorf("Expected outlier_detection disabled, got %v", rc.Disable)
+	}
+	if len(rc.Enable) != len(engine.CheckOrder())-1 {
+		t.Errorf("Expected every other check enabled, got %v", rc.Enable)
+	}
+	if rc.Config["max_easting"]["limit"] != 1200.0 {
+		t.Errorf("Expected limit 1200 echoed, got %v", rc.Config["max_easting"])
+	}
+	if rc.Severity["max_easting"] != models.SeverityInfo || rc.Versions["max_easting"] != "2.1" {
+		t.Errorf("Unexpected severity/version echo: %v %v", rc.Severity, rc.Versions)
+	}
+
+	// feeding the echo back gives the same result
+	again, err := engine.ValidateContext(context.Background(), data, Options{
+		Profile:  &Profile{Name: rc.Profile, RequiredPrecision: rc.RequiredPrecision},
+		Enable:   rc.Enable,
+		Disable:  rc.Disable,
+		Config:   map[string]Config{"max_easting": rc.Config["max_easting"]},
+		Severity: rc.Severity,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(again.Issues) != len(report.Issues) || again.ConfidenceScore != report.ConfidenceScore {
+		t.Errorf("Repeat run differs: %d issues / %.0f vs %d / %.0f",
+			len(again.Issues), again.ConfidenceScore, len(report.Issues), report.ConfidenceScore)
+	}
+}
+
+func TestEngine_Suppressions(t *testing.T) {
+	engine := NewEngine()
+	if err := engine.Add(thresholdCheck{}); err != nil {
+		t.Fatal(err)
+	}
+
+	data := &models.SurveyData{
+		ProjectID: "TEST-012",
+		Points: []models.SurveyPoint{
+			{PointID: "P1", Easting: 1500, Northing: 100},
+			{PointID: "P2", Easting: 1600, Northing: 100},
+		},
+	}
+	opts := Options{Enable: []string{"max_easting"}}
+
+	report, err := engine.ValidateContext(context.Background(), data, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Issues) != 2 || report.Status != models.StatusWarning {
+		t.Fatalf("Expected 2 warnings, got %d (%s)", len(report.Issues), report.Status)
+	}
+	before := report.ConfidenceScore
+
+	opts.Suppressions = []models.Suppression{
+		{Fingerprint: report.Issues[0].Fingerprint, Justification: "P1 is off site on purpose", AcknowledgedBy: "JB"},
+		{Fingerprint: "0123456789abcdef", Justification: "fixed last month"},
+	}
+	report, err = engine.ValidateContext(context.Background(), data, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Issues) != 1 || len(report.Suppressed) != 1 {
+		t.Fatalf("Expected 1 open and 1 suppressed issue, got %d / %d", len(report.Issues), len(report.Suppressed))
+	}
+	if s := report.Suppressed[0]; s.PointIDs[0] != "P1" || s.Suppression == nil || s.Suppression.AcknowledgedBy != "JB" {
+		t.Errorf("Unexpected suppressed issue: %+v", s)
+	}
+	if report.ConfidenceScore <= before {
+		t.Errorf("Expected suppressing to raise the score above %.0f, got %.0f", before, report.ConfidenceScore)
+	}
+	if len(report.UnusedSuppressions) != 1 || report.UnusedSuppressions[0] != "0123456789abcdef" {
+		t.Errorf("Expected the stale suppression reported, got %v", report.UnusedSuppressions)
+	}
+
+	opts.Suppressions = append(opts.Suppressions, models.Suppression{Fingerprint: report.Issues[0].Fingerprint})
+	if _, err := engine.ValidateContext(context.Background(), data, opts); !errors.Is(err, ErrInvalidOptions) {
+		t.Errorf("Expected a suppression without justification to be rejected, got %v", err)
+	}
+}
+
+func TestEngine_CrashNotSuppressible(t *testing.T) {
+	engine := NewEngine()
+	engine.RegisterCheck("crashy", func(data *models.SurveyData) []models.ValidationIssue {
+		panic("boom")
+	})
+	data := &models.SurveyData{ProjectID: "TEST-013", Points: []models.SurveyPoint{{PointID: "P1", Easting: 1, Northing: 1}}}
+
+	report, _ := engine.ValidateContext(context.Background(), data, Options{Enable: []string{"crashy"}})
+	fp := report.Issues[0].Fingerprint
+
+	report, _ = engine.ValidateContext(context.Background(), data, Options{
+		Enable:       []string{"crashy"},
+		Suppressions: []models.Suppression{{Fingerprint: fp, Justification: "ignore"}},
+	})
+	if len(report.Issues) != 1 || report.Status != models.StatusFail {
+		t.Errorf("Expected the crash to stay an open error, got %d issues (%s)", len(report.Issues), report.Status)
+	}
+}
+
+func TestEngine_ScoreBreakdown(t *testing.T) {
+	engine := NewEngine()
+	data := &models.SurveyData{
+		ProjectID: "TEST-014",
+		Points: []models.SurveyPoint{
+			{PointID: "P1", Easting: 1000, Northing: 1000, SurveyType: models.SurveyTypeDetail},
+			{PointID: "P2", Easting: 1000.005, Northing: 1000, SurveyType: models.SurveyTypeDetail},
+			{PointID: "P3", Easting: 1050, Northing: 1020, SurveyType: models.SurveyTypeDetail},
+		},
+	}
+
+	report, err := engine.ValidateContext(context.Background(), data, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := report.ScoreBreakdown
+	if b == nil || b.Coverage != 1 || len(b.Deductions) != 1 {
+		t.Fatalf("Expected full coverage and one deduction, got %+v", b)
+	}
+	if d := b.Deductions[0]; d.CheckName != "duplicate_detection" || d.Category != models.CategoryIntegrity {
+		t.Errorf("Expected the near duplicate to be the deduction, got %+v", d)
+	}
+	want := report.ConfidenceScore
+
+	// switching a check off, by request or profile, isn't missing coverage
+	linear, _ := LookupProfile("linear")
+	for _, opts := range []Options{{Disable: []string{"outlier_detection"}}, {Profile: &linear}} {
+		report, _ = engine.ValidateContext(context.Background(), data, opts)
+		b = report.ScoreBreakdown
+		if b.Coverage != 1 || len(b.Deductions) != 1 || report.ConfidenceScore != want {
+			t.Errorf("Expected no coverage deduction with outlier_detection off, got %+v", b)
+		}
+	}
+
+	// a check that was meant to run and crashed is
+	engine.RegisterCheck("broken_check", func(data *models.SurveyData) []models.ValidationIssue { panic("boom") })
+	report, _ = engine.ValidateContext(context.Background(), data, Options{})
+	b = report.ScoreBreakdown
+	last := b.Deductions[len(b.Deductions)-1]
+	if last.Category != "coverage" || !strings.Contains(last.Reason, "broken_check") || strings.Contains(last.Reason, "outlier_detection") {
+		t.Errorf("Expected a coverage deduction for broken_check only, got %+v", last)
+	}
+}
+
+func TestEngine_ValidateSourceMatchesInMemory(t *testing.T) {
+	engine := NewEngine()
+	engine.RegisterCheck("count_points", func(data *models.SurveyData) []models.ValidationIssue {
+		return []models.ValidationIssue{{
+			CheckName:   "count_points",
+			Severity:    models.SeverityInfo,
+			Description: fmt.Sprintf("%d points", len(data.Points)),
+		}}
+	})
+
+	data := &models.SurveyData{
+		ProjectID: "TEST-015",
+		Points: []models.SurveyPoint{
+			{PointID: "T1", Easting: 1000, Northing: 1000, SurveyType: models.SurveyTypeTraverse},
+			{PointID: "T2", Easting: 1100, Northing: 1000, SurveyType: models.SurveyTypeTraverse},
+			{PointID: "T3", Easting: 1100, Northing: 1100, SurveyType: models.SurveyTypeTraverse},
+			{PointID: "T4", Easting: 1000.02, Northing: 1000.01, SurveyType: models.SurveyTypeTraverse},
+			{PointID: "D1", Easting: 1050, Northing: 1050.004, SurveyType: models.SurveyTypeDetail},
+			{PointID: "D2", Easting: 1050, Northing: 1050, SurveyType: models.SurveyTypeDetail},
+			{PointID: "", Easting: 0, Northing: 0, SurveyType: "bogus"},
+		},
+	}
+
+	want, err := engine.ValidateContext(context.Background(), data, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var progress []Progress
+	got, err := engine.ValidateSource(context.Background(), data, Options{Progress: func(p Progress) { progress = append(progress, p) }})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got.Issues, want.Issues) {
+		t.Errorf("Issues differ:\n got %+v\nwant %+v", got.Issues, want.Issues)
+	}
+	if got.Summary != want.Summary || got.ConfidenceScore != want.ConfidenceScore || got.Status != want.Status {
+		t.Errorf("Summary/score/status differ: %+v %v %s vs %+v %v %s",
+			got.Summary, got.ConfidenceScore, got.Status, want.Summary, want.ConfidenceScore, want.Status)
+	}
+	if !reflect.DeepEqual(got.TraverseResult, want.TraverseResult) || got.TraverseResult == nil {
+		t.Errorf("Traverse adjustment differs")
+	}
+	if len(progress) != len(got.ChecksPerformed) {
+		t.Errorf("Expected a progress call per check, got %d for %d", len(progress), len(got.ChecksPerformed))
+	}
+
+	// over the memory limit the plain check is skipped, the rest still run
+	engine.MaxInMemoryPoints = 3
+	got, _ = engine.ValidateSource(context.Background(), data, Options{})
+	var skipped *models.ValidationIssue
+	for i, issue := range got.Issues {
+		if issue.CheckName == "count_points" {
+			skipped = &got.Issues[i]
+		}
+	}
+	if skipped == nil || skipped.Kind != models.KindCheckFailed || skipped.Severity != models.SeverityWarning ||
+		!strings.Contains(skipped.Description, "7 points") {
+		t.Errorf("Expected count_points skipped with a warning, got %+v", skipped)
+	}
+	if got.ScoreBreakdown.Coverage == 1 {
+		t.Error("Expected the skipped check to cost coverage")
+	}
+}
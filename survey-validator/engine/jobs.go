@@ -0,0 +1,391 @@
+package engine
+
+// jobs.go - background validation for datasets too big to wait on: a
+// queue in front of a fixed number of workers, and jobs that can be polled,
+// watched and cancelled
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/survey-validator/models"
+)
+
+// job pool defaults
+const (
+	DefaultQueueSize  = 100
+	DefaultJobTimeout = 10 * time.Minute
+	DefaultJobRetain  = time.Hour
+)
+
+var (
+	ErrQueueFull  = errors.New("job queue is full")
+	ErrPoolClosed = errors.New("job pool is closed")
+	ErrNoJob      = errors.New("no such job")
+)
+
+// JobStatus - where a job is
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobDone      JobStatus = "done"
+	JobFailed    JobStatus = "failed"
+	JobCancelled JobStatus = "cancelled"
+)
+
+// Finished - done, failed or cancelled
+func (s JobStatus) Finished() bool {
+	return s == JobDone || s == JobFailed || s == JobCancelled
+}
+
+// Job - a snapshot of a background validation. The report is only set
+// once it is done, and isn't part of the JSON; fetch it separately.
+type Job struct {
+	ID          string     `json:"id"`
+	ProjectID   string     `json:"project_id,omitempty"`
+	Points      int        `json:"points"`
+	Status      JobStatus  `json:"status"`
+	Progress    Progress   `json:"progress"`
+	SubmittedAt time.Time  `json:"submitted_at"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	Error       string     `json:"error,omitempty"`
+
+	Report *models.ValidationReport `json:"-"`
+}
+
+// job - the pool's side of a Job
+type job struct {
+	Job
+	data   *models.SurveyData
+	opts   Options
+	after  func(data *models.SurveyData, report *models.ValidationReport)
+	cancel context.CancelFunc // set while running
+	subs   []chan Job
+}
+
+// Pool - runs validations in the background, at most Workers at a time
+type Pool struct {
+	engine *Engine
+
+	// JobTimeout - how long one job may run. Retain - how long finished
+	// jobs are kept for their reports to be fetched.
+	JobTimeout time.Duration
+	Retain     time.Duration
+
+	workers int
+	queue   chan *job
+	mu      sync.Mutex
+	jobs    map[string]*job
+	closed  bool
+	wg      sync.WaitGroup
+}
+
+// NewPool - start workers validating with e. The queue holds up to
+// queueSize jobs waiting for a worker.
+func NewPool(e *Engine, workers, queueSize int) *Pool {
+	if workers < 1 {
+		workers = 1
+	}
+	if queueSize < 1 {
+		queueSize = DefaultQueueSize
+	}
+	p := &Pool{
+		engine:     e,
+		workers:    workers,
+		JobTimeout: DefaultJobTimeout,
+		Retain:     DefaultJobRetain,
+		queue:      make(chan *job, queueSize),
+		jobs:       make(map[string]*job),
+	}
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+// Submit - queue a validation. Bad options are refused here rather than
+// failing the job later.
+func (p *Pool) Submit(data *models.SurveyData, opts Options) (Job, error) {
+	return p.SubmitWith(data, opts, nil)
+}
+
+// SubmitWith - Submit, calling after with the report if the job
+// succeeds, e.g. to store it
+func (p *Pool) SubmitWith(data *models.SurveyData, opts Options, after func(data *models.SurveyData, report *models.ValidationReport)) (Job, error) {
+	if _, err := p.engine.resolveOptions(opts); err != nil {
+		return Job{}, err
+	}
+	id, err := jobID()
+	if err != nil {
+		return Job{}, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return Job{}, ErrPoolClosed
+	}
+	p.prune()
+
+	j := &job{
+		Job: Job{
+			ID:          id,
+			ProjectID:   data.ProjectID,
+			Points:      len(data.Points),
+			Status:      JobQueued,
+			SubmittedAt: time.Now().UTC(),
+		},
+		data:  data,
+		opts:  opts,
+		after: after,
+	}
+	select {
+	case p.queue <- j:
+	default:
+		return Job{}, ErrQueueFull
+	}
+	p.jobs[id] = j
+	return j.Job, nil
+}
+
+// Get - the job as it stands
+func (p *Pool) Get(id string) (Job, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	j, ok := p.jobs[id]
+	if !ok {
+		return Job{}, ErrNoJob
+	}
+	return j.Job, nil
+}
+
+// Watch - a snapshot on every change until the job finishes, then the
+// channel is closed. Slow readers miss intermediate snapshots, never the
+// last one. Call stop when done with the channel.
+func (p *Pool) Watch(id string) (updates <-chan Job, stop func(), err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	j, ok := p.jobs[id]
+	if !ok {
+		return nil, nil, ErrNoJob
+	}
+
+	ch := make(chan Job, 1)
+	ch <- j.Job
+	if j.Status.Finished() {
+		close(ch)
+		return ch, func() {}, nil
+	}
+	j.subs = append(j.subs, ch)
+	stop = func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		for i, c := range j.subs {
+			if c == ch {
+				j.subs = append(j.subs[:i], j.subs[i+1:]...)
+				close(ch)
+				return
+			}
+		}
+	}
+	return ch, stop, nil
+}
+
+// Cancel - drop a queued job or stop a running one. Finished jobs are
+// left as they are.
+func (p *Pool) Cancel(id string) (Job, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	j, ok := p.jobs[id]
+	if !ok {
+		return Job{}, ErrNoJob
+	}
+	switch j.Status {
+	case JobQueued:
+		p.finish(j, JobCancelled, "cancelled before it started", nil)
+	case JobRunning:
+		j.cancel()
+	}
+	return j.Job, nil
+}
+
+// Close - stop taking jobs, cancel the running ones and wait for the
+// workers. Queued jobs are cancelled.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	p.stop()
+	p.cancelAll()
+	p.mu.Unlock()
+	p.wg.Wait()
+}
+
+// Drain - stop taking jobs and wait for the queued and running ones to
+// finish. If ctx ends first the rest are cancelled as by Close and ctx's
+// error is returned.
+func (p *Pool) Drain(ctx context.Context) error {
+	p.mu.Lock()
+	p.stop()
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		p.mu.Lock()
+		p.cancelAll()
+		p.mu.Unlock()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// stop - refuse new jobs; the workers finish what is queued. Holds p.mu.
+func (p *Pool) stop() {
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+}
+
+// cancelAll - cancel every queued and running job. Holds p.mu.
+func (p *Pool) cancelAll() {
+	for _, j := range p.jobs {
+		switch j.Status {
+		case JobQueued:
+			p.finish(j, JobCancelled, "server shutting down", nil)
+		case JobRunning:
+			j.cancel()
+		}
+	}
+}
+
+func (p *Pool) work() {
+	defer p.wg.Done()
+	for j := range p.queue {
+		p.run(j)
+	}
+}
+
+func (p *Pool) run(j *job) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	p.mu.Lock()
+	if j.Status != JobQueued {
+		p.mu.Unlock()
+		return
+	}
+	started := time.Now().UTC()
+	j.Status, j.StartedAt, j.cancel = JobRunning, &started, cancel
+	p.notify(j)
+	p.mu.Unlock()
+
+	opts := j.opts
+	if opts.Timeout == 0 {
+		opts.Timeout = p.JobTimeout
+	}
+	opts.Progress = func(pr Progress) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		j.Progress = pr
+		p.notify(j)
+	}
+
+	report, err := p.engine.ValidateContext(ctx, j.data, opts)
+	if err == nil && j.after != nil {
+		j.after(j.data, report)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch {
+	case err == nil:
+		p.finish(j, JobDone, "", report)
+	case errors.Is(err, context.Canceled):
+		p.finish(j, JobCancelled, "cancelled while running", nil)
+	default:
+		p.finish(j, JobFailed, err.Error(), nil)
+	}
+}
+
+// PoolStats - how busy a pool is. Running == Workers means new jobs wait,
+// and Queued == QueueSize means they are refused.
+type PoolStats struct {
+	Workers   int `json:"workers"`
+	Running   int `json:"running"`
+	Queued    int `json:"queued"`
+	QueueSize int `json:"queue_size"`
+}
+
+// Stats - the pool right now
+func (p *Pool) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	st := PoolStats{Workers: p.workers, Queued: len(p.queue), QueueSize: cap(p.queue)}
+	for _, j := range p.jobs {
+		if j.Status == JobRunning {
+			st.Running++
+		}
+	}
+	return st
+}
+
+// finish - record the outcome and close the watchers. Holds p.mu.
+func (p *Pool) finish(j *job, status JobStatus, msg string, report *models.ValidationReport) {
+	now := time.Now().UTC()
+	j.Status, j.Error, j.Report, j.FinishedAt = status, msg, report, &now
+	j.data, j.cancel = nil, nil
+	p.notify(j)
+	for _, ch := range j.subs {
+		close(ch)
+	}
+	j.subs = nil
+}
+
+// notify - send the latest snapshot, replacing one a watcher hasn't read
+// yet. Holds p.mu.
+func (p *Pool) notify(j *job) {
+	for _, ch := range j.subs {
+		select {
+		case ch <- j.Job:
+		default:
+			select {
+			case <-ch:
+			default:
+			}
+			ch <- j.Job
+		}
+	}
+}
+
+// prune - forget jobs that finished more than Retain ago. Holds p.mu.
+func (p *Pool) prune() {
+	cutoff := time.Now().Add(-p.Retain)
+	for id, j := range p.jobs {
+		if j.FinishedAt != nil && j.FinishedAt.Before(cutoff) {
+			delete(p.jobs, id)
+		}
+	}
+}
+
+func jobID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("job ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
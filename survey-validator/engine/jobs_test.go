@@ -0,0 +1,213 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/survey-validator/models"
+)
+
+func jobData(id string) *models.SurveyData {
+	return &models.SurveyData{
+		ProjectID: id,
+		Points: []models.SurveyPoint{
+			{PointID: "A", Easting: 1000, Northing: 1000},
+			{PointID: "B", Easting: 1010, Northing: 1000},
+		},
+	}
+}
+
+// wait - the last snapshot Watch sends
+func wait(t *testing.T, p *Pool, id string) (last Job, seen []Job) {
+	t.Helper()
+	updates, stop, err := p.Watch(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case j, ok := <-updates:
+			if !ok {
+				return last, seen
+			}
+			last, seen = j, append(seen, j)
+		case <-timeout:
+			t.Fatalf("job %s didn't finish, last seen %+v", id, last)
+		}
+	}
+}
+
+func TestPool_RunsJobs(t *testing.T) {
+	e := NewEngine()
+	p := NewPool(e, 2, 10)
+	defer p.Close()
+
+	var stored []string
+	job, err := p.SubmitWith(jobData("JOB-1"), Options{}, func(data *models.SurveyData, report *models.ValidationReport) {
+		stored = append(stored, report.ProjectID)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != JobQueued || job.Points != 2 {
+		t.Errorf("Expected a queued job of 2 points, got %+v", job)
+	}
+
+	last, _ := wait(t, p, job.ID)
+	if last.Status != JobDone || last.Report == nil || last.FinishedAt == nil {
+		t.Fatalf("Expected a finished job with a report, got %+v", last)
+	}
+	if last.Progress.Done != last.Progress.Total || last.Progress.Total != len(last.Report.ChecksPerformed) {
+		t.Errorf("Expected progress to end at %d/%d, got %+v", len(last.Report.ChecksPerformed), len(last.Report.ChecksPerformed), last.Progress)
+	}
+	if len(stored) != 1 || stored[0] != "JOB-1" {
+		t.Errorf("Expected the after func to see the report, got %v", stored)
+	}
+
+	// watching a finished job gives the final state straight away
+	if again, _ := wait(t, p, job.ID); again.Status != JobDone {
+		t.Errorf("Expected done, got %s", again.Status)
+	}
+	if _, err := p.Get("nope"); !errors.Is(err, ErrNoJob) {
+		t.Errorf("Expected ErrNoJob, got %v", err)
+	}
+}
+
+func TestPool_BadOptionsRefused(t *testing.T) {
+	p := NewPool(NewEngine(), 1, 1)
+	defer p.Close()
+	if _, err := p.Submit(jobData("X"), Options{Disable: []string{"no_such_check"}}); !errors.Is(err, ErrInvalidOptions) {
+		t.Errorf("Expected ErrInvalidOptions, got %v", err)
+	}
+}
+
+func TestPool_CancelAndQueueLimit(t *testing.T) {
+	e := NewEngine()
+	release := make(chan struct{})
+	e.mustRegister(CheckSpec{
+		Name: "slow",
+		Check: func(data *models.SurveyData) []models.ValidationIssue {
+			<-release
+			return nil
+		},
+	})
+	defer close(release)
+
+	p := NewPool(e, 1, 1)
+	defer p.Close()
+
+	running, err := p.Submit(jobData("RUN"), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// wait for the worker to pick it up so the queue is empty
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+		if j, _ := p.Get(running.ID); j.Status == JobRunning {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("job never started")
+		}
+	}
+
+	queued, err := p.Submit(jobData("QUEUED"), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Submit(jobData("FULL"), Options{}); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Expected ErrQueueFull, got %v", err)
+	}
+	if st := p.Stats(); st != (PoolStats{Workers: 1, Running: 1, Queued: 1, QueueSize: 1}) {
+		t.Errorf("Expected a saturated pool, got %+v", st)
+	}
+
+	if j, _ := p.Cancel(queued.ID); j.Status != JobCancelled {
+		t.Errorf("Expected the queued job cancelled at once, got %s", j.Status)
+	}
+	if _, err := p.Cancel(running.ID); err != nil {
+		t.Fatal(err)
+	}
+	if last, _ := wait(t, p, running.ID); last.Status != JobCancelled || last.Report != nil {
+		t.Errorf("Expected the running job cancelled without a report, got %+v", last)
+	}
+}
+
+func TestPool_JobTimeout(t *testing.T) {
+	e := NewEngine()
+	e.CheckTimeout = 0
+	e.mustRegister(CheckSpec{
+		Name: "stuck",
+		Check: func(data *models.SurveyData) []models.ValidationIssue {
+			time.Sleep(time.Second)
+			return nil
+		},
+	})
+	p := NewPool(e, 1, 1)
+	defer p.Close()
+	p.JobTimeout = 20 * time.Millisecond
+
+	job, _ := p.Submit(jobData("SLOW"), Options{})
+	last, _ := wait(t, p, job.ID)
+	if last.Status != JobFailed || !strings.Contains(last.Error, "deadline") {
+		t.Errorf("Expected the job to fail on its timeout, got %+v", last)
+	}
+}
+
+func TestPool_Drain(t *testing.T) {
+	e := NewEngine()
+	release := make(chan struct{})
+	e.mustRegister(CheckSpec{
+		Name: "slow",
+		Check: func(data *models.SurveyData) []models.ValidationIssue {
+			if data.ProjectID == "STUCK" {
+				<-release
+			} else {
+				time.Sleep(20 * time.Millisecond)
+			}
+			return nil
+		},
+	})
+
+	p := NewPool(e, 1, 2)
+	first, _ := p.Submit(jobData("ONE"), Options{})
+	second, _ := p.Submit(jobData("TWO"), Options{})
+	if err := p.Drain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{first.ID, second.ID} {
+		if j, _ := p.Get(id); j.Status != JobDone {
+			t.Errorf("Expected %s to finish while draining, got %s", id, j.Status)
+		}
+	}
+	if _, err := p.Submit(jobData("LATE"), Options{}); !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("Expected ErrPoolClosed after draining, got %v", err)
+	}
+	p.Close() // still fine
+
+	// one that won't finish in time is cancelled
+	e.CheckTimeout = 0
+	p = NewPool(e, 1, 1)
+	defer close(release)
+	stuck, _ := p.Submit(&models.SurveyData{ProjectID: "STUCK", Points: jobData("").Points}, Options{Timeout: time.Minute})
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+		if j, _ := p.Get(stuck.ID); j.Status == JobRunning {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("job never started")
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	if err := p.Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the drain to time out, got %v", err)
+	}
+	if j, _ := p.Get(stuck.ID); j.Status != JobCancelled {
+		t.Errorf("Expected the running job cancelled, got %s", j.Status)
+	}
+}
@@ -0,0 +1,96 @@
+package engine
+
+// profile.go - named validation profiles for different kinds of job
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// Profile - tolerances and check selection for a kind of survey
+type Profile struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+
+	// traverse closure must be at least 1:RequiredPrecision
+	RequiredPrecision float64 `json:"required_precision,omitempty"`
+
+	// checks to skip, e.g. outlier_detection on a linear road survey
+	DisabledChecks []string `json:"disabled_checks,omitempty"`
+}
+
+// DefaultProfile - what you get when you don't ask for one
+const DefaultProfile = "default"
+
+var builtinProfiles = map[string]Profile{
+	DefaultProfile: {
+		Name:        DefaultProfile,
+		Description: "All checks, 1:5000 traverse closure",
+	},
+	"topo": {
+		Name:              "topo",
+		Description:       "Topographic and detail surveys, 1:5000 closure",
+		RequiredPrecision: 5000,
+	},
+	"boundary": {
+		Name:              "boundary",
+		Description:       "Cadastral/boundary work, 1:10000 closure",
+		RequiredPrecision: 10000,
+	},
+	"control": {
+		Name:              "control",
+		Description:       "Control networks, 1:20000 closure",
+		RequiredPrecision: 20000,
+	},
+	"linear": {
+		Name:              "linear",
+		Description:       "Roads, pipelines and other corridor jobs, no centroid outlier test",
+		RequiredPrecision: 5000,
+		DisabledChecks:    []string{"outlier_detection"},
+	},
+}
+
+// LookupProfile - a built-in profile by name, empty name gives the default
+func LookupProfile(name string) (Profile, error) {
+	if name == "" {
+		name = DefaultProfile
+	}
+	p, ok := builtinProfiles[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("unknown profile %q", name)
+	}
+	return p, nil
+}
+
+// Profiles - the built-in profiles sorted by name
+func Profiles() []Profile {
+	out := make([]Profile, 0, len(builtinProfiles))
+	for _, p := range builtinProfiles {
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// LoadProfiles - read extra profiles from a JSON file (an array of profiles),
+// keyed by name. They can shadow the built-ins.
+func LoadProfiles(path string) (map[string]Profile, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var list []Profile
+	if err := json.Unmarshal(b, &list); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	out := make(map[string]Profile, len(list))
+	for _, p := range list {
+		if p.Name == "" {
+			return nil, fmt.Errorf("%s: profile without a name", path)
+		}
+		out[p.Name] = p
+	}
+	return out, nil
+}
@@ -0,0 +1,271 @@
+package formats
+
+// csv.go - coordinate lists as CSV (PointID,Easting,Northing,Height,Type)
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/survey-validator/models"
+)
+
+// FormatCSV - a plain coordinate list, one point per row
+const FormatCSV Format = "csv"
+
+// csvColumns - column index for each field, -1 when the file doesn't have it
+type csvColumns struct {
+	id, code, desc, e, n, h, typ int
+}
+
+// RowError - a CSV value that isn't a usable number: which line, which
+// column and what was there
+type RowError struct {
+	Line      int
+	Column    string // easting, northing or height
+	Value     string
+	NotFinite bool // NaN or infinite rather than not a number at all
+}
+
+func (e *RowError) Error() string {
+	if e.NotFinite {
+		return fmt.Sprintf("csv line %d: %s %q isn't a finite number", e.Line, e.Column, e.Value)
+	}
+	return fmt.Sprintf("csv line %d: bad %s %q", e.Line, e.Column, e.Value)
+}
+
+// parseCoord - a coordinate cell. ParseFloat takes NaN and Inf, and gives
+// Inf for 1e999; none of those are coordinates.
+func parseCoord(line int, column, s string) (float64, error) {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil && !errors.Is(err, strconv.ErrRange) {
+		return 0, &RowError{Line: line, Column: column, Value: s}
+	}
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, &RowError{Line: line, Column: column, Value: s, NotFinite: true}
+	}
+	return v, nil
+}
+
+// ParseCSV - read a coordinate list. A header row is used when present
+// (same column names the web UI accepts), otherwise the columns are guessed
+// as id,E,N[,H] or id,code,E,N[,H] which is what most controllers dump,
+// with a text column after the height taken as the code or description.
+// Types come from a type column, then the code map, then the point name.
+func ParseCSV(r io.Reader, opts ImportOptions) (*models.SurveyData, error) {
+	data := &models.SurveyData{
+		ProjectID:        opts.ProjectID,
+		CoordinateSystem: opts.CoordinateSystem,
+	}
+	err := ScanCSV(r, opts, func(p models.SurveyPoint) error {
+		data.Points = append(data.Points, p)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// ScanCSV - ParseCSV a row at a time, handing each point to fn instead of
+// keeping them, for files too big to hold. An error from fn stops the scan
+// and is returned as is.
+func ScanCSV(r io.Reader, opts ImportOptions, fn func(models.SurveyPoint) error) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	cr.Comment = '#'
+	cr.ReuseRecord = true
+
+	first, err := cr.Read()
+	if err == io.EOF {
+		return fmt.Errorf("csv: no rows")
+	}
+	if err != nil {
+		return fmt.Errorf("csv: %w", err)
+	}
+
+	// tab separated files come through as one field per row
+	tabs := len(first) == 1 && strings.Contains(first[0], "\t")
+	split := func(rec []string) []string {
+		if tabs && len(rec) == 1 {
+			return strings.Split(rec[0], "\t")
+		}
+		return rec
+	}
+	first = append([]string(nil), split(first)...)
+
+	cols, hasHeader := csvHeader(first)
+	if !hasHeader {
+		cols = csvGuessColumns(first)
+	}
+	if cols.e < 0 || cols.n < 0 {
+		return fmt.Errorf("csv: cannot find easting and northing columns")
+	}
+
+	line, count := 1, 0
+	next := func() ([]string, error) {
+		if !hasHeader && line == 1 {
+			return first, nil
+		}
+		rec, err := cr.Read()
+		if err != nil {
+			return nil, err
+		}
+		return split(rec), nil
+	}
+	if hasHeader {
+		line = 2
+	}
+	for ; ; line++ {
+		rec, err := next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("csv: %w", err)
+		}
+		cell := func(idx int) string {
+			if idx < 0 || idx >= len(rec) {
+				return ""
+			}
+			return strings.TrimSpace(rec[idx])
+		}
+
+		// skip blank rows and rows without coordinates, like the UI does
+		if cell(cols.e) == "" || cell(cols.n) == "" {
+			continue
+		}
+
+		e, err := parseCoord(line, "easting", cell(cols.e))
+		if err != nil {
+			return err
+		}
+		n, err := parseCoord(line, "northing", cell(cols.n))
+		if err != nil {
+			return err
+		}
+
+		p := models.SurveyPoint{
+			PointID:     cell(cols.id),
+			Easting:     toMeters(e, opts.LinearUnit),
+			Northing:    toMeters(n, opts.LinearUnit),
+			Code:        cell(cols.code),
+			Description: cell(cols.desc),
+		}
+		if p.PointID == "" {
+			p.PointID = fmt.Sprintf("P%d", count+1)
+		}
+		if s := cell(cols.h); s != "" {
+			h, err := parseCoord(line, "height", s)
+			if err != nil {
+				return err
+			}
+			h = toMeters(h, opts.LinearUnit)
+			p.Height = &h
+		}
+
+		switch t := models.SurveyType(strings.ToLower(cell(cols.typ))); t {
+		case models.SurveyTypeControl, models.SurveyTypeTraverse, models.SurveyTypeDetail:
+			p.SurveyType = t
+		default:
+			if mapped, ok := opts.CodeMap[p.Code]; ok && p.Code != "" {
+				p.SurveyType = mapped
+			} else {
+				p.SurveyType = TypeFromName(p.PointID)
+			}
+		}
+
+		count++
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+}
+
+// csvHeader - map header names to columns, false if the row is data
+func csvHeader(row []string) (csvColumns, bool) {
+	cols := csvColumns{-1, -1, -1, -1, -1, -1, -1}
+	for i, h := range row {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if _, err := strconv.ParseFloat(h, 64); err == nil {
+			return cols, false
+		}
+		set := func(idx *int) {
+			if *idx < 0 {
+				*idx = i
+			}
+		}
+		switch {
+		case strings.Contains(h, "type"):
+			set(&cols.typ)
+		case strings.Contains(h, "code"):
+			set(&cols.code)
+		case strings.Contains(h, "desc"):
+			set(&cols.desc)
+		case strings.Contains(h, "east"), h == "x", h == "e":
+			set(&cols.e)
+		case strings.Contains(h, "north"), h == "y", h == "n":
+			set(&cols.n)
+		case strings.Contains(h, "height"), strings.Contains(h, "elev"), h == "z", h == "h", h == "rl":
+			set(&cols.h)
+		case strings.Contains(h, "point"), strings.Contains(h, "id"), strings.Contains(h, "name"):
+			set(&cols.id)
+		}
+	}
+	// a description column on its own is what the controller calls the code
+	if cols.code < 0 {
+		cols.code, cols.desc = cols.desc, -1
+	}
+	return cols, cols.e >= 0 && cols.n >= 0
+}
+
+// csvGuessColumns - headerless: first column is the ID, then an optional
+// code column if the second field isn't a number, and an optional text
+// column after the height (the code, or the description if there is one)
+func csvGuessColumns(row []string) csvColumns {
+	cols := csvColumns{id: 0, code: -1, desc: -1, e: 1, n: 2, h: -1, typ: -1}
+	if len(row) > 1 {
+		if _, err := strconv.ParseFloat(strings.TrimSpace(row[1]), 64); err != nil {
+			cols.code, cols.e, cols.n = 1, 2, 3
+		}
+	}
+	if len(row) > cols.n+1 {
+		cols.h = cols.n + 1
+	}
+	if cols.h >= 0 && len(row) > cols.h+1 {
+		if _, err := strconv.ParseFloat(strings.TrimSpace(row[cols.h+1]), 64); err != nil {
+			if cols.code < 0 {
+				cols.code = cols.h + 1
+			} else {
+				cols.desc = cols.h + 1
+			}
+		}
+	}
+	if len(row) <= cols.n {
+		cols.e, cols.n = -1, -1
+	}
+	return cols
+}
+
+var (
+	controlName  = regexp.MustCompile(`(?i)^(cp|bm|gcp|ctrl|control|bench)`)
+	traverseName = regexp.MustCompile(`(?i)^(tp|t\d|stn|sta|trav|peg)`)
+)
+
+// TypeFromName - guess the survey type from the point name, same patterns
+// the web UI uses (CP1/BM2 are control, T1/STN3 are traverse)
+func TypeFromName(id string) models.SurveyType {
+	switch {
+	case controlName.MatchString(id):
+		return models.SurveyTypeControl
+	case traverseName.MatchString(id):
+		return models.SurveyTypeTraverse
+	}
+	return models.SurveyTypeDetail
+}
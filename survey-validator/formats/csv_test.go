@@ -0,0 +1,120 @@
+package formats
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/survey-validator/models"
+)
+
+func TestParseCSV_Header(t *testing.T) {
+	in := `PointID,Easting,Northing,Height,Type
+CP1,499950.000,599950.000,99.500,control
+T1,500000.000,600000.000,,traverse
+D1,500010.000,600010.000,100.2,
+`
+	data, err := ParseCSV(strings.NewReader(in), ImportOptions{ProjectID: "CSV-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Points) != 3 || data.ProjectID != "CSV-1" {
+		t.Fatalf("Unexpected data: %+v", data)
+	}
+
+	cp1 := findPoint(t, data.Points, "CP1")
+	if cp1.SurveyType != models.SurveyTypeControl || !cp1.HasHeight() || *cp1.Height != 99.5 {
+		t.Errorf("Unexpected CP1: %+v", cp1)
+	}
+	if t1 := findPoint(t, data.Points, "T1"); t1.HasHeight() {
+		t.Error("Expected T1 without height")
+	}
+	if d1 := findPoint(t, data.Points, "D1"); d1.SurveyType != models.SurveyTypeDetail {
+		t.Errorf("D1 type = %s, expected detail", d1.SurveyType)
+	}
+}
+
+func TestParseCSV_Headerless(t *testing.T) {
+	// id,code,E,N,H as dumped by the data collector, blank code allowed
+	in := "bm1,BM,556621.876,715059.353,2.208\nbo1,,556625.394,715058.507,2.16\nbo2,,,,\n"
+	opts := ImportOptions{CodeMap: map[string]models.SurveyType{"BM": models.SurveyTypeControl}}
+
+	data, err := ParseCSV(strings.NewReader(in), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Points) != 2 {
+		t.Fatalf("Expected 2 points (empty row skipped), got %d", len(data.Points))
+	}
+	if p := data.Points[0]; p.Code != "BM" || p.SurveyType != models.SurveyTypeControl || p.Easting != 556621.876 {
+		t.Errorf("Unexpected first point: %+v", p)
+	}
+}
+
+func TestParseCSV_CodeAndDescription(t *testing.T) {
+	in := "Point,Code,Description,East,North\nT1,TREE SPREAD=4,Oak by the gate,100,200\n"
+	data, err := ParseCSV(strings.NewReader(in), ImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := data.Points[0]; p.Code != "TREE SPREAD=4" || p.Description != "Oak by the gate" {
+		t.Errorf("Unexpected point: %+v", p)
+	}
+
+	// a description column alone is the code, as before
+	data, err = ParseCSV(strings.NewReader("Point,Desc,East,North\nT1,TREE,100,200\n"), ImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := data.Points[0]; p.Code != "TREE" || p.Description != "" {
+		t.Errorf("Unexpected point: %+v", p)
+	}
+
+	// id,E,N,H,code and id,code,E,N,H,desc without a header
+	data, err = ParseCSV(strings.NewReader("P1,100,200,10,EP1 START\n"), ImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := data.Points[0]; p.Code != "EP1 START" || *p.Height != 10 {
+		t.Errorf("Unexpected point: %+v", p)
+	}
+	data, err = ParseCSV(strings.NewReader("P1,EP1,100,200,10,kerb line\n"), ImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := data.Points[0]; p.Code != "EP1" || p.Description != "kerb line" || p.Easting != 100 {
+		t.Errorf("Unexpected point: %+v", p)
+	}
+}
+
+func TestParseCSV_BadNumber(t *testing.T) {
+	_, err := ParseCSV(strings.NewReader("P1,abc,100\n"), ImportOptions{})
+	if err == nil {
+		t.Error("Expected error for bad easting")
+	}
+
+	// NaN, Inf and numbers past float64 parse, but aren't coordinates
+	for _, in := range []string{"P1,NaN,100\n", "P1,100,-Inf\n", "P1,100,100,1e999\n"} {
+		_, err := ParseCSV(strings.NewReader(in), ImportOptions{})
+		var row *RowError
+		if !errors.As(err, &row) || !row.NotFinite || row.Line != 1 {
+			t.Errorf("%q: expected a not finite RowError on line 1, got %v", in, err)
+		}
+	}
+}
+
+func TestTypeFromName(t *testing.T) {
+	tests := map[string]models.SurveyType{
+		"CP1":  models.SurveyTypeControl,
+		"bm2":  models.SurveyTypeControl,
+		"T12":  models.SurveyTypeTraverse,
+		"STN3": models.SurveyTypeTraverse,
+		"TREE": models.SurveyTypeDetail,
+		"bo1":  models.SurveyTypeDetail,
+	}
+	for id, expected := range tests {
+		if got := TypeFromName(id); got != expected {
+			t.Errorf("TypeFromName(%q) = %s; expected %s", id, got, expected)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package formats
+
+// dxf.go - AutoCAD R12 ASCII DXF export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"github.com/survey-validator/models"
+)
+
+// DXF layers, one per survey type plus legs, labels and issue markers
+const (
+	LayerControl       = "SV_CONTROL"
+	LayerTraverse      = "SV_TRAVERSE"
+	LayerDetail        = "SV_DETAIL"
+	LayerTraverseLegs  = "SV_TRAVERSE_LEGS"
+	LayerAdjustedLegs  = "SV_ADJUSTED_LEGS"
+	LayerLabels        = "SV_LABELS"
+	LayerIssueErrors   = "SV_ISSUES_ERROR"
+	LayerIssueWarnings = "SV_ISSUES_WARNING"
+)
+
+// ACI colours for each layer
+var dxfLayers = []struct {
+	name  string
+	color int
+}{
+	{LayerControl, 3},       // green
+	{LayerTraverse, 5},      // blue
+	{LayerDetail, 8},        // grey
+	{LayerTraverseLegs, 5},  // blue
+	{LayerAdjustedLegs, 6},  // magenta
+	{LayerLabels, 7},        // white/black
+	{LayerIssueErrors, 1},   // red
+	{LayerIssueWarnings, 2}, // yellow
+}
+
+// dxf sizes in drawing units (meters)
+const (
+	dxfTextHeight   = 0.5
+	dxfMarkerRadius = 1.0
+)
+
+// WriteDXF - points on a layer per survey type with ID labels, the traverse
+// legs (raw and adjusted) as polylines, and a circle round every point with
+// an error or warning on its own layer
+func WriteDXF(w io.Writer, in ExportInput) error {
+	d := &dxfWriter{w: bufio.NewWriter(w)}
+
+	d.section("HEADER")
+	d.pair(9, "$ACADVER")
+	d.pair(1, "AC1009")
+	d.pair(0, "ENDSEC")
+
+	d.section("TABLES")
+	d.pair(0, "TABLE")
+	d.pair(2, "LAYER")
+	d.pair(70, fmt.Sprint(len(dxfLayers)))
+	for _, l := range dxfLayers {
+		d.pair(0, "LAYER")
+		d.pair(2, l.name)
+		d.pair(70, "0")
+		d.pair(62, fmt.Sprint(l.color))
+		d.pair(6, "CONTINUOUS")
+	}
+	d.pair(0, "ENDTAB")
+	d.pair(0, "ENDSEC")
+
+	d.section("ENTITIES")
+	issues := in.pointIssues()
+	for _, p := range in.Data.Points {
+		layer := LayerDetail
+		switch p.SurveyType {
+		case models.SurveyTypeControl:
+			layer = LayerControl
+		case models.SurveyTypeTraverse:
+			layer = LayerTraverse
+		}
+
+		z := 0.0
+		if p.HasHeight() {
+			z = *p.Height
+		}
+		d.pair(0, "POINT")
+		d.pair(8, layer)
+		d.xyz(p.Easting, p.Northing, z)
+
+		d.pair(0, "TEXT")
+		d.pair(8, LayerLabels)
+		d.xyz(p.Easting+dxfTextHeight, p.Northing+dxfTextHeight, z)
+		d.pair(40, fmt.Sprint(dxfTextHeight))
+		d.pair(1, p.PointID)
+
+		marker := ""
+		switch worstSeverity(issues[p.PointID]) {
+		case models.SeverityError:
+			marker = LayerIssueErrors
+		case models.SeverityWarning:
+			marker = LayerIssueWarnings
+		}
+		if marker != "" {
+			d.pair(0, "CIRCLE")
+			d.pair(8, marker)
+			d.xyz(p.Easting, p.Northing, z)
+			d.pair(40, fmt.Sprint(dxfMarkerRadius))
+		}
+	}
+
+	if trav := in.traversePoints(); len(trav) >= 2 {
+		verts := make([][2]float64, 0, len(trav))
+		for _, p := range trav {
+			verts = append(verts, [2]float64{p.Easting, p.Northing})
+		}
+		d.polyline(LayerTraverseLegs, verts)
+	}
+
+	if in.Report != nil && in.Report.TraverseResult != nil && len(in.Report.TraverseResult.AdjustedPoints) >= 2 {
+		tr := in.Report.TraverseResult
+		verts := make([][2]float64, 0, len(tr.AdjustedPoints)+1)
+		for _, ap := range tr.AdjustedPoints {
+			verts = append(verts, [2]float64{ap.AdjEasting, ap.AdjNorthing})
+		}
+		if tr.TraverseType == "closed" {
+			verts = append(verts, verts[0])
+		}
+		d.polyline(LayerAdjustedLegs, verts)
+	}
+
+	d.pair(0, "ENDSEC")
+	d.pair(0, "EOF")
+
+	if d.err != nil {
+		return d.err
+	}
+	return d.w.Flush()
+}
+
+// dxfWriter - group code / value pairs, keeps the first write error
+type dxfWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (d *dxfWriter) pair(code int, value string) {
+	if d.err != nil {
+		return
+	}
+	_, d.err = fmt.Fprintf(d.w, "%3d\n%s\n", code, value)
+}
+
+func (d *dxfWriter) section(name string) {
+	d.pair(0, "SECTION")
+	d.pair(2, name)
+}
+
+func (d *dxfWriter) xyz(x, y, z float64) {
+	d.pair(10, fmt.Sprintf("%.4f", x))
+	d.pair(20, fmt.Sprintf("%.4f", y))
+	d.pair(30, fmt.Sprintf("%.4f", z))
+}
+
+// polyline - R12 style POLYLINE / VERTEX / SEQEND
+func (d *dxfWriter) polyline(layer string, verts [][2]float64) {
+	d.pair(0, "POLYLINE")
+	d.pair(8, layer)
+	d.pair(66, "1")
+	d.xyz(0, 0, 0)
+	for _, v := range verts {
+		d.pair(0, "VERTEX")
+		d.pair(8, layer)
+		d.xyz(v[0], v[1], 0)
+	}
+	d.pair(0, "SEQEND")
+	d.pair(8, layer)
+}
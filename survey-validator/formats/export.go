@@ -0,0 +1,148 @@
+package formats
+
+// export.go - shared bits for the GIS/CAD exporters
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/survey-validator/models"
+)
+
+const (
+	FormatGeoJSON Format = "geojson"
+	FormatKML     Format = "kml"
+	FormatDXF     Format = "dxf"
+)
+
+// ExportInput - validated data and its report, what the GIS/CAD exporters write
+type ExportInput struct {
+	Data   *models.SurveyData
+	Report *models.ValidationReport
+}
+
+// pointIssues - issues touching each point, in report order
+func (in ExportInput) pointIssues() map[string][]models.ValidationIssue {
+	byPoint := make(map[string][]models.ValidationIssue)
+	if in.Report == nil {
+		return byPoint
+	}
+	for _, issue := range in.Report.Issues {
+		for _, id := range issue.PointIDs {
+			byPoint[id] = append(byPoint[id], issue)
+		}
+	}
+	return byPoint
+}
+
+// traversePoints - traverse stations in the order they were walked
+func (in ExportInput) traversePoints() []models.SurveyPoint {
+	var pts []models.SurveyPoint
+	for _, p := range in.Data.Points {
+		if p.SurveyType == models.SurveyTypeTraverse {
+			pts = append(pts, p)
+		}
+	}
+	return pts
+}
+
+// worstSeverity - "error", "warning", "info" or "" when the point is clean
+func worstSeverity(issues []models.ValidationIssue) models.IssueSeverity {
+	var worst models.IssueSeverity
+	for _, issue := range issues {
+		switch issue.Severity {
+		case models.SeverityError:
+			return models.SeverityError
+		case models.SeverityWarning:
+			worst = models.SeverityWarning
+		case models.SeverityInfo:
+			if worst == "" {
+				worst = models.SeverityInfo
+			}
+		}
+	}
+	return worst
+}
+
+var (
+	utmNamePattern = regexp.MustCompile(`(?i)utm\s*zone\s*(\d{1,2})\s*([ns]|north|south)?\b`)
+	utmEPSGPattern = regexp.MustCompile(`(?i)epsg:\s*32(6|7)(\d{2})\b`)
+)
+
+// ParseUTMZone - pull a WGS84 UTM zone out of a coordinate system name,
+// e.g. "UTM Zone 36N", "WGS 84 / UTM zone 33S" or "EPSG:32636"
+func ParseUTMZone(cs string) (zone int, south bool, ok bool) {
+	if m := utmEPSGPattern.FindStringSubmatch(cs); m != nil {
+		zone, _ = strconv.Atoi(m[2])
+		return zone, m[1] == "7", zone >= 1 && zone <= 60
+	}
+	if m := utmNamePattern.FindStringSubmatch(cs); m != nil {
+		zone, _ = strconv.Atoi(m[1])
+		hemi := strings.ToLower(m[2])
+		return zone, hemi == "s" || hemi == "south", zone >= 1 && zone <= 60
+	}
+	return 0, false, false
+}
+
+// UTMToLatLon - inverse transverse mercator on WGS84 (Snyder's series),
+// good to well under a millimetre inside the zone
+func UTMToLatLon(easting, northing float64, zone int, south bool) (lat, lon float64) {
+	const (
+		a  = 6378137.0
+		f  = 1 / 298.257223563
+		k0 = 0.9996
+	)
+	e2 := f * (2 - f)
+	ep2 := e2 / (1 - e2)
+
+	x := easting - 500000
+	y := northing
+	if south {
+		y -= 10000000
+	}
+
+	mu := y / k0 / (a * (1 - e2/4 - 3*e2*e2/64 - 5*e2*e2*e2/256))
+	e1 := (1 - math.Sqrt(1-e2)) / (1 + math.Sqrt(1-e2))
+	phi1 := mu +
+		(3*e1/2-27*math.Pow(e1, 3)/32)*math.Sin(2*mu) +
+		(21*e1*e1/16-55*math.Pow(e1, 4)/32)*math.Sin(4*mu) +
+		(151*math.Pow(e1, 3)/96)*math.Sin(6*mu) +
+		(1097*math.Pow(e1, 4)/512)*math.Sin(8*mu)
+
+	sin1, cos1, tan1 := math.Sin(phi1), math.Cos(phi1), math.Tan(phi1)
+	n1 := a / math.Sqrt(1-e2*sin1*sin1)
+	t1 := tan1 * tan1
+	c1 := ep2 * cos1 * cos1
+	r1 := a * (1 - e2) / math.Pow(1-e2*sin1*sin1, 1.5)
+	d := x / (n1 * k0)
+
+	latRad := phi1 - (n1*tan1/r1)*(d*d/2-
+		(5+3*t1+10*c1-4*c1*c1-9*ep2)*math.Pow(d, 4)/24+
+		(61+90*t1+298*c1+45*t1*t1-252*ep2-3*c1*c1)*math.Pow(d, 6)/720)
+	lonRad := (d - (1+2*t1+c1)*math.Pow(d, 3)/6 +
+		(5-2*c1+28*t1-3*c1*c1+8*ep2+24*t1*t1)*math.Pow(d, 5)/120) / cos1
+
+	lon0 := float64((zone-1)*6 - 180 + 3)
+	return latRad * 180 / math.Pi, lon0 + lonRad*180/math.Pi
+}
+
+// geographic - lat/lon for every point, or an error if the grid isn't one we can unproject
+type geographic struct {
+	zone  int
+	south bool
+}
+
+func newGeographic(cs string) (*geographic, error) {
+	zone, south, ok := ParseUTMZone(cs)
+	if !ok {
+		return nil, fmt.Errorf("coordinate system %q is not a UTM zone we can convert to lat/long", cs)
+	}
+	return &geographic{zone: zone, south: south}, nil
+}
+
+func (g *geographic) latLon(p models.SurveyPoint) (float64, float64) {
+	return UTMToLatLon(p.Easting, p.Northing, g.zone, g.south)
+}
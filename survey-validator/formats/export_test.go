@@ -0,0 +1,136 @@
+package formats
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/survey-validator/models"
+)
+
+func exportSample() ExportInput {
+	data := &models.SurveyData{
+		ProjectID:        "EXP-001",
+		CoordinateSystem: "UTM Zone 31N",
+		Points: []models.SurveyPoint{
+			{PointID: "CP1", Easting: 500000, Northing: 100000, SurveyType: models.SurveyTypeControl, Code: "CP"},
+			{PointID: "T1", Easting: 500100, Northing: 100000, SurveyType: models.SurveyTypeTraverse},
+			{PointID: "T2", Easting: 500100, Northing: 100100, SurveyType: models.SurveyTypeTraverse},
+			{PointID: "D1", Easting: 500050, Northing: 100050, SurveyType: models.SurveyTypeDetail},
+		},
+	}
+	report := models.NewValidationReport("EXP-001")
+	report.AddIssue(models.ValidationIssue{
+		CheckName:   "outlier_detection",
+		Severity:    models.SeverityWarning,
+		PointIDs:    []string{"D1"},
+		Description: "Point D1 may be an outlier",
+	})
+	return ExportInput{Data: data, Report: report}
+}
+
+func TestParseUTMZone(t *testing.T) {
+	tests := []struct {
+		in    string
+		zone  int
+		south bool
+		ok    bool
+	}{
+		{"UTM Zone 36N", 36, false, true},
+		{"WGS 84 / UTM zone 33S", 33, true, true},
+		{"EPSG:32636", 36, false, true},
+		{"epsg:32755", 55, true, true},
+		{"Local Grid", 0, false, false},
+	}
+
+	for _, tt := range tests {
+		zone, south, ok := ParseUTMZone(tt.in)
+		if zone != tt.zone || south != tt.south || ok != tt.ok {
+			t.Errorf("ParseUTMZone(%q) = %d, %v, %v; expected %d, %v, %v",
+				tt.in, zone, south, ok, tt.zone, tt.south, tt.ok)
+		}
+	}
+}
+
+func TestUTMToLatLon(t *testing.T) {
+	// central meridian on the equator
+	lat, lon := UTMToLatLon(500000, 0, 31, false)
+	if math.Abs(lat) > 1e-9 || math.Abs(lon-3) > 1e-9 {
+		t.Errorf("got (%.9f, %.9f), expected (0, 3)", lat, lon)
+	}
+
+	// 1 degree off the central meridian at 45N, zone 31
+	lat, lon = UTMToLatLon(578815.303, 4983436.769, 31, false)
+	if math.Abs(lat-45) > 1e-6 || math.Abs(lon-4) > 1e-6 {
+		t.Errorf("got (%.7f, %.7f), expected (45, 4)", lat, lon)
+	}
+}
+
+func TestWriteGeoJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteGeoJSON(&buf, exportSample()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var fc struct {
+		Type     string `json:"type"`
+		Features []struct {
+			Geometry struct {
+				Type        string          `json:"type"`
+				Coordinates json.RawMessage `json:"coordinates"`
+			} `json:"geometry"`
+			Properties map[string]interface{} `json:"properties"`
+		} `json:"features"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &fc); err != nil {
+		t.Fatalf("invalid GeoJSON: %v", err)
+	}
+
+	// 4 points plus the traverse line
+	if len(fc.Features) != 5 {
+		t.Fatalf("expected 5 features, got %d", len(fc.Features))
+	}
+	d1 := fc.Features[3].Properties
+	if d1["point_id"] != "D1" || d1["has_warning"] != true || d1["severity"] != "warning" {
+		t.Errorf("D1 properties = %v, expected a warning flag", d1)
+	}
+	if fc.Features[4].Geometry.Type != "LineString" {
+		t.Errorf("last feature = %s, expected traverse LineString", fc.Features[4].Geometry.Type)
+	}
+}
+
+func TestWriteKML_NeedsUTM(t *testing.T) {
+	in := exportSample()
+
+	var buf bytes.Buffer
+	if err := WriteKML(&buf, in); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(buf.String(), "<styleUrl>#warning</styleUrl>") {
+		t.Error("expected D1 to use the warning style")
+	}
+
+	in.Data.CoordinateSystem = "Local Grid"
+	if err := WriteKML(&bytes.Buffer{}, in); err == nil {
+		t.Error("expected error for a grid we can't convert")
+	}
+}
+
+func TestWriteDXF(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteDXF(&buf, exportSample()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{LayerControl, LayerTraverseLegs, "POLYLINE", "CIRCLE\n  8\n" + LayerIssueWarnings} {
+		if !strings.Contains(out, want) {
+			t.Errorf("DXF output missing %q", want)
+		}
+	}
+	if !strings.HasSuffix(out, "  0\nEOF\n") {
+		t.Error("DXF output should end with EOF")
+	}
+}
@@ -0,0 +1,471 @@
+package formats
+
+// fieldbook.go - raw total station observations and reduction to coordinates
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/survey-validator/models"
+)
+
+// Format - a supported raw data format
+type Format string
+
+const (
+	FormatGSI   Format = "gsi"   // Leica GSI-8 / GSI-16
+	FormatRW5   Format = "rw5"   // TDS / Carlson raw file
+	FormatSDR33 Format = "sdr33" // Sokkia SDR33
+)
+
+// CheckShotTolerance - how far a shot onto a stored point may miss before we warn
+const CheckShotTolerance = 0.02
+
+// LinearUnit - distance unit used in a raw file
+type LinearUnit string
+
+const (
+	UnitMeter      LinearUnit = "m"
+	UnitMillimeter LinearUnit = "mm"
+	UnitCentimeter LinearUnit = "cm"
+	UnitKilometer  LinearUnit = "km"
+	UnitFoot       LinearUnit = "ft"    // international foot
+	UnitUSFoot     LinearUnit = "us_ft" // US survey foot
+	UnitInch       LinearUnit = "in"
+	UnitMile       LinearUnit = "mi" // international mile
+)
+
+// AngleUnit - angle unit used in a raw file
+type AngleUnit string
+
+const (
+	AngleDegrees AngleUnit = "deg" // decimal degrees
+	AngleDMS     AngleUnit = "dms" // packed ddd.mmss
+	AngleGons    AngleUnit = "gon"
+	AngleMils    AngleUnit = "mil" // 6400 per circle
+	AngleRadians AngleUnit = "rad"
+)
+
+// ImportOptions - how to interpret a raw file
+type ImportOptions struct {
+	ProjectID        string
+	CoordinateSystem string
+
+	// override the units declared in the file, leave empty to trust the file
+	LinearUnit LinearUnit
+	AngleUnit  AngleUnit
+
+	// CodeMap maps field codes to survey types (e.g. "CP" -> control).
+	// sideshots with unmapped codes become detail points
+	CodeMap map[string]models.SurveyType
+}
+
+// Setup - one instrument occupation
+type Setup struct {
+	StationID        string
+	BacksightID      string
+	InstrumentHeight float64
+	// azimuth to the backsight, when the file records one
+	BacksightAzimuth    float64
+	HasBacksightAzimuth bool
+	// horizontal circle reading on the backsight
+	BacksightCircle float64
+}
+
+// RawObservation - one pointing from a setup, angles in degrees, distances in meters
+type RawObservation struct {
+	Setup         int // index into FieldBook.Setups
+	TargetID      string
+	Circle        float64 // horizontal circle reading
+	Azimuth       float64 // used instead of Circle when HasAzimuth
+	HasAzimuth    bool
+	Zenith        float64 // 0 means not observed, distance treated as horizontal
+	SlopeDistance float64
+	HorizDistance float64
+	TargetHeight  float64
+	Code          string
+	Backsight     bool
+	Traverse      bool // foresight to the next traverse station
+}
+
+// FieldBook - a parsed raw job before it is reduced to coordinates
+type FieldBook struct {
+	Format       Format
+	Known        []models.SurveyPoint // coordinates stored in the file
+	Setups       []Setup
+	Observations []RawObservation
+	Warnings     []string
+}
+
+// ImportResult - reduced raw job, ready for the validator
+type ImportResult struct {
+	Format       Format                       `json:"format"`
+	Data         *models.SurveyData           `json:"data"`
+	Observations []models.TraverseObservation `json:"observations"`
+	Warnings     []string                     `json:"warnings,omitempty"`
+}
+
+// Import - parse a raw file and reduce it in one go
+func Import(format Format, r io.Reader, opts ImportOptions) (*ImportResult, error) {
+	var fb *FieldBook
+	var err error
+
+	switch format {
+	case FormatGSI:
+		fb, err = ParseGSI(r, opts)
+	case FormatRW5:
+		fb, err = ParseRW5(r, opts)
+	case FormatSDR33:
+		fb, err = ParseSDR33(r, opts)
+	case FormatLandXML:
+		return ParseLandXML(r, opts)
+	case FormatCSV:
+		data, err := ParseCSV(r, opts)
+		if err != nil {
+			return nil, err
+		}
+		return &ImportResult{Format: FormatCSV, Data: data}, nil
+	default:
+		return nil, fmt.Errorf("unsupported raw format: %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return fb.Reduce(opts), nil
+}
+
+// DetectFormat - guess the format from the file extension
+func DetectFormat(filename string) (Format, bool) {
+	name := strings.ToLower(filename)
+	switch {
+	case strings.HasSuffix(name, ".gsi"):
+		return FormatGSI, true
+	case strings.HasSuffix(name, ".rw5"):
+		return FormatRW5, true
+	case strings.HasSuffix(name, ".sdr"):
+		return FormatSDR33, true
+	case strings.HasSuffix(name, ".xml"), strings.HasSuffix(name, ".landxml"):
+		return FormatLandXML, true
+	case strings.HasSuffix(name, ".csv"):
+		return FormatCSV, true
+	}
+	return "", false
+}
+
+// stationCoord - working coordinates during reduction
+type stationCoord struct {
+	e, n, h float64
+	hasH    bool
+}
+
+// Reduce - turn setups and observations into points and traverse observations.
+// Stations are placed from stored coordinates or from earlier foresights,
+// each setup is oriented on its backsight, then every pointing is computed
+// as a polar shot. Occupied stations form the traverse in occupation order.
+func (fb *FieldBook) Reduce(opts ImportOptions) *ImportResult {
+	result := &ImportResult{
+		Format: fb.Format,
+		Data: &models.SurveyData{
+			ProjectID:        opts.ProjectID,
+			CoordinateSystem: opts.CoordinateSystem,
+			Points:           make([]models.SurveyPoint, 0),
+		},
+		Observations: make([]models.TraverseObservation, 0),
+		Warnings:     append([]string(nil), fb.Warnings...),
+	}
+
+	coords := make(map[string]stationCoord)
+	codes := make(map[string]string)
+	for _, p := range fb.Known {
+		c := stationCoord{e: p.Easting, n: p.Northing}
+		if p.Height != nil {
+			c.h, c.hasH = *p.Height, true
+		}
+		coords[p.PointID] = c
+		codes[p.PointID] = p.Code
+	}
+
+	stored := make(map[string]bool)
+	for _, p := range fb.Known {
+		stored[p.PointID] = true
+	}
+
+	occupied := make(map[string]bool)
+	for _, s := range fb.Setups {
+		occupied[s.StationID] = true
+	}
+
+	// known points that were never occupied are control unless the file says otherwise
+	for _, p := range fb.Known {
+		if occupied[p.PointID] {
+			continue
+		}
+		pt := p
+		fallback := models.SurveyTypeControl
+		if p.SurveyType != "" {
+			fallback = p.SurveyType
+		}
+		pt.SurveyType = opts.surveyType(p.Code, fallback)
+		result.Data.Points = append(result.Data.Points, pt)
+	}
+
+	var traverse []models.SurveyPoint
+	var firstStation string
+	var foresights []string // stations placed by a foresight, in order
+	placed := make(map[string]bool)
+
+	for si, setup := range fb.Setups {
+		st, ok := coords[setup.StationID]
+		if !ok {
+			result.Warnings = append(result.Warnings,
+				fmt.Sprintf("Station %s has no coordinates, skipping its observations", setup.StationID))
+			continue
+		}
+
+		if !placed[setup.StationID] {
+			placed[setup.StationID] = true
+			if firstStation == "" {
+				firstStation = setup.StationID
+			}
+			traverse = append(traverse, st.point(setup.StationID, codes[setup.StationID], models.SurveyTypeTraverse))
+		}
+
+		backsight := fb.backsightCircle(si)
+		orient, ok := fb.orientation(si, coords)
+		if !ok {
+			result.Warnings = append(result.Warnings,
+				fmt.Sprintf("Setup at %s has no usable backsight, assuming an oriented circle", setup.StationID))
+		}
+
+		for _, o := range fb.Observations {
+			if o.Setup != si || o.Backsight {
+				continue
+			}
+
+			az := o.Azimuth
+			if !o.HasAzimuth {
+				az = o.Circle + orient
+			}
+			az = normalizeDegrees(az)
+
+			hd, vd := o.reduceDistance()
+			target := stationCoord{
+				e: st.e + hd*math.Sin(az*math.Pi/180),
+				n: st.n + hd*math.Cos(az*math.Pi/180),
+			}
+			if st.hasH {
+				target.h = st.h + setup.InstrumentHeight + vd - o.TargetHeight
+				target.hasH = true
+			}
+
+			isStation := o.Traverse || occupied[o.TargetID]
+			if isStation {
+				obs := models.TraverseObservation{
+					StationID: setup.StationID,
+					TargetID:  o.TargetID,
+					Distance:  hd,
+					Bearing:   az,
+				}
+				if !o.HasAzimuth {
+					obs.Angle = normalizeDegrees(o.Circle - backsight)
+					obs.AngleType = "right"
+				}
+				result.Observations = append(result.Observations, obs)
+			}
+
+			switch {
+			case isStation && o.TargetID == firstStation && len(traverse) > 2:
+				// closing shot back onto the start - keep it so closure can be checked
+				traverse = append(traverse, target.point(o.TargetID, o.Code, models.SurveyTypeTraverse))
+			case isStation:
+				if _, known := coords[o.TargetID]; !known {
+					coords[o.TargetID] = target
+					codes[o.TargetID] = o.Code
+					foresights = append(foresights, o.TargetID)
+				}
+			case stored[o.TargetID]:
+				// check shot onto a stored point - report it rather than duplicate the point
+				known := coords[o.TargetID]
+				if miss := math.Hypot(target.e-known.e, target.n-known.n); miss > CheckShotTolerance {
+					result.Warnings = append(result.Warnings,
+						fmt.Sprintf("Check shot from %s misses stored point %s by %.3fm", setup.StationID, o.TargetID, miss))
+				}
+			default:
+				result.Data.Points = append(result.Data.Points,
+					target.point(o.TargetID, o.Code, opts.surveyType(o.Code, models.SurveyTypeDetail)))
+			}
+		}
+	}
+
+	// the end of an open traverse is foresighted but never occupied
+	for _, id := range foresights {
+		if !placed[id] {
+			placed[id] = true
+			traverse = append(traverse, coords[id].point(id, codes[id], models.SurveyTypeTraverse))
+		}
+	}
+
+	result.Data.Points = append(result.Data.Points, traverse...)
+	return result
+}
+
+// backsightCircle - the circle reading on a setup's backsight, from the
+// backsight pointing itself when there is one
+func (fb *FieldBook) backsightCircle(si int) float64 {
+	for _, o := range fb.Observations {
+		if o.Setup == si && o.Backsight {
+			return o.Circle
+		}
+	}
+	return fb.Setups[si].BacksightCircle
+}
+
+// orientation - circle-to-azimuth correction for a setup
+func (fb *FieldBook) orientation(si int, coords map[string]stationCoord) (float64, bool) {
+	setup := fb.Setups[si]
+	circle := fb.backsightCircle(si)
+
+	if setup.HasBacksightAzimuth {
+		return setup.BacksightAzimuth - circle, true
+	}
+
+	st, ok1 := coords[setup.StationID]
+	bs, ok2 := coords[setup.BacksightID]
+	if setup.BacksightID == "" || !ok1 || !ok2 {
+		return 0, false
+	}
+
+	az := math.Atan2(bs.e-st.e, bs.n-st.n) * 180 / math.Pi
+	return az - circle, true
+}
+
+// reduceDistance - horizontal distance and height difference for a pointing
+func (o *RawObservation) reduceDistance() (float64, float64) {
+	if o.SlopeDistance > 0 && o.Zenith != 0 {
+		z := o.Zenith * math.Pi / 180
+		return o.SlopeDistance * math.Sin(z), o.SlopeDistance * math.Cos(z)
+	}
+	if o.HorizDistance > 0 {
+		return o.HorizDistance, 0
+	}
+	return o.SlopeDistance, 0
+}
+
+func (c stationCoord) point(id, code string, t models.SurveyType) models.SurveyPoint {
+	p := models.SurveyPoint{
+		PointID:    id,
+		Easting:    round4(c.e),
+		Northing:   round4(c.n),
+		SurveyType: t,
+		Code:       code,
+	}
+	if c.hasH {
+		h := round4(c.h)
+		p.Height = &h
+	}
+	return p
+}
+
+func (opts ImportOptions) surveyType(code string, fallback models.SurveyType) models.SurveyType {
+	if code == "" {
+		return fallback
+	}
+	if t, ok := opts.CodeMap[code]; ok {
+		return t
+	}
+	if t, ok := opts.CodeMap[strings.ToUpper(code)]; ok {
+		return t
+	}
+	return fallback
+}
+
+// toMeters - convert a distance in the given unit
+func toMeters(v float64, unit LinearUnit) float64 {
+	switch unit {
+	case UnitFoot:
+		return v * 0.3048
+	case UnitUSFoot:
+		return v * 1200.0 / 3937.0
+	case UnitMillimeter:
+		return v / 1000
+	case UnitCentimeter:
+		return v / 100
+	case UnitKilometer:
+		return v * 1000
+	case UnitInch:
+		return v * 0.0254
+	case UnitMile:
+		return v * 1609.344
+	default:
+		return v
+	}
+}
+
+// parseAngle - convert an angle string in the given unit to decimal degrees
+func parseAngle(s string, unit AngleUnit) (float64, error) {
+	s = strings.TrimSpace(s)
+	if unit == AngleDMS {
+		return parseDMS(s)
+	}
+
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	switch unit {
+	case AngleGons:
+		return v * 0.9, nil
+	case AngleMils:
+		return v * 360.0 / 6400.0, nil
+	case AngleRadians:
+		return v * 180 / math.Pi, nil
+	default:
+		return v, nil
+	}
+}
+
+// parseDMS - packed ddd.mmss(s) to decimal degrees, done on the string
+// so 90.3015 doesn't come out as 90°30'14.999"
+func parseDMS(s string) (float64, error) {
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimLeft(s, "+-")
+
+	whole, frac, _ := strings.Cut(s, ".")
+	deg, err := strconv.Atoi(whole)
+	if err != nil {
+		return 0, fmt.Errorf("invalid angle %q", s)
+	}
+
+	for len(frac) < 4 {
+		frac += "0"
+	}
+	min, err := strconv.Atoi(frac[:2])
+	if err != nil {
+		return 0, fmt.Errorf("invalid angle %q", s)
+	}
+	sec, err := strconv.ParseFloat(frac[2:4]+"."+frac[4:], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid angle %q", s)
+	}
+
+	v := float64(deg) + float64(min)/60 + sec/3600
+	if neg {
+		v = -v
+	}
+	return v, nil
+}
+
+func normalizeDegrees(a float64) float64 {
+	a = math.Mod(a, 360)
+	if a < 0 {
+		a += 360
+	}
+	return a
+}
+
+func round4(v float64) float64 {
+	return math.Round(v*10000) / 10000
+}
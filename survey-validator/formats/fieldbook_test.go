@@ -0,0 +1,216 @@
+package formats
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/survey-validator/models"
+)
+
+func findPoint(t *testing.T, points []models.SurveyPoint, id string) models.SurveyPoint {
+	t.Helper()
+	for _, p := range points {
+		if p.PointID == id {
+			return p
+		}
+	}
+	t.Fatalf("point %s not found", id)
+	return models.SurveyPoint{}
+}
+
+func TestParseDMS(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected float64
+	}{
+		{"90.3015", 90 + 30.0/60 + 15.0/3600},
+		{"0.0030", 30.0 / 3600},
+		{"359.5959", 359 + 59.0/60 + 59.0/3600},
+		{"-12.3", -(12 + 30.0/60)},
+	}
+
+	for _, tt := range tests {
+		got, err := parseDMS(tt.in)
+		if err != nil {
+			t.Fatalf("parseDMS(%q) error: %v", tt.in, err)
+		}
+		if math.Abs(got-tt.expected) > 1e-9 {
+			t.Errorf("parseDMS(%q) = %.9f, expected %.9f", tt.in, got, tt.expected)
+		}
+	}
+}
+
+func TestImport_GSI(t *testing.T) {
+	raw := strings.Join([]string{
+		"110001+000000BM 81..00+01000000 82..00+01100000",
+		"110002+000000S1 84..10+01000000 85..10+01000000 86..10+00100000 88..10+00001500",
+		"110003+000000BM 21.323+00000000 22.323+09000000 31..00+00100000",
+		"110004+000000P1 21.323+09000000 22.323+09000000 31..00+00050000 87..10+00001500 41....+00000TOP",
+	}, "\n")
+
+	result, err := Import(FormatGSI, strings.NewReader(raw), ImportOptions{ProjectID: "GSI-TEST"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	bm := findPoint(t, result.Data.Points, "BM")
+	if bm.SurveyType != models.SurveyTypeControl {
+		t.Errorf("BM type = %s, expected control", bm.SurveyType)
+	}
+
+	p1 := findPoint(t, result.Data.Points, "P1")
+	if math.Abs(p1.Easting-1050) > 0.001 || math.Abs(p1.Northing-1000) > 0.001 {
+		t.Errorf("P1 = (%.4f, %.4f), expected (1050, 1000)", p1.Easting, p1.Northing)
+	}
+	if p1.Height == nil || math.Abs(*p1.Height-100) > 0.001 {
+		t.Errorf("P1 height = %v, expected 100", p1.Height)
+	}
+	if p1.Code != "TOP" {
+		t.Errorf("P1 code = %q, expected TOP", p1.Code)
+	}
+	if p1.SurveyType != models.SurveyTypeDetail {
+		t.Errorf("P1 type = %s, expected detail", p1.SurveyType)
+	}
+}
+
+func TestImport_RW5ClosedTraverse(t *testing.T) {
+	raw := strings.Join([]string{
+		"MO,AD0,UN1,SF1.00000000,EC1,EO0.0,AU0",
+		"SP,PN1,N 1000.0000,E 1000.0000,EL100.0000,--CP",
+		"LS,HI1.500,HR1.500",
+		"BK,OP1,BP99,BS90.0000,BC0.0000",
+		"TR,OP1,FP2,AR0.0000,ZE90.0000,SD100.0000",
+		"SS,OP1,FP50,AR45.0000,ZE90.0000,SD10.0000,--tree",
+		"BK,OP2,BP1,BC0.0000",
+		"TR,OP2,FP3,AR270.0000,ZE90.0000,SD100.0000",
+		"BK,OP3,BP2,BC0.0000",
+		"TR,OP3,FP4,AR270.0000,ZE90.0000,SD100.0000",
+		"BK,OP4,BP3,BC0.0000",
+		"TR,OP4,FP1,AR270.0000,ZE90.0000,SD100.0100",
+	}, "\n")
+
+	opts := ImportOptions{
+		ProjectID: "RW5-TEST",
+		CodeMap:   map[string]models.SurveyType{"TREE": models.SurveyTypeDetail},
+	}
+	result, err := Import(FormatRW5, strings.NewReader(raw), opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var traverse []models.SurveyPoint
+	for _, p := range result.Data.Points {
+		if p.SurveyType == models.SurveyTypeTraverse {
+			traverse = append(traverse, p)
+		}
+	}
+
+	// 1, 2, 3, 4 and the closing shot back on 1
+	if len(traverse) != 5 {
+		t.Fatalf("expected 5 traverse points, got %d", len(traverse))
+	}
+	if traverse[4].PointID != "1" {
+		t.Errorf("last traverse point = %s, expected closing shot on 1", traverse[4].PointID)
+	}
+	if math.Abs(traverse[2].Easting-1100) > 0.001 || math.Abs(traverse[2].Northing-900) > 0.001 {
+		t.Errorf("station 3 = (%.4f, %.4f), expected (1100, 900)", traverse[2].Easting, traverse[2].Northing)
+	}
+	if math.Abs(traverse[4].Northing-1000.01) > 0.001 {
+		t.Errorf("closing shot northing = %.4f, expected 1000.01", traverse[4].Northing)
+	}
+
+	if len(result.Observations) != 4 {
+		t.Errorf("expected 4 traverse observations, got %d", len(result.Observations))
+	}
+
+	tree := findPoint(t, result.Data.Points, "50")
+	if tree.Code != "tree" || tree.SurveyType != models.SurveyTypeDetail {
+		t.Errorf("sideshot 50 = %+v, expected detail with code tree", tree)
+	}
+}
+
+func TestImport_RW5OpenTraverse(t *testing.T) {
+	raw := strings.Join([]string{
+		"MO,AD0,UN1,SF1.00000000,EC1,EO0.0,AU0",
+		"SP,PN1,N 1000.0000,E 1000.0000,EL100.0000,--CP",
+		"SP,PN2,N 1100.0000,E 1000.0000,EL100.0000,--CP",
+		"BK,OP2,BP1,BC0.0000",
+		"TR,OP2,FP3,AR90.0000,ZE90.0000,SD50.0000",
+	}, "\n")
+
+	result, err := Import(FormatRW5, strings.NewReader(raw), ImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 3 is never occupied, but it is the end of the traverse
+	p3 := findPoint(t, result.Data.Points, "3")
+	if p3.SurveyType != models.SurveyTypeTraverse {
+		t.Errorf("3 type = %s, expected traverse", p3.SurveyType)
+	}
+	if math.Abs(p3.Easting-950) > 0.001 || math.Abs(p3.Northing-1100) > 0.001 {
+		t.Errorf("3 = (%.4f, %.4f), expected (950, 1100)", p3.Easting, p3.Northing)
+	}
+	if len(result.Observations) != 1 || result.Observations[0].TargetID != "3" {
+		t.Errorf("Unexpected observations: %+v", result.Observations)
+	}
+}
+
+func TestReduce_BacksightCircle(t *testing.T) {
+	// the setup says the backsight read 0, the pointing on it read 10
+	fb := &FieldBook{
+		Known: []models.SurveyPoint{
+			{PointID: "1", Easting: 1000, Northing: 1000},
+			{PointID: "2", Easting: 1000, Northing: 1100},
+		},
+		Setups: []Setup{{StationID: "2", BacksightID: "1"}},
+		Observations: []RawObservation{
+			{TargetID: "1", Circle: 10, HorizDistance: 100, Backsight: true},
+			{TargetID: "3", Circle: 100, HorizDistance: 50, Traverse: true},
+		},
+	}
+	result := fb.Reduce(ImportOptions{})
+
+	obs := result.Observations[0]
+	if math.Abs(obs.Angle-90) > 1e-9 {
+		t.Errorf("angle = %.6f, expected 90 from the backsight pointing", obs.Angle)
+	}
+	// backsight due south, 90 degrees right of it is west
+	if math.Abs(obs.Bearing-270) > 1e-9 {
+		t.Errorf("bearing = %.6f, expected 270", obs.Bearing)
+	}
+}
+
+func TestImport_SDR33Feet(t *testing.T) {
+	raw := strings.Join([]string{
+		"00NMSDR33 V04-03.02    01-Jan-00 00:00 121111",
+		fmt.Sprintf("08TP%4s%10s%10s%10s%s", "1", "6561.68", "9842.52", "328.08", "CP"),
+		fmt.Sprintf("08TP%4s%10s%10s%10s%s", "2", "6889.76", "9842.52", "328.08", "CP"),
+		fmt.Sprintf("02TP%4s%10s%10s%10s%10s", "1", "6561.68", "9842.52", "328.08", "4.92"),
+		fmt.Sprintf("03NM%10s", "4.92"),
+		fmt.Sprintf("07TP%4s%4s%10s%10s", "1", "2", "0.0000", "0.0000"),
+		fmt.Sprintf("09F1%4s%4s%10s%10s%10s", "1", "2", "328.0800", "90.0000", "0.0000"),
+		fmt.Sprintf("09F1%4s%4s%10s%10s%10s%s", "1", "100", "82.0210", "90.0000", "180.0000", "FENCE"),
+	}, "\n")
+
+	result, err := Import(FormatSDR33, strings.NewReader(raw), ImportOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	p := findPoint(t, result.Data.Points, "100")
+	if math.Abs(p.Easting-3000) > 0.01 || math.Abs(p.Northing-1975) > 0.01 {
+		t.Errorf("point 100 = (%.3f, %.3f), expected (3000, 1975)", p.Easting, p.Northing)
+	}
+	if p.Code != "FENCE" {
+		t.Errorf("point 100 code = %q, expected FENCE", p.Code)
+	}
+}
+
+func TestImport_UnknownFormat(t *testing.T) {
+	if _, err := Import("xyz", strings.NewReader(""), ImportOptions{}); err == nil {
+		t.Error("expected error for unsupported format")
+	}
+}
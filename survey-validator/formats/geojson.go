@@ -0,0 +1,150 @@
+package formats
+
+// geojson.go - GeoJSON export with issue flags on each point
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+
+	"github.com/survey-validator/models"
+)
+
+type geoJSONCollection struct {
+	Type     string           `json:"type"`
+	CRS      *geoJSONCRS      `json:"crs,omitempty"`
+	Features []geoJSONFeature `json:"features"`
+}
+
+// geoJSONCRS - only written for grids we can't unproject (pre RFC 7946 style)
+type geoJSONCRS struct {
+	Type       string            `json:"type"`
+	Properties map[string]string `json:"properties"`
+}
+
+type geoJSONFeature struct {
+	Type       string                 `json:"type"`
+	ID         string                 `json:"id,omitempty"`
+	Geometry   geoJSONGeometry        `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type geoJSONGeometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+// WriteGeoJSON - one Point feature per survey point, carrying its type, code
+// and any issues, plus a LineString for the traverse. UTM grids are converted
+// to WGS84 lon/lat; anything else stays in grid units with a named crs.
+func WriteGeoJSON(w io.Writer, in ExportInput) error {
+	cs := coordinateSystem(in.Data)
+	geo, geoErr := newGeographic(cs)
+
+	position := func(p models.SurveyPoint) []float64 {
+		var pos []float64
+		if geoErr == nil {
+			lat, lon := geo.latLon(p)
+			pos = []float64{round8(lon), round8(lat)}
+		} else {
+			pos = []float64{p.Easting, p.Northing}
+		}
+		if p.HasHeight() {
+			pos = append(pos, *p.Height)
+		}
+		return pos
+	}
+
+	fc := geoJSONCollection{Type: "FeatureCollection", Features: make([]geoJSONFeature, 0)}
+	if geoErr != nil && cs != "" {
+		fc.CRS = &geoJSONCRS{Type: "name", Properties: map[string]string{"name": cs}}
+	}
+
+	issues := in.pointIssues()
+	adjusted := make(map[string]models.AdjustedPoint)
+	if in.Report != nil && in.Report.TraverseResult != nil {
+		for _, ap := range in.Report.TraverseResult.AdjustedPoints {
+			adjusted[ap.PointID] = ap
+		}
+	}
+
+	for _, p := range in.Data.Points {
+		props := map[string]interface{}{
+			"point_id":    p.PointID,
+			"survey_type": p.SurveyType,
+			"easting":     p.Easting,
+			"northing":    p.Northing,
+			"issue_count": len(issues[p.PointID]),
+			"has_error":   worstSeverity(issues[p.PointID]) == models.SeverityError,
+			"has_warning": worstSeverity(issues[p.PointID]) == models.SeverityWarning,
+		}
+		if p.Code != "" {
+			props["code"] = p.Code
+		}
+		if p.Description != "" {
+			props["description"] = p.Description
+		}
+		if p.HasHeight() {
+			props["height"] = *p.Height
+		}
+		if sev := worstSeverity(issues[p.PointID]); sev != "" {
+			props["severity"] = sev
+			var descs []string
+			for _, issue := range issues[p.PointID] {
+				descs = append(descs, issue.Description)
+			}
+			props["issues"] = descs
+		}
+		if ap, ok := adjusted[p.PointID]; ok {
+			props["adjusted_easting"] = ap.AdjEasting
+			props["adjusted_northing"] = ap.AdjNorthing
+			props["residual_distance"] = ap.ResidualDist
+		}
+
+		fc.Features = append(fc.Features, geoJSONFeature{
+			Type:       "Feature",
+			ID:         p.PointID,
+			Geometry:   geoJSONGeometry{Type: "Point", Coordinates: position(p)},
+			Properties: props,
+		})
+	}
+
+	if trav := in.traversePoints(); len(trav) >= 2 {
+		line := make([][]float64, 0, len(trav))
+		for _, p := range trav {
+			line = append(line, position(p))
+		}
+		props := map[string]interface{}{"feature": "traverse"}
+		if in.Report != nil && in.Report.TraverseResult != nil {
+			props["closure_ratio"] = in.Report.TraverseResult.ClosureRatio
+			props["status"] = in.Report.TraverseResult.Status
+		}
+		fc.Features = append(fc.Features, geoJSONFeature{
+			Type:       "Feature",
+			ID:         "traverse",
+			Geometry:   geoJSONGeometry{Type: "LineString", Coordinates: line},
+			Properties: props,
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(fc)
+}
+
+// coordinateSystem - dataset level name, falling back to the first point's
+func coordinateSystem(data *models.SurveyData) string {
+	if data.CoordinateSystem != "" {
+		return data.CoordinateSystem
+	}
+	for _, p := range data.Points {
+		if p.CoordinateSystem != "" {
+			return p.CoordinateSystem
+		}
+	}
+	return ""
+}
+
+func round8(v float64) float64 {
+	return math.Round(v*1e8) / 1e8
+}
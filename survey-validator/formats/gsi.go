@@ -0,0 +1,305 @@
+package formats
+
+// gsi.go - Leica GSI-8 / GSI-16 raw data
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/survey-validator/models"
+)
+
+// GSI word indices we care about
+const (
+	gsiPointID      = "11"
+	gsiHz           = "21"
+	gsiV            = "22"
+	gsiSlopeDist    = "31"
+	gsiHorizDist    = "32"
+	gsiCode         = "41"
+	gsiRemark       = "71"
+	gsiTargetE      = "81"
+	gsiTargetN      = "82"
+	gsiTargetH      = "83"
+	gsiStationE     = "84"
+	gsiStationN     = "85"
+	gsiStationH     = "86"
+	gsiReflectorHt  = "87"
+	gsiInstrumentHt = "88"
+)
+
+// gsiWord - one decoded data word
+type gsiWord struct {
+	wi   string // word index
+	unit byte   // unit digit (position 6)
+	data string // signed data, leading zeros kept
+}
+
+// ParseGSI - reads GSI-8 and GSI-16 blocks.
+// A block with station coordinates (84/85) starts a setup, the first
+// pointing after it is the backsight. Blocks with target coordinates
+// (81/82) and no measurements are stored points.
+func ParseGSI(r io.Reader, opts ImportOptions) (*FieldBook, error) {
+	fb := &FieldBook{Format: FormatGSI}
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	awaitingBacksight := false
+
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		line = strings.TrimPrefix(line, "*") // GSI-16 block marker
+		if line == "" {
+			continue
+		}
+
+		words := make(map[string]gsiWord)
+		for _, raw := range strings.Fields(line) {
+			w, err := parseGSIWord(raw)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			words[w.wi] = w
+		}
+
+		id, ok := words[gsiPointID]
+		if !ok {
+			fb.Warnings = append(fb.Warnings, fmt.Sprintf("line %d: block without point number skipped", lineNo))
+			continue
+		}
+		pointID := gsiText(id.data)
+		code := gsiText(words[gsiCode].data)
+		if code == "" {
+			code = gsiText(words[gsiRemark].data)
+		}
+
+		_, hasStnE := words[gsiStationE]
+		_, hasHz := words[gsiHz]
+		_, hasTgtE := words[gsiTargetE]
+
+		switch {
+		case hasStnE:
+			e, err1 := gsiDistance(words[gsiStationE], opts)
+			n, err2 := gsiDistance(words[gsiStationN], opts)
+			if err := firstErr(err1, err2); err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			p := models.SurveyPoint{PointID: pointID, Easting: e, Northing: n, Code: code}
+			if w, ok := words[gsiStationH]; ok {
+				h, err := gsiDistance(w, opts)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %w", lineNo, err)
+				}
+				p.Height = &h
+			}
+			fb.Known = appendKnown(fb.Known, p)
+
+			setup := Setup{StationID: pointID}
+			if w, ok := words[gsiInstrumentHt]; ok {
+				hi, err := gsiDistance(w, opts)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %w", lineNo, err)
+				}
+				setup.InstrumentHeight = hi
+			}
+			fb.Setups = append(fb.Setups, setup)
+			awaitingBacksight = true
+
+		case hasHz:
+			if len(fb.Setups) == 0 {
+				fb.Warnings = append(fb.Warnings, fmt.Sprintf("line %d: observation before any setup skipped", lineNo))
+				continue
+			}
+			o, err := gsiObservation(words, opts)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			o.Setup = len(fb.Setups) - 1
+			o.TargetID = pointID
+			o.Code = code
+			if awaitingBacksight {
+				o.Backsight = true
+				fb.Setups[o.Setup].BacksightID = pointID
+				fb.Setups[o.Setup].BacksightCircle = o.Circle
+				awaitingBacksight = false
+			}
+			fb.Observations = append(fb.Observations, o)
+
+		case hasTgtE:
+			e, err1 := gsiDistance(words[gsiTargetE], opts)
+			n, err2 := gsiDistance(words[gsiTargetN], opts)
+			if err := firstErr(err1, err2); err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			p := models.SurveyPoint{PointID: pointID, Easting: e, Northing: n, Code: code}
+			if w, ok := words[gsiTargetH]; ok {
+				h, err := gsiDistance(w, opts)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %w", lineNo, err)
+				}
+				p.Height = &h
+			}
+			fb.Known = appendKnown(fb.Known, p)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return fb, nil
+}
+
+// parseGSIWord - "WWAAAU±DDDDDDDD" (GSI-8) or 16 data digits (GSI-16)
+func parseGSIWord(raw string) (gsiWord, error) {
+	if len(raw) < 8 || (raw[6] != '+' && raw[6] != '-') {
+		return gsiWord{}, fmt.Errorf("malformed GSI word %q", raw)
+	}
+	return gsiWord{
+		wi:   raw[0:2],
+		unit: raw[5],
+		data: raw[6:],
+	}, nil
+}
+
+func gsiObservation(words map[string]gsiWord, opts ImportOptions) (RawObservation, error) {
+	var o RawObservation
+	var err error
+
+	if o.Circle, err = gsiAngle(words[gsiHz], opts); err != nil {
+		return o, err
+	}
+	if w, ok := words[gsiV]; ok {
+		if o.Zenith, err = gsiAngle(w, opts); err != nil {
+			return o, err
+		}
+	}
+	if w, ok := words[gsiSlopeDist]; ok {
+		if o.SlopeDistance, err = gsiDistance(w, opts); err != nil {
+			return o, err
+		}
+	}
+	if w, ok := words[gsiHorizDist]; ok {
+		if o.HorizDistance, err = gsiDistance(w, opts); err != nil {
+			return o, err
+		}
+	}
+	if w, ok := words[gsiReflectorHt]; ok {
+		if o.TargetHeight, err = gsiDistance(w, opts); err != nil {
+			return o, err
+		}
+	}
+	return o, nil
+}
+
+// gsiDistance - unit digit 0/1 = mm or 1/1000 ft, 6/7 = 1/10 mm or 1/10000 ft, 8 = 1/100 mm
+func gsiDistance(w gsiWord, opts ImportOptions) (float64, error) {
+	if w.wi == "" {
+		return 0, fmt.Errorf("missing GSI value")
+	}
+	v, err := strconv.ParseFloat(w.data, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid GSI value %q", w.data)
+	}
+
+	unit := UnitMeter
+	switch w.unit {
+	case '0':
+		v /= 1000
+	case '1':
+		v /= 1000
+		unit = UnitFoot
+	case '6':
+		v /= 10000
+	case '7':
+		v /= 10000
+		unit = UnitFoot
+	case '8':
+		v /= 100000
+	default:
+		v /= 1000
+	}
+
+	if opts.LinearUnit != "" {
+		unit = opts.LinearUnit
+	}
+	return toMeters(v, unit), nil
+}
+
+// gsiAngle - unit digit 2 = gon, 3 = decimal degrees, 4 = dddmmsss, 5 = mil
+func gsiAngle(w gsiWord, opts ImportOptions) (float64, error) {
+	digits := strings.TrimLeft(w.data, "+-")
+	if _, err := strconv.ParseUint(digits, 10, 64); err != nil {
+		return 0, fmt.Errorf("invalid GSI angle %q", w.data)
+	}
+
+	unit := AngleDegrees
+	switch w.unit {
+	case '2':
+		unit = AngleGons
+	case '4':
+		unit = AngleDMS
+	case '5':
+		unit = AngleMils
+	}
+	if opts.AngleUnit != "" {
+		unit = opts.AngleUnit
+	}
+
+	var s string
+	switch unit {
+	case AngleDMS:
+		// dddmmsss - last digit is tenths of a second
+		for len(digits) < 8 {
+			digits = "0" + digits
+		}
+		n := len(digits)
+		s = digits[:n-5] + "." + digits[n-5:]
+	case AngleMils:
+		s = insertPoint(digits, 2)
+	default:
+		s = insertPoint(digits, 5)
+	}
+	return parseAngle(s, unit)
+}
+
+// insertPoint - put an implied decimal point back in
+func insertPoint(digits string, decimals int) string {
+	for len(digits) <= decimals {
+		digits = "0" + digits
+	}
+	n := len(digits)
+	return digits[:n-decimals] + "." + digits[n-decimals:]
+}
+
+// gsiText - text words are right aligned and zero padded
+func gsiText(data string) string {
+	s := strings.TrimLeft(data, "+-")
+	s = strings.TrimLeft(s, "0")
+	if s == "" && data != "" {
+		return "0"
+	}
+	return s
+}
+
+// appendKnown - later coordinates for the same point win
+func appendKnown(known []models.SurveyPoint, p models.SurveyPoint) []models.SurveyPoint {
+	for i := range known {
+		if known[i].PointID == p.PointID {
+			known[i] = p
+			return known
+		}
+	}
+	return append(known, p)
+}
+
+func firstErr(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package formats
+
+// kml.go - KML export for a quick look in Google Earth
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/survey-validator/models"
+)
+
+type kmlDocument struct {
+	XMLName   xml.Name `xml:"kml"`
+	Namespace string   `xml:"xmlns,attr"`
+	Document  kmlBody  `xml:"Document"`
+}
+
+type kmlBody struct {
+	Name    string      `xml:"name"`
+	Styles  []kmlStyle  `xml:"Style"`
+	Folders []kmlFolder `xml:"Folder"`
+}
+
+type kmlStyle struct {
+	ID        string         `xml:"id,attr"`
+	IconStyle *kmlIconStyle  `xml:"IconStyle,omitempty"`
+	LineStyle *kmlLineStyle  `xml:"LineStyle,omitempty"`
+	Label     *kmlLabelStyle `xml:"LabelStyle,omitempty"`
+}
+
+type kmlIconStyle struct {
+	Color string  `xml:"color"` // aabbggrr
+	Scale float64 `xml:"scale"`
+	Icon  string  `xml:"Icon>href"`
+}
+
+type kmlLineStyle struct {
+	Color string  `xml:"color"`
+	Width float64 `xml:"width"`
+}
+
+type kmlLabelStyle struct {
+	Scale float64 `xml:"scale"`
+}
+
+type kmlFolder struct {
+	Name       string         `xml:"name"`
+	Placemarks []kmlPlacemark `xml:"Placemark"`
+}
+
+type kmlPlacemark struct {
+	Name        string         `xml:"name"`
+	Description string         `xml:"description,omitempty"`
+	StyleURL    string         `xml:"styleUrl"`
+	Point       *kmlCoords     `xml:"Point,omitempty"`
+	LineString  *kmlLineString `xml:"LineString,omitempty"`
+}
+
+type kmlCoords struct {
+	Coordinates string `xml:"coordinates"`
+}
+
+type kmlLineString struct {
+	Tessellate  int    `xml:"tessellate"`
+	Coordinates string `xml:"coordinates"`
+}
+
+const kmlIcon = "http://maps.google.com/mapfiles/kml/shapes/placemark_circle.png"
+
+// WriteKML - placemarks grouped into folders by survey type, flagged points
+// styled by their worst issue, and the traverse as a line. KML is always
+// WGS84 so the data has to be on a UTM grid we can convert.
+func WriteKML(w io.Writer, in ExportInput) error {
+	geo, err := newGeographic(coordinateSystem(in.Data))
+	if err != nil {
+		return err
+	}
+
+	coord := func(p models.SurveyPoint) string {
+		lat, lon := geo.latLon(p)
+		return fmt.Sprintf("%.8f,%.8f", lon, lat)
+	}
+
+	doc := kmlDocument{
+		Namespace: "http://www.opengis.net/kml/2.2",
+		Document: kmlBody{
+			Name: in.Data.ProjectID,
+			Styles: []kmlStyle{
+				pointStyle("control", "ff00c800"),
+				pointStyle("traverse", "ffeb6325"),
+				pointStyle("detail", "ff8b7464"),
+				pointStyle("warning", "ff0b9ef5"),
+				pointStyle("error", "ff2626dc"),
+				{ID: "traverse_line", LineStyle: &kmlLineStyle{Color: "ffeb6325", Width: 2}},
+			},
+		},
+	}
+
+	issues := in.pointIssues()
+	folders := map[models.SurveyType]*kmlFolder{
+		models.SurveyTypeControl:  {Name: "Control"},
+		models.SurveyTypeTraverse: {Name: "Traverse"},
+		models.SurveyTypeDetail:   {Name: "Detail"},
+	}
+	flagged := &kmlFolder{Name: "Issues"}
+
+	for _, p := range in.Data.Points {
+		pm := kmlPlacemark{
+			Name:        p.PointID,
+			Description: kmlDescription(p, issues[p.PointID]),
+			StyleURL:    "#detail",
+			Point:       &kmlCoords{Coordinates: coord(p)},
+		}
+		if p.SurveyType == models.SurveyTypeControl || p.SurveyType == models.SurveyTypeTraverse {
+			pm.StyleURL = "#" + string(p.SurveyType)
+		}
+
+		switch worstSeverity(issues[p.PointID]) {
+		case models.SeverityError:
+			pm.StyleURL = "#error"
+			flagged.Placemarks = append(flagged.Placemarks, pm)
+			continue
+		case models.SeverityWarning:
+			pm.StyleURL = "#warning"
+			flagged.Placemarks = append(flagged.Placemarks, pm)
+			continue
+		}
+
+		folder, ok := folders[p.SurveyType]
+		if !ok {
+			folder = folders[models.SurveyTypeDetail]
+		}
+		folder.Placemarks = append(folder.Placemarks, pm)
+	}
+
+	if trav := in.traversePoints(); len(trav) >= 2 {
+		coords := make([]string, 0, len(trav))
+		for _, p := range trav {
+			coords = append(coords, coord(p))
+		}
+		folders[models.SurveyTypeTraverse].Placemarks = append(folders[models.SurveyTypeTraverse].Placemarks, kmlPlacemark{
+			Name:       "Traverse",
+			StyleURL:   "#traverse_line",
+			LineString: &kmlLineString{Tessellate: 1, Coordinates: strings.Join(coords, " ")},
+		})
+	}
+
+	for _, t := range []models.SurveyType{models.SurveyTypeControl, models.SurveyTypeTraverse, models.SurveyTypeDetail} {
+		if f := folders[t]; len(f.Placemarks) > 0 {
+			doc.Document.Folders = append(doc.Document.Folders, *f)
+		}
+	}
+	if len(flagged.Placemarks) > 0 {
+		doc.Document.Folders = append(doc.Document.Folders, *flagged)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
+
+func pointStyle(id, color string) kmlStyle {
+	return kmlStyle{
+		ID:        id,
+		IconStyle: &kmlIconStyle{Color: color, Scale: 0.8, Icon: kmlIcon},
+		Label:     &kmlLabelStyle{Scale: 0.7},
+	}
+}
+
+// kmlDescription - grid coordinates and issue list for the balloon
+func kmlDescription(p models.SurveyPoint, issues []models.ValidationIssue) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Type: %s\nE: %.3f\nN: %.3f", p.SurveyType, p.Easting, p.Northing)
+	if p.HasHeight() {
+		fmt.Fprintf(&b, "\nH: %.3f", *p.Height)
+	}
+	if p.Code != "" {
+		fmt.Fprintf(&b, "\nCode: %s", p.Code)
+	}
+	if p.Description != "" {
+		fmt.Fprintf(&b, "\nDescription: %s", p.Description)
+	}
+	for _, issue := range issues {
+		fmt.Fprintf(&b, "\n[%s] %s", issue.Severity, issue.Description)
+	}
+	return b.String()
+}
@@ -0,0 +1,589 @@
+package formats
+
+// landxml.go - LandXML 1.2 import and export
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/survey-validator/models"
+)
+
+const (
+	FormatLandXML Format = "landxml"
+
+	landXMLNamespace = "http://www.landxml.org/schema/LandXML-1.2"
+)
+
+// LandXML document, only the parts we read or write
+type lxDocument struct {
+	XMLName          xml.Name     `xml:"LandXML"`
+	Namespace        string       `xml:"xmlns,attr,omitempty"`
+	Version          string       `xml:"version,attr,omitempty"`
+	Date             string       `xml:"date,attr,omitempty"`
+	Time             string       `xml:"time,attr,omitempty"`
+	Units            *lxUnits     `xml:"Units,omitempty"`
+	CoordinateSystem *lxCoordSys  `xml:"CoordinateSystem,omitempty"`
+	Project          *lxProject   `xml:"Project,omitempty"`
+	Application      *lxApp       `xml:"Application,omitempty"`
+	CgPoints         []lxCgPoints `xml:"CgPoints"`
+	Surveys          []lxSurvey   `xml:"Survey"`
+}
+
+type lxUnits struct {
+	Metric   *lxUnitSet `xml:"Metric,omitempty"`
+	Imperial *lxUnitSet `xml:"Imperial,omitempty"`
+}
+
+type lxUnitSet struct {
+	LinearUnit    string `xml:"linearUnit,attr"`
+	AreaUnit      string `xml:"areaUnit,attr,omitempty"`
+	VolumeUnit    string `xml:"volumeUnit,attr,omitempty"`
+	AngularUnit   string `xml:"angularUnit,attr,omitempty"`
+	DirectionUnit string `xml:"directionUnit,attr,omitempty"`
+}
+
+type lxCoordSys struct {
+	Name     string `xml:"name,attr,omitempty"`
+	EPSGCode string `xml:"epsgCode,attr,omitempty"`
+	Desc     string `xml:"desc,attr,omitempty"`
+}
+
+type lxProject struct {
+	Name string `xml:"name,attr"`
+	Desc string `xml:"desc,attr,omitempty"`
+}
+
+type lxApp struct {
+	Name    string `xml:"name,attr"`
+	Version string `xml:"version,attr,omitempty"`
+}
+
+type lxCgPoints struct {
+	Name   string      `xml:"name,attr,omitempty"`
+	Points []lxCgPoint `xml:"CgPoint"`
+}
+
+type lxCgPoint struct {
+	Name    string `xml:"name,attr"`
+	Code    string `xml:"code,attr,omitempty"`
+	Desc    string `xml:"desc,attr,omitempty"`
+	PntSurv string `xml:"pntSurv,attr,omitempty"`
+	Coords  string `xml:",chardata"` // "northing easting [elevation]"
+}
+
+type lxSurvey struct {
+	Setups       []lxInstrumentSetup `xml:"InstrumentSetup"`
+	Groups       []lxObsGroup        `xml:"ObservationGroup"`
+	Observations []lxRawObservation  `xml:"RawObservation"`
+	Traverses    []lxTraverse        `xml:"Traverse"`
+	Features     []lxFeature         `xml:"Feature,omitempty"`
+}
+
+type lxInstrumentSetup struct {
+	ID               string             `xml:"id,attr"`
+	StationName      string             `xml:"stationName,attr"`
+	InstrumentHeight float64            `xml:"instrumentHeight,attr"`
+	Point            lxPointRef         `xml:"InstrumentPoint"`
+	Backsight        *lxBacksight       `xml:"Backsight"`
+	Observations     []lxRawObservation `xml:"RawObservation"`
+}
+
+type lxBacksight struct {
+	Azimuth string     `xml:"azimuth,attr"`
+	Circle  string     `xml:"circle,attr"`
+	Point   lxPointRef `xml:"BacksightPoint"`
+}
+
+type lxObsGroup struct {
+	Observations []lxRawObservation `xml:"RawObservation"`
+}
+
+type lxRawObservation struct {
+	SetupID       string     `xml:"setupID,attr"`
+	Purpose       string     `xml:"purpose,attr"`
+	TargetHeight  float64    `xml:"targetHeight,attr"`
+	HorizAngle    string     `xml:"horizAngle,attr"`
+	Azimuth       string     `xml:"azimuth,attr"`
+	ZenithAngle   string     `xml:"zenithAngle,attr"`
+	SlopeDistance float64    `xml:"slopeDistance,attr"`
+	HorizDistance float64    `xml:"horizDistance,attr"`
+	Target        lxPointRef `xml:"TargetPoint"`
+}
+
+type lxPointRef struct {
+	PntRef string `xml:"pntRef,attr,omitempty"`
+	Name   string `xml:"name,attr,omitempty"`
+	Code   string `xml:"code,attr,omitempty"`
+	Desc   string `xml:"desc,attr,omitempty"`
+	Coords string `xml:",chardata"`
+}
+
+type lxTraverse struct {
+	Name   string       `xml:"name,attr,omitempty"`
+	Points []lxPointRef `xml:"TraversePoint"`
+}
+
+type lxFeature struct {
+	Name       string       `xml:"name,attr,omitempty"`
+	Code       string       `xml:"code,attr,omitempty"`
+	Properties []lxProperty `xml:"Property"`
+}
+
+type lxProperty struct {
+	Label string `xml:"label,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// ParseLandXML - reads CgPoints, Survey/InstrumentSetup/RawObservation and
+// Traverse elements. Observations are reduced like any other raw job, with
+// CgPoints as the stored coordinates. A Traverse lists its stations in order
+// by pntRef; listing the first station again closes the loop.
+func ParseLandXML(r io.Reader, opts ImportOptions) (*ImportResult, error) {
+	var doc lxDocument
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid LandXML: %w", err)
+	}
+
+	lin, ang := UnitMeter, lxAngles{angle: AngleDegrees, direction: AngleDegrees}
+	if doc.Units != nil {
+		var err error
+		if lin, ang, err = doc.Units.units(); err != nil {
+			return nil, fmt.Errorf("invalid LandXML: %w", err)
+		}
+	}
+	if opts.LinearUnit != "" {
+		lin = opts.LinearUnit
+	}
+	if opts.AngleUnit != "" {
+		ang = lxAngles{angle: opts.AngleUnit, direction: opts.AngleUnit}
+	}
+
+	if opts.ProjectID == "" && doc.Project != nil {
+		opts.ProjectID = doc.Project.Name
+	}
+	if opts.CoordinateSystem == "" && doc.CoordinateSystem != nil {
+		opts.CoordinateSystem = doc.CoordinateSystem.Name
+		if opts.CoordinateSystem == "" && doc.CoordinateSystem.EPSGCode != "" {
+			opts.CoordinateSystem = "EPSG:" + doc.CoordinateSystem.EPSGCode
+		}
+	}
+
+	fb := &FieldBook{Format: FormatLandXML}
+	for _, group := range doc.CgPoints {
+		for _, cg := range group.Points {
+			p, err := cgPointToSurvey(cg, lin)
+			if err != nil {
+				return nil, err
+			}
+			fb.Known = appendKnown(fb.Known, p)
+		}
+	}
+
+	var traverses []lxTraverse
+	for _, survey := range doc.Surveys {
+		if err := survey.addTo(fb, lin, ang); err != nil {
+			return nil, err
+		}
+		traverses = append(traverses, survey.Traverses...)
+	}
+
+	result := fb.Reduce(opts)
+	result.Format = FormatLandXML
+	for _, trav := range traverses {
+		applyTraverse(result, trav)
+	}
+	return result, nil
+}
+
+// addTo - pour one Survey element into the field book
+func (s lxSurvey) addTo(fb *FieldBook, lin LinearUnit, ang lxAngles) error {
+	setupIndex := make(map[string]int)
+
+	for _, is := range s.Setups {
+		station := is.StationName
+		if station == "" {
+			station = is.Point.ref()
+		}
+		if is.Point.Coords != "" {
+			p, err := parseLXCoords(station, is.Point.Coords, lin)
+			if err != nil {
+				return err
+			}
+			p.Code = is.Point.Code
+			fb.Known = appendKnown(fb.Known, p)
+		}
+
+		setup := Setup{StationID: station, InstrumentHeight: toMeters(is.InstrumentHeight, lin)}
+		if bs := is.Backsight; bs != nil {
+			setup.BacksightID = bs.Point.ref()
+			if bs.Azimuth != "" {
+				az, err := parseAngle(bs.Azimuth, ang.direction)
+				if err != nil {
+					return fmt.Errorf("setup %s: invalid backsight azimuth %q", is.ID, bs.Azimuth)
+				}
+				setup.BacksightAzimuth, setup.HasBacksightAzimuth = az, true
+			}
+			if bs.Circle != "" {
+				c, err := parseAngle(bs.Circle, ang.angle)
+				if err != nil {
+					return fmt.Errorf("setup %s: invalid backsight circle %q", is.ID, bs.Circle)
+				}
+				setup.BacksightCircle = c
+			}
+		}
+
+		fb.Setups = append(fb.Setups, setup)
+		setupIndex[is.ID] = len(fb.Setups) - 1
+
+		for _, o := range is.Observations {
+			if o.SetupID == "" {
+				o.SetupID = is.ID
+			}
+			if err := o.addTo(fb, setupIndex, lin, ang); err != nil {
+				return err
+			}
+		}
+	}
+
+	observations := append([]lxRawObservation(nil), s.Observations...)
+	for _, g := range s.Groups {
+		observations = append(observations, g.Observations...)
+	}
+	for _, o := range observations {
+		if err := o.addTo(fb, setupIndex, lin, ang); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o lxRawObservation) addTo(fb *FieldBook, setupIndex map[string]int, lin LinearUnit, ang lxAngles) error {
+	si, ok := setupIndex[o.SetupID]
+	if !ok {
+		fb.Warnings = append(fb.Warnings, fmt.Sprintf("RawObservation references unknown setup %q", o.SetupID))
+		return nil
+	}
+
+	raw := RawObservation{
+		Setup:         si,
+		TargetID:      o.Target.ref(),
+		TargetHeight:  toMeters(o.TargetHeight, lin),
+		SlopeDistance: toMeters(o.SlopeDistance, lin),
+		HorizDistance: toMeters(o.HorizDistance, lin),
+		Code:          o.Target.Code,
+		Backsight:     strings.EqualFold(o.Purpose, "backsight"),
+		Traverse:      strings.EqualFold(o.Purpose, "traverse"),
+	}
+
+	var err error
+	if o.HorizAngle != "" {
+		if raw.Circle, err = parseAngle(o.HorizAngle, ang.angle); err != nil {
+			return fmt.Errorf("observation to %s: invalid horizAngle %q", raw.TargetID, o.HorizAngle)
+		}
+	} else if o.Azimuth != "" {
+		if raw.Azimuth, err = parseAngle(o.Azimuth, ang.direction); err != nil {
+			return fmt.Errorf("observation to %s: invalid azimuth %q", raw.TargetID, o.Azimuth)
+		}
+		raw.HasAzimuth = true
+	}
+	if o.ZenithAngle != "" {
+		if raw.Zenith, err = parseAngle(o.ZenithAngle, ang.angle); err != nil {
+			return fmt.Errorf("observation to %s: invalid zenithAngle %q", raw.TargetID, o.ZenithAngle)
+		}
+	}
+
+	if raw.Backsight {
+		if fb.Setups[si].BacksightID == "" {
+			fb.Setups[si].BacksightID = raw.TargetID
+		}
+		fb.Setups[si].BacksightCircle = raw.Circle
+	}
+	fb.Observations = append(fb.Observations, raw)
+	return nil
+}
+
+// applyTraverse - mark and order the points a Traverse element lists
+func applyTraverse(result *ImportResult, trav lxTraverse) {
+	if len(trav.Points) == 0 {
+		return
+	}
+
+	byID := make(map[string]models.SurveyPoint)
+	for _, p := range result.Data.Points {
+		if _, seen := byID[p.PointID]; !seen {
+			byID[p.PointID] = p
+		}
+	}
+
+	listed := make(map[string]bool)
+	var ordered []models.SurveyPoint
+	for _, ref := range trav.Points {
+		id := ref.ref()
+		p, ok := byID[id]
+		if !ok {
+			result.Warnings = append(result.Warnings,
+				fmt.Sprintf("Traverse %s references unknown point %s", trav.Name, id))
+			continue
+		}
+		p.SurveyType = models.SurveyTypeTraverse
+		ordered = append(ordered, p)
+		listed[id] = true
+	}
+
+	// everything else keeps its place, listed stations go to the end in traverse order
+	rest := make([]models.SurveyPoint, 0, len(result.Data.Points))
+	for _, p := range result.Data.Points {
+		if !listed[p.PointID] {
+			rest = append(rest, p)
+		}
+	}
+	result.Data.Points = append(rest, ordered...)
+}
+
+func (r lxPointRef) ref() string {
+	if r.PntRef != "" {
+		return r.PntRef
+	}
+	return r.Name
+}
+
+// lxAngles - LandXML gives angles (circle readings, zenith angles) and
+// directions (azimuths) their own units
+type lxAngles struct {
+	angle, direction AngleUnit
+}
+
+// the LandXML 1.2 unit names
+var (
+	lxMetricLinear = map[string]LinearUnit{
+		"meter": UnitMeter, "millimeter": UnitMillimeter, "centimeter": UnitCentimeter, "kilometer": UnitKilometer,
+	}
+	lxImperialLinear = map[string]LinearUnit{
+		"foot": UnitFoot, "internationalFoot": UnitFoot, "USSurveyFoot": UnitUSFoot, "inch": UnitInch, "mile": UnitMile,
+	}
+	lxAngular = map[string]AngleUnit{
+		"radians": AngleRadians, "grads": AngleGons, "decimal degrees": AngleDegrees, "decimal dd.mm.ss": AngleDMS,
+		"grad": AngleGons, "gon": AngleGons, // seen in the wild
+	}
+)
+
+// units - the declared units. A unit we don't know is an error rather than
+// a guess. The schema's default angle unit is radians, but files that leave
+// it out are written in degrees, so that is what we assume; a missing
+// directionUnit follows angularUnit.
+func (u *lxUnits) units() (LinearUnit, lxAngles, error) {
+	set, linear, lin := u.Metric, lxMetricLinear, UnitMeter
+	if u.Imperial != nil {
+		set, linear, lin = u.Imperial, lxImperialLinear, UnitUSFoot
+	}
+	ang := lxAngles{angle: AngleDegrees, direction: AngleDegrees}
+	if set == nil {
+		return lin, ang, nil
+	}
+
+	if set.LinearUnit != "" {
+		l, ok := linear[set.LinearUnit]
+		if !ok {
+			return "", ang, fmt.Errorf("unsupported linearUnit %q", set.LinearUnit)
+		}
+		lin = l
+	}
+	if set.AngularUnit != "" {
+		a, ok := lxAngular[set.AngularUnit]
+		if !ok {
+			return "", ang, fmt.Errorf("unsupported angularUnit %q", set.AngularUnit)
+		}
+		ang = lxAngles{angle: a, direction: a}
+	}
+	if set.DirectionUnit != "" {
+		d, ok := lxAngular[set.DirectionUnit]
+		if !ok {
+			return "", ang, fmt.Errorf("unsupported directionUnit %q", set.DirectionUnit)
+		}
+		ang.direction = d
+	}
+	return lin, ang, nil
+}
+
+func cgPointToSurvey(cg lxCgPoint, lin LinearUnit) (models.SurveyPoint, error) {
+	p, err := parseLXCoords(cg.Name, cg.Coords, lin)
+	if err != nil {
+		return p, err
+	}
+	p.Code, p.Description = cg.Code, cg.Desc
+	if p.Code == "" {
+		p.Code = cg.Desc
+	}
+
+	switch strings.ToLower(cg.PntSurv) {
+	case "control", "monument":
+		p.SurveyType = models.SurveyTypeControl
+	case "traverse":
+		p.SurveyType = models.SurveyTypeTraverse
+	case "sideshot", "topo":
+		p.SurveyType = models.SurveyTypeDetail
+	}
+	return p, nil
+}
+
+// parseLXCoords - LandXML coordinates are "northing easting [elevation]"
+func parseLXCoords(id, text string, lin LinearUnit) (models.SurveyPoint, error) {
+	p := models.SurveyPoint{PointID: id}
+	fields := strings.Fields(text)
+	if len(fields) < 2 {
+		return p, fmt.Errorf("point %s: expected \"northing easting [elevation]\", got %q", id, text)
+	}
+
+	vals := make([]float64, len(fields))
+	for i, f := range fields {
+		v, err := strconv.ParseFloat(f, 64)
+		if err != nil {
+			return p, fmt.Errorf("point %s: invalid coordinate %q", id, f)
+		}
+		vals[i] = toMeters(v, lin)
+	}
+
+	p.Northing, p.Easting = vals[0], vals[1]
+	if len(vals) > 2 {
+		h := vals[2]
+		p.Height = &h
+	}
+	return p, nil
+}
+
+// LandXMLExport - what goes into an exported document
+type LandXMLExport struct {
+	Data     *models.SurveyData
+	Traverse *models.TraverseResult // adjusted coordinates win over raw ones
+	Leveling *models.LevelingResult // adjusted RLs win over raw heights
+}
+
+// WriteLandXML - write points as CgPoints, with adjusted coordinates and
+// heights where we have them. Codes and the coordinate system carry over;
+// the traverse order and level run summary go into a Survey element.
+func WriteLandXML(w io.Writer, exp LandXMLExport) error {
+	if exp.Data == nil {
+		return fmt.Errorf("no survey data to export")
+	}
+
+	now := time.Now()
+	doc := lxDocument{
+		Namespace: landXMLNamespace,
+		Version:   "1.2",
+		Date:      now.Format("2006-01-02"),
+		Time:      now.Format("15:04:05"),
+		Units: &lxUnits{Metric: &lxUnitSet{
+			LinearUnit:    "meter",
+			AreaUnit:      "squareMeter",
+			VolumeUnit:    "cubicMeter",
+			AngularUnit:   "decimal degrees",
+			DirectionUnit: "decimal degrees",
+		}},
+		Application: &lxApp{Name: "survey-validator"},
+	}
+	if exp.Data.CoordinateSystem != "" {
+		doc.CoordinateSystem = &lxCoordSys{Name: exp.Data.CoordinateSystem}
+	}
+	if exp.Data.ProjectID != "" {
+		doc.Project = &lxProject{Name: exp.Data.ProjectID}
+	}
+
+	adjusted := make(map[string]models.AdjustedPoint)
+	if exp.Traverse != nil {
+		for _, ap := range exp.Traverse.AdjustedPoints {
+			adjusted[ap.PointID] = ap
+		}
+	}
+	levels := make(map[string]float64)
+	if exp.Leveling != nil {
+		for _, lp := range exp.Leveling.Points {
+			levels[lp.PointID] = lp.AdjustedRL
+		}
+	}
+
+	group := lxCgPoints{Name: exp.Data.ProjectID}
+	seen := make(map[string]bool)
+	for _, p := range exp.Data.Points {
+		// closed traverses repeat the start point, one CgPoint per name
+		if seen[p.PointID] {
+			continue
+		}
+		seen[p.PointID] = true
+
+		e, n := p.Easting, p.Northing
+		if ap, ok := adjusted[p.PointID]; ok {
+			e, n = ap.AdjEasting, ap.AdjNorthing
+		}
+		coords := fmt.Sprintf("%.4f %.4f", n, e)
+		if rl, ok := levels[p.PointID]; ok {
+			coords += fmt.Sprintf(" %.4f", rl)
+		} else if p.HasHeight() {
+			coords += fmt.Sprintf(" %.4f", *p.Height)
+		}
+
+		group.Points = append(group.Points, lxCgPoint{
+			Name:    p.PointID,
+			Code:    p.Code,
+			Desc:    p.Description,
+			PntSurv: pntSurv(p.SurveyType),
+			Coords:  coords,
+		})
+	}
+	doc.CgPoints = []lxCgPoints{group}
+
+	var survey lxSurvey
+	if exp.Traverse != nil && len(exp.Traverse.AdjustedPoints) > 0 {
+		trav := lxTraverse{Name: exp.Traverse.TraverseType}
+		for _, ap := range exp.Traverse.AdjustedPoints {
+			trav.Points = append(trav.Points, lxPointRef{PntRef: ap.PointID})
+		}
+		if exp.Traverse.TraverseType == "closed" {
+			trav.Points = append(trav.Points, trav.Points[0])
+		}
+		survey.Traverses = append(survey.Traverses, trav)
+	}
+	if lv := exp.Leveling; lv != nil {
+		f := lxFeature{Name: "leveling", Code: "LevelRun", Properties: []lxProperty{
+			{"startBM", lv.StartBM},
+			{"endBM", lv.EndBM},
+			{"heightMisclosure", fmt.Sprintf("%.4f", lv.HeightMisclosure)},
+			{"allowableMisclosure", fmt.Sprintf("%.4f", lv.AllowableMisc)},
+			{"status", lv.Status},
+		}}
+		for _, lp := range lv.Points {
+			f.Properties = append(f.Properties, lxProperty{"RL:" + lp.PointID, fmt.Sprintf("%.4f", lp.AdjustedRL)})
+		}
+		survey.Features = append(survey.Features, f)
+	}
+	if len(survey.Traverses) > 0 || len(survey.Features) > 0 {
+		doc.Surveys = []lxSurvey{survey}
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func pntSurv(t models.SurveyType) string {
+	switch t {
+	case models.SurveyTypeControl:
+		return "control"
+	case models.SurveyTypeTraverse:
+		return "traverse"
+	case models.SurveyTypeDetail:
+		return "sideshot"
+	}
+	return ""
+}
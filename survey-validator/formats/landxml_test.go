@@ -0,0 +1,168 @@
+package formats
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/survey-validator/models"
+)
+
+const sampleLandXML = `<?xml version="1.0"?>
+<LandXML xmlns="http://www.landxml.org/schema/LandXML-1.2" version="1.2">
+  <Units><Metric linearUnit="meter" angularUnit="decimal degrees"/></Units>
+  <CoordinateSystem name="UTM Zone 36N" epsgCode="32636"/>
+  <Project name="LX-001"/>
+  <CgPoints>
+    <CgPoint name="CP1" code="CP" pntSurv="control">6000000.000 500000.000 100.000</CgPoint>
+    <CgPoint name="A">1000.000 1000.000</CgPoint>
+    <CgPoint name="B">1000.002 1100.005</CgPoint>
+    <CgPoint name="C">1100.004 1100.008</CgPoint>
+  </CgPoints>
+  <Survey>
+    <InstrumentSetup id="IS1" stationName="A" instrumentHeight="1.5">
+      <InstrumentPoint pntRef="A"/>
+      <Backsight azimuth="90"><BacksightPoint pntRef="B"/></Backsight>
+    </InstrumentSetup>
+    <ObservationGroup>
+      <RawObservation setupID="IS1" purpose="backsight" horizAngle="0" zenithAngle="90" slopeDistance="100">
+        <TargetPoint pntRef="B"/>
+      </RawObservation>
+      <RawObservation setupID="IS1" horizAngle="180" zenithAngle="90" slopeDistance="20">
+        <TargetPoint pntRef="S1" code="TREE"/>
+      </RawObservation>
+    </ObservationGroup>
+    <Traverse name="main">
+      <TraversePoint pntRef="A"/>
+      <TraversePoint pntRef="B"/>
+      <TraversePoint pntRef="C"/>
+      <TraversePoint pntRef="A"/>
+    </Traverse>
+  </Survey>
+</LandXML>`
+
+func TestParseLandXML(t *testing.T) {
+	result, err := Import(FormatLandXML, strings.NewReader(sampleLandXML), ImportOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if result.Data.ProjectID != "LX-001" {
+		t.Errorf("ProjectID = %q, expected LX-001", result.Data.ProjectID)
+	}
+	if result.Data.CoordinateSystem != "UTM Zone 36N" {
+		t.Errorf("CoordinateSystem = %q, expected UTM Zone 36N", result.Data.CoordinateSystem)
+	}
+
+	cp := findPoint(t, result.Data.Points, "CP1")
+	if cp.SurveyType != models.SurveyTypeControl || cp.Code != "CP" {
+		t.Errorf("CP1 = %+v, expected control with code CP", cp)
+	}
+	if cp.Easting != 500000 || cp.Northing != 6000000 {
+		t.Errorf("CP1 coords = (%.3f, %.3f), northing/easting swapped?", cp.Easting, cp.Northing)
+	}
+
+	// sideshot at azimuth 270 (circle 180 + orientation 90) from A
+	s1 := findPoint(t, result.Data.Points, "S1")
+	if s1.Easting < 979.99 || s1.Easting > 980.01 || s1.Code != "TREE" {
+		t.Errorf("S1 = %+v, expected easting 980 with code TREE", s1)
+	}
+
+	var traverse []string
+	for _, p := range result.Data.Points {
+		if p.SurveyType == models.SurveyTypeTraverse {
+			traverse = append(traverse, p.PointID)
+		}
+	}
+	if strings.Join(traverse, ",") != "A,B,C,A" {
+		t.Errorf("traverse order = %v, expected A,B,C,A", traverse)
+	}
+}
+
+func TestWriteLandXML_RoundTrip(t *testing.T) {
+	height := 100.0
+	data := &models.SurveyData{
+		ProjectID:        "RT-001",
+		CoordinateSystem: "Local Grid",
+		Points: []models.SurveyPoint{
+			{PointID: "CP1", Easting: 5000, Northing: 5000, Height: &height, SurveyType: models.SurveyTypeControl, Code: "CP"},
+			{PointID: "T1", Easting: 5100, Northing: 5000, SurveyType: models.SurveyTypeTraverse},
+		},
+	}
+	trav := &models.TraverseResult{
+		TraverseType:   "open",
+		AdjustedPoints: []models.AdjustedPoint{{PointID: "T1", AdjEasting: 5100.01, AdjNorthing: 4999.99}},
+	}
+	lev := &models.LevelingResult{
+		StartBM: "CP1",
+		Status:  "PASS",
+		Points:  []models.LevelingPoint{{PointID: "CP1", AdjustedRL: 100.123}},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteLandXML(&buf, LandXMLExport{Data: data, Traverse: trav, Leveling: lev}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	result, err := ParseLandXML(&buf, ImportOptions{})
+	if err != nil {
+		t.Fatalf("exported document does not parse: %v", err)
+	}
+	if result.Data.CoordinateSystem != "Local Grid" {
+		t.Errorf("CoordinateSystem = %q, expected Local Grid", result.Data.CoordinateSystem)
+	}
+
+	cp := findPoint(t, result.Data.Points, "CP1")
+	if cp.Code != "CP" || cp.Height == nil || *cp.Height != 100.123 {
+		t.Errorf("CP1 = %+v, expected code CP and adjusted RL 100.123", cp)
+	}
+	t1 := findPoint(t, result.Data.Points, "T1")
+	if t1.Easting != 5100.01 || t1.Northing != 4999.99 {
+		t.Errorf("T1 = (%.3f, %.3f), expected adjusted (5100.01, 4999.99)", t1.Easting, t1.Northing)
+	}
+}
+
+func TestParseLandXML_Units(t *testing.T) {
+	// the sample again in millimetres, radians for angles, degrees for directions
+	doc := `<LandXML version="1.2">
+  <Units><Metric linearUnit="millimeter" angularUnit="radians" directionUnit="decimal degrees"/></Units>
+  <CgPoints>
+    <CgPoint name="A">1000000 1000000 100000</CgPoint>
+    <CgPoint name="B">1000000 1100000</CgPoint>
+  </CgPoints>
+  <Survey>
+    <InstrumentSetup id="IS1" stationName="A" instrumentHeight="1500">
+      <InstrumentPoint pntRef="A"/>
+      <Backsight azimuth="90"><BacksightPoint pntRef="B"/></Backsight>
+      <RawObservation horizAngle="3.141592653589793" zenithAngle="1.5707963267948966" slopeDistance="20000" targetHeight="1500">
+        <TargetPoint pntRef="S1"/>
+      </RawObservation>
+    </InstrumentSetup>
+  </Survey>
+</LandXML>`
+	result, err := ParseLandXML(strings.NewReader(doc), ImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := findPoint(t, result.Data.Points, "A")
+	if a.Easting != 1000 || a.Northing != 1000 || *a.Height != 100 {
+		t.Errorf("A = %+v, expected (1000, 1000, 100) in metres", a)
+	}
+	s1 := findPoint(t, result.Data.Points, "S1")
+	if math.Abs(s1.Easting-980) > 0.001 || math.Abs(s1.Northing-1000) > 0.001 || math.Abs(*s1.Height-100) > 0.001 {
+		t.Errorf("S1 = (%.4f, %.4f, %.4f), expected (980, 1000, 100)", s1.Easting, s1.Northing, *s1.Height)
+	}
+
+	for _, units := range []string{
+		`<Metric linearUnit="furlong"/>`,
+		`<Metric angularUnit="turns"/>`,
+		`<Imperial linearUnit="meter"/>`,
+		`<Metric directionUnit="degrees"/>`,
+	} {
+		doc := `<LandXML><Units>` + units + `</Units></LandXML>`
+		if _, err := ParseLandXML(strings.NewReader(doc), ImportOptions{}); err == nil || !strings.Contains(err.Error(), "unsupported") {
+			t.Errorf("%s: expected an unsupported unit error, got %v", units, err)
+		}
+	}
+}
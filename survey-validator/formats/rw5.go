@@ -0,0 +1,269 @@
+package formats
+
+// rw5.go - TDS / Carlson RW5 raw files
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/survey-validator/models"
+)
+
+// rw5Record - one comma separated record, fields keyed by their 2 letter prefix
+type rw5Record struct {
+	kind   string
+	fields map[string]string
+	note   string // the "--" description
+}
+
+// ParseRW5 - reads MO, SP, OC, LS, BK, BD/BR, SS, TR and FS records.
+// Angles are packed dd.mmss unless the MO record says gons.
+func ParseRW5(r io.Reader, opts ImportOptions) (*FieldBook, error) {
+	fb := &FieldBook{Format: FormatRW5}
+	scanner := bufio.NewScanner(r)
+
+	linUnit := UnitFoot
+	angUnit := AngleDMS
+	var hi, hr float64
+	lineNo := 0
+
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		rec := parseRW5Record(line)
+
+		lin := linUnit
+		if opts.LinearUnit != "" {
+			lin = opts.LinearUnit
+		}
+		ang := angUnit
+		if opts.AngleUnit != "" {
+			ang = opts.AngleUnit
+		}
+
+		dist := func(key string) (float64, bool, error) {
+			s, ok := rec.fields[key]
+			if !ok || strings.TrimSpace(s) == "" {
+				return 0, false, nil
+			}
+			v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+			if err != nil {
+				return 0, false, fmt.Errorf("line %d: invalid %s value %q", lineNo, key, s)
+			}
+			return toMeters(v, lin), true, nil
+		}
+		angle := func(key string) (float64, bool, error) {
+			s, ok := rec.fields[key]
+			if !ok || strings.TrimSpace(s) == "" {
+				return 0, false, nil
+			}
+			v, err := parseAngle(s, ang)
+			if err != nil {
+				return 0, false, fmt.Errorf("line %d: invalid %s value %q", lineNo, key, s)
+			}
+			return v, true, nil
+		}
+
+		switch rec.kind {
+		case "MO":
+			switch rec.fields["UN"] {
+			case "0":
+				linUnit = UnitFoot
+			case "1":
+				linUnit = UnitMeter
+			case "2":
+				linUnit = UnitUSFoot
+			}
+			if rec.fields["AU"] == "1" {
+				angUnit = AngleGons
+			}
+
+		case "SP", "OC":
+			key := "PN"
+			if rec.kind == "OC" {
+				key = "OP"
+			}
+			p, err := rw5Point(rec, key, dist)
+			if err != nil {
+				return nil, err
+			}
+			fb.Known = appendKnown(fb.Known, p)
+
+		case "LS":
+			if v, ok, err := dist("HI"); err != nil {
+				return nil, err
+			} else if ok {
+				hi = v
+			}
+			if v, ok, err := dist("HR"); err != nil {
+				return nil, err
+			} else if ok {
+				hr = v
+			}
+
+		case "BK":
+			setup := Setup{
+				StationID:        rec.fields["OP"],
+				BacksightID:      rec.fields["BP"],
+				InstrumentHeight: hi,
+			}
+			bs, hasBS, err := angle("BS")
+			if err != nil {
+				return nil, err
+			}
+			bc, _, err := angle("BC")
+			if err != nil {
+				return nil, err
+			}
+			setup.BacksightAzimuth = bs
+			setup.HasBacksightAzimuth = hasBS
+			setup.BacksightCircle = bc
+			fb.Setups = append(fb.Setups, setup)
+
+		case "SS", "TR", "FS", "BD", "BR":
+			if len(fb.Setups) == 0 {
+				fb.Warnings = append(fb.Warnings, fmt.Sprintf("line %d: %s record before any BK skipped", lineNo, rec.kind))
+				continue
+			}
+			setup := fb.Setups[len(fb.Setups)-1]
+			if op := rec.fields["OP"]; op != "" && op != setup.StationID {
+				fb.Warnings = append(fb.Warnings,
+					fmt.Sprintf("line %d: observation from %s but occupied station is %s", lineNo, op, setup.StationID))
+			}
+
+			o := RawObservation{
+				Setup:        len(fb.Setups) - 1,
+				TargetID:     rec.fields["FP"],
+				Code:         rec.note,
+				TargetHeight: hr,
+				Traverse:     rec.kind == "TR",
+				Backsight:    rec.kind == "BD" || rec.kind == "BR",
+			}
+			if o.Backsight && o.TargetID == "" {
+				o.TargetID = rec.fields["BP"]
+			}
+
+			if err := rw5Direction(&o, setup, angle); err != nil {
+				return nil, err
+			}
+			if err := rw5Vertical(&o, angle); err != nil {
+				return nil, err
+			}
+
+			var err error
+			if o.SlopeDistance, _, err = dist("SD"); err != nil {
+				return nil, err
+			}
+			if o.HorizDistance, _, err = dist("HD"); err != nil {
+				return nil, err
+			}
+			fb.Observations = append(fb.Observations, o)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return fb, nil
+}
+
+func parseRW5Record(line string) rw5Record {
+	parts := strings.Split(line, ",")
+	rec := rw5Record{
+		kind:   strings.ToUpper(strings.TrimSpace(parts[0])),
+		fields: make(map[string]string),
+	}
+	for _, part := range parts[1:] {
+		if strings.HasPrefix(part, "--") {
+			rec.note = strings.TrimSpace(part[2:])
+			continue
+		}
+		if len(part) < 2 {
+			continue
+		}
+		// northing and easting use a single letter prefix, "N 5000.000" or "N5000.000"
+		if (part[0] == 'N' || part[0] == 'E') && strings.ContainsRune(" -.0123456789", rune(part[1])) {
+			rec.fields[part[:1]] = strings.TrimSpace(part[1:])
+			continue
+		}
+		rec.fields[strings.ToUpper(part[:2])] = strings.TrimSpace(part[2:])
+	}
+	return rec
+}
+
+func rw5Point(rec rw5Record, idKey string, dist func(string) (float64, bool, error)) (models.SurveyPoint, error) {
+	p := models.SurveyPoint{PointID: rec.fields[idKey], Code: rec.note}
+
+	var err error
+	if p.Northing, _, err = dist("N"); err != nil {
+		return p, err
+	}
+	if p.Easting, _, err = dist("E"); err != nil {
+		return p, err
+	}
+	h, ok, err := dist("EL")
+	if err != nil {
+		return p, err
+	}
+	if ok {
+		p.Height = &h
+	}
+	return p, nil
+}
+
+// rw5Direction - AR/AL are turned from the backsight, DR/DL are deflections
+// off the back line, AZ is an azimuth straight up
+func rw5Direction(o *RawObservation, setup Setup, angle func(string) (float64, bool, error)) error {
+	if v, ok, err := angle("AZ"); err != nil {
+		return err
+	} else if ok {
+		o.Azimuth, o.HasAzimuth = v, true
+		return nil
+	}
+
+	turns := []struct {
+		key  string
+		sign float64
+		base float64
+	}{
+		{"AR", 1, 0},
+		{"AL", -1, 0},
+		{"DR", 1, 180},
+		{"DL", -1, 180},
+	}
+	for _, t := range turns {
+		v, ok, err := angle(t.key)
+		if err != nil {
+			return err
+		}
+		if ok {
+			o.Circle = normalizeDegrees(setup.BacksightCircle + t.base + t.sign*v)
+			return nil
+		}
+	}
+
+	o.Circle = setup.BacksightCircle
+	return nil
+}
+
+// rw5Vertical - ZE is a zenith angle, VA is measured from the horizon
+func rw5Vertical(o *RawObservation, angle func(string) (float64, bool, error)) error {
+	if v, ok, err := angle("ZE"); err != nil {
+		return err
+	} else if ok {
+		o.Zenith = v
+		return nil
+	}
+	if v, ok, err := angle("VA"); err != nil {
+		return err
+	} else if ok {
+		o.Zenith = 90 - v
+	}
+	return nil
+}
@@ -0,0 +1,231 @@
+package formats
+
+// sdr33.go - Sokkia SDR33 fixed width raw files
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/survey-validator/models"
+)
+
+// SDR33 field widths for the standard (4 character point ID) layout
+const (
+	sdrIDWidth    = 4
+	sdrValueWidth = 10
+	sdrCodeWidth  = 16
+)
+
+// ParseSDR33 - reads 00NM, 02TP, 03NM, 07TP, 08TP and 09F1/09F2 records.
+// The unit flags at the end of 00NM give the angle unit (1 degrees, 2 gons,
+// 3 mils) then the distance unit (1 meters, 2 feet, 3 US feet).
+func ParseSDR33(r io.Reader, opts ImportOptions) (*FieldBook, error) {
+	fb := &FieldBook{Format: FormatSDR33}
+	scanner := bufio.NewScanner(r)
+
+	linUnit := UnitMeter
+	angUnit := AngleDegrees
+	var targetHt float64
+	awaitingBacksight := ""
+	lineNo := 0
+
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimRight(scanner.Text(), " \r")
+		if len(line) < 4 {
+			continue
+		}
+		rec := sdrReader{line: line, pos: 4}
+
+		lin := linUnit
+		if opts.LinearUnit != "" {
+			lin = opts.LinearUnit
+		}
+		ang := angUnit
+		if opts.AngleUnit != "" {
+			ang = opts.AngleUnit
+		}
+
+		switch line[:4] {
+		case "00NM":
+			flags := strings.TrimSpace(line)
+			if n := len(flags); n >= 6 {
+				flags = flags[n-6:]
+				switch flags[0] {
+				case '2':
+					angUnit = AngleGons
+				case '3':
+					angUnit = AngleMils
+				}
+				switch flags[1] {
+				case '2':
+					linUnit = UnitFoot
+				case '3':
+					linUnit = UnitUSFoot
+				}
+			}
+
+		case "08TP":
+			id := rec.text(sdrIDWidth)
+			n, e, h, hasH, err := rec.coords(lin)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			p := models.SurveyPoint{PointID: id, Easting: e, Northing: n, Code: rec.text(sdrCodeWidth)}
+			if hasH {
+				p.Height = &h
+			}
+			fb.Known = appendKnown(fb.Known, p)
+
+		case "02TP":
+			id := rec.text(sdrIDWidth)
+			n, e, h, hasH, err := rec.coords(lin)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			hi, _, err := rec.distance(lin)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			if e != 0 || n != 0 {
+				p := models.SurveyPoint{PointID: id, Easting: e, Northing: n, Code: rec.text(sdrCodeWidth)}
+				if hasH {
+					p.Height = &h
+				}
+				fb.Known = appendKnown(fb.Known, p)
+			}
+			fb.Setups = append(fb.Setups, Setup{StationID: id, InstrumentHeight: hi})
+
+		case "03NM":
+			v, _, err := rec.distance(lin)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			targetHt = v
+
+		case "07TP":
+			if len(fb.Setups) == 0 {
+				fb.Warnings = append(fb.Warnings, fmt.Sprintf("line %d: backsight before any station skipped", lineNo))
+				continue
+			}
+			setup := &fb.Setups[len(fb.Setups)-1]
+			if stn := rec.text(sdrIDWidth); stn != setup.StationID {
+				fb.Warnings = append(fb.Warnings,
+					fmt.Sprintf("line %d: backsight from %s but occupied station is %s", lineNo, stn, setup.StationID))
+			}
+			setup.BacksightID = rec.text(sdrIDWidth)
+			az, hasAz, err := rec.angle(ang)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			circle, _, err := rec.angle(ang)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			setup.BacksightAzimuth, setup.HasBacksightAzimuth = az, hasAz
+			setup.BacksightCircle = circle
+			awaitingBacksight = setup.BacksightID
+
+		case "09F1", "09F2":
+			if len(fb.Setups) == 0 {
+				fb.Warnings = append(fb.Warnings, fmt.Sprintf("line %d: observation before any station skipped", lineNo))
+				continue
+			}
+			rec.text(sdrIDWidth) // from station, implied by the setup
+			o := RawObservation{
+				Setup:        len(fb.Setups) - 1,
+				TargetID:     rec.text(sdrIDWidth),
+				TargetHeight: targetHt,
+			}
+			var err error
+			if o.SlopeDistance, _, err = rec.distance(lin); err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			if o.Zenith, _, err = rec.angle(ang); err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			if o.Circle, _, err = rec.angle(ang); err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			o.Code = rec.text(sdrCodeWidth)
+
+			if line[:4] == "09F2" {
+				// face 2 pointing - the face 1 reading already placed this target
+				continue
+			}
+			if awaitingBacksight != "" && o.TargetID == awaitingBacksight {
+				o.Backsight = true
+				awaitingBacksight = ""
+			}
+			fb.Observations = append(fb.Observations, o)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return fb, nil
+}
+
+// sdrReader - walks the fixed width fields of one record
+type sdrReader struct {
+	line string
+	pos  int
+}
+
+func (s *sdrReader) text(width int) string {
+	if s.pos >= len(s.line) {
+		return ""
+	}
+	end := s.pos + width
+	if end > len(s.line) {
+		end = len(s.line)
+	}
+	v := strings.TrimSpace(s.line[s.pos:end])
+	s.pos = end
+	return v
+}
+
+func (s *sdrReader) number() (float64, bool, error) {
+	t := s.text(sdrValueWidth)
+	if t == "" {
+		return 0, false, nil
+	}
+	v, err := strconv.ParseFloat(t, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid SDR value %q", t)
+	}
+	return v, true, nil
+}
+
+func (s *sdrReader) distance(unit LinearUnit) (float64, bool, error) {
+	v, ok, err := s.number()
+	return toMeters(v, unit), ok, err
+}
+
+func (s *sdrReader) angle(unit AngleUnit) (float64, bool, error) {
+	t := s.text(sdrValueWidth)
+	if t == "" {
+		return 0, false, nil
+	}
+	v, err := parseAngle(t, unit)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid SDR angle %q", t)
+	}
+	return v, true, nil
+}
+
+// coords - northing, easting, elevation in that order
+func (s *sdrReader) coords(unit LinearUnit) (n, e, h float64, hasH bool, err error) {
+	if n, _, err = s.distance(unit); err != nil {
+		return
+	}
+	if e, _, err = s.distance(unit); err != nil {
+		return
+	}
+	h, hasH, err = s.distance(unit)
+	return
+}
@@ -0,0 +1,157 @@
+package ingest
+
+// ingest.go - survey data parsed as it arrives, point by point, into a
+// Spool, so a multi-million point upload never sits in memory whole
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/survey-validator/formats"
+	"github.com/survey-validator/models"
+)
+
+// ReadJSON - the validate body ({"project_id": ..., "points": [...]}) or a
+// bare array of points. Other keys in the object are skipped, and the
+// project ID in the body wins over the spool's.
+func ReadJSON(r io.Reader, sp *Spool) error {
+	dec := json.NewDecoder(r)
+	tok, err := dec.Token()
+	if err != nil {
+		return jsonError(dec, err)
+	}
+
+	switch tok {
+	case json.Delim('['):
+		return readPoints(dec, sp)
+	case json.Delim('{'):
+	default:
+		return fmt.Errorf("json: expected an object or an array of points")
+	}
+
+	meta := sp.Meta()
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return jsonError(dec, err)
+		}
+		key, _ := tok.(string)
+		switch key {
+		case "project_id":
+			err = dec.Decode(&meta.ProjectID)
+		case "coordinate_system":
+			err = dec.Decode(&meta.CoordinateSystem)
+		case "points":
+			if tok, err = dec.Token(); err == nil && tok != json.Delim('[') {
+				return fmt.Errorf("json: points must be an array")
+			}
+			if err == nil {
+				if err := readPoints(dec, sp); err != nil {
+					return err
+				}
+			}
+		default:
+			var skip json.RawMessage
+			err = dec.Decode(&skip)
+		}
+		if err != nil {
+			return jsonError(dec, err)
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return jsonError(dec, err)
+	}
+	sp.SetMeta(meta.ProjectID, meta.CoordinateSystem)
+	return nil
+}
+
+// readPoints - the elements of an array whose '[' has been read, and the ']'
+func readPoints(dec *json.Decoder, sp *Spool) error {
+	for dec.More() {
+		var p models.SurveyPoint
+		if err := dec.Decode(&p); err != nil {
+			return &InputError{Point: sp.Len() + 1, Offset: dec.InputOffset(), Literal: literal(dec, err), Err: eof(err)}
+		}
+		if err := sp.Add(&p); err != nil {
+			return err
+		}
+	}
+	_, err := dec.Token()
+	return jsonError(dec, err)
+}
+
+// InputError - where in the body the JSON went wrong: the byte offset and,
+// inside the points array, which point (from 1)
+type InputError struct {
+	Point  int
+	Offset int64
+	// Literal - NaN, Infinity or -Infinity when one of those is what the
+	// parser stopped at, as some encoders write them
+	Literal string
+	Err     error
+}
+
+func (e *InputError) Error() string {
+	msg := fmt.Sprintf("json at byte %d: %v", e.Offset, e.Err)
+	if e.Point > 0 {
+		msg = fmt.Sprintf("point %d: %s", e.Point, msg)
+	}
+	return msg
+}
+
+func (e *InputError) Unwrap() error { return e.Err }
+
+// jsonError - say where in the body it went wrong
+func jsonError(dec *json.Decoder, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &InputError{Offset: dec.InputOffset(), Literal: literal(dec, err), Err: eof(err)}
+}
+
+// literal - LiteralAt for a syntax error, looked up in what the decoder
+// still has buffered. That starts at the value being read, so it holds the
+// byte the error points at unless the literal runs off the end of a read.
+func literal(dec *json.Decoder, err error) string {
+	var syntax *json.SyntaxError
+	if !errors.As(err, &syntax) {
+		return ""
+	}
+	buf, _ := io.ReadAll(dec.Buffered())
+	return LiteralAt(buf, syntax.Offset-1-dec.InputOffset())
+}
+
+// LiteralAt - NaN, Infinity or -Infinity when that is what is at offset
+func LiteralAt(raw []byte, offset int64) string {
+	if offset < 0 || offset >= int64(len(raw)) {
+		return ""
+	}
+	if offset > 0 && raw[offset-1] == '-' {
+		offset-- // the scanner takes the sign and stops at the I
+	}
+	for _, lit := range []string{"NaN", "Infinity", "-Infinity"} {
+		if bytes.HasPrefix(raw[offset:], []byte(lit)) {
+			return lit
+		}
+	}
+	return ""
+}
+
+// eof - a body that stops part way is a truncated body, not a clean end
+func eof(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// ReadCSV - a coordinate list, as formats.ParseCSV reads it
+func ReadCSV(r io.Reader, opts formats.ImportOptions, sp *Spool) error {
+	sp.SetMeta(opts.ProjectID, opts.CoordinateSystem)
+	return formats.ScanCSV(r, opts, func(p models.SurveyPoint) error {
+		return sp.Add(&p)
+	})
+}
@@ -0,0 +1,157 @@
+package ingest
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/survey-validator/formats"
+	"github.com/survey-validator/models"
+)
+
+func spool(t *testing.T) *Spool {
+	t.Helper()
+	sp, err := NewSpool(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sp.Close() })
+	return sp
+}
+
+func ids(t *testing.T, sp *Spool) string {
+	t.Helper()
+	if err := sp.Finish(); err != nil {
+		t.Fatal(err)
+	}
+	var out []string
+	err := sp.Each(func(i int, p *models.SurveyPoint) error {
+		out = append(out, p.PointID)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Join(out, ",")
+}
+
+func TestReadJSON_Object(t *testing.T) {
+	sp := spool(t)
+	body := `{"traverse_input": {"required_precision": 5000}, "project_id": "BIG-1",
+		"points": [
+			{"point_id": "CP1", "easting": 1, "northing": 2, "height": 3.5, "survey_type": "control"},
+			{"point_id": "T1", "easting": 4, "northing": 5, "survey_type": "traverse"}
+		], "coordinate_system": "MGA94 Zone 55"}`
+	if err := ReadJSON(strings.NewReader(body), sp); err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(t, sp); got != "CP1,T1" {
+		t.Errorf("Expected CP1,T1, got %s", got)
+	}
+	if m := sp.Meta(); m.ProjectID != "BIG-1" || m.CoordinateSystem != "MGA94 Zone 55" {
+		t.Errorf("Expected the project and coordinate system, got %+v", m)
+	}
+
+	// a second pass sees the same thing, heights included
+	var h float64
+	sp.Each(func(i int, p *models.SurveyPoint) error {
+		if p.Height != nil {
+			h = *p.Height
+		}
+		return nil
+	})
+	if h != 3.5 {
+		t.Errorf("Expected the height back, got %v", h)
+	}
+}
+
+func TestReadJSON_ArrayAndErrors(t *testing.T) {
+	sp := spool(t)
+	sp.SetMeta("FROM-QUERY", "")
+	if err := ReadJSON(strings.NewReader(`[{"point_id":"A","easting":1,"northing":1}]`), sp); err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(t, sp); got != "A" || sp.Meta().ProjectID != "FROM-QUERY" {
+		t.Errorf("Expected A in FROM-QUERY, got %s in %s", got, sp.Meta().ProjectID)
+	}
+
+	for body, want := range map[string]string{
+		`{"points": [{"point_id": "A", "easting": "x"}]}`: "point 1",
+		`{"points": [{"point_id": "A"}`:                   "end of JSON input",
+		`{"points": {}}`:                                  "must be an array",
+		`"nope"`:                                          "object or an array",
+	} {
+		if err := ReadJSON(strings.NewReader(body), spool(t)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: expected an error with %q, got %v", body, want, err)
+		}
+	}
+
+	err := ReadJSON(strings.NewReader(`[{"point_id": "A"}, {"point_id": "B", "easting": "x"}]`), spool(t))
+	var input *InputError
+	if !errors.As(err, &input) || input.Point != 2 {
+		t.Errorf("Expected an InputError for point 2, got %v", err)
+	}
+
+	for body, want := range map[string]string{
+		`[{"point_id": "A", "easting": NaN}]`:                   "NaN",
+		`{"project_id": -Infinity, "points": []}`:               "-Infinity",
+		`[{"point_id": "A", "easting": 1}, {"easting": Null}]`:  "",
+		`{"points": [{"point_id": "A", "northing": Infinite}]}`: "",
+	} {
+		err := ReadJSON(strings.NewReader(body), spool(t))
+		if !errors.As(err, &input) || input.Literal != want {
+			t.Errorf("%s: expected literal %q, got %v", body, want, err)
+		}
+	}
+}
+
+func TestReadCSV(t *testing.T) {
+	sp := spool(t)
+	csv := "PointID,Easting,Northing,Height\nCP1,100,200,10\nT1,110,210,11\n"
+	if err := ReadCSV(strings.NewReader(csv), formats.ImportOptions{ProjectID: "CSV-1"}, sp); err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(t, sp); got != "CP1,T1" || sp.Meta().ProjectID != "CSV-1" {
+		t.Errorf("Expected CP1,T1 in CSV-1, got %s in %s", got, sp.Meta().ProjectID)
+	}
+	if err := sp.Add(&models.SurveyPoint{}); err == nil {
+		t.Error("Expected Add after Finish to fail")
+	}
+}
+
+func TestSpool_KeepsZeros(t *testing.T) {
+	zero := 0.0
+	sp := spool(t)
+	in := models.SurveyPoint{PointID: "BM1", Easting: 1, Northing: 2, Height: &zero,
+		SigmaE: &zero, SigmaN: &zero, SigmaH: &zero}
+	if err := sp.Add(&in); err != nil {
+		t.Fatal(err)
+	}
+	if err := sp.Add(&models.SurveyPoint{PointID: "P2", Easting: 3, Northing: 4}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sp.Finish(); err != nil {
+		t.Fatal(err)
+	}
+	var got []models.SurveyPoint
+	err := sp.Each(func(i int, p *models.SurveyPoint) error {
+		got = append(got, *p)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d points, want 2", len(got))
+	}
+	p := got[0]
+	if p.Height == nil || p.SigmaE == nil || p.SigmaN == nil || p.SigmaH == nil {
+		t.Fatalf("zeros lost: height %v sigma_e %v sigma_n %v sigma_h %v", p.Height, p.SigmaE, p.SigmaN, p.SigmaH)
+	}
+	if *p.Height != 0 {
+		t.Errorf("height = %v, want 0", *p.Height)
+	}
+	if got[1].Height != nil {
+		t.Errorf("P2 came back with a height")
+	}
+}
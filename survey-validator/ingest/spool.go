@@ -0,0 +1,111 @@
+package ingest
+
+// spool.go - points parked in a temp file so a big upload can be read in
+// passes without holding it. One JSON point per line: gob would drop a
+// height or sigma of 0 behind its pointer and hand the point back without
+// one.
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/survey-validator/models"
+)
+
+// Spool - a models.PointSource backed by a temp file. Add the points,
+// Finish, then read it as often as needed; passes can run concurrently.
+// Close removes the file.
+type Spool struct {
+	meta models.SurveyData
+	path string
+	f    *os.File
+	w    *bufio.Writer
+	enc  *json.Encoder
+	n    int
+}
+
+// NewSpool - an empty spool in dir, the system temp dir if ""
+func NewSpool(dir string) (*Spool, error) {
+	f, err := os.CreateTemp(dir, "survey-*.spool")
+	if err != nil {
+		return nil, err
+	}
+	w := bufio.NewWriterSize(f, 1<<16)
+	return &Spool{path: f.Name(), f: f, w: w, enc: json.NewEncoder(w)}, nil
+}
+
+// SetMeta - project ID and coordinate system, what Meta gives back
+func (s *Spool) SetMeta(projectID, coordinateSystem string) {
+	s.meta = models.SurveyData{ProjectID: projectID, CoordinateSystem: coordinateSystem}
+}
+
+// Add - append a point
+func (s *Spool) Add(p *models.SurveyPoint) error {
+	if s.enc == nil {
+		return errors.New("spool is finished")
+	}
+	if err := s.enc.Encode(p); err != nil {
+		return fmt.Errorf("spooling point %d: %w", s.n+1, err)
+	}
+	s.n++
+	return nil
+}
+
+// Finish - done adding, ready to read
+func (s *Spool) Finish() error {
+	if s.enc == nil {
+		return nil
+	}
+	s.enc = nil
+	if err := s.w.Flush(); err != nil {
+		return err
+	}
+	return s.f.Close()
+}
+
+// Close - finish and remove the file
+func (s *Spool) Close() error {
+	s.Finish()
+	return os.Remove(s.path)
+}
+
+// Meta - models.PointSource
+func (s *Spool) Meta() models.SurveyData {
+	return s.meta
+}
+
+// Len - models.PointSource
+func (s *Spool) Len() int {
+	return s.n
+}
+
+// Each - models.PointSource. Each pass opens the file afresh.
+func (s *Spool) Each(fn func(i int, p *models.SurveyPoint) error) error {
+	if s.enc != nil {
+		return errors.New("spool read before Finish")
+	}
+	f, err := os.Open(s.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(bufio.NewReaderSize(f, 1<<16))
+	for i := 0; i < s.n; i++ {
+		var p models.SurveyPoint
+		if err := dec.Decode(&p); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return fmt.Errorf("reading spooled point %d: %w", i+1, err)
+		}
+		if err := fn(i, &p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,298 @@
+package metrics
+
+// metrics.go - counters, gauges and histograms with labels, written out in
+// the Prometheus text format. Just what the server needs, so there is no
+// client library to pull in.
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets - histogram bounds in seconds, from 1ms to 1 minute
+var DefaultBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// Registry - the metrics to write out, in the order they were made
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+}
+
+type metric interface {
+	write(w io.Writer) error
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) add(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics = append(r.metrics, m)
+}
+
+// WriteText - every metric in the Prometheus text format, version 0.0.4
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	list := append([]metric(nil), r.metrics...)
+	r.mu.Unlock()
+	for _, m := range list {
+		if err := m.write(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ContentType - what WriteText writes
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// desc - a metric's name, help and label names. Series are kept by their
+// label values joined with \xff.
+type desc struct {
+	name   string
+	help   string
+	labels []string
+}
+
+func (d *desc) header(w io.Writer, kind string) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.name, escapeHelp(d.help), d.name, kind)
+	return err
+}
+
+// key - the label values as a map key, checking there are the right number
+func (d *desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", d.name, len(d.labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// labelText - {a="1",b="2"} for the series key, with extra pairs after
+func (d *desc) labelText(key string, extra ...string) string {
+	var pairs []string
+	if len(d.labels) > 0 {
+		for i, v := range strings.Split(key, "\xff") {
+			pairs = append(pairs, d.labels[i]+`="`+escapeLabel(v)+`"`)
+		}
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+`="`+escapeLabel(extra[i+1])+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// Counter - a count that only goes up, one per set of label values
+type Counter struct {
+	desc
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+// NewCounter - a counter in r with the given label names
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{desc: desc{name, help, labels}, values: make(map[string]float64)}
+	r.add(c)
+	return c
+}
+
+// Inc - add one to the series for these label values
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add - add n, which can't be negative
+func (c *Counter) Add(n float64, values ...string) {
+	if n < 0 {
+		panic("metrics: counters can't go down")
+	}
+	k := c.key(values)
+	c.mu.Lock()
+	c.values[k] += n
+	c.mu.Unlock()
+}
+
+// Value - the series for these label values
+func (c *Counter) Value(values ...string) float64 {
+	k := c.key(values)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[k]
+}
+
+func (c *Counter) write(w io.Writer) error {
+	return writeSimple(w, &c.desc, "counter", &c.mu, c.values)
+}
+
+// Gauge - a value that goes up and down, one per set of label values
+type Gauge struct {
+	desc
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+// NewGauge - a gauge in r with the given label names
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{desc: desc{name, help, labels}, values: make(map[string]float64)}
+	r.add(g)
+	return g
+}
+
+// Add - add n (negative to take away) to the series for these label values
+func (g *Gauge) Add(n float64, values ...string) {
+	k := g.key(values)
+	g.mu.Lock()
+	g.values[k] += n
+	g.mu.Unlock()
+}
+
+// Set - the series for these label values is now v
+func (g *Gauge) Set(v float64, values ...string) {
+	k := g.key(values)
+	g.mu.Lock()
+	g.values[k] = v
+	g.mu.Unlock()
+}
+
+func (g *Gauge) write(w io.Writer) error {
+	return writeSimple(w, &g.desc, "gauge", &g.mu, g.values)
+}
+
+func writeSimple(w io.Writer, d *desc, kind string, mu *sync.Mutex, values map[string]float64) error {
+	mu.Lock()
+	keys := sortedKeys(values)
+	lines := make([]string, len(keys))
+	for i, k := range keys {
+		lines[i] = d.name + d.labelText(k) + " " + formatValue(values[k]) + "\n"
+	}
+	mu.Unlock()
+
+	if err := d.header(w, kind); err != nil {
+		return err
+	}
+	if len(d.labels) == 0 && len(lines) == 0 {
+		lines = []string{d.name + " 0\n"}
+	}
+	_, err := io.WriteString(w, strings.Join(lines, ""))
+	return err
+}
+
+// Histogram - observations counted into buckets, one set per label values
+type Histogram struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	series  map[string]*histSeries
+}
+
+type histSeries struct {
+	counts []uint64 // per bucket, not cumulative; the last is +Inf
+	sum    float64
+	count  uint64
+}
+
+// NewHistogram - a histogram in r with these upper bounds (DefaultBuckets
+// when nil) and label names
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	h := &Histogram{desc: desc{name, help, labels}, buckets: buckets, series: make(map[string]*histSeries)}
+	r.add(h)
+	return h
+}
+
+// Observe - count v into the series for these label values
+func (h *Histogram) Observe(v float64, values ...string) {
+	k := h.key(values)
+	i := sort.SearchFloat64s(h.buckets, v) // the first bound >= v
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.series[k]
+	if s == nil {
+		s = &histSeries{counts: make([]uint64, len(h.buckets)+1)}
+		h.series[k] = s
+	}
+	s.counts[i]++
+	s.sum += v
+	s.count++
+}
+
+// Count - how many observations the series for these label values has
+func (h *Histogram) Count(values ...string) uint64 {
+	k := h.key(values)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s := h.series[k]; s != nil {
+		return s.count
+	}
+	return 0
+}
+
+func (h *Histogram) write(w io.Writer) error {
+	h.mu.Lock()
+	keys := make([]string, 0, len(h.series))
+	for k := range h.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		s := h.series[k]
+		var cum uint64
+		for i, bound := range h.buckets {
+			cum += s.counts[i]
+			fmt.Fprintf(&b, "%s_bucket%s %d\n", h.name, h.labelText(k, "le", formatValue(bound)), cum)
+		}
+		fmt.Fprintf(&b, "%s_bucket%s %d\n", h.name, h.labelText(k, "le", "+Inf"), s.count)
+		fmt.Fprintf(&b, "%s_sum%s %s\n", h.name, h.labelText(k), formatValue(s.sum))
+		fmt.Fprintf(&b, "%s_count%s %d\n", h.name, h.labelText(k), s.count)
+	}
+	h.mu.Unlock()
+
+	if err := h.header(w, "histogram"); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(s)
+}
+
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
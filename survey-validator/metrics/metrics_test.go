@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteText(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("requests_total", "Requests.", "method", "path")
+	g := r.NewGauge("in_flight", "Now.")
+	h := r.NewHistogram("took_seconds", "How long.\nIn seconds.", []float64{1, 0.1}, "check")
+
+	c.Inc("GET", "/a")
+	c.Add(2, "GET", "/a")
+	c.Inc("POST", `say "hi"\`)
+	g.Add(1)
+	g.Add(-1)
+	h.Observe(0.05, "gaps")
+	h.Observe(0.5, "gaps")
+	h.Observe(5, "gaps")
+
+	if v := c.Value("GET", "/a"); v != 3 {
+		t.Errorf("Expected 3, got %v", v)
+	}
+	if n := h.Count("gaps"); n != 3 {
+		t.Errorf("Expected 3 observations, got %d", n)
+	}
+
+	var b strings.Builder
+	if err := r.WriteText(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP requests_total Requests.
+# TYPE requests_total counter
+requests_total{method="GET",path="/a"} 3
+requests_total{method="POST",path="say \"hi\"\\"} 1
+# HELP in_flight Now.
+# TYPE in_flight gauge
+in_flight 0
+# HELP took_seconds How long.\nIn seconds.
+# TYPE took_seconds histogram
+took_seconds_bucket{check="gaps",le="0.1"} 1
+took_seconds_bucket{check="gaps",le="1"} 2
+took_seconds_bucket{check="gaps",le="+Inf"} 3
+took_seconds_sum{check="gaps"} 5.55
+took_seconds_count{check="gaps"} 3
+`
+	if b.String() != want {
+		t.Errorf("Got:\n%s\nexpected:\n%s", b.String(), want)
+	}
+}
+
+func TestLabelCount(t *testing.T) {
+	c := NewRegistry().NewCounter("x_total", "X.", "a")
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a panic for the wrong number of label values")
+		}
+	}()
+	c.Inc("1", "2")
+}
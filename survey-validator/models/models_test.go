@@ -107,3 +107,73 @@ func TestValidationReport_CalculateConfidenceScore(t *testing.T) {
 		t.Errorf("Expected score %f, got %f", expected, report.ConfidenceScore)
 	}
 }
+
+func TestFingerprint(t *testing.T) {
+	a := ValidationIssue{CheckName: "distance_bearing_check", Kind: "short_leg", PointIDs: []string{"T1", "T2"},
+		Description: "Very short distance between T1 and T2: 0.0500m"}
+	b := ValidationIssue{CheckName: "distance_bearing_check", Kind: "short_leg", PointIDs: []string{"T2", "T1"},
+		Description: "Very short distance between T2 and T1: 0.0620m", Severity: SeverityInfo}
+
+	if Fingerprint(a) != Fingerprint(b) {
+		t.Error("Expected the same fingerprint regardless of point order, description and severity")
+	}
+	if len(Fingerprint(a)) != 16 {
+		t.Errorf("Expected a 16 character fingerprint, got %q", Fingerprint(a))
+	}
+
+	b.Kind = "bearing_change"
+	if Fingerprint(a) == Fingerprint(b) {
+		t.Error("Expected a different kind to change the fingerprint")
+	}
+}
+
+func TestScore_NormalisedBySize(t *testing.T) {
+	warnings := func(points int) *ValidationReport {
+		r := NewValidationReport("TEST")
+		r.Summary.TotalPoints = points
+		for i := 0; i < 5; i++ {
+			r.AddIssue(ValidationIssue{CheckName: "outlier_detection", Severity: SeverityWarning, PointIDs: []string{"P"}})
+		}
+		r.Score(ScoreInput{Categories: map[string]string{"outlier_detection": CategoryGeometry}})
+		return r
+	}
+
+	small, big := warnings(5), warnings(10000)
+	if small.ConfidenceScore != 80 { // 5 x 5 x 0.8
+		t.Errorf("Expected 80 for 5 warnings on 5 points, got %.2f", small.ConfidenceScore)
+	}
+	if big.ConfidenceScore <= 98 {
+		t.Errorf("Expected 5 warnings on 10,000 points to cost under 2, got %.2f", big.ConfidenceScore)
+	}
+	if b := big.ScoreBreakdown; b == nil || len(b.Deductions) != 1 || b.SizeFactor >= 0.1 {
+		t.Errorf("Unexpected breakdown: %+v", b)
+	}
+}
+
+func TestScore_MagnitudeCategoryAndCoverage(t *testing.T) {
+	r := NewValidationReport("TEST")
+	r.Summary.TotalPoints = 10
+	r.ChecksPerformed = []string{"traverse_closure", "outlier_detection"}
+	r.AddIssue(ValidationIssue{CheckName: "traverse_closure", Kind: "closure_good", Severity: SeverityInfo})
+	r.AddIssue(ValidationIssue{CheckName: "outlier_detection", Severity: SeverityWarning, PointIDs: []string{"P9"}, Magnitude: 7})
+	r.Score(ScoreInput{
+		Categories: map[string]string{"traverse_closure": CategoryClosure, "outlier_detection": CategoryGeometry},
+		Applicable: []string{"traverse_closure", "outlier_detection", "duplicate_detection"},
+	})
+
+	b := r.ScoreBreakdown
+	if len(b.Deductions) != 2 {
+		t.Fatalf("Expected an outlier and a coverage deduction, got %+v", b.Deductions)
+	}
+	// magnitude capped at 3: 5 x 0.8 x 3
+	if d := b.Deductions[0]; d.CheckName != "outlier_detection" || d.Points != 12 {
+		t.Errorf("Expected outlier_detection -12, got %+v", d)
+	}
+	// duplicate_detection (custom, 1.0) missing out of 1.5 + 0.8 + 1.0
+	if d := b.Deductions[1]; d.Category != "coverage" || d.Points != round2(20*1.0/3.3) {
+		t.Errorf("Unexpected coverage deduction %+v", d)
+	}
+	if r.ConfidenceScore != round2(100-12-20*1.0/3.3) {
+		t.Errorf("Unexpected score %.2f", r.ConfidenceScore)
+	}
+}
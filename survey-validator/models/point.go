@@ -18,7 +18,19 @@ type SurveyPoint struct {
 	Northing         float64    `json:"northing"`
 	Height           *float64   `json:"height,omitempty"`
 	SurveyType       SurveyType `json:"survey_type"`
+	Code             string     `json:"code,omitempty"` // field code from the data collector
+	Description      string     `json:"description,omitempty"`
 	CoordinateSystem string     `json:"coordinate_system,omitempty"`
+
+	// Attributes - feature attributes keyed by name, e.g. SPREAD=4 on a
+	// tree. Code libraries can require some (see the codes package).
+	Attributes map[string]string `json:"attributes,omitempty"`
+
+	// one-sigma precisions in metres, from the adjustment or the instrument
+	// spec. Optional; monitoring uses them to test whether a move is real.
+	SigmaE *float64 `json:"sigma_e,omitempty"`
+	SigmaN *float64 `json:"sigma_n,omitempty"`
+	SigmaH *float64 `json:"sigma_h,omitempty"`
 }
 
 // SurveyData - what comes in from the API
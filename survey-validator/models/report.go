@@ -22,10 +22,22 @@ const (
 
 type ValidationIssue struct {
 	CheckName   string        `json:"check_name"`
+	Kind        string        `json:"kind,omitempty"` // what sort of problem, e.g. short_leg
+	Fingerprint string        `json:"fingerprint,omitempty"`
 	Severity    IssueSeverity `json:"severity"`
 	PointIDs    []string      `json:"point_ids,omitempty"`
 	Description string        `json:"description"`
 	Details     interface{}   `json:"details,omitempty"`
+
+	// Magnitude - how far past tolerance, 2 = twice the limit. Scales the
+	// issue's score deduction; unset counts as 1.
+	Magnitude float64 `json:"magnitude,omitempty"`
+
+	// OriginalSeverity - what the check reported, when the request overrode it
+	OriginalSeverity IssueSeverity `json:"original_severity,omitempty"`
+
+	// Suppression - why the issue was accepted, on issues in Suppressed
+	Suppression *Suppression `json:"suppression,omitempty"`
 }
 
 type SummaryStatistics struct {
@@ -57,14 +69,37 @@ type TraverseClosureDetails struct {
 
 type ValidationReport struct {
 	ProjectID       string            `json:"project_id"`
+	ReportID        string            `json:"report_id,omitempty"` // set when the server stored the report
 	Timestamp       time.Time         `json:"timestamp"`
 	Status          ValidationStatus  `json:"status"`
 	ConfidenceScore float64           `json:"confidence_score"`
+	ScoreBreakdown  *ScoreBreakdown   `json:"score_breakdown,omitempty"`
 	Summary         SummaryStatistics `json:"summary"`
 	Issues          []ValidationIssue `json:"issues"`
+	Suppressed      []ValidationIssue `json:"suppressed,omitempty"` // accepted, not in status or score
 	ChecksPerformed []string          `json:"checks_performed"`
 	ProcessingTime  string            `json:"processing_time"`
+	CheckDurations  map[string]string `json:"check_durations,omitempty"`
 	TraverseResult  *TraverseResult   `json:"traverse_adjustment,omitempty"`
+	Configuration   *RunConfiguration `json:"configuration,omitempty"`
+
+	// UnusedSuppressions - fingerprints that were submitted but matched
+	// nothing, usually because the issue has been fixed
+	UnusedSuppressions []string `json:"unused_suppressions,omitempty"`
+}
+
+// RunConfiguration - the effective settings behind a report. Enable,
+// Disable, Config and Severity have the same shape as the validate
+// request's checks block, so a run can be repeated exactly.
+type RunConfiguration struct {
+	Profile           string                            `json:"profile,omitempty"`
+	RequiredPrecision float64                           `json:"required_precision,omitempty"`
+	Enable            []string                          `json:"enable"`
+	Disable           []string                          `json:"disable,omitempty"`
+	Config            map[string]map[string]interface{} `json:"config,omitempty"`
+	Severity          map[string]IssueSeverity          `json:"severity,omitempty"`
+	Suppressions      []Suppression                     `json:"suppressions,omitempty"`
+	Versions          map[string]string                 `json:"versions"` // check name to version
 }
 
 // NewValidationReport - starts with PASS, we'll downgrade if issues found
@@ -90,29 +125,3 @@ func (r *ValidationReport) AddIssue(issue ValidationIssue) {
 		}
 	}
 }
-
-// CalculateConfidenceScore - rough quality indicator
-// starts at 100, deduct points for each issue
-func (r *ValidationReport) CalculateConfidenceScore() {
-	if len(r.Issues) == 0 {
-		r.ConfidenceScore = 100.0
-		return
-	}
-
-	score := 100.0
-	for _, issue := range r.Issues {
-		switch issue.Severity {
-		case SeverityError:
-			score -= 15.0
-		case SeverityWarning:
-			score -= 5.0
-		case SeverityInfo:
-			score -= 1.0
-		}
-	}
-
-	if score < 0 {
-		score = 0
-	}
-	r.ConfidenceScore = score
-}
@@ -0,0 +1,237 @@
+package models
+
+// score.go - the confidence score and the breakdown that explains it
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// check categories, used to weight deductions
+const (
+	CategoryIntegrity = "integrity" // missing/duplicate data - the data is wrong
+	CategoryGeometry  = "geometry"  // odd legs, outliers - the data might be wrong
+	CategoryClosure   = "closure"   // how well the traverse closes
+	CategoryCustom    = "custom"    // plugins and rule files
+)
+
+// scoring knobs
+const (
+	scoreModel = "weighted-v2"
+
+	// sizeReference - datasets up to this many points take deductions in
+	// full; bigger ones are scaled by sqrt(sizeReference/points), so five
+	// warnings in 10,000 points cost less than five in 20
+	sizeReference = 50
+
+	maxMagnitude    = 3.0  // an issue costs at most 3x its base however far out it is
+	coveragePenalty = 20.0 // taken off in full if none of the applicable checks ran
+)
+
+var severityPoints = map[IssueSeverity]float64{
+	SeverityError:   15,
+	SeverityWarning: 5,
+	SeverityInfo:    1,
+}
+
+var categoryWeight = map[string]float64{
+	CategoryIntegrity: 1.2,
+	CategoryGeometry:  0.8,
+	CategoryClosure:   1.5,
+	CategoryCustom:    1.0,
+}
+
+// ScoreInput - what the scorer needs to know that the report doesn't say
+type ScoreInput struct {
+	Categories map[string]string // check name to category, CategoryCustom if missing
+	Applicable []string          // checks that were meant to run on this data, nil to skip coverage
+}
+
+// ScoreBreakdown - how ConfidenceScore was reached, one line per deduction
+type ScoreBreakdown struct {
+	Model      string      `json:"model"`
+	Base       float64     `json:"base"`
+	Points     int         `json:"points"`
+	SizeFactor float64     `json:"size_factor"`        // applied to point-level deductions
+	Coverage   float64     `json:"coverage,omitempty"` // share of applicable check weight that ran
+	Deductions []Deduction `json:"deductions"`
+	Score      float64     `json:"score"`
+}
+
+// Deduction - what one check (or missing coverage) cost
+type Deduction struct {
+	CheckName string  `json:"check_name,omitempty"`
+	Category  string  `json:"category"`
+	Issues    int     `json:"issues,omitempty"`
+	Points    float64 `json:"points"`
+	Reason    string  `json:"reason"`
+}
+
+// CalculateConfidenceScore - score with no knowledge of check categories
+// or coverage, every check weighted as custom
+func (r *ValidationReport) CalculateConfidenceScore() {
+	r.Score(ScoreInput{})
+}
+
+// Score - 100 less a deduction per issue, each one
+//
+//	severity points (15/5/1) x category weight x magnitude x size factor
+//
+// where magnitude is how far past tolerance the issue was (1 to 3) and the
+// size factor only applies to issues about particular points, not to
+// whole-dataset ones like the closure. Info results from the closure check
+// are just the closure being reported and cost nothing. If some of the
+// checks that were meant to run didn't (crashed, timed out, skipped on
+// data too big to hold) up to 20 more comes off in proportion to their
+// weight. Checks switched off on purpose don't count.
+func (r *ValidationReport) Score(in ScoreInput) {
+	b := &ScoreBreakdown{
+		Model:      scoreModel,
+		Base:       100,
+		Points:     r.Summary.TotalPoints,
+		SizeFactor: 1,
+		Deductions: make([]Deduction, 0),
+	}
+	if b.Points > sizeReference {
+		b.SizeFactor = math.Sqrt(float64(sizeReference) / float64(b.Points))
+	}
+
+	category := func(check string) string {
+		if c, ok := in.Categories[check]; ok && categoryWeight[c] > 0 {
+			return c
+		}
+		return CategoryCustom
+	}
+
+	type tally struct {
+		points    float64
+		issues    int
+		bySev     map[IssueSeverity]int
+		worst     float64
+		firstSeen int
+	}
+	byCheck := make(map[string]*tally)
+	failed := make(map[string]bool)
+
+	for i, issue := range r.Issues {
+		if issue.Kind == KindCheckFailed {
+			failed[issue.CheckName] = true
+		}
+		cat := category(issue.CheckName)
+		if cat == CategoryClosure && issue.Severity == SeverityInfo {
+			continue
+		}
+
+		mag := math.Max(1, math.Min(issue.Magnitude, maxMagnitude))
+		cost := severityPoints[issue.Severity] * categoryWeight[cat] * mag
+		if len(issue.PointIDs) > 0 && cat != CategoryClosure {
+			cost *= b.SizeFactor
+		}
+
+		t := byCheck[issue.CheckName]
+		if t == nil {
+			t = &tally{bySev: make(map[IssueSeverity]int), firstSeen: i}
+			byCheck[issue.CheckName] = t
+		}
+		t.points += cost
+		t.issues++
+		t.bySev[issue.Severity]++
+		t.worst = math.Max(t.worst, issue.Magnitude)
+	}
+
+	names := make([]string, 0, len(byCheck))
+	for name := range byCheck {
+		names = append(names, name)
+	}
+	// biggest first, ties in report order
+	sort.Slice(names, func(i, j int) bool {
+		a, c := byCheck[names[i]], byCheck[names[j]]
+		if a.points != c.points {
+			return a.points > c.points
+		}
+		return a.firstSeen < c.firstSeen
+	})
+
+	score := b.Base
+	for _, name := range names {
+		t := byCheck[name]
+		cat := category(name)
+		reason := fmt.Sprintf("%s, %s weight %.1f", countSeverities(t.bySev), cat, categoryWeight[cat])
+		if t.worst > 1 {
+			reason += fmt.Sprintf(", worst %.1fx past tolerance", t.worst)
+		}
+		if b.SizeFactor < 1 && cat != CategoryClosure {
+			reason += fmt.Sprintf(", scaled %.2f for %d points", b.SizeFactor, b.Points)
+		}
+		b.Deductions = append(b.Deductions, Deduction{
+			CheckName: name,
+			Category:  cat,
+			Issues:    t.issues,
+			Points:    round2(t.points),
+			Reason:    reason,
+		})
+		score -= t.points
+	}
+
+	if in.Applicable != nil {
+		ran := make(map[string]bool, len(r.ChecksPerformed))
+		for _, name := range r.ChecksPerformed {
+			ran[name] = !failed[name]
+		}
+		var total, covered float64
+		var missing []string
+		for _, name := range in.Applicable {
+			w := categoryWeight[category(name)]
+			total += w
+			if ran[name] {
+				covered += w
+			} else {
+				missing = append(missing, name)
+			}
+		}
+		b.Coverage = 1
+		if total > 0 {
+			b.Coverage = covered / total
+		}
+		if len(missing) > 0 {
+			cost := coveragePenalty * (1 - b.Coverage)
+			b.Deductions = append(b.Deductions, Deduction{
+				Category: "coverage",
+				Points:   round2(cost),
+				Reason:   fmt.Sprintf("%d of %d applicable checks did not run: %v", len(missing), len(in.Applicable), missing),
+			})
+			score -= cost
+		}
+	}
+
+	if score < 0 {
+		score = 0
+	}
+	r.ConfidenceScore = round2(score)
+	b.Score = r.ConfidenceScore
+	r.ScoreBreakdown = b
+}
+
+// countSeverities - "2 warnings, 1 info"
+func countSeverities(n map[IssueSeverity]int) string {
+	out := ""
+	for _, sev := range []IssueSeverity{SeverityError, SeverityWarning, SeverityInfo} {
+		if n[sev] == 0 {
+			continue
+		}
+		if out != "" {
+			out += ", "
+		}
+		word := string(sev)
+		if n[sev] > 1 && sev != SeverityInfo {
+			word += "s"
+		}
+		out += fmt.Sprintf("%d %s", n[sev], word)
+	}
+	return out
+}
+
+func round2(x float64) float64 {
+	return math.Round(x*100) / 100
+}
@@ -0,0 +1,33 @@
+package models
+
+// source.go - datasets read point by point instead of held in memory
+
+// PointSource - a dataset that can be read in passes, for files too big to
+// hold as a SurveyData. Each pass sees the points in the same order.
+type PointSource interface {
+	// Meta - project ID and coordinate system, Points left empty
+	Meta() SurveyData
+	Len() int
+	// Each - call fn for every point in order, stopping at the first error
+	Each(fn func(i int, p *SurveyPoint) error) error
+}
+
+// Meta - PointSource, so in-memory data can go anywhere a source can
+func (d *SurveyData) Meta() SurveyData {
+	return SurveyData{ProjectID: d.ProjectID, CoordinateSystem: d.CoordinateSystem}
+}
+
+// Len - number of points
+func (d *SurveyData) Len() int {
+	return len(d.Points)
+}
+
+// Each - PointSource
+func (d *SurveyData) Each(fn func(i int, p *SurveyPoint) error) error {
+	for i := range d.Points {
+		if err := fn(i, &d.Points[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package models
+
+// suppression.go - issue fingerprints and accepted (suppressed) issues
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+)
+
+// KindCheckFailed - the engine's own issue for a check that crashed or ran
+// out of time. These can't be suppressed.
+const KindCheckFailed = "check_failed"
+
+// Suppression - an issue someone has looked at and accepted, e.g. a leg
+// that is short on purpose. Matched to issues by fingerprint.
+type Suppression struct {
+	Fingerprint    string `json:"fingerprint"`
+	Justification  string `json:"justification"`
+	AcknowledgedBy string `json:"acknowledged_by,omitempty"`
+}
+
+// Fingerprint - stable ID for an issue built from the check, the kind of
+// problem and the points involved (in any order). Numbers in the
+// description don't count, so a re-run of the same data, or a revised file
+// where the short leg is still short, gives the same fingerprint.
+func Fingerprint(issue ValidationIssue) string {
+	ids := append([]string(nil), issue.PointIDs...)
+	sort.Strings(ids)
+
+	h := sha256.New()
+	h.Write([]byte(issue.CheckName + "\x00" + issue.Kind + "\x00" + strings.Join(ids, "\x00")))
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
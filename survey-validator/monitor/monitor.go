@@ -0,0 +1,345 @@
+package monitor
+
+// monitor.go - deformation monitoring: the same targets surveyed again and
+// again, each epoch compared with a baseline to see what is really moving
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/survey-validator/compare"
+	"github.com/survey-validator/models"
+)
+
+// Epoch - one survey of the monitoring targets
+type Epoch struct {
+	ID         string               `json:"id"`
+	ProjectID  string               `json:"project_id"`
+	ObservedAt time.Time            `json:"observed_at"`
+	ReceivedAt time.Time            `json:"received_at"`
+	Notes      string               `json:"notes,omitempty"`
+	Points     []models.SurveyPoint `json:"points"`
+}
+
+// Config - a project's baseline, precisions and alert limits. Limits are
+// in metres and metres per day; zero turns a limit off.
+type Config struct {
+	Baseline   string  `json:"baseline,omitempty"` // epoch ID, the earliest epoch if empty
+	Confidence float64 `json:"confidence"`         // for the significance tests, 0.95 or 0.99 say
+
+	// used for points that don't carry their own sigma_e/n/h
+	SigmaEN     float64 `json:"default_sigma_en"` // per coordinate
+	SigmaHeight float64 `json:"default_sigma_h"`
+
+	MaxDisplacement     float64 `json:"max_displacement"` // horizontal, from the baseline
+	MaxVertical         float64 `json:"max_vertical"`
+	MaxVelocity         float64 `json:"max_velocity"` // horizontal, over the latest interval
+	MaxVerticalVelocity float64 `json:"max_vertical_velocity"`
+}
+
+// DefaultConfig - 2mm/3mm targets, alert past 20mm or 1mm a day
+func DefaultConfig() Config {
+	return Config{
+		Confidence:          0.95,
+		SigmaEN:             0.002,
+		SigmaHeight:         0.003,
+		MaxDisplacement:     0.02,
+		MaxVertical:         0.02,
+		MaxVelocity:         0.001,
+		MaxVerticalVelocity: 0.001,
+	}
+}
+
+// Validate - is the config usable
+func (c Config) Validate() error {
+	if c.Confidence <= 0.5 || c.Confidence >= 1 {
+		return fmt.Errorf("confidence must be between 0.5 and 1, e.g. 0.95")
+	}
+	if c.SigmaEN <= 0 || c.SigmaHeight <= 0 {
+		return fmt.Errorf("default_sigma_en and default_sigma_h must be positive")
+	}
+	if c.MaxDisplacement < 0 || c.MaxVertical < 0 || c.MaxVelocity < 0 || c.MaxVerticalVelocity < 0 {
+		return fmt.Errorf("limits can't be negative")
+	}
+	return nil
+}
+
+// Report - the state of a monitoring project
+type Report struct {
+	ProjectID string      `json:"project_id"`
+	Baseline  string      `json:"baseline"`
+	Latest    string      `json:"latest"`
+	Config    Config      `json:"config"`
+	Epochs    []EpochInfo `json:"epochs"`
+	Points    []Series    `json:"points"`
+	Alerts    []Alert     `json:"alerts"`
+
+	// NotInBaseline - targets seen later that the baseline doesn't have,
+	// so they have no series yet
+	NotInBaseline []string `json:"not_in_baseline,omitempty"`
+
+	// Systematic - best-fit transform from the baseline to the latest
+	// epoch. If it is significant the whole network (or its control) moved.
+	Systematic *compare.Transform `json:"systematic,omitempty"`
+}
+
+// EpochInfo - an epoch without its points
+type EpochInfo struct {
+	ID         string    `json:"id"`
+	ObservedAt time.Time `json:"observed_at"`
+	Points     int       `json:"points"`
+}
+
+// Series - one target through time, displacements from the baseline
+type Series struct {
+	PointID      string        `json:"point_id"`
+	Observations []Observation `json:"observations"`
+
+	// latest interval, metres per day
+	Velocity         float64 `json:"velocity"`
+	VerticalVelocity float64 `json:"vertical_velocity,omitempty"`
+
+	Status string `json:"status"` // stable, moving (significant) or alert
+}
+
+// Observation - a target in one epoch, relative to the baseline
+type Observation struct {
+	Epoch      string    `json:"epoch"`
+	ObservedAt time.Time `json:"observed_at"`
+	DE         float64   `json:"de"`
+	DN         float64   `json:"dn"`
+	DH         *float64  `json:"dh,omitempty"`
+	Horizontal float64   `json:"horizontal"`
+
+	// test statistics against their critical values at the configured
+	// confidence: chi-squared (2 dof) horizontally, |z| vertically
+	TestH       float64  `json:"test_h"`
+	TestV       *float64 `json:"test_v,omitempty"`
+	Significant bool     `json:"significant"`
+}
+
+// Alert - a significant movement past a limit
+type Alert struct {
+	PointID string  `json:"point_id"`
+	Epoch   string  `json:"epoch"`
+	Kind    string  `json:"kind"` // displacement, vertical, velocity, vertical_velocity
+	Value   float64 `json:"value"`
+	Limit   float64 `json:"limit"`
+	Message string  `json:"message"`
+}
+
+// status values
+const (
+	StatusStable = "stable"
+	StatusMoving = "moving"
+	StatusAlert  = "alert"
+)
+
+// Analyse - displacement series for every baseline target across the
+// epochs, with significance tests and alerts on the latest epoch
+func Analyse(projectID string, cfg Config, epochs []*Epoch) (*Report, error) {
+	if len(epochs) == 0 {
+		return nil, fmt.Errorf("project %s has no epochs", projectID)
+	}
+	epochs = append([]*Epoch(nil), epochs...)
+	sort.SliceStable(epochs, func(i, j int) bool { return epochs[i].ObservedAt.Before(epochs[j].ObservedAt) })
+
+	base := epochs[0]
+	if cfg.Baseline != "" {
+		base = nil
+		for _, e := range epochs {
+			if e.ID == cfg.Baseline {
+				base = e
+			}
+		}
+		if base == nil {
+			return nil, fmt.Errorf("baseline epoch %s not found", cfg.Baseline)
+		}
+	}
+	latest := epochs[len(epochs)-1]
+
+	rep := &Report{
+		ProjectID: projectID,
+		Baseline:  base.ID,
+		Latest:    latest.ID,
+		Config:    cfg,
+		Points:    []Series{},
+		Alerts:    []Alert{},
+	}
+	for _, e := range epochs {
+		rep.Epochs = append(rep.Epochs, EpochInfo{ID: e.ID, ObservedAt: e.ObservedAt, Points: len(e.Points)})
+	}
+
+	chiCrit := chiSquared2(cfg.Confidence)
+	zCrit := normalTwoSided(cfg.Confidence)
+
+	byEpoch := make(map[*Epoch]map[string]*models.SurveyPoint, len(epochs))
+	for _, e := range epochs {
+		byEpoch[e] = pointsByID(e.Points)
+	}
+	baseline := byEpoch[base]
+	ids := make([]string, 0, len(baseline))
+	for id := range baseline {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	extra := make(map[string]bool)
+	for _, e := range epochs {
+		for id := range byEpoch[e] {
+			if _, ok := baseline[id]; !ok && !extra[id] {
+				extra[id] = true
+				rep.NotInBaseline = append(rep.NotInBaseline, id)
+			}
+		}
+	}
+	sort.Strings(rep.NotInBaseline)
+
+	for _, id := range ids {
+		b := baseline[id]
+		s := Series{PointID: id, Observations: []Observation{}, Status: StatusStable}
+
+		var prev, last *models.SurveyPoint
+		var prevAt, lastAt time.Time
+		for _, e := range epochs {
+			p, ok := byEpoch[e][id]
+			if !ok || e == base {
+				continue
+			}
+			s.Observations = append(s.Observations, observe(cfg, b, p, e, chiCrit, zCrit))
+			prev, prevAt = last, lastAt
+			last, lastAt = p, e.ObservedAt
+		}
+
+		// velocity over the latest interval the point was seen in; before
+		// the second epoch that is the interval from the baseline
+		if prev == nil {
+			prev, prevAt = b, base.ObservedAt
+		}
+		var intervalSignificant, intervalSignificantV bool
+		if last != nil {
+			if days := lastAt.Sub(prevAt).Hours() / 24; days > 0 {
+				iv := observe(cfg, prev, last, nil, chiCrit, zCrit)
+				s.Velocity = round6(iv.Horizontal / days)
+				intervalSignificant = iv.TestH > chiCrit
+				if iv.DH != nil {
+					s.VerticalVelocity = round6(math.Abs(*iv.DH) / days)
+					intervalSignificantV = iv.TestV != nil && *iv.TestV > zCrit
+				}
+			}
+		}
+
+		if n := len(s.Observations); n > 0 && last != nil && lastAt.Equal(latest.ObservedAt) {
+			o := s.Observations[n-1]
+			if o.Significant {
+				s.Status = StatusMoving
+			}
+			alerts := check(cfg, id, latest.ID, o, s, intervalSignificant, intervalSignificantV, chiCrit, zCrit)
+			if len(alerts) > 0 {
+				s.Status = StatusAlert
+				rep.Alerts = append(rep.Alerts, alerts...)
+			}
+		}
+		rep.Points = append(rep.Points, s)
+	}
+
+	if latest != base {
+		res := compare.Compare(&models.SurveyData{ProjectID: base.ID, Points: base.Points},
+			&models.SurveyData{ProjectID: latest.ID, Points: latest.Points},
+			compare.Options{Threshold: cfg.MaxDisplacement})
+		rep.Systematic = res.Transform
+	}
+	return rep, nil
+}
+
+// observe - p against the reference point r. e is nil for an interval
+// between two epochs rather than a baseline comparison.
+func observe(cfg Config, r, p *models.SurveyPoint, e *Epoch, chiCrit, zCrit float64) Observation {
+	o := Observation{
+		DE: round4(p.Easting - r.Easting),
+		DN: round4(p.Northing - r.Northing),
+	}
+	if e != nil {
+		o.Epoch, o.ObservedAt = e.ID, e.ObservedAt
+	}
+	o.Horizontal = round4(math.Hypot(p.Easting-r.Easting, p.Northing-r.Northing))
+
+	// variance of a difference is the sum of the two variances
+	varE := sq(sigma(r.SigmaE, cfg.SigmaEN)) + sq(sigma(p.SigmaE, cfg.SigmaEN))
+	varN := sq(sigma(r.SigmaN, cfg.SigmaEN)) + sq(sigma(p.SigmaN, cfg.SigmaEN))
+	dE, dN := p.Easting-r.Easting, p.Northing-r.Northing
+	o.TestH = round4(dE*dE/varE + dN*dN/varN)
+	o.Significant = o.TestH > chiCrit
+
+	if r.Height != nil && p.Height != nil {
+		dh := *p.Height - *r.Height
+		rdh := round4(dh)
+		o.DH = &rdh
+		z := math.Abs(dh) / math.Sqrt(sq(sigma(r.SigmaH, cfg.SigmaHeight))+sq(sigma(p.SigmaH, cfg.SigmaHeight)))
+		z = round4(z)
+		o.TestV = &z
+		o.Significant = o.Significant || z > zCrit
+	}
+	return o
+}
+
+// check - the latest observation and interval against the limits. Only
+// significant movement alerts, so noise inside the precisions doesn't.
+func check(cfg Config, id, epoch string, o Observation, s Series, sigInterval, sigIntervalV bool, chiCrit, zCrit float64) []Alert {
+	var out []Alert
+	add := func(kind string, value, limit float64, unit string) {
+		out = append(out, Alert{
+			PointID: id, Epoch: epoch, Kind: kind, Value: value, Limit: limit,
+			Message: fmt.Sprintf("%s %s %.4f%s exceeds %.4f%s", id, kind, value, unit, limit, unit),
+		})
+	}
+
+	if cfg.MaxDisplacement > 0 && o.TestH > chiCrit && o.Horizontal > cfg.MaxDisplacement {
+		add("displacement", o.Horizontal, cfg.MaxDisplacement, "m")
+	}
+	if cfg.MaxVertical > 0 && o.DH != nil && *o.TestV > zCrit && math.Abs(*o.DH) > cfg.MaxVertical {
+		add("vertical", math.Abs(*o.DH), cfg.MaxVertical, "m")
+	}
+	if cfg.MaxVelocity > 0 && sigInterval && s.Velocity > cfg.MaxVelocity {
+		add("velocity", s.Velocity, cfg.MaxVelocity, "m/day")
+	}
+	if cfg.MaxVerticalVelocity > 0 && sigIntervalV && s.VerticalVelocity > cfg.MaxVerticalVelocity {
+		add("vertical_velocity", s.VerticalVelocity, cfg.MaxVerticalVelocity, "m/day")
+	}
+	return out
+}
+
+func pointsByID(points []models.SurveyPoint) map[string]*models.SurveyPoint {
+	m := make(map[string]*models.SurveyPoint, len(points))
+	for i := range points {
+		if _, dup := m[points[i].PointID]; !dup && points[i].PointID != "" {
+			m[points[i].PointID] = &points[i]
+		}
+	}
+	return m
+}
+
+func sigma(v *float64, fallback float64) float64 {
+	if v != nil && *v > 0 {
+		return *v
+	}
+	return fallback
+}
+
+func sq(x float64) float64 { return x * x }
+
+func round4(v float64) float64 { return math.Round(v*1e4) / 1e4 }
+func round6(v float64) float64 { return math.Round(v*1e6) / 1e6 }
+
+// chiSquared2 - critical value of chi-squared with 2 degrees of freedom,
+// which has the closed form -2 ln(1-p): 5.991 at 95%
+func chiSquared2(p float64) float64 {
+	return -2 * math.Log(1-p)
+}
+
+// normalTwoSided - critical |z| for a two-sided test: 1.960 at 95%
+func normalTwoSided(p float64) float64 {
+	return math.Sqrt2 * math.Erfinv(p)
+}
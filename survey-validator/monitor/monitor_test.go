@@ -0,0 +1,206 @@
+package monitor
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/survey-validator/models"
+)
+
+func h(v float64) *float64 { return &v }
+
+var day0 = time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+
+// epoch - three wall targets and a reference pillar, with PR2 moving
+// eastwards by move metres
+func epoch(id string, days int, move float64) *Epoch {
+	return &Epoch{
+		ID:         id,
+		ProjectID:  "DAM-1",
+		ObservedAt: day0.AddDate(0, 0, days),
+		Points: []models.SurveyPoint{
+			{PointID: "REF", Easting: 1000, Northing: 1000, Height: h(50)},
+			{PointID: "PR1", Easting: 1050, Northing: 1000, Height: h(52)},
+			{PointID: "PR2", Easting: 1100 + move, Northing: 1000, Height: h(52.5)},
+			{PointID: "PR3", Easting: 1150, Northing: 1000, Height: h(53)},
+		},
+	}
+}
+
+func series(t *testing.T, rep *Report, id string) Series {
+	t.Helper()
+	for _, s := range rep.Points {
+		if s.PointID == id {
+			return s
+		}
+	}
+	t.Fatalf("no series for %s", id)
+	return Series{}
+}
+
+func TestCriticalValues(t *testing.T) {
+	if c := chiSquared2(0.95); math.Abs(c-5.991) > 0.001 {
+		t.Errorf("chi2(2) at 95%% = %.3f, expected 5.991", c)
+	}
+	if z := normalTwoSided(0.95); math.Abs(z-1.960) > 0.001 {
+		t.Errorf("z at 95%% = %.3f, expected 1.960", z)
+	}
+}
+
+func TestAnalyse_SignificanceAndAlerts(t *testing.T) {
+	epochs := []*Epoch{
+		epoch("E3", 60, 0.025), // out of order on purpose
+		epoch("E1", 0, 0),
+		epoch("E2", 30, 0.004),
+	}
+	// PR1 wobbles inside its precision
+	epochs[2].Points[1].Easting += 0.003
+
+	rep, err := Analyse("DAM-1", DefaultConfig(), epochs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Baseline != "E1" || rep.Latest != "E3" {
+		t.Errorf("Expected baseline E1 and latest E3, got %s/%s", rep.Baseline, rep.Latest)
+	}
+
+	pr1 := series(t, rep, "PR1")
+	if pr1.Status != StatusStable || pr1.Observations[0].Significant {
+		t.Errorf("3mm on 2mm targets shouldn't be significant: %+v", pr1)
+	}
+
+	pr2 := series(t, rep, "PR2")
+	if len(pr2.Observations) != 2 || pr2.Status != StatusAlert {
+		t.Fatalf("Expected PR2 in alert with 2 observations, got %+v", pr2)
+	}
+	if o := pr2.Observations[1]; !o.Significant || o.Horizontal != 0.025 {
+		t.Errorf("Expected a significant 25mm move, got %+v", o)
+	}
+	// 21mm over the last 30 days
+	if math.Abs(pr2.Velocity-0.0007) > 1e-6 {
+		t.Errorf("Expected 0.0007 m/day, got %f", pr2.Velocity)
+	}
+
+	if len(rep.Alerts) != 1 || rep.Alerts[0].Kind != "displacement" || rep.Alerts[0].PointID != "PR2" {
+		t.Errorf("Expected one displacement alert on PR2, got %+v", rep.Alerts)
+	}
+	if rep.Systematic == nil || rep.Systematic.Significant {
+		t.Errorf("One moving target isn't a systematic shift: %+v", rep.Systematic)
+	}
+}
+
+func TestAnalyse_PointPrecisionsAndVelocity(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MaxDisplacement = 0 // velocity only
+
+	a, b := epoch("A", 0, 0), epoch("B", 2, 0.008)
+	rep, _ := Analyse("DAM-1", cfg, []*Epoch{a, b})
+	if s := series(t, rep, "PR2"); s.Status != StatusAlert || rep.Alerts[0].Kind != "velocity" {
+		t.Errorf("Expected a velocity alert for 8mm in 2 days, got %+v / %+v", s, rep.Alerts)
+	}
+
+	// the same move measured with 5mm precision is within the noise
+	for _, e := range []*Epoch{a, b} {
+		e.Points[2].SigmaE, e.Points[2].SigmaN = h(0.005), h(0.005)
+	}
+	rep, _ = Analyse("DAM-1", cfg, []*Epoch{a, b})
+	if s := series(t, rep, "PR2"); s.Status != StatusStable || len(rep.Alerts) != 0 {
+		t.Errorf("Expected no alert with 5mm precisions, got %+v / %+v", s, rep.Alerts)
+	}
+}
+
+func TestAnalyse_Baseline(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Baseline = "E2"
+	rep, err := Analyse("DAM-1", cfg, []*Epoch{epoch("E1", 0, 0), epoch("E2", 30, 0.03), epoch("E3", 60, 0.03)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := series(t, rep, "PR2"); s.Status != StatusStable {
+		t.Errorf("PR2 hasn't moved since E2, got %+v", s)
+	}
+
+	cfg.Baseline = "nope"
+	if _, err := Analyse("DAM-1", cfg, []*Epoch{epoch("E1", 0, 0)}); err == nil {
+		t.Error("Expected an error for a missing baseline")
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	s, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Epochs("DAM-1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for an empty project, got %v", err)
+	}
+	for _, e := range []*Epoch{epoch("E2", 30, 0), epoch("E1", 0, 0)} {
+		if err := s.SaveEpoch(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	list, err := s.Epochs("DAM-1")
+	if err != nil || len(list) != 2 || list[0].ID != "E1" {
+		t.Fatalf("Expected E1, E2 back in time order, got %v (%v)", list, err)
+	}
+	if len(list[0].Points) != 4 || *list[0].Points[1].Height != 52 {
+		t.Errorf("Points didn't round-trip: %+v", list[0].Points)
+	}
+
+	if err := s.DeleteEpoch("DAM-1", "E1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteEpoch("DAM-1", "E1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound deleting twice, got %v", err)
+	}
+
+	cfg, _ := s.Config("DAM-1")
+	if cfg != DefaultConfig() {
+		t.Errorf("Expected the default config, got %+v", cfg)
+	}
+	cfg.MaxDisplacement = 0.01
+	if err := s.SaveConfig("DAM-1", cfg); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.Config("DAM-1"); got.MaxDisplacement != 0.01 {
+		t.Errorf("Config didn't save, got %+v", got)
+	}
+	cfg.Confidence = 2
+	if err := s.SaveConfig("DAM-1", cfg); err == nil {
+		t.Error("Expected a bad confidence to be rejected")
+	}
+
+	// IDs are escaped like the report store's, so any of them stays inside
+	if err := s.SaveEpoch(epoch("../x", 0, 0)); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"Dam West", "../etc"} {
+		e := epoch("E1", 0, 0)
+		e.ProjectID = id
+		if err := s.SaveEpoch(e); err != nil {
+			t.Fatal(err)
+		}
+		if list, err := s.Epochs(id); err != nil || len(list) != 1 || list[0].ProjectID != id {
+			t.Errorf("%s: expected its epoch back, got %v (%v)", id, list, err)
+		}
+	}
+	projects, err := s.Projects()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(projects)
+	if want := []string{"../etc", "DAM-1", "Dam West"}; !reflect.DeepEqual(projects, want) {
+		t.Errorf("Projects = %q, expected %q", projects, want)
+	}
+	if list, _ := s.Epochs("DAM-1"); len(list) != 2 || list[0].ID != "../x" {
+		t.Errorf("Expected the ../x epoch in DAM-1, got %v", list)
+	}
+	if err := s.SaveEpoch(&Epoch{ProjectID: "", ID: "x"}); err == nil {
+		t.Error("Expected an epoch without a project to be rejected")
+	}
+}
@@ -0,0 +1,161 @@
+package monitor
+
+// store.go - epochs and project settings kept as JSON files on disk
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/survey-validator/store"
+)
+
+// ErrNotFound - no such project or epoch
+var ErrNotFound = errors.New("not found")
+
+// FileStore - one directory per project:
+//
+//	<dir>/<project>/config.json
+//	<dir>/<project>/epochs/<epoch>.json
+//
+// Project and epoch IDs are escaped to make the names, the same way as
+// the report store does (see store.EscapeID).
+type FileStore struct {
+	dir string
+	mu  sync.RWMutex
+}
+
+// NewFileStore - store under dir, created if missing
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// Check - nil if the store can be written to, see store.Writable
+func (s *FileStore) Check() error {
+	return store.Writable(s.dir)
+}
+
+// SaveEpoch - add an epoch, or replace the one with the same ID
+func (s *FileStore) SaveEpoch(e *Epoch) error {
+	if e.ProjectID == "" || e.ID == "" {
+		return fmt.Errorf("an epoch needs a project and an ID")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return store.WriteJSON(s.epochPath(e.ProjectID, e.ID), e)
+}
+
+// Epochs - every epoch of a project, oldest first
+func (s *FileStore) Epochs(project string) ([]*Epoch, error) {
+	if project == "" {
+		return nil, ErrNotFound
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	dir := filepath.Join(s.projectDir(project), "epochs")
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var out []*Epoch
+	for _, ent := range entries {
+		if ent.IsDir() || !strings.HasSuffix(ent.Name(), ".json") {
+			continue
+		}
+		var e Epoch
+		if err := store.ReadJSON(filepath.Join(dir, ent.Name()), &e); err != nil {
+			return nil, err
+		}
+		out = append(out, &e)
+	}
+	if len(out) == 0 {
+		return nil, ErrNotFound
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].ObservedAt.Before(out[j].ObservedAt) })
+	return out, nil
+}
+
+// DeleteEpoch - remove one epoch
+func (s *FileStore) DeleteEpoch(project, id string) error {
+	if project == "" || id == "" {
+		return ErrNotFound
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := os.Remove(s.epochPath(project, id))
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// Config - a project's settings, DefaultConfig until some are saved
+func (s *FileStore) Config(project string) (Config, error) {
+	if project == "" {
+		return Config{}, ErrNotFound
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	cfg := DefaultConfig()
+	err := store.ReadJSON(filepath.Join(s.projectDir(project), "config.json"), &cfg)
+	if errors.Is(err, os.ErrNotExist) {
+		return DefaultConfig(), nil
+	}
+	return cfg, err
+}
+
+// SaveConfig - replace a project's settings
+func (s *FileStore) SaveConfig(project string, cfg Config) error {
+	if project == "" {
+		return fmt.Errorf("a project needs an ID")
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return store.WriteJSON(filepath.Join(s.projectDir(project), "config.json"), cfg)
+}
+
+// Projects - IDs of every project with something stored
+func (s *FileStore) Projects() ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	out := []string{}
+	for _, ent := range entries {
+		if !ent.IsDir() {
+			continue
+		}
+		if id, ok := store.UnescapeID(ent.Name()); ok {
+			out = append(out, id)
+		}
+	}
+	return out, nil
+}
+
+func (s *FileStore) projectDir(project string) string {
+	return filepath.Join(s.dir, store.EscapeID(project))
+}
+
+func (s *FileStore) epochPath(project, id string) string {
+	return filepath.Join(s.projectDir(project), "epochs", store.EscapeID(id)+".json")
+}
@@ -0,0 +1,148 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testPoint struct {
+	ID     string   `json:"id"`
+	E      float64  `json:"e"`
+	Height *float64 `json:"height,omitempty"`
+	Kind   testKind `json:"kind,omitempty"`
+	hidden int
+}
+
+type testKind string
+
+type testBase struct {
+	Name   string      `json:"name"`
+	Points []testPoint `json:"points"`
+	Count  int         `json:"count"`
+}
+
+type testBody struct {
+	testBase
+	Count    string             `json:"count"` // hides testBase.Count
+	When     time.Time          `json:"when"`
+	Settings map[string]float64 `json:"settings,omitempty"`
+	Any      interface{}        `json:"any,omitempty"`
+	Skip     string             `json:"-"`
+}
+
+func testGenerator() *Generator {
+	g := NewGenerator()
+	g.Require(testBase{}, "points")
+	g.Require(testPoint{}, "id", "e")
+	g.Enum(testKind(""), "a", "b")
+	return g
+}
+
+func TestGenerator_Schema(t *testing.T) {
+	g := testGenerator()
+	ref := g.Schema(reflect.TypeOf(&testBody{}))
+	if ref.Ref != "#/components/schemas/testBody" {
+		t.Fatalf("Expected a $ref to testBody, got %+v", ref)
+	}
+
+	body := g.schemas["testBody"]
+	var names []string
+	for name := range body.Properties {
+		names = append(names, name)
+	}
+	want := "any,count,name,points,settings,when"
+	if got := strings.Join(sorted(names), ","); got != want {
+		t.Errorf("Expected properties %s, got %s", want, got)
+	}
+	if body.Properties["count"].Type != "string" {
+		t.Errorf("Expected the outer count to hide the embedded one, got %+v", body.Properties["count"])
+	}
+	if !reflect.DeepEqual(body.Required, []string{"points"}) {
+		t.Errorf("Expected the embedded struct's required fields, got %v", body.Required)
+	}
+	if s := body.Properties["when"]; s.Type != "string" || s.Format != "date-time" {
+		t.Errorf("Expected time as date-time, got %+v", s)
+	}
+	if s := body.Properties["settings"]; s.Type != "object" || s.AdditionalProperties.Type != "number" {
+		t.Errorf("Expected a map of numbers, got %+v", s)
+	}
+
+	point := g.schemas["testPoint"]
+	if point.Properties["kind"].Enum == nil || point.Properties["height"].Type != "number" {
+		t.Errorf("Expected the enum and the pointer's element type, got %+v", point.Properties)
+	}
+	if _, ok := point.Properties["hidden"]; ok {
+		t.Error("Expected unexported fields to be left out")
+	}
+
+	doc := NewDocument(Info{Title: "t", Version: "1"}, g)
+	doc.Add("POST", "/things/{id}", &Operation{OperationID: "add", RequestBody: doc.Body(testBody{})})
+	out, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{`"openapi":"3.0.3"`, `"name":"id","in":"path","required":true`, `"$ref":"#/components/schemas/testBody"`} {
+		if !strings.Contains(string(out), s) {
+			t.Errorf("Expected %s in %s", s, out)
+		}
+	}
+}
+
+func TestGenerator_Validate(t *testing.T) {
+	g := testGenerator()
+	typ := reflect.TypeOf(testBody{})
+
+	valid := `{"name": "x", "points": [{"id": "P1", "e": 1, "kind": "a"}], "when": "2026-01-31T09:00:00Z",
+		"settings": {"a": 1}, "any": [1, "two"], "extra": true, "count": null}`
+	var v interface{}
+	json.Unmarshal([]byte(valid), &v)
+	if err := g.Validate(typ, v); err != nil {
+		t.Errorf("Expected a valid body, got %v", err)
+	}
+
+	bad := `{"name": 3, "points": [{"id": "P1"}, {"id": "P2", "e": "1", "kind": "c"}], "when": "yesterday", "settings": {"a": "b"}}`
+	json.Unmarshal([]byte(bad), &v)
+	err := g.Validate(typ, v)
+	errs, ok := err.(Errors)
+	if !ok {
+		t.Fatalf("Expected Errors, got %v", err)
+	}
+	want := []string{
+		"/name: expected a string, got a number",
+		"/points/0/e: is required",
+		"/points/1/e: expected a number, got a string",
+		"/points/1/kind: must be one of a, b, got \"c\"",
+		"/settings/a: expected a number, got a string",
+		"/when: expected an RFC 3339 time like 2026-01-31T09:00:00Z, got \"yesterday\"",
+	}
+	var got []string
+	for _, e := range errs {
+		got = append(got, e.String())
+	}
+	if !reflect.DeepEqual(sorted(got), want) {
+		t.Errorf("Expected\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+	for _, e := range errs {
+		if e.Pointer == "/points/1/kind" && (e.Code != CodeInvalidValue || e.Value != "c") {
+			t.Errorf("Expected the enum error to carry its code and value, got %+v", e)
+		}
+		if e.Pointer == "/points/0/e" && e.Code != CodeRequired {
+			t.Errorf("Expected a required code, got %+v", e)
+		}
+	}
+
+	json.Unmarshal([]byte(`[]`), &v)
+	if err := g.Validate(typ, v); err == nil || err.Error() != "expected an object, got an array" {
+		t.Errorf("Expected the body itself to be wrong, got %v", err)
+	}
+}
+
+func sorted(s []string) []string {
+	out := append([]string(nil), s...)
+	sort.Strings(out)
+	return out
+}
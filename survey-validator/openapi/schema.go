@@ -0,0 +1,224 @@
+package openapi
+
+// schema.go - schemas read off the Go types the API decodes and encodes,
+// so the document can't drift from the structs
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Schema - the subset of JSON Schema that OpenAPI 3.0 uses, as far as the
+// API needs it
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	MinItems             int                `json:"minItems,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Generator - component schemas for named struct types, made once each
+// and referred to by $ref after that
+type Generator struct {
+	mu       sync.Mutex // Validate may be called from many requests
+	schemas  map[string]*Schema
+	names    map[reflect.Type]string
+	enums    map[reflect.Type][]string
+	required map[reflect.Type][]string
+	describe map[reflect.Type]string
+	fields   map[reflect.Type]map[string]func(*Schema)
+	rename   map[reflect.Type]string
+}
+
+func NewGenerator() *Generator {
+	return &Generator{
+		schemas:  make(map[string]*Schema),
+		names:    make(map[reflect.Type]string),
+		enums:    make(map[reflect.Type][]string),
+		required: make(map[reflect.Type][]string),
+		describe: make(map[reflect.Type]string),
+		fields:   make(map[reflect.Type]map[string]func(*Schema)),
+		rename:   make(map[reflect.Type]string),
+	}
+}
+
+// Enum - the values a named string type can take. Only for types the
+// server checks itself; the schema will reject anything else.
+func (g *Generator) Enum(v interface{}, values ...string) {
+	g.enums[reflect.TypeOf(v)] = values
+}
+
+// Require - JSON fields of v's struct type a request must have. Go's
+// omitempty says what is left out of responses, not what requests need,
+// so nothing is required unless it is listed here.
+func (g *Generator) Require(v interface{}, fields ...string) {
+	t := reflect.TypeOf(v)
+	g.required[t] = append(g.required[t], fields...)
+}
+
+// Name - the component name for v's struct type, for type names that
+// only make sense next to their package name (compare.Result)
+func (g *Generator) Name(v interface{}, name string) {
+	g.rename[reflect.TypeOf(v)] = name
+}
+
+// Describe - a description for v's type
+func (g *Generator) Describe(v interface{}, description string) {
+	g.describe[reflect.TypeOf(v)] = description
+}
+
+// Field - adjust the schema of one field of v's struct type, say to add
+// a description or a minimum
+func (g *Generator) Field(v interface{}, field string, fn func(*Schema)) {
+	t := reflect.TypeOf(v)
+	if g.fields[t] == nil {
+		g.fields[t] = make(map[string]func(*Schema))
+	}
+	g.fields[t][field] = fn
+}
+
+// Schema - the schema for t, a $ref for named structs
+func (g *Generator) Schema(t reflect.Type) *Schema {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.schema(t)
+}
+
+func (g *Generator) schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+	if values, ok := g.enums[t]; ok {
+		return &Schema{Type: "string", Enum: values, Description: g.describe[t]}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string", Description: g.describe[t]}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem()), Description: g.describe[t]}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + g.component(t)}
+	}
+	return &Schema{} // interface{}: anything
+}
+
+// component - the name t's schema is kept under, made on first use
+func (g *Generator) component(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+	name := t.Name()
+	if n, ok := g.rename[t]; ok {
+		name = n
+	}
+	if _, taken := g.schemas[name]; taken {
+		pkg := t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]
+		name = string(unicode.ToUpper(rune(pkg[0]))) + pkg[1:] + name
+	}
+	g.names[t] = name
+	g.schemas[name] = &Schema{} // placeholder, for types that refer to themselves
+	*g.schemas[name] = *g.object(t)
+	return name
+}
+
+// object - a struct's fields as encoding/json sees them, embedded structs
+// flattened into their parent along with what they require
+func (g *Generator) object(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema), Description: g.describe[t]}
+	g.addFields(s, t)
+	return s
+}
+
+func (g *Generator) addFields(s *Schema, t reflect.Type) {
+	var embedded []reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		ft := f.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			embedded = append(embedded, ft)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fs := g.schema(f.Type)
+		if fn := g.fields[t][name]; fn != nil {
+			fn(fs)
+		}
+		s.Properties[name] = fs
+	}
+
+	// as in encoding/json, a field of the struct itself hides one of the
+	// same name from an embedded struct
+	for _, et := range embedded {
+		inner := &Schema{Properties: make(map[string]*Schema)}
+		g.addFields(inner, et)
+		for name, fs := range inner.Properties {
+			if _, ok := s.Properties[name]; !ok {
+				s.Properties[name] = fs
+			}
+		}
+		for _, name := range inner.Required {
+			if !contains(s.Required, name) {
+				s.Required = append(s.Required, name)
+			}
+		}
+	}
+	for _, name := range g.required[t] {
+		if _, ok := s.Properties[name]; ok && !contains(s.Required, name) {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
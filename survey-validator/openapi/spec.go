@@ -0,0 +1,163 @@
+package openapi
+
+// spec.go - just enough of the OpenAPI 3.0 document model to describe the
+// API, plus building one up operation by operation
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// Document - an OpenAPI 3.0 document
+type Document struct {
+	OpenAPI    string                `json:"openapi"`
+	Info       Info                  `json:"info"`
+	Servers    []Server              `json:"servers,omitempty"`
+	Paths      map[string]*PathItem  `json:"paths"`
+	Components Components            `json:"components"`
+	Security   []SecurityRequirement `json:"security,omitempty"`
+
+	gen *Generator
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type Server struct {
+	URL         string `json:"url"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem - the operations on one path, by lower-case method
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []SecurityRequirement `json:"security,omitempty"` // [{}] for none
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"` // path or query
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Description string               `json:"description,omitempty"`
+	Required    bool                 `json:"required,omitempty"`
+	Content     map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme - how a client authenticates, apiKey or http (bearer)
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	Scheme      string `json:"scheme,omitempty"` // http: bearer
+	In          string `json:"in,omitempty"`     // apiKey: header
+	Name        string `json:"name,omitempty"`   // apiKey: the header's name
+	Description string `json:"description,omitempty"`
+}
+
+// SecurityRequirement - scheme names to the scopes needed, any one of the
+// requirements in a list will do
+type SecurityRequirement map[string][]string
+
+// NewDocument - an empty document whose schemas come from g
+func NewDocument(info Info, g *Generator) *Document {
+	return &Document{
+		OpenAPI:    "3.0.3",
+		Info:       info,
+		Paths:      make(map[string]*PathItem),
+		Components: Components{Schemas: g.schemas},
+		gen:        g,
+	}
+}
+
+// Add - an operation on path. Path parameters ({id}) are added as
+// required strings unless op already lists them.
+func (d *Document) Add(method, path string, op *Operation) {
+	item := d.Paths[path]
+	if item == nil {
+		item = &PathItem{}
+		d.Paths[path] = item
+	}
+	for _, seg := range strings.Split(path, "/") {
+		if !strings.HasPrefix(seg, "{") {
+			continue
+		}
+		name := strings.Trim(seg, "{}")
+		if !hasParam(op.Parameters, name) {
+			op.Parameters = append(op.Parameters, Parameter{Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"}})
+		}
+	}
+	if op.Responses == nil {
+		op.Responses = make(map[string]*Response)
+	}
+	(*item)[strings.ToLower(method)] = op
+}
+
+func hasParam(params []Parameter, name string) bool {
+	for _, p := range params {
+		if p.Name == name && p.In == "path" {
+			return true
+		}
+	}
+	return false
+}
+
+// JSON - a request body or response content of v's type
+func (d *Document) JSON(v interface{}) map[string]MediaType {
+	return map[string]MediaType{"application/json": {Schema: d.gen.Schema(reflect.TypeOf(v))}}
+}
+
+// Body - a required JSON request body of v's type
+func (d *Document) Body(v interface{}) *RequestBody {
+	return &RequestBody{Required: true, Content: d.JSON(v)}
+}
+
+// Reply - a JSON response of v's type, or no content for nil
+func (d *Document) Reply(description string, v interface{}) *Response {
+	if v == nil {
+		return &Response{Description: description}
+	}
+	return &Response{Description: description, Content: d.JSON(v)}
+}
+
+// Validate - check a decoded JSON body against the schema for v's type
+func (d *Document) Validate(v interface{}, body interface{}) error {
+	return d.gen.Validate(reflect.TypeOf(v), body)
+}
+
+// MarshalJSON - the document, with the schemas held still while they are
+// written out
+func (d *Document) MarshalJSON() ([]byte, error) {
+	d.gen.mu.Lock()
+	defer d.gen.mu.Unlock()
+	type plain Document
+	return json.Marshal((*plain)(d))
+}
@@ -0,0 +1,202 @@
+package openapi
+
+// validate.go - a decoded JSON body checked against a schema, so a bad
+// request is told every field that is wrong rather than the first thing
+// encoding/json tripped on
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxErrors - past this many, Errors stops listing them
+const maxErrors = 20
+
+// what is wrong with a field, FieldError.Code
+const (
+	CodeRequired      = "required"
+	CodeWrongType     = "wrong_type"
+	CodeInvalidValue  = "invalid_value" // not one of the enum's values
+	CodeInvalidFormat = "invalid_format"
+	CodeTooSmall      = "too_small"
+	CodeTooFewItems   = "too_few_items"
+)
+
+// FieldError - one thing wrong with a request body. Pointer is a JSON
+// pointer (RFC 6901) like /points/3/easting, "" for the body itself.
+// Value is the offending value, left out for objects and arrays.
+type FieldError struct {
+	Code    string      `json:"code"`
+	Pointer string      `json:"pointer"`
+	Message string      `json:"message"`
+	Value   interface{} `json:"value,omitempty"`
+}
+
+func (e FieldError) String() string {
+	if e.Pointer == "" {
+		return e.Message
+	}
+	return e.Pointer + ": " + e.Message
+}
+
+// Errors - everything wrong with a body, up to maxErrors of it
+type Errors []FieldError
+
+func (errs Errors) Error() string {
+	parts := make([]string, len(errs))
+	for i, e := range errs {
+		parts[i] = e.String()
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Validate - check body, as decoded into interface{} by encoding/json,
+// against the schema for t. Nil, or Errors.
+func (g *Generator) Validate(t reflect.Type, body interface{}) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	v := validator{g: g}
+	v.check("", g.schema(t), body)
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}
+
+type validator struct {
+	g    *Generator
+	errs Errors
+}
+
+func (v *validator) fail(path, code string, value interface{}, format string, args ...interface{}) {
+	if len(v.errs) >= maxErrors {
+		return
+	}
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+		value = nil
+	}
+	v.errs = append(v.errs, FieldError{Code: code, Pointer: path, Message: fmt.Sprintf(format, args...), Value: value})
+}
+
+func (v *validator) resolve(s *Schema) *Schema {
+	for s.Ref != "" {
+		s = v.g.schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+	}
+	return s
+}
+
+func (v *validator) check(path string, s *Schema, value interface{}) {
+	s = v.resolve(s)
+	// encoding/json takes null for anything and leaves the zero value
+	if value == nil || len(v.errs) >= maxErrors {
+		return
+	}
+
+	switch s.Type {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			v.fail(path, CodeWrongType, value, "expected an object, got %s", kind(value))
+			return
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				v.fail(join(path, name), CodeRequired, nil, "is required")
+			}
+		}
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if ps, ok := s.Properties[k]; ok {
+				v.check(join(path, k), ps, obj[k])
+			} else if s.AdditionalProperties != nil {
+				v.check(join(path, k), s.AdditionalProperties, obj[k])
+			}
+			// other keys are ignored, as encoding/json ignores them
+		}
+	case "array":
+		arr, ok := value.([]interface{})
+		if !ok {
+			v.fail(path, CodeWrongType, value, "expected an array, got %s", kind(value))
+			return
+		}
+		if len(arr) < s.MinItems {
+			v.fail(path, CodeTooFewItems, nil, "needs at least %d item(s)", s.MinItems)
+		}
+		for i, item := range arr {
+			v.check(path+"/"+strconv.Itoa(i), s.Items, item)
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			v.fail(path, CodeWrongType, value, "expected a string, got %s", kind(value))
+			return
+		}
+		if len(s.Enum) > 0 && !contains(s.Enum, str) {
+			v.fail(path, CodeInvalidValue, str, "must be one of %s, got %q", enumList(s.Enum), str)
+		}
+		if s.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339, str); err != nil {
+				v.fail(path, CodeInvalidFormat, str, "expected an RFC 3339 time like 2026-01-31T09:00:00Z, got %q", str)
+			}
+		}
+	case "number", "integer":
+		n, ok := value.(float64)
+		if !ok {
+			v.fail(path, CodeWrongType, value, "expected a number, got %s", kind(value))
+			return
+		}
+		if s.Type == "integer" && n != math.Trunc(n) {
+			v.fail(path, CodeWrongType, n, "expected a whole number, got %v", n)
+		}
+		if s.Minimum != nil && n < *s.Minimum {
+			v.fail(path, CodeTooSmall, n, "must be at least %v, got %v", *s.Minimum, n)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			v.fail(path, CodeWrongType, value, "expected true or false, got %s", kind(value))
+		}
+	}
+}
+
+// join - path with one more key, escaped as RFC 6901 says
+func join(path, key string) string {
+	return path + "/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
+
+// enumList - the allowed values, "" shown as (empty)
+func enumList(values []string) string {
+	out := make([]string, len(values))
+	for i, v := range values {
+		if v == "" {
+			v = "(empty)"
+		}
+		out[i] = v
+	}
+	return strings.Join(out, ", ")
+}
+
+func kind(value interface{}) string {
+	switch value.(type) {
+	case map[string]interface{}:
+		return "an object"
+	case []interface{}:
+		return "an array"
+	case string:
+		return "a string"
+	case float64:
+		return "a number"
+	case bool:
+		return "a boolean"
+	}
+	return fmt.Sprintf("%T", value)
+}
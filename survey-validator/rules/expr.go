@@ -0,0 +1,574 @@
+package rules
+
+// expr.go - the little expression language rules are written in.
+//
+//   code == 'TREE' && has(height) && height < max_height
+//
+// Values are numbers, strings, booleans and null (a missing height).
+// Operators: || && ! == != < <= > >= + - * / % and parentheses, with
+// `and`, `or`, `not` as spellings of the logical ones. Comparing anything
+// with null is false (except == null / != null), arithmetic with null is null.
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// value - float64, string, bool or nil
+type value interface{}
+
+// env - looks up identifiers while evaluating
+type env func(name string) (value, bool)
+
+type expr interface {
+	eval(env env) (value, error)
+}
+
+// compile - parse an expression. known says which identifiers exist so
+// typos are caught when the rule loads, not on the first point.
+func compile(src string, known func(name string) bool) (expr, error) {
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks, known: known}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+	}
+	return e, nil
+}
+
+// --- lexer ---
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokNumber
+	tokString
+	tokIdent
+	tokOp
+)
+
+type token struct {
+	kind tokKind
+	text string
+	num  float64
+	pos  int
+}
+
+func lex(src string) ([]token, error) {
+	var toks []token
+	rs := []rune(src)
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case unicode.IsDigit(r) || (r == '.' && i+1 < len(rs) && unicode.IsDigit(rs[i+1])):
+			start := i
+			for i < len(rs) && (unicode.IsDigit(rs[i]) || rs[i] == '.' || rs[i] == 'e' || rs[i] == 'E' ||
+				((rs[i] == '-' || rs[i] == '+') && (rs[i-1] == 'e' || rs[i-1] == 'E'))) {
+				i++
+			}
+			text := string(rs[start:i])
+			n, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				return nil, fmt.Errorf("bad number %q at %d", text, start)
+			}
+			toks = append(toks, token{kind: tokNumber, text: text, num: n, pos: start})
+
+		case r == '\'' || r == '"':
+			start := i
+			i++
+			var b strings.Builder
+			for i < len(rs) && rs[i] != r {
+				if rs[i] == '\\' && i+1 < len(rs) {
+					i++
+				}
+				b.WriteRune(rs[i])
+				i++
+			}
+			if i >= len(rs) {
+				return nil, fmt.Errorf("unterminated string at %d", start)
+			}
+			i++
+			toks = append(toks, token{kind: tokString, text: b.String(), pos: start})
+
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(rs) && (unicode.IsLetter(rs[i]) || unicode.IsDigit(rs[i]) || rs[i] == '_') {
+				i++
+			}
+			toks = append(toks, token{kind: tokIdent, text: string(rs[start:i]), pos: start})
+
+		default:
+			start := i
+			two := ""
+			if i+1 < len(rs) {
+				two = string(rs[i : i+2])
+			}
+			switch two {
+			case "==", "!=", "<=", ">=", "&&", "||":
+				toks = append(toks, token{kind: tokOp, text: two, pos: start})
+				i += 2
+				continue
+			}
+			if !strings.ContainsRune("<>!+-*/%(),", r) {
+				return nil, fmt.Errorf("unexpected %q at %d", string(r), start)
+			}
+			toks = append(toks, token{kind: tokOp, text: string(r), pos: start})
+			i++
+		}
+	}
+	return append(toks, token{kind: tokEOF, pos: len(rs)}), nil
+}
+
+// --- parser, precedence climbing from || down to unary ---
+
+type parser struct {
+	toks  []token
+	pos   int
+	known func(string) bool
+}
+
+func (p *parser) peek() token { return p.toks[p.pos] }
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept - consume the next token if it is one of ops (or the word forms)
+func (p *parser) accept(ops ...string) (string, bool) {
+	t := p.peek()
+	for _, op := range ops {
+		if (t.kind == tokOp || t.kind == tokIdent) && t.text == op {
+			p.next()
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *parser) parseOr() (expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("||", "or"); !ok {
+			return left, nil
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logical{op: "||", left: left, right: right}
+	}
+}
+
+func (p *parser) parseAnd() (expr, error) {
+	left, err := p.parseCompare()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("&&", "and"); !ok {
+			return left, nil
+		}
+		right, err := p.parseCompare()
+		if err != nil {
+			return nil, err
+		}
+		left = &logical{op: "&&", left: left, right: right}
+	}
+}
+
+func (p *parser) parseCompare() (expr, error) {
+	left, err := p.parseAdd()
+	if err != nil {
+		return nil, err
+	}
+	if op, ok := p.accept("==", "!=", "<=", ">=", "<", ">"); ok {
+		right, err := p.parseAdd()
+		if err != nil {
+			return nil, err
+		}
+		return &binary{op: op, left: left, right: right}, nil
+	}
+	return left, nil
+}
+
+func (p *parser) parseAdd() (expr, error) {
+	left, err := p.parseMul()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept("+", "-")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.parseMul()
+		if err != nil {
+			return nil, err
+		}
+		left = &binary{op: op, left: left, right: right}
+	}
+}
+
+func (p *parser) parseMul() (expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept("*", "/", "%")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &binary{op: op, left: left, right: right}
+	}
+}
+
+func (p *parser) parseUnary() (expr, error) {
+	if op, ok := p.accept("!", "not", "-"); ok {
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if op == "not" {
+			op = "!"
+		}
+		return &unary{op: op, x: x}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (expr, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNumber:
+		return literal{t.num}, nil
+	case tokString:
+		return literal{t.text}, nil
+	case tokOp:
+		if t.text == "(" {
+			e, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := p.accept(")"); !ok {
+				return nil, fmt.Errorf("missing ) at %d", p.peek().pos)
+			}
+			return e, nil
+		}
+	case tokIdent:
+		switch t.text {
+		case "true":
+			return literal{true}, nil
+		case "false":
+			return literal{false}, nil
+		case "null":
+			return literal{nil}, nil
+		}
+		if _, ok := p.accept("("); ok {
+			return p.parseCall(t)
+		}
+		if p.known != nil && !p.known(t.text) {
+			return nil, fmt.Errorf("unknown name %q at %d", t.text, t.pos)
+		}
+		return ident(t.text), nil
+	case tokEOF:
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+}
+
+func (p *parser) parseCall(name token) (expr, error) {
+	fn, ok := functions[name.text]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %q at %d", name.text, name.pos)
+	}
+
+	var args []expr
+	if _, ok := p.accept(")"); !ok {
+		for {
+			arg, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if _, ok := p.accept(","); ok {
+				continue
+			}
+			if _, ok := p.accept(")"); !ok {
+				return nil, fmt.Errorf("expected , or ) at %d", p.peek().pos)
+			}
+			break
+		}
+	}
+
+	if len(args) < fn.minArgs || (fn.maxArgs >= 0 && len(args) > fn.maxArgs) {
+		return nil, fmt.Errorf("%s: wrong number of arguments (%d)", name.text, len(args))
+	}
+
+	c := &call{name: name.text, fn: fn, args: args}
+	// a literal pattern is compiled once, and checked now
+	if name.text == "matches" {
+		if lit, ok := args[1].(literal); ok {
+			s, _ := lit.v.(string)
+			re, err := regexp.Compile(s)
+			if err != nil {
+				return nil, fmt.Errorf("matches: %v", err)
+			}
+			c.re = re
+		}
+	}
+	return c, nil
+}
+
+// --- evaluation ---
+
+type literal struct{ v value }
+
+func (l literal) eval(env) (value, error) { return l.v, nil }
+
+type ident string
+
+func (i ident) eval(env env) (value, error) {
+	v, ok := env(string(i))
+	if !ok {
+		return nil, fmt.Errorf("unknown name %q", string(i))
+	}
+	return v, nil
+}
+
+type unary struct {
+	op string
+	x  expr
+}
+
+func (u *unary) eval(env env) (value, error) {
+	v, err := u.x.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	if u.op == "!" {
+		return !truthy(v), nil
+	}
+	if v == nil {
+		return nil, nil
+	}
+	n, ok := v.(float64)
+	if !ok {
+		return nil, fmt.Errorf("cannot negate %s", typeName(v))
+	}
+	return -n, nil
+}
+
+type logical struct {
+	op          string
+	left, right expr
+}
+
+func (l *logical) eval(env env) (value, error) {
+	a, err := l.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	if l.op == "&&" && !truthy(a) {
+		return false, nil
+	}
+	if l.op == "||" && truthy(a) {
+		return true, nil
+	}
+	b, err := l.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	return truthy(b), nil
+}
+
+type binary struct {
+	op          string
+	left, right expr
+}
+
+func (b *binary) eval(env env) (value, error) {
+	x, err := b.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	y, err := b.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	switch b.op {
+	case "==":
+		return equal(x, y), nil
+	case "!=":
+		return !equal(x, y), nil
+	case "<", "<=", ">", ">=":
+		if x == nil || y == nil {
+			return false, nil
+		}
+		c, err := compare(x, y)
+		if err != nil {
+			return nil, err
+		}
+		switch b.op {
+		case "<":
+			return c < 0, nil
+		case "<=":
+			return c <= 0, nil
+		case ">":
+			return c > 0, nil
+		}
+		return c >= 0, nil
+	}
+
+	// arithmetic
+	if x == nil || y == nil {
+		return nil, nil
+	}
+	if b.op == "+" {
+		if xs, ok := x.(string); ok {
+			return xs + toString(y), nil
+		}
+	}
+	xn, xok := x.(float64)
+	yn, yok := y.(float64)
+	if !xok || !yok {
+		return nil, fmt.Errorf("cannot apply %s to %s and %s", b.op, typeName(x), typeName(y))
+	}
+	switch b.op {
+	case "+":
+		return xn + yn, nil
+	case "-":
+		return xn - yn, nil
+	case "*":
+		return xn * yn, nil
+	case "/":
+		if yn == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return xn / yn, nil
+	}
+	if yn == 0 {
+		return nil, fmt.Errorf("division by zero")
+	}
+	return math.Mod(xn, yn), nil
+}
+
+type call struct {
+	name string
+	fn   function
+	args []expr
+	re   *regexp.Regexp
+}
+
+func (c *call) eval(env env) (value, error) {
+	args := make([]value, len(c.args))
+	for i, a := range c.args {
+		v, err := a.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	if c.re != nil {
+		s, _ := args[0].(string)
+		return c.re.MatchString(s), nil
+	}
+	v, err := c.fn.call(args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", c.name, err)
+	}
+	return v, nil
+}
+
+// --- helpers ---
+
+func truthy(v value) bool {
+	switch x := v.(type) {
+	case bool:
+		return x
+	case float64:
+		return x != 0
+	case string:
+		return x != ""
+	}
+	return false
+}
+
+func equal(x, y value) bool {
+	if x == nil || y == nil {
+		return x == nil && y == nil
+	}
+	return x == y
+}
+
+func compare(x, y value) (int, error) {
+	switch a := x.(type) {
+	case float64:
+		if b, ok := y.(float64); ok {
+			switch {
+			case a < b:
+				return -1, nil
+			case a > b:
+				return 1, nil
+			}
+			return 0, nil
+		}
+	case string:
+		if b, ok := y.(string); ok {
+			return strings.Compare(a, b), nil
+		}
+	}
+	return 0, fmt.Errorf("cannot compare %s with %s", typeName(x), typeName(y))
+}
+
+func typeName(v value) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func toString(v value) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case string:
+		return x
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,91 @@
+package rules
+
+import (
+	"testing"
+)
+
+func TestExpressions(t *testing.T) {
+	vars := map[string]value{
+		"height": 12.5,
+		"code":   "TREE",
+		"none":   nil,
+	}
+	env := func(name string) (value, bool) {
+		v, ok := vars[name]
+		return v, ok
+	}
+
+	tests := []struct {
+		src      string
+		expected value
+	}{
+		{"1 + 2 * 3", 7.0},
+		{"(1 + 2) * 3", 9.0},
+		{"-height + 2.5", -10.0},
+		{"10 % 4", 2.0},
+		{"height > 10 && height < 20", true},
+		{"height > 10 and not (code == 'TREE')", false},
+		{"code == \"TREE\" || false", true},
+		{"none < 5", false},
+		{"none == null", true},
+		{"has(none) or has(height)", true},
+		{"none + 1 == null", true},
+		{"'a' + 1", "a1"},
+		{"lower(code) == 'tree'", true},
+		{"matches(code, '^TR')", true},
+		{"in(code, 'FENCE', 'TREE')", true},
+		{"round(1.23456, 2)", 1.23},
+		{"max(1, none, 3)", 3.0},
+		{"len(code)", 4.0},
+		{"1e3 == 1000", true},
+	}
+
+	for _, tt := range tests {
+		e, err := compile(tt.src, func(string) bool { return true })
+		if err != nil {
+			t.Errorf("compile(%q): %v", tt.src, err)
+			continue
+		}
+		got, err := e.eval(env)
+		if err != nil {
+			t.Errorf("eval(%q): %v", tt.src, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("eval(%q) = %v; expected %v", tt.src, got, tt.expected)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	known := func(name string) bool { return name == "height" }
+	bad := []string{
+		"",
+		"height >",
+		"(height > 1",
+		"heigth > 1",
+		"nope(height)",
+		"abs(1, 2)",
+		"matches(height, '[')",
+		"'unterminated",
+		"height # 1",
+	}
+	for _, src := range bad {
+		if _, err := compile(src, known); err == nil {
+			t.Errorf("compile(%q) should fail", src)
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	env := func(name string) (value, bool) { return "text", true }
+	for _, src := range []string{"x < 1", "x * 2", "1 / 0"} {
+		e, err := compile(src, nil)
+		if err != nil {
+			t.Fatalf("compile(%q): %v", src, err)
+		}
+		if _, err := e.eval(env); err == nil {
+			t.Errorf("eval(%q) should fail", src)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package rules
+
+// functions.go - functions callable from rule expressions
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+)
+
+type function struct {
+	minArgs, maxArgs int // maxArgs -1 means any number
+	call             func(args []value) (value, error)
+}
+
+var functions = map[string]function{
+	// has(x) - x is not null, e.g. has(height)
+	"has": {1, 1, func(a []value) (value, error) { return a[0] != nil, nil }},
+
+	"abs":   numeric1(math.Abs),
+	"sqrt":  numeric1(math.Sqrt),
+	"floor": numeric1(math.Floor),
+	"ceil":  numeric1(math.Ceil),
+	"round": {1, 2, func(a []value) (value, error) {
+		x, ok := a[0].(float64)
+		if !ok {
+			return nil, nil
+		}
+		scale := 1.0
+		if len(a) == 2 {
+			dp, ok := a[1].(float64)
+			if !ok {
+				return nil, fmt.Errorf("decimal places must be a number")
+			}
+			scale = math.Pow(10, dp)
+		}
+		return math.Round(x*scale) / scale, nil
+	}},
+	"min": {1, -1, func(a []value) (value, error) { return fold(a, math.Min) }},
+	"max": {1, -1, func(a []value) (value, error) { return fold(a, math.Max) }},
+
+	"len":   {1, 1, func(a []value) (value, error) { return float64(len([]rune(toString(a[0])))), nil }},
+	"lower": {1, 1, func(a []value) (value, error) { return strings.ToLower(toString(a[0])), nil }},
+	"upper": {1, 1, func(a []value) (value, error) { return strings.ToUpper(toString(a[0])), nil }},
+	"contains": {2, 2, func(a []value) (value, error) {
+		return strings.Contains(toString(a[0]), toString(a[1])), nil
+	}},
+	"startswith": {2, 2, func(a []value) (value, error) {
+		return strings.HasPrefix(toString(a[0]), toString(a[1])), nil
+	}},
+	"endswith": {2, 2, func(a []value) (value, error) {
+		return strings.HasSuffix(toString(a[0]), toString(a[1])), nil
+	}},
+	// matches(s, pattern) - Go regexp; literal patterns are compiled at load
+	"matches": {2, 2, func(a []value) (value, error) {
+		re, err := regexp.Compile(toString(a[1]))
+		if err != nil {
+			return nil, err
+		}
+		return re.MatchString(toString(a[0])), nil
+	}},
+	// in(x, a, b, ...) - x equals one of the rest
+	"in": {2, -1, func(a []value) (value, error) {
+		for _, v := range a[1:] {
+			if equal(a[0], v) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}},
+}
+
+func numeric1(f func(float64) float64) function {
+	return function{1, 1, func(a []value) (value, error) {
+		if a[0] == nil {
+			return nil, nil
+		}
+		x, ok := a[0].(float64)
+		if !ok {
+			return nil, fmt.Errorf("expected a number, got %s", typeName(a[0]))
+		}
+		return f(x), nil
+	}}
+}
+
+// fold - min/max over numbers, nulls ignored
+func fold(a []value, f func(x, y float64) float64) (value, error) {
+	var out value
+	for _, v := range a {
+		if v == nil {
+			continue
+		}
+		x, ok := v.(float64)
+		if !ok {
+			return nil, fmt.Errorf("expected numbers, got %s", typeName(v))
+		}
+		if out == nil {
+			out = x
+		} else {
+			out = f(out.(float64), x)
+		}
+	}
+	return out, nil
+}
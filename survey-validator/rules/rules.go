@@ -0,0 +1,296 @@
+package rules
+
+// rules.go - organisation-specific checks written as expressions over
+// points and loaded from a JSON file, no recompiling needed
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/survey-validator/engine"
+	"github.com/survey-validator/models"
+)
+
+// Rule - one check, tested against every point it applies to.
+//
+//	{
+//	  "name": "tree_height",
+//	  "description": "Trees need a height within the site range",
+//	  "severity": "warning",
+//	  "applies_to": ["detail"],
+//	  "when": "code == 'TREE'",
+//	  "assert": "has(height) && height < max_height",
+//	  "message": "Tree {point_id} height {height} is missing or above {max_height}",
+//	  "config": {"max_height": {"type": "number", "default": 3000}}
+//	}
+type Rule struct {
+	Name        string                      `json:"name"`
+	Description string                      `json:"description,omitempty"`
+	Version     string                      `json:"version,omitempty"`
+	Severity    models.IssueSeverity        `json:"severity"`
+	Category    string                      `json:"category,omitempty"` // for the score, default custom
+	AppliesTo   []models.SurveyType         `json:"applies_to,omitempty"`
+	When        string                      `json:"when,omitempty"` // only points where this is true
+	Assert      string                      `json:"assert"`         // issue when this is false
+	Message     string                      `json:"message,omitempty"`
+	Config      map[string]engine.ParamSpec `json:"config,omitempty"`
+	DependsOn   []string                    `json:"depends_on,omitempty"`
+	Priority    *int                        `json:"priority,omitempty"`
+
+	when   expr
+	assert expr
+}
+
+// point fields a rule can use
+var pointFields = map[string]func(p *models.SurveyPoint, i int) value{
+	"point_id":    func(p *models.SurveyPoint, i int) value { return p.PointID },
+	"easting":     func(p *models.SurveyPoint, i int) value { return p.Easting },
+	"northing":    func(p *models.SurveyPoint, i int) value { return p.Northing },
+	"survey_type": func(p *models.SurveyPoint, i int) value { return string(p.SurveyType) },
+	"code":        func(p *models.SurveyPoint, i int) value { return p.Code },
+	"description": func(p *models.SurveyPoint, i int) value { return p.Description },
+	"index":       func(p *models.SurveyPoint, i int) value { return float64(i) },
+	"height": func(p *models.SurveyPoint, i int) value {
+		if p.Height == nil {
+			return nil
+		}
+		return *p.Height
+	},
+}
+
+var ruleName = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// defaultRulePriority - rules report after the built-in checks
+const defaultRulePriority = 200
+
+// Load - read and compile a JSON array of rules
+func Load(r io.Reader) ([]*Rule, error) {
+	var list []*Rule
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&list); err != nil {
+		return nil, fmt.Errorf("rules: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	for _, rule := range list {
+		if seen[rule.Name] {
+			return nil, fmt.Errorf("rules: %s defined twice", rule.Name)
+		}
+		seen[rule.Name] = true
+		if err := rule.compile(); err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+// LoadFile - Load from a path
+func LoadFile(path string) ([]*Rule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Load(f)
+}
+
+// Register - add rules to an engine. A rule can't replace a check that is
+// already registered, so a rules file can't quietly switch off a built-in.
+func Register(e *engine.Engine, list []*Rule) error {
+	for _, rule := range list {
+		if e.HasCheck(rule.Name) {
+			return fmt.Errorf("rules: %s is already a registered check", rule.Name)
+		}
+		if err := e.Add(rule); err != nil {
+			return fmt.Errorf("rules: %w", err)
+		}
+	}
+	return nil
+}
+
+func (r *Rule) compile() error {
+	if !ruleName.MatchString(r.Name) {
+		return fmt.Errorf("rules: name %q must be lower_snake_case", r.Name)
+	}
+	switch r.Severity {
+	case models.SeverityError, models.SeverityWarning, models.SeverityInfo:
+	case "":
+		r.Severity = models.SeverityWarning
+	default:
+		return fmt.Errorf("rules: %s: unknown severity %q", r.Name, r.Severity)
+	}
+	if r.Assert == "" {
+		return fmt.Errorf("rules: %s: assert is required", r.Name)
+	}
+	for key := range r.Config {
+		if _, clash := pointFields[key]; clash {
+			return fmt.Errorf("rules: %s: setting %q clashes with a point field", r.Name, key)
+		}
+	}
+
+	known := func(name string) bool {
+		_, isField := pointFields[name]
+		_, isParam := r.Config[name]
+		return isField || isParam
+	}
+
+	var err error
+	if r.When != "" {
+		if r.when, err = compile(r.When, known); err != nil {
+			return fmt.Errorf("rules: %s: when: %w", r.Name, err)
+		}
+	}
+	if r.assert, err = compile(r.Assert, known); err != nil {
+		return fmt.Errorf("rules: %s: assert: %w", r.Name, err)
+	}
+	return nil
+}
+
+// Info - engine.Check
+func (r *Rule) Info() engine.CheckInfo {
+	version := r.Version
+	if version == "" {
+		version = "1"
+	}
+	priority := defaultRulePriority
+	if r.Priority != nil {
+		priority = *r.Priority
+	}
+	desc := r.Description
+	if desc == "" {
+		desc = r.Assert
+	}
+	return engine.CheckInfo{
+		Name:        r.Name,
+		Description: desc,
+		Version:     version,
+		Category:    r.Category,
+		AppliesTo:   r.AppliesTo,
+		Config:      r.Config,
+		DependsOn:   r.DependsOn,
+		Priority:    priority,
+	}
+}
+
+// Run - engine.Check. One issue per point that fails the assertion. If the
+// expression itself fails (comparing a string with a number, say) that is
+// reported once as an error and the rule stops.
+func (r *Rule) Run(ctx context.Context, data *models.SurveyData, cfg engine.Config) []models.ValidationIssue {
+	issues, _ := r.RunStream(ctx, data, cfg)
+	return issues
+}
+
+// errStop - a rule that hit an expression error is done
+var errStop = errors.New("rule stopped")
+
+// RunStream - engine.StreamCheck, rules only ever look at one point
+func (r *Rule) RunStream(ctx context.Context, src models.PointSource, cfg engine.Config) ([]models.ValidationIssue, error) {
+	var issues []models.ValidationIssue
+
+	err := src.Each(func(i int, p *models.SurveyPoint) error {
+		if i%1000 == 0 && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if !r.appliesTo(p) {
+			return nil
+		}
+
+		idx := i
+		lookup := func(name string) (value, bool) {
+			if f, ok := pointFields[name]; ok {
+				return f(p, idx), true
+			}
+			v, ok := cfg[name]
+			return v, ok
+		}
+
+		if r.when != nil {
+			ok, err := r.when.eval(lookup)
+			if err != nil {
+				issues = append(issues, r.failed(p, err))
+				return errStop
+			}
+			if !truthy(ok) {
+				return nil
+			}
+		}
+
+		ok, err := r.assert.eval(lookup)
+		if err != nil {
+			issues = append(issues, r.failed(p, err))
+			return errStop
+		}
+		if truthy(ok) {
+			return nil
+		}
+
+		issues = append(issues, models.ValidationIssue{
+			CheckName:   r.Name,
+			Kind:        "assert",
+			Severity:    r.Severity,
+			PointIDs:    []string{p.PointID},
+			Description: r.message(lookup, p),
+		})
+		return nil
+	})
+	if errors.Is(err, errStop) || errors.Is(err, ctx.Err()) {
+		err = nil
+	}
+	return issues, err
+}
+
+func (r *Rule) appliesTo(p *models.SurveyPoint) bool {
+	if len(r.AppliesTo) == 0 {
+		return true
+	}
+	for _, t := range r.AppliesTo {
+		if p.SurveyType == t {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) failed(p *models.SurveyPoint, err error) models.ValidationIssue {
+	return models.ValidationIssue{
+		CheckName:   r.Name,
+		Kind:        "rule_error",
+		Severity:    models.SeverityError,
+		PointIDs:    []string{p.PointID},
+		Description: fmt.Sprintf("Rule %s could not be evaluated on %s: %v", r.Name, p.PointID, err),
+	}
+}
+
+var placeholder = regexp.MustCompile(`\{([a-z_][a-z0-9_]*)\}`)
+
+// message - the rule's message with {field} placeholders filled in
+func (r *Rule) message(lookup env, p *models.SurveyPoint) string {
+	if r.Message == "" {
+		desc := r.Description
+		if desc == "" {
+			desc = r.Assert
+		}
+		return fmt.Sprintf("Point %s fails %s: %s", p.PointID, r.Name, desc)
+	}
+	return placeholder.ReplaceAllStringFunc(r.Message, func(m string) string {
+		v, ok := lookup(strings.Trim(m, "{}"))
+		if !ok {
+			return m
+		}
+		if f, isNum := v.(float64); isNum {
+			return strconv.FormatFloat(f, 'f', -1, 64)
+		}
+		if v == nil {
+			return "none"
+		}
+		return toString(v)
+	})
+}
@@ -0,0 +1,142 @@
+package rules
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/survey-validator/engine"
+	"github.com/survey-validator/models"
+)
+
+const treeRules = `[
+  {
+    "name": "tree_height",
+    "description": "Trees need a height within range",
+    "severity": "warning",
+    "applies_to": ["detail"],
+    "when": "code == 'TREE'",
+    "assert": "has(height) && height < max_height",
+    "message": "Tree {point_id} height {height} is missing or above {max_height}",
+    "config": {"max_height": {"type": "number", "default": 3000}}
+  }
+]`
+
+func height(h float64) *float64 { return &h }
+
+func treeData() *models.SurveyData {
+	return &models.SurveyData{
+		ProjectID: "RULES-1",
+		Points: []models.SurveyPoint{
+			{PointID: "CP1", Easting: 1000, Northing: 1000, SurveyType: models.SurveyTypeControl, Code: "TREE"},
+			{PointID: "D1", Easting: 1010, Northing: 1010, SurveyType: models.SurveyTypeDetail, Code: "TREE", Height: height(120)},
+			{PointID: "D2", Easting: 1020, Northing: 1020, SurveyType: models.SurveyTypeDetail, Code: "TREE"},
+			{PointID: "D3", Easting: 1030, Northing: 1030, SurveyType: models.SurveyTypeDetail, Code: "FENCE"},
+		},
+	}
+}
+
+func TestRuleRun(t *testing.T) {
+	list, err := Load(strings.NewReader(treeRules))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := engine.NewEngine()
+	if err := Register(e, list); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := e.ValidateContext(context.Background(), treeData(), engine.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []models.ValidationIssue
+	for _, issue := range report.Issues {
+		if issue.CheckName == "tree_height" {
+			got = append(got, issue)
+		}
+	}
+	// CP1 is control, D3 isn't a tree, D1 is in range
+	if len(got) != 1 || got[0].PointIDs[0] != "D2" {
+		t.Fatalf("Expected one issue for D2, got %+v", got)
+	}
+	if got[0].Description != "Tree D2 height none is missing or above 3000" {
+		t.Errorf("Unexpected message: %s", got[0].Description)
+	}
+
+	// a request can tighten the setting
+	report, err = e.ValidateContext(context.Background(), treeData(), engine.Options{
+		Config: map[string]engine.Config{"tree_height": {"max_height": 100.0}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := 0
+	for _, issue := range report.Issues {
+		if issue.CheckName == "tree_height" {
+			count++
+		}
+	}
+	if count != 2 {
+		t.Errorf("Expected D1 and D2 with max_height 100, got %d issues", count)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	bad := []string{
+		`[{"name": "Bad Name", "assert": "true"}]`,
+		`[{"name": "no_assert"}]`,
+		`[{"name": "typo", "assert": "heigth > 1"}]`,
+		`[{"name": "clash", "assert": "true", "config": {"height": {"type": "number"}}}]`,
+		`[{"name": "sev", "assert": "true", "severity": "fatal"}]`,
+		`[{"name": "dup", "assert": "true"}, {"name": "dup", "assert": "true"}]`,
+		`[{"name": "extra", "assert": "true", "unknown_field": 1}]`,
+	}
+	for _, src := range bad {
+		if _, err := Load(strings.NewReader(src)); err == nil {
+			t.Errorf("Load(%s) should fail", src)
+		}
+	}
+}
+
+func TestRegisterRejectsBuiltinNames(t *testing.T) {
+	list, err := Load(strings.NewReader(`[{"name": "outlier_detection", "assert": "true"}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Register(engine.NewEngine(), list); err == nil {
+		t.Error("Expected a rule named like a built-in check to be rejected")
+	}
+}
+
+func TestLoadFile_Example(t *testing.T) {
+	list, err := LoadFile("testdata/example.json")
+	if err != nil {
+		t.Fatalf("example rules don't load: %v", err)
+	}
+
+	e := engine.NewEngine()
+	if err := Register(e, list); err != nil {
+		t.Fatal(err)
+	}
+	report, err := e.ValidateContext(context.Background(), treeData(), engine.Options{
+		Enable: []string{"control_code_prefix", "site_window"},
+		Config: map[string]engine.Config{"site_window": {"max_e": 1015}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string][]string{}
+	for _, issue := range report.Issues {
+		got[issue.CheckName] = append(got[issue.CheckName], issue.PointIDs...)
+	}
+	if ids := got["control_code_prefix"]; len(ids) != 1 || ids[0] != "CP1" {
+		t.Errorf("control_code_prefix flagged %v, expected [CP1]", ids)
+	}
+	if ids := got["site_window"]; len(ids) != 2 || ids[0] != "D2" || ids[1] != "D3" {
+		t.Errorf("site_window flagged %v, expected [D2 D3]", ids)
+	}
+}
@@ -0,0 +1,134 @@
+package selftest
+
+// selftest.go - run the engine over sample datasets whose outcome is
+// known, so a deployment can show its checks still give the right answers
+// before it takes traffic
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/survey-validator/engine"
+	"github.com/survey-validator/models"
+)
+
+// copies of the repo's testdata samples, kept in step by the tests
+//
+//go:embed testdata/*.json
+var samples embed.FS
+
+// Case - a sample and what validating it must give
+type Case struct {
+	Name   string
+	File   string // under testdata/
+	Status models.ValidationStatus
+	Points int
+	// Kinds - the issue kinds the report must have, and no others
+	Kinds []string
+}
+
+// Cases - what Run checks
+var Cases = []Case{
+	{
+		Name:   "clean",
+		File:   "sample_survey.json",
+		Status: models.StatusPass,
+		Points: 9,
+	},
+	{
+		Name:   "errors",
+		File:   "sample_with_errors.json",
+		Status: models.StatusWarning,
+		Points: 8,
+		Kinds:  []string{"distance_ratio", "near_duplicate", "short_leg", "zero_coordinates"},
+	},
+}
+
+// Result - how one case went
+type Result struct {
+	Name       string                  `json:"name"`
+	Passed     bool                    `json:"passed"`
+	Status     models.ValidationStatus `json:"status,omitempty"`
+	Problems   []string                `json:"problems,omitempty"`
+	DurationMS float64                 `json:"duration_ms"`
+}
+
+// Report - every case, passed only if they all did
+type Report struct {
+	Passed  bool      `json:"passed"`
+	RanAt   time.Time `json:"ran_at"`
+	Results []Result  `json:"results"`
+}
+
+// Run - validate every case with e, which should have the built-in checks
+// and nothing that would add issues of its own (rules, say). Give it a
+// fresh engine.NewEngine() to test the code rather than a configuration.
+func Run(ctx context.Context, e *engine.Engine) Report {
+	rep := Report{Passed: true, RanAt: time.Now().UTC()}
+	for _, c := range Cases {
+		res := runCase(ctx, e, c)
+		rep.Passed = rep.Passed && res.Passed
+		rep.Results = append(rep.Results, res)
+	}
+	return rep
+}
+
+func runCase(ctx context.Context, e *engine.Engine, c Case) (res Result) {
+	res.Name = c.Name
+	start := time.Now()
+	defer func() {
+		res.DurationMS = float64(time.Since(start).Microseconds()) / 1000
+	}()
+	fail := func(format string, args ...interface{}) {
+		res.Problems = append(res.Problems, fmt.Sprintf(format, args...))
+	}
+
+	raw, err := samples.ReadFile(path.Join("testdata", c.File))
+	if err != nil {
+		fail("reading %s: %v", c.File, err)
+		return res
+	}
+	var data models.SurveyData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		fail("decoding %s: %v", c.File, err)
+		return res
+	}
+	report, err := e.ValidateContext(ctx, &data, engine.Options{})
+	if err != nil {
+		fail("validating: %v", err)
+		return res
+	}
+
+	res.Status = report.Status
+	if report.Status != c.Status {
+		fail("status %s, expected %s", report.Status, c.Status)
+	}
+	if report.Summary.TotalPoints != c.Points {
+		fail("%d points, expected %d", report.Summary.TotalPoints, c.Points)
+	}
+	if got := kinds(report); strings.Join(got, ",") != strings.Join(c.Kinds, ",") {
+		fail("issue kinds %v, expected %v", got, c.Kinds)
+	}
+	res.Passed = len(res.Problems) == 0
+	return res
+}
+
+// kinds - the report's issue kinds, sorted, each once
+func kinds(report *models.ValidationReport) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, issue := range report.Issues {
+		if !seen[issue.Kind] {
+			seen[issue.Kind] = true
+			out = append(out, issue.Kind)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,59 @@
+package selftest
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/survey-validator/engine"
+	"github.com/survey-validator/models"
+)
+
+func TestRun(t *testing.T) {
+	rep := Run(context.Background(), engine.NewEngine())
+	if !rep.Passed || len(rep.Results) != len(Cases) {
+		t.Fatalf("Expected every case to pass, got %+v", rep)
+	}
+}
+
+func TestRun_WrongAnswer(t *testing.T) {
+	e := engine.NewEngine()
+	err := e.Register(engine.CheckSpec{
+		Name: "always_fails",
+		Check: func(data *models.SurveyData) []models.ValidationIssue {
+			return []models.ValidationIssue{{CheckName: "always_fails", Kind: "bogus", Severity: models.SeverityError, Description: "no"}}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rep := Run(context.Background(), e)
+	if rep.Passed {
+		t.Fatal("Expected the self-test to fail")
+	}
+	clean := rep.Results[0]
+	if clean.Passed || clean.Status != models.StatusFail || !strings.Contains(strings.Join(clean.Problems, "; "), "bogus") {
+		t.Errorf("Expected the clean sample to fail on status and kinds, got %+v", clean)
+	}
+}
+
+// the embedded samples are copies of the repo's, which other tests and the
+// docs use; keep them the same
+func TestSamplesMatchRepo(t *testing.T) {
+	for _, c := range Cases {
+		embedded, err := samples.ReadFile("testdata/" + c.File)
+		if err != nil {
+			t.Fatal(err)
+		}
+		repo, err := os.ReadFile(filepath.Join("..", "testdata", c.File))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(embedded, repo) {
+			t.Errorf("selftest/testdata/%s differs from testdata/%s, copy it over", c.File, c.File)
+		}
+	}
+}
@@ -0,0 +1,319 @@
+package store
+
+// store.go - what was validated and what came of it, kept per project so
+// past reports can be audited and fetched again
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/survey-validator/models"
+)
+
+// ErrNotFound - no such project or report
+var ErrNotFound = errors.New("not found")
+
+// Record - one validation: the dataset as submitted and its report
+type Record struct {
+	ID        string                   `json:"id"`
+	ProjectID string                   `json:"project_id"`
+	CreatedAt time.Time                `json:"created_at"`
+	Data      *models.SurveyData       `json:"data"`
+	Report    *models.ValidationReport `json:"report"`
+}
+
+// ReportInfo - a stored report without the data or issues
+type ReportInfo struct {
+	ID              string                  `json:"id"`
+	CreatedAt       time.Time               `json:"created_at"`
+	Status          models.ValidationStatus `json:"status"`
+	ConfidenceScore float64                 `json:"confidence_score"`
+	Points          int                     `json:"points"`
+	Issues          int                     `json:"issues"`
+}
+
+// Project - a project and its reports, oldest first
+type Project struct {
+	ID      string       `json:"project_id"`
+	Reports []ReportInfo `json:"reports"`
+}
+
+// ProjectSummary - a project in a listing
+type ProjectSummary struct {
+	ID      string      `json:"project_id"`
+	Reports int         `json:"reports"`
+	FirstAt time.Time   `json:"first_at"`
+	Latest  *ReportInfo `json:"latest,omitempty"`
+}
+
+// FileStore - one directory per project:
+//
+//	<dir>/<project>/index.json          []ReportInfo, so listings don't read every report
+//	<dir>/<project>/reports/<id>.json   Record
+//
+// Project IDs are escaped to make directory names, anything outside
+// [A-Za-z0-9_-] (and a leading '.') becomes %XX.
+type FileStore struct {
+	dir   string
+	mu    sync.RWMutex
+	now   func() time.Time
+	write func(path string, v interface{}) error // WriteJSON, but for tests
+}
+
+// NewFileStore - store under dir, created if missing
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir, now: time.Now, write: WriteJSON}, nil
+}
+
+// Check - nil if the store can be written to, see Writable
+func (s *FileStore) Check() error {
+	return Writable(s.dir)
+}
+
+// Save - keep a validation and set report.ReportID to its ID. Reports
+// need a ProjectID to be stored.
+func (s *FileStore) Save(data *models.SurveyData, report *models.ValidationReport) (string, error) {
+	if report.ProjectID == "" {
+		return "", errors.New("report has no project ID")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	created := s.now().UTC()
+	id, err := newID(created)
+	if err != nil {
+		return "", err
+	}
+	report.ReportID = id
+
+	dir := s.projectDir(report.ProjectID)
+	index, err := s.readIndex(dir)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		report.ReportID = ""
+		return "", err
+	}
+
+	rec := Record{ID: id, ProjectID: report.ProjectID, CreatedAt: created, Data: data, Report: report}
+	path := filepath.Join(dir, "reports", id+".json")
+	if err := s.write(path, rec); err != nil {
+		report.ReportID = ""
+		return "", err
+	}
+	index = append(index, info(&rec))
+	if err := s.write(filepath.Join(dir, "index.json"), index); err != nil {
+		// a report the index doesn't list would never be listed or deleted
+		os.Remove(path)
+		report.ReportID = ""
+		return "", err
+	}
+	return id, nil
+}
+
+// Projects - every project with stored reports, by ID
+func (s *FileStore) Projects() ([]ProjectSummary, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	out := []ProjectSummary{}
+	for _, ent := range entries {
+		if !ent.IsDir() {
+			continue
+		}
+		id, ok := UnescapeID(ent.Name())
+		if !ok {
+			continue
+		}
+		index, err := s.readIndex(filepath.Join(s.dir, ent.Name()))
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		latest := index[len(index)-1]
+		out = append(out, ProjectSummary{ID: id, Reports: len(index), FirstAt: index[0].CreatedAt, Latest: &latest})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+// Project - one project's reports
+func (s *FileStore) Project(id string) (*Project, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	index, err := s.readIndex(s.projectDir(id))
+	if err != nil {
+		return nil, err
+	}
+	return &Project{ID: id, Reports: index}, nil
+}
+
+// Report - a stored validation, data and all
+func (s *FileStore) Report(project, id string) (*Record, error) {
+	if !validReportID(id) {
+		return nil, ErrNotFound
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var rec Record
+	err := ReadJSON(filepath.Join(s.projectDir(project), "reports", id+".json"), &rec)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// DeleteReport - remove one report, and the project with its last one
+func (s *FileStore) DeleteReport(project, id string) error {
+	if !validReportID(id) {
+		return ErrNotFound
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dir := s.projectDir(project)
+	index, err := s.readIndex(dir)
+	if err != nil {
+		return err
+	}
+	kept := index[:0]
+	for _, r := range index {
+		if r.ID != id {
+			kept = append(kept, r)
+		}
+	}
+	if len(kept) == len(index) {
+		return ErrNotFound
+	}
+	if len(kept) == 0 {
+		return os.RemoveAll(dir)
+	}
+	if err := os.Remove(filepath.Join(dir, "reports", id+".json")); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return s.write(filepath.Join(dir, "index.json"), kept)
+}
+
+// DeleteProject - remove a project and every report in it
+func (s *FileStore) DeleteProject(project string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dir := s.projectDir(project)
+	if _, err := os.Stat(filepath.Join(dir, "index.json")); errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	}
+	return os.RemoveAll(dir)
+}
+
+func (s *FileStore) projectDir(project string) string {
+	return filepath.Join(s.dir, EscapeID(project))
+}
+
+// readIndex - a project's report list, ErrNotFound if it has none
+func (s *FileStore) readIndex(dir string) ([]ReportInfo, error) {
+	var index []ReportInfo
+	err := ReadJSON(filepath.Join(dir, "index.json"), &index)
+	if errors.Is(err, os.ErrNotExist) || (err == nil && len(index) == 0) {
+		return nil, ErrNotFound
+	}
+	return index, err
+}
+
+func info(rec *Record) ReportInfo {
+	return ReportInfo{
+		ID:              rec.ID,
+		CreatedAt:       rec.CreatedAt,
+		Status:          rec.Report.Status,
+		ConfidenceScore: rec.Report.ConfidenceScore,
+		Points:          rec.Report.Summary.TotalPoints,
+		Issues:          len(rec.Report.Issues),
+	}
+}
+
+// newID - sortable by time, with a random tail for reports saved in the
+// same second
+func newID(t time.Time) (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return t.Format("20060102T150405Z") + "-" + hex.EncodeToString(b), nil
+}
+
+func validReportID(id string) bool {
+	if id == "" {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+func safe(c byte, first bool) bool {
+	return c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' ||
+		c == '-' || c == '_' || (c == '.' && !first)
+}
+
+// EscapeID - an ID as a directory or file name. The monitoring store uses
+// it too, so both take the same project IDs.
+func EscapeID(id string) string {
+	var b strings.Builder
+	for i := 0; i < len(id); i++ {
+		if safe(id[i], i == 0) {
+			b.WriteByte(id[i])
+		} else {
+			fmt.Fprintf(&b, "%%%02X", id[i])
+		}
+	}
+	return b.String()
+}
+
+// UnescapeID - the ID back from a directory name, false for
+// anything escape wouldn't have made
+func UnescapeID(name string) (string, bool) {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] != '%' {
+			if !safe(name[i], i == 0) {
+				return "", false
+			}
+			b.WriteByte(name[i])
+			continue
+		}
+		if i+2 >= len(name) {
+			return "", false
+		}
+		v, err := hex.DecodeString(name[i+1 : i+3])
+		if err != nil {
+			return "", false
+		}
+		b.WriteByte(v[0])
+		i += 2
+	}
+	return b.String(), name != ""
+}
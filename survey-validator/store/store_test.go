@@ -0,0 +1,167 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/survey-validator/models"
+)
+
+func save(t *testing.T, s *FileStore, project string, score float64) string {
+	t.Helper()
+	data := &models.SurveyData{ProjectID: project, Points: []models.SurveyPoint{{PointID: "CP1", Easting: 1, Northing: 2}}}
+	report := models.NewValidationReport(project)
+	report.ConfidenceScore = score
+	report.Summary.TotalPoints = 1
+	id, err := s.Save(data, report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.ReportID != id {
+		t.Errorf("Expected the report to carry its ID %s, got %q", id, report.ReportID)
+	}
+	return id
+}
+
+func TestFileStore(t *testing.T) {
+	s, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { clock = clock.Add(time.Hour); return clock }
+
+	first := save(t, s, "SITE-A", 90)
+	second := save(t, s, "SITE-A", 75)
+	save(t, s, "SITE B/2", 100)
+
+	projects, err := s.Projects()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(projects) != 2 || projects[0].ID != "SITE B/2" || projects[1].ID != "SITE-A" {
+		t.Fatalf("Expected both projects by ID, got %+v", projects)
+	}
+	if p := projects[1]; p.Reports != 2 || p.Latest.ID != second || p.Latest.ConfidenceScore != 75 {
+		t.Errorf("Expected SITE-A's latest to be the second report, got %+v", p)
+	}
+
+	rec, err := s.Report("SITE-A", first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Data.Points[0].PointID != "CP1" || rec.Report.ConfidenceScore != 90 || rec.Report.ReportID != first {
+		t.Errorf("Record didn't round-trip: %+v", rec)
+	}
+	if _, err := s.Report("SITE-A", "../SITE B%2F2/index"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a path-like report ID, got %v", err)
+	}
+
+	if err := s.DeleteReport("SITE-A", first); err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := s.Project("SITE-A"); len(p.Reports) != 1 || p.Reports[0].ID != second {
+		t.Errorf("Expected only the second report left, got %+v", p)
+	}
+	if err := s.DeleteReport("SITE-A", first); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound deleting twice, got %v", err)
+	}
+
+	// the last report takes the project with it
+	if err := s.DeleteReport("SITE-A", second); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Project("SITE-A"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected SITE-A gone, got %v", err)
+	}
+
+	if err := s.DeleteProject("SITE B/2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteProject("SITE B/2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound deleting twice, got %v", err)
+	}
+	if _, err := s.Save(&models.SurveyData{}, models.NewValidationReport("")); err == nil {
+		t.Error("Expected a report without a project ID to be refused")
+	}
+}
+
+func TestFileStore_IndexWriteFails(t *testing.T) {
+	s, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := save(t, s, "SITE-A", 90)
+
+	s.write = func(path string, v interface{}) error {
+		if filepath.Base(path) == "index.json" {
+			return errors.New("disk full")
+		}
+		return WriteJSON(path, v)
+	}
+	data := &models.SurveyData{ProjectID: "SITE-A", Points: []models.SurveyPoint{{PointID: "CP1", Easting: 1, Northing: 2}}}
+	report := models.NewValidationReport("SITE-A")
+	if _, err := s.Save(data, report); err == nil {
+		t.Fatal("Expected the failed index write to fail the save")
+	}
+	if report.ReportID != "" {
+		t.Errorf("Expected no report ID on a failed save, got %q", report.ReportID)
+	}
+	entries, err := os.ReadDir(filepath.Join(s.dir, "SITE-A", "reports"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != first+".json" {
+		t.Errorf("Expected only the first report on disk, got %d files", len(entries))
+	}
+}
+
+func TestEscape(t *testing.T) {
+	for _, id := range []string{"SITE-A", "..", ".hidden", "a/b\\c", "100%", "Ünïcode job", "x.y_z"} {
+		name := EscapeID(id)
+		if filepath.Base(name) != name || name == "." || name == ".." || name[0] == '.' {
+			t.Errorf("EscapeID(%q) = %q isn't a safe directory name", id, name)
+		}
+		if back, ok := UnescapeID(name); !ok || back != id {
+			t.Errorf("UnescapeID(%q) = %q, %v; expected %q", name, back, ok, id)
+		}
+	}
+	if _, ok := UnescapeID("bad%2"); ok {
+		t.Error("Expected a truncated escape to be rejected")
+	}
+}
+
+func TestWriteJSON_NoPartialFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "sub", "doc.json")
+	if err := WriteJSON(path, map[string]int{"a": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteJSON(path, func() {}); err == nil {
+		t.Fatal("Expected an unencodable value to fail")
+	}
+	var got map[string]int
+	if err := ReadJSON(path, &got); err != nil || got["a"] != 1 {
+		t.Errorf("A failed write shouldn't touch the old file, got %v (%v)", got, err)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("Expected no temp files left behind, got %d entries", len(entries))
+	}
+}
+
+func TestWritable(t *testing.T) {
+	dir := t.TempDir()
+	if err := Writable(dir); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("Expected the probe to be removed, got %v", entries)
+	}
+	if err := Writable(filepath.Join(dir, "missing")); err == nil {
+		t.Error("Expected a missing directory to fail")
+	}
+}
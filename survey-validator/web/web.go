@@ -0,0 +1,110 @@
+package web
+
+// web.go - the browser app, embedded in the binary so the server serves
+// the same files wherever it runs from, locally or on Vercel. Every file
+// gets an ETag from its content, so a browser revalidates for the price
+// of a 304.
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+//go:embed static
+var embedded embed.FS
+
+// FS - the embedded app, index.html at its root
+func FS() fs.FS {
+	sub, err := fs.Sub(embedded, "static")
+	if err != nil {
+		panic("web: " + err.Error())
+	}
+	return sub
+}
+
+// Handler - serve the embedded app. Its files can't change, so their
+// ETags are worked out once.
+func Handler() http.Handler {
+	return &files{fsys: FS(), cache: make(map[string]*file)}
+}
+
+// DirHandler - serve the app from a directory instead, for working on it
+// without a rebuild. Files are read and hashed on every request.
+func DirHandler(dir string) http.Handler {
+	return &files{fsys: os.DirFS(dir)}
+}
+
+// Cache-Control for HTML, which names the other files and so has to be
+// checked each time, and for everything else
+const (
+	htmlCache  = "no-cache"
+	assetCache = "public, max-age=3600"
+)
+
+type files struct {
+	fsys  fs.FS
+	mu    sync.Mutex
+	cache map[string]*file // nil to read every time
+}
+
+type file struct {
+	data []byte
+	etag string
+}
+
+func (h *files) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if name == "" || strings.HasSuffix(r.URL.Path, "/") {
+		name = path.Join(name, "index.html")
+	}
+	f, err := h.open(name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("ETag", f.etag)
+	if strings.HasSuffix(name, ".html") {
+		w.Header().Set("Cache-Control", htmlCache)
+	} else {
+		w.Header().Set("Cache-Control", assetCache)
+	}
+	// ServeContent answers If-None-Match from the ETag, and sets the type
+	// from the extension
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(f.data))
+}
+
+// open - the file and its ETag, from the cache when there is one
+func (h *files) open(name string) (*file, error) {
+	if h.cache != nil {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if f, ok := h.cache[name]; ok {
+			return f, nil
+		}
+	}
+	data, err := fs.ReadFile(h.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	f := &file{data: data, etag: `"` + hex.EncodeToString(sum[:8]) + `"`}
+	if h.cache != nil {
+		h.cache[name] = f
+	}
+	return f, nil
+}
@@ -0,0 +1,71 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func get(t *testing.T, h http.Handler, method, path, etag string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, nil)
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestHandler(t *testing.T) {
+	h := Handler()
+	w := get(t, h, http.MethodGet, "/", "")
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") || !strings.Contains(w.Body.String(), "<html") {
+		t.Fatalf("Expected the app at /, got %d %s", w.Code, w.Header())
+	}
+	if etag == "" || w.Header().Get("Cache-Control") != htmlCache {
+		t.Errorf("Expected an ETag and %q, got %s", htmlCache, w.Header())
+	}
+	if w := get(t, h, http.MethodGet, "/index.html", ""); w.Header().Get("ETag") != etag {
+		t.Errorf("Expected /index.html to be the same file, got ETag %q", w.Header().Get("ETag"))
+	}
+
+	if w := get(t, h, http.MethodGet, "/", etag); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("Expected a 304 for a matching ETag, got %d with %d bytes", w.Code, w.Body.Len())
+	}
+	if w := get(t, h, http.MethodGet, "/", `"stale"`); w.Code != http.StatusOK {
+		t.Errorf("Expected a 200 for a stale ETag, got %d", w.Code)
+	}
+	if w := get(t, h, http.MethodHead, "/", ""); w.Code != http.StatusOK || w.Body.Len() != 0 {
+		t.Errorf("Expected HEAD to answer without a body, got %d", w.Code)
+	}
+	if w := get(t, h, http.MethodGet, "/../web.go", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected files outside the app to be missing, got %d", w.Code)
+	}
+	if w := get(t, h, http.MethodPost, "/", ""); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected POST to be refused, got %d", w.Code)
+	}
+}
+
+func TestDirHandler(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "index.html"), []byte("<html>one</html>"), 0o644)
+	os.WriteFile(filepath.Join(dir, "app.js"), []byte("let x = 1"), 0o644)
+	h := DirHandler(dir)
+
+	first := get(t, h, http.MethodGet, "/", "").Header().Get("ETag")
+	os.WriteFile(filepath.Join(dir, "index.html"), []byte("<html>two</html>"), 0o644)
+	w := get(t, h, http.MethodGet, "/", first)
+	if w.Code != http.StatusOK || w.Body.String() != "<html>two</html>" || w.Header().Get("ETag") == first {
+		t.Errorf("Expected the edited file with a new ETag, got %d %q %s", w.Code, w.Body.String(), w.Header())
+	}
+
+	w = get(t, h, http.MethodGet, "/app.js", "")
+	if w.Header().Get("Cache-Control") != assetCache || !strings.Contains(w.Header().Get("Content-Type"), "javascript") {
+		t.Errorf("Unexpected asset headers %s", w.Header())
+	}
+}
@@ -0,0 +1,97 @@
+package wire
+
+// checks.go - what the engine says about its checks and background jobs
+
+import (
+	"time"
+
+	"github.com/survey-validator/models"
+)
+
+// CheckInfo - what a check is and when it runs
+type CheckInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Version     string `json:"version"`
+
+	// Category - integrity, geometry, closure or custom (the default),
+	// weights the check's issues in the confidence score
+	Category string `json:"category,omitempty"`
+
+	// AppliesTo - the check only runs when the data has points of one of
+	// these types (a detail-only job skips traverse checks). Empty means any.
+	AppliesTo []models.SurveyType `json:"applies_to,omitempty"`
+
+	// Config - the settings a request may pass to this check
+	Config map[string]ParamSpec `json:"config,omitempty"`
+
+	DependsOn []string `json:"depends_on,omitempty"`
+	Priority  int      `json:"priority"`
+}
+
+// ParamSpec - one config setting
+type ParamSpec struct {
+	Type        ParamType   `json:"type"`
+	Description string      `json:"description,omitempty"`
+	Default     interface{} `json:"default,omitempty"`
+}
+
+// ParamType - JSON type of a config setting
+type ParamType string
+
+const (
+	ParamNumber ParamType = "number"
+	ParamString ParamType = "string"
+	ParamBool   ParamType = "bool"
+)
+
+// CheckConfig - settings for one check, keyed by name
+type CheckConfig map[string]interface{}
+
+// JobStatus - where a job is
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobDone      JobStatus = "done"
+	JobFailed    JobStatus = "failed"
+	JobCancelled JobStatus = "cancelled"
+)
+
+// Finished - done, failed or cancelled
+func (s JobStatus) Finished() bool {
+	return s == JobDone || s == JobFailed || s == JobCancelled
+}
+
+// Job - a snapshot of a background validation. The report is only set
+// once it is done, and isn't part of the JSON; fetch it separately.
+type Job struct {
+	ID          string     `json:"id"`
+	ProjectID   string     `json:"project_id,omitempty"`
+	Points      int        `json:"points"`
+	Status      JobStatus  `json:"status"`
+	Progress    Progress   `json:"progress"`
+	SubmittedAt time.Time  `json:"submitted_at"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	Error       string     `json:"error,omitempty"`
+
+	Report *models.ValidationReport `json:"-"`
+}
+
+// Progress - how far a validation has got
+type Progress struct {
+	Done  int    `json:"done"`
+	Total int    `json:"total"`           // checks that will run
+	Check string `json:"check,omitempty"` // the one that just finished
+}
+
+// PoolStats - how busy a pool is. Running == Workers means new jobs wait,
+// and Queued == QueueSize means they are refused.
+type PoolStats struct {
+	Workers   int `json:"workers"`
+	Running   int `json:"running"`
+	Queued    int `json:"queued"`
+	QueueSize int `json:"queue_size"`
+}
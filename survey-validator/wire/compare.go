@@ -0,0 +1,159 @@
+package wire
+
+// compare.go - dataset comparisons and deformation monitoring
+
+import "time"
+
+// CompareOptions - tolerances for a comparison, in metres
+type CompareOptions struct {
+	Threshold       float64 `json:"threshold,omitempty"`
+	RenameTolerance float64 `json:"rename_tolerance,omitempty"`
+}
+
+// CompareResult - the difference between two datasets
+type CompareResult struct {
+	Before    string  `json:"before"` // project IDs
+	After     string  `json:"after"`
+	Threshold float64 `json:"threshold"`
+
+	Matched int      `json:"matched"` // points in both, renamed ones included
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+	Renamed []Rename `json:"renamed"`
+	Moved   []string `json:"moved"` // displaced more than Threshold
+
+	Displacements []Displacement `json:"displacements"`
+	Summary       CompareSummary `json:"summary"`
+
+	// Transform - the best-fit shift, rotation and scale from before to
+	// after, nil with fewer than 3 matched points
+	Transform *Transform `json:"transform,omitempty"`
+}
+
+// Rename - an added and a removed point sitting on the same spot
+type Rename struct {
+	From     string  `json:"from"`
+	To       string  `json:"to"`
+	Distance float64 `json:"distance"`
+}
+
+// Displacement - how one point moved, after minus before
+type Displacement struct {
+	PointID    string   `json:"point_id"`
+	PreviousID string   `json:"previous_id,omitempty"` // when renamed
+	DE         float64  `json:"de"`
+	DN         float64  `json:"dn"`
+	DH         *float64 `json:"dh,omitempty"` // when both have a height
+	Horizontal float64  `json:"horizontal"`
+	Bearing    float64  `json:"bearing"` // direction of movement, degrees
+	Moved      bool     `json:"moved"`
+
+	// Residual - what's left after taking out the systematic transform,
+	// i.e. how much the point moved relative to the rest
+	Residual float64 `json:"residual"`
+}
+
+// CompareSummary - displacement stats over the matched points
+type CompareSummary struct {
+	MaxHorizontal  float64 `json:"max_horizontal"`
+	MaxPointID     string  `json:"max_point_id,omitempty"`
+	MeanHorizontal float64 `json:"mean_horizontal"`
+	RMSHorizontal  float64 `json:"rms_horizontal"`
+	MaxVertical    float64 `json:"max_vertical,omitempty"`
+}
+
+// Transform - 2D similarity (Helmert) fit of after on before. Rotation is
+// in arc-seconds, positive clockwise like a bearing swing. Scale is in ppm.
+type Transform struct {
+	ShiftE      float64  `json:"shift_e"`
+	ShiftN      float64  `json:"shift_n"`
+	Shift       float64  `json:"shift"`
+	ShiftDir    float64  `json:"shift_bearing"` // degrees
+	Rotation    float64  `json:"rotation_seconds"`
+	Scale       float64  `json:"scale_ppm"`
+	RMS         float64  `json:"rms"`
+	PointsUsed  int      `json:"points_used"`
+	Excluded    []string `json:"excluded,omitempty"` // left out of the fit as moved
+	Significant bool     `json:"significant"`        // moves some point more than Threshold
+	Description string   `json:"description"`
+}
+
+// MonitorConfig - a project's baseline, precisions and alert limits.
+// Limits are in metres and metres per day; zero turns a limit off.
+type MonitorConfig struct {
+	Baseline   string  `json:"baseline,omitempty"` // epoch ID, the earliest epoch if empty
+	Confidence float64 `json:"confidence"`         // for the significance tests, 0.95 or 0.99 say
+
+	// used for points that don't carry their own sigma_e/n/h
+	SigmaEN     float64 `json:"default_sigma_en"` // per coordinate
+	SigmaHeight float64 `json:"default_sigma_h"`
+
+	MaxDisplacement     float64 `json:"max_displacement"` // horizontal, from the baseline
+	MaxVertical         float64 `json:"max_vertical"`
+	MaxVelocity         float64 `json:"max_velocity"` // horizontal, over the latest interval
+	MaxVerticalVelocity float64 `json:"max_vertical_velocity"`
+}
+
+// MonitorReport - the state of a monitoring project
+type MonitorReport struct {
+	ProjectID string        `json:"project_id"`
+	Baseline  string        `json:"baseline"`
+	Latest    string        `json:"latest"`
+	Config    MonitorConfig `json:"config"`
+	Epochs    []EpochInfo   `json:"epochs"`
+	Points    []Series      `json:"points"`
+	Alerts    []Alert       `json:"alerts"`
+
+	// NotInBaseline - targets seen later that the baseline doesn't have,
+	// so they have no series yet
+	NotInBaseline []string `json:"not_in_baseline,omitempty"`
+
+	// Systematic - best-fit transform from the baseline to the latest
+	// epoch. If it is significant the whole network (or its control) moved.
+	Systematic *Transform `json:"systematic,omitempty"`
+}
+
+// EpochInfo - an epoch without its points
+type EpochInfo struct {
+	ID         string    `json:"id"`
+	ObservedAt time.Time `json:"observed_at"`
+	Points     int       `json:"points"`
+}
+
+// Series - one target through time, displacements from the baseline
+type Series struct {
+	PointID      string               `json:"point_id"`
+	Observations []MonitorObservation `json:"observations"`
+
+	// latest interval, metres per day
+	Velocity         float64 `json:"velocity"`
+	VerticalVelocity float64 `json:"vertical_velocity,omitempty"`
+
+	Status string `json:"status"` // stable, moving (significant) or alert
+}
+
+// MonitorObservation - a target in one epoch, relative to the baseline
+type MonitorObservation struct {
+	Epoch      string    `json:"epoch"`
+	ObservedAt time.Time `json:"observed_at"`
+	DE         float64   `json:"de"`
+	DN         float64   `json:"dn"`
+	DH         *float64  `json:"dh,omitempty"`
+	Horizontal float64   `json:"horizontal"`
+
+	// test statistics against their critical values at the configured
+	// confidence: chi-squared (2 dof) horizontally, |z| vertically
+	TestH       float64  `json:"test_h"`
+	TestV       *float64 `json:"test_v,omitempty"`
+	Significant bool     `json:"significant"`
+}
+
+// Alert - a significant movement past a limit
+type Alert struct {
+	PointID string  `json:"point_id"`
+	Epoch   string  `json:"epoch"`
+	Kind    string  `json:"kind"` // displacement, vertical, velocity, vertical_velocity
+	Value   float64 `json:"value"`
+	Limit   float64 `json:"limit"`
+	Message string  `json:"message"`
+}
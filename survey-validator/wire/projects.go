@@ -0,0 +1,57 @@
+package wire
+
+// projects.go - stored reports and API keys
+
+import (
+	"time"
+
+	"github.com/survey-validator/models"
+)
+
+// Record - one validation: the dataset as submitted and its report
+type Record struct {
+	ID        string                   `json:"id"`
+	ProjectID string                   `json:"project_id"`
+	CreatedAt time.Time                `json:"created_at"`
+	Data      *models.SurveyData       `json:"data"`
+	Report    *models.ValidationReport `json:"report"`
+}
+
+// ReportInfo - a stored report without the data or issues
+type ReportInfo struct {
+	ID              string                  `json:"id"`
+	CreatedAt       time.Time               `json:"created_at"`
+	Status          models.ValidationStatus `json:"status"`
+	ConfidenceScore float64                 `json:"confidence_score"`
+	Points          int                     `json:"points"`
+	Issues          int                     `json:"issues"`
+}
+
+// Project - a project and its reports, oldest first
+type Project struct {
+	ID      string       `json:"project_id"`
+	Reports []ReportInfo `json:"reports"`
+}
+
+// ProjectSummary - a project in a listing
+type ProjectSummary struct {
+	ID      string      `json:"project_id"`
+	Reports int         `json:"reports"`
+	FirstAt time.Time   `json:"first_at"`
+	Latest  *ReportInfo `json:"latest,omitempty"`
+}
+
+// Key - an API key. ID is public and is what logs and the audit trail
+// show; the secret isn't part of it.
+type Key struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Admin     bool      `json:"admin,omitempty"` // may manage keys
+	CreatedAt time.Time `json:"created_at"`
+
+	// RateLimit - requests per minute, 0 for no limit. Short bursts of up
+	// to a minute's worth are allowed.
+	RateLimit int `json:"rate_limit,omitempty"`
+	// DailyQuota - requests per UTC day, 0 for no quota
+	DailyQuota int `json:"daily_quota,omitempty"`
+}
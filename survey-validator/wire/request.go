@@ -0,0 +1,86 @@
+package wire
+
+// request.go - request bodies
+
+import (
+	"time"
+
+	"github.com/survey-validator/models"
+)
+
+// ValidateBody is the body for /api/v1/validate: survey data plus an
+// optional choice of checks and the issues already accepted for this job.
+// Store keeps the dataset and report under the project ID, see
+// /api/v1/projects.
+type ValidateBody struct {
+	models.SurveyData
+	Checks       *CheckSelection      `json:"checks,omitempty"`
+	Suppressions []models.Suppression `json:"suppressions,omitempty"`
+	Store        bool                 `json:"store,omitempty"`
+}
+
+// CheckSelection picks which checks run and passes them settings, see
+// GET /api/v1/checks for the names and what each one accepts
+type CheckSelection struct {
+	Enable  []string               `json:"enable,omitempty"` // only these, default all
+	Disable []string               `json:"disable,omitempty"`
+	Config  map[string]CheckConfig `json:"config,omitempty"`
+
+	// Severity - report a check's issues at this level instead
+	Severity map[string]models.IssueSeverity `json:"severity,omitempty"`
+}
+
+// ExportRequest is the body for /api/v1/export: survey data plus the
+// optional traverse settings and level run to include in the export, and
+// the same check selection, suppressions and store as a validate request
+type ExportRequest struct {
+	models.SurveyData
+	Traverse     *models.TraverseInput         `json:"traverse,omitempty"`
+	Control      *models.ControlExtensionInput `json:"control,omitempty"`
+	Checks       *CheckSelection               `json:"checks,omitempty"`
+	Suppressions []models.Suppression          `json:"suppressions,omitempty"`
+	Store        bool                          `json:"store,omitempty"`
+}
+
+// CertificateRequest is the body for /api/v1/certificate: an export request
+// plus who the certificate is for and who signs it
+type CertificateRequest struct {
+	ExportRequest
+	Certificate CertificateMetadata `json:"certificate"`
+}
+
+// CompareRequest is the body for /api/v1/compare: two versions of a dataset
+// and the tolerances to compare them with
+type CompareRequest struct {
+	Before models.SurveyData `json:"before"`
+	After  models.SurveyData `json:"after"`
+	CompareOptions
+}
+
+// KeyRequest is the body for POST /api/v1/keys
+type KeyRequest struct {
+	Name       string `json:"name"`
+	Admin      bool   `json:"admin,omitempty"`
+	RateLimit  int    `json:"rate_limit,omitempty"`  // requests per minute, 0 for no limit
+	DailyQuota int    `json:"daily_quota,omitempty"` // requests per UTC day, 0 for none
+}
+
+// EpochRequest is the body for POST /api/v1/monitor/{project}/epochs: one
+// survey of the monitoring targets. ID defaults to the observation time.
+type EpochRequest struct {
+	ID         string               `json:"id,omitempty"`
+	ObservedAt time.Time            `json:"observed_at"`
+	Notes      string               `json:"notes,omitempty"`
+	Points     []models.SurveyPoint `json:"points"`
+}
+
+// CertificateMetadata - who the certificate is for and who signs it
+type CertificateMetadata struct {
+	ProjectName     string `json:"project_name,omitempty"`
+	Client          string `json:"client,omitempty"`
+	Company         string `json:"company,omitempty"`
+	SurveyorName    string `json:"surveyor_name"`
+	SurveyorLicence string `json:"surveyor_licence,omitempty"`
+	SurveyDate      string `json:"survey_date,omitempty"` // as the surveyor wrote it
+	Notes           string `json:"notes,omitempty"`
+}
@@ -0,0 +1,105 @@
+package wire
+
+// response.go - response bodies
+
+import (
+	"time"
+
+	"github.com/survey-validator/models"
+)
+
+// HealthResponse is the answer to GET /health and /health/live
+type HealthResponse struct {
+	Status        string  `json:"status"`
+	Service       string  `json:"service"`
+	Version       string  `json:"version,omitempty"`
+	UptimeSeconds float64 `json:"uptime_seconds"`
+}
+
+// ReadinessResponse is the answer to GET /health/ready, 503 with the
+// reasons in Problems when Ready is false
+type ReadinessResponse struct {
+	HealthResponse
+	Ready    bool     `json:"ready"`
+	Problems []string `json:"problems,omitempty"`
+
+	// Checks - what the engine runs, rules included
+	Checks  []string                 `json:"checks"`
+	Storage map[string]StorageHealth `json:"storage,omitempty"` // none without a data directory
+	Jobs    *JobsHealth              `json:"jobs,omitempty"`    // none before Start
+	// SelfTest - the self-test from when the server started
+	SelfTest *SelfTestReport `json:"self_test"`
+}
+
+// StorageHealth - whether a store can be written to
+type StorageHealth struct {
+	Available bool   `json:"available"`
+	Error     string `json:"error,omitempty"`
+}
+
+// JobsHealth - the background job pool, Saturation being running/workers
+type JobsHealth struct {
+	PoolStats
+	Saturation float64 `json:"saturation"`
+}
+
+// SelfTestResult - how one self-test case went
+type SelfTestResult struct {
+	Name       string                  `json:"name"`
+	Passed     bool                    `json:"passed"`
+	Status     models.ValidationStatus `json:"status,omitempty"`
+	Problems   []string                `json:"problems,omitempty"`
+	DurationMS float64                 `json:"duration_ms"`
+}
+
+// SelfTestReport - every self-test case, passed only if they all did
+type SelfTestReport struct {
+	Passed  bool             `json:"passed"`
+	RanAt   time.Time        `json:"ran_at"`
+	Results []SelfTestResult `json:"results"`
+}
+
+// ChecksResponse lists what the engine will run, see GET /api/v1/checks
+type ChecksResponse struct {
+	Checks []CheckInfo `json:"checks"`
+}
+
+// ImportResponse is the reduced raw job, plus the report if validation was
+// asked for
+type ImportResponse struct {
+	*ImportResult
+	Report *models.ValidationReport `json:"report,omitempty"`
+}
+
+// ImportFormat - a supported raw data format
+type ImportFormat string
+
+// ImportResult - reduced raw job, ready for the validator
+type ImportResult struct {
+	Format       ImportFormat                 `json:"format"`
+	Data         *models.SurveyData           `json:"data"`
+	Observations []models.TraverseObservation `json:"observations"`
+	Warnings     []string                     `json:"warnings,omitempty"`
+}
+
+// ProjectsResponse lists the projects with stored reports
+type ProjectsResponse struct {
+	Projects []ProjectSummary `json:"projects"`
+}
+
+// KeysResponse lists the API keys, without their secrets
+type KeysResponse struct {
+	Keys []Key `json:"keys"`
+}
+
+// CreatedKeyResponse is a new key with its secret, which is shown this
+// once and can't be fetched again
+type CreatedKeyResponse struct {
+	Key
+	Secret string `json:"secret"`
+}
+
+// MonitorProjectsResponse lists the projects with monitoring epochs
+type MonitorProjectsResponse struct {
+	Projects []string `json:"projects"`
+}
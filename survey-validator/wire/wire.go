@@ -0,0 +1,65 @@
+package wire
+
+// wire.go - the JSON the HTTP API sends and receives, with nothing but
+// models underneath so a Go service talking to the server (see client)
+// gets the same structs without building the server. The server's own
+// packages don't import it; api copies their types to and from these.
+// This file has the error bodies, their codes and the request ID header.
+
+// RequestIDHeader - set on every response, and taken from the request when
+// the client sends a sensible one
+const RequestIDHeader = "X-Request-ID"
+
+// ErrorResponse.Code, one per kind of failure
+const (
+	CodeBadRequest       = "bad_request"
+	CodeMalformedJSON    = "malformed_json"  // not JSON at all, see the details
+	CodeInvalidRequest   = "invalid_request" // JSON that doesn't fit the schema
+	CodeInvalidUpload    = "invalid_upload"  // a CSV or field book that couldn't be read
+	CodeInvalidOptions   = "invalid_options" // unknown checks or bad check settings
+	CodeUnauthorized     = "unauthorized"    // no key, or one that isn't known
+	CodeForbidden        = "forbidden"
+	CodeNotFound         = "not_found"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeNotAcceptable    = "not_acceptable"
+	CodeConflict         = "conflict"
+	CodePayloadTooLarge  = "payload_too_large"
+	CodeRateLimited      = "rate_limited"   // too many requests a minute for the key
+	CodeQuotaExceeded    = "quota_exceeded" // the key's requests for the day are used up
+	CodeUnprocessable    = "unprocessable"
+	CodeInternal         = "internal_error"
+	CodeUnavailable      = "unavailable"
+)
+
+// ErrorDetail.Code, past the schema's own (openapi.CodeRequired and co)
+const (
+	CodeSyntax    = "syntax_error" // where the JSON or CSV stopped parsing
+	CodeNotFinite = "not_finite"   // NaN, Infinity or a number too big for a float64
+	CodeBadNumber = "bad_number"   // a CSV cell that isn't a number
+)
+
+// ErrorResponse is the body of every error: the message, a code to branch
+// on, for bad input what is wrong where, and the request ID the middleware
+// put on the response so it can be found in the logs
+type ErrorResponse struct {
+	Error     string        `json:"error"`
+	Code      string        `json:"code"`
+	Details   []ErrorDetail `json:"details,omitempty"`
+	RequestID string        `json:"request_id,omitempty"`
+}
+
+// ErrorDetail - one thing wrong with the input. Pointer is a JSON pointer
+// (RFC 6901) into the request body; Row is the point's position in the
+// upload, from 1; Line and Column are where in the text it is, from 1;
+// Field names the CSV column. Only what is known is filled in.
+type ErrorDetail struct {
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Pointer string      `json:"pointer,omitempty"`
+	Row     int         `json:"row,omitempty"`
+	Line    int         `json:"line,omitempty"`
+	Column  int         `json:"column,omitempty"`
+	Offset  int64       `json:"offset,omitempty"`
+	Field   string      `json:"field,omitempty"`
+	Value   interface{} `json:"value,omitempty"`
+}